@@ -21,15 +21,19 @@ import (
 	"github.com/monimesl/operator-helper/k8s"
 	"github.com/monimesl/operator-helper/k8s/pod"
 	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	v2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
-	"math"
-	"strconv"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"strings"
+	"time"
 )
 
 const (
 	imageRepository                   = "apachepulsar/pulsar"
+	allImageRepository                = "apachepulsar/pulsar-all"
 	BrokerSetupImageRepository        = "monime/pulsar-broker-setup"
 	DefaultBrokerSetupImageVersion    = "latest"
 	DefaultBrokerSetupImagePullPolicy = "Always"
@@ -60,6 +64,32 @@ const (
 	defaultKopSSLPort    = 9093
 )
 
+// ClusterNameLabel labels the cluster objects with the name the cluster is registered with in pulsar
+const ClusterNameLabel = internal.Domain + "/cluster-name"
+
+const (
+	// MaintenanceAnnotation names the broker pod of the cluster to take out of rotation for maintenance
+	MaintenanceAnnotation = internal.Domain + "/maintenance"
+	// InRotationLabel labels the broker pods selected by the client service; it's
+	// "false" on the broker taken out of rotation by the MaintenanceAnnotation
+	InRotationLabel = internal.Domain + "/in-rotation"
+)
+
+const (
+	// InternalListenerName is the name of the listener the brokers advertise their in-cluster address on
+	InternalListenerName        = "internal"
+	defaultExternalListenerName = "external"
+)
+
+const (
+	// AuthenticationProviderToken is the JWT token authentication provider
+	AuthenticationProviderToken  = "token"
+	defaultTokenSigningAlgorithm = "RS256"
+	defaultTokenBrokerRole       = "broker"
+	defaultTokenOperatorRole     = "pulsar-operator"
+	defaultTokenProxyRole        = "proxy"
+)
+
 var (
 	defaultTerminationGracePeriod int64 = 30
 	defaultClusterSize                  = int32(minimumClusterSize)
@@ -74,17 +104,35 @@ type PulsarClusterSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// ClusterName defines the name the cluster is registered with in pulsar. It defaults
+	// to the object name and cannot be changed once the cluster metadata is initialized
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+	// MetadataStore configures the metadata and configuration stores of the cluster
+	// +optional
+	MetadataStore *MetadataStoreConfig `json:"metadataStore,omitempty"`
 	// ZookeeperServers specifies the hostname/IP address and port in the format "hostname:port".
-	// +kubebuilder:validation:Required
-	ZookeeperServers string `json:"zookeeperServers"`
+	// Deprecated: use metadataStore; the webhook migrates it to a zookeeper metadataStore
+	// +optional
+	ZookeeperServers string `json:"zookeeperServers,omitempty"`
+	// ZookeeperRef references a ZookeeperCluster whose client service is used as the zookeeper
+	// metadata store. It replaces metadataStore and zookeeperServers
+	// +optional
+	ZookeeperRef *ZookeeperReference `json:"zookeeperRef,omitempty"`
 	// BookkeeperClusterUri specifies the URI of the existing BookKeeper cluster that you want to use.
 	// see https://pulsar.apache.org/docs/en/reference-cli-tools/#initialize-cluster-metadata
-	// +kubebuilder:validation:Required
-	BookkeeperClusterUri string `json:"bookkeeperClusterUri"`
+	// Either it or bookkeeperRef is required
+	// +optional
+	BookkeeperClusterUri string `json:"bookkeeperClusterUri,omitempty"`
+	// BookkeeperRef references a BookkeeperCluster whose metadata service URI is used. It replaces bookkeeperClusterUri
+	// +optional
+	BookkeeperRef *BookkeeperReference `json:"bookkeeperRef,omitempty"`
 	// ConfigurationStoreServers specifies the configuration store connection string (as a comma-separated list)
+	// Deprecated: use metadataStore.configurationURL
 	// +optional
-	ConfigurationStoreServers string `json:"configurationStoreServers"`
-	// PulsarVersion defines the version of broker to use
+	ConfigurationStoreServers string `json:"configurationStoreServers,omitempty"`
+	// PulsarVersion defines the version of broker to use. It's either a semantic version without the `v` prefix
+	// e.g. 3.0.1, `latest` or an image digest e.g. sha256:<hex>
 	// +optional
 	PulsarVersion string `json:"pulsarVersion,omitempty"`
 	// ImagePullPolicy describes a policy for if/when to pull the image
@@ -125,11 +173,771 @@ type PulsarClusterSpec struct {
 	// ClusterDomain defines the cluster domain for the cluster
 	// It defaults to cluster.local
 	ClusterDomain string `json:"clusterDomain,omitempty"`
+
+	// TLS configures TLS for the broker client, web and internal traffic
+	// +optional
+	TLS *TLSConfig `json:"tls,omitempty"`
+
+	// Authentication configures the authentication of the broker clients
+	// +optional
+	Authentication *AuthenticationConfig `json:"authentication,omitempty"`
+
+	// Authorization configures the authorization of the authenticated roles
+	// +optional
+	Authorization *AuthorizationConfig `json:"authorization,omitempty"`
+
+	// ExternalAccess exposes every broker outside the kubernetes cluster through its own service
+	// +optional
+	ExternalAccess *ExternalAccessConfig `json:"externalAccess,omitempty"`
+
+	// TieredStorage configures the offloading of the ledgers to a long term storage
+	// +optional
+	TieredStorage *TieredStorageConfig `json:"tieredStorage,omitempty"`
+
+	// Storage configures how the brokers store the topic data in bookkeeper
+	// +optional
+	Storage *StorageConfig `json:"storage,omitempty"`
+
+	// Drain configures the draining of the brokers before they're removed or terminated
+	// +optional
+	Drain *DrainConfig `json:"drain,omitempty"`
+
+	// Monitoring configures the scraping of the broker metrics and the default alerts
+	// +optional
+	Monitoring *MonitoringConfig `json:"monitoring,omitempty"`
+
+	// Autoscaling configures a HorizontalPodAutoscaler scaling the brokers. When it's
+	// enabled, the size only defines the broker count the statefulset is created with
+	// +optional
+	Autoscaling *AutoscalingConfig `json:"autoscaling,omitempty"`
+
+	// Replication configures the peer clusters the namespaces of the cluster can be geo-replicated to
+	// +optional
+	Replication *ReplicationConfig `json:"replication,omitempty"`
+}
+
+// TLSConfig defines the TLS configuration of the brokers
+type TLSConfig struct {
+	// Enabled defines whether TLS is enabled or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// SecretName references an existing secret in the cluster namespace holding
+	// the `tls.crt`, `tls.key` and `ca.crt` keys. When it's empty, the operator
+	// creates a cert-manager Certificate using the CertManager config
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// CertManager configures the cert-manager Certificate created by the operator
+	// +optional
+	CertManager *CertManagerConfig `json:"certManager,omitempty"`
+	// Internal enables TLS for the broker's internal client traffic e.g. to other brokers
+	// +optional
+	Internal bool `json:"internal,omitempty"`
+}
+
+// CertManagerConfig defines the cert-manager Certificate of the brokers
+type CertManagerConfig struct {
+	// IssuerRef references the cert-manager Issuer or ClusterIssuer issuing the certificate
+	IssuerRef IssuerReference `json:"issuerRef"`
+	// Duration defines the lifetime of the certificate. Defaults to cert-manager's default
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// RenewBefore defines how long before expiry the certificate is renewed
+	// +optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+}
+
+// IssuerReference references a cert-manager issuer
+type IssuerReference struct {
+	// Name of the issuer
+	Name string `json:"name"`
+	// Kind of the issuer; Issuer or ClusterIssuer. Defaults to Issuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Group of the issuer. Defaults to cert-manager.io
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// IsEnabled checks whether TLS is enabled
+func (in *TLSConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+// IsCertManaged checks whether the operator manages the certificate through cert-manager
+func (in *TLSConfig) IsCertManaged() bool {
+	return in.IsEnabled() && in.SecretName == ""
+}
+
+// AuthenticationConfig defines the authentication of the brokers
+type AuthenticationConfig struct {
+	// Enabled defines whether authentication is enabled or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// Provider defines the authentication provider. Only `token` is supported for now
+	// +kubebuilder:validation:Enum=token
+	// +optional
+	Provider string `json:"provider,omitempty"`
+	// Token configures the JWT token authentication provider
+	// +optional
+	Token *TokenAuthentication `json:"token,omitempty"`
+}
+
+// TokenAuthentication defines the JWT token authentication of the brokers
+type TokenAuthentication struct {
+	// SigningAlgorithm defines the algorithm the tokens are signed with. Defaults to RS256
+	// +kubebuilder:validation:Enum=HS256;RS256;ES256
+	// +optional
+	SigningAlgorithm string `json:"signingAlgorithm,omitempty"`
+	// KeySecretName references an existing secret in the cluster namespace holding
+	// the DER encoded signing keys; `secret.key` for HS256 or `private.key` and `public.key`
+	// otherwise. When it's empty, the operator generates the keys in its own secret
+	// +optional
+	KeySecretName string `json:"keySecretName,omitempty"`
+	// BrokerRole defines the role of the brokers' internal client. Defaults to `broker`
+	// +optional
+	BrokerRole string `json:"brokerRole,omitempty"`
+	// OperatorRole defines the superuser role the operator uses to call the admin API.
+	// Defaults to `pulsar-operator`
+	// +optional
+	OperatorRole string `json:"operatorRole,omitempty"`
+	// ProxyRole defines the role the proxies of the cluster connect to the brokers with.
+	// It's added to the proxy roles of the brokers. Defaults to `proxy`
+	// +optional
+	ProxyRole string `json:"proxyRole,omitempty"`
+}
+
+// IsEnabled checks whether authentication is enabled
+func (in *AuthenticationConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+func (in *AuthenticationConfig) setDefaults() (changed bool) {
+	if in.Provider == "" {
+		changed = true
+		in.Provider = AuthenticationProviderToken
+	}
+	if in.Token == nil {
+		changed = true
+		in.Token = &TokenAuthentication{}
+	}
+	if in.Token.SigningAlgorithm == "" {
+		changed = true
+		in.Token.SigningAlgorithm = defaultTokenSigningAlgorithm
+	}
+	if in.Token.BrokerRole == "" {
+		changed = true
+		in.Token.BrokerRole = defaultTokenBrokerRole
+	}
+	if in.Token.OperatorRole == "" {
+		changed = true
+		in.Token.OperatorRole = defaultTokenOperatorRole
+	}
+	if in.Token.ProxyRole == "" {
+		changed = true
+		in.Token.ProxyRole = defaultTokenProxyRole
+	}
+	return
+}
+
+// AuthorizationConfig defines the authorization of the brokers
+type AuthorizationConfig struct {
+	// Enabled defines whether authorization is enabled or not. It requires authentication
+	Enabled bool `json:"enabled,omitempty"`
+	// SuperUserRoles defines the roles allowed to perform every operation. The operator
+	// and broker roles are always superusers, so there's no need to list them
+	// +listType=set
+	// +optional
+	SuperUserRoles []string `json:"superUserRoles,omitempty"`
+	// ProxyRoles defines the roles of the proxies allowed to act on behalf of the clients
+	// +listType=set
+	// +optional
+	ProxyRoles []string `json:"proxyRoles,omitempty"`
+	// Provider defines the class name of a custom authorization provider.
+	// Defaults to pulsar's PulsarAuthorizationProvider
+	// +optional
+	Provider string `json:"provider,omitempty"`
+}
+
+// IsEnabled checks whether authorization is enabled
+func (in *AuthorizationConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+// ExternalAccessType is the type of the per-broker services exposing the brokers
+type ExternalAccessType string
+
+const (
+	// ExternalAccessLoadBalancer exposes every broker through its own load balancer
+	ExternalAccessLoadBalancer ExternalAccessType = "LoadBalancer"
+	// ExternalAccessNodePort exposes every broker on a node port of the node it runs on
+	ExternalAccessNodePort ExternalAccessType = "NodePort"
+)
+
+// ExternalAccessConfig defines the external access to the brokers. Every broker advertises
+// its in-cluster address on the `internal` listener and its external address on the
+// external listener, which the clients outside the cluster select with their `listenerName`
+type ExternalAccessConfig struct {
+	// Enabled defines whether the external access is enabled or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// Type defines the type of the per-broker services. Defaults to LoadBalancer. With NodePort,
+	// the brokers advertise the IP of their node which must be reachable by the clients
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	// +optional
+	Type ExternalAccessType `json:"type,omitempty"`
+	// ListenerName defines the name of the external listener. Defaults to `external`
+	// +optional
+	ListenerName string `json:"listenerName,omitempty"`
+	// Annotations defines the annotations to attach to the per-broker services
+	// e.g. to configure the load balancers of the cloud provider
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// LoadBalancerSourceRanges restricts the client IPs allowed through the load balancers
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+}
+
+// IsEnabled checks whether the external access is enabled
+func (in *ExternalAccessConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
 }
 
+func (in *ExternalAccessConfig) setDefaults() (changed bool) {
+	if in.Type == "" {
+		changed = true
+		in.Type = ExternalAccessLoadBalancer
+	}
+	if in.ListenerName == "" {
+		changed = true
+		in.ListenerName = defaultExternalListenerName
+	}
+	return
+}
+
+// ZookeeperReference references a ZookeeperCluster of the zookeeper operator
+type ZookeeperReference struct {
+	// Name of the ZookeeperCluster
+	Name string `json:"name"`
+	// Namespace of the ZookeeperCluster. Defaults to the cluster namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// ChrootPath defines the znode the pulsar metadata is stored under e.g. /pulsar
+	// +optional
+	ChrootPath string `json:"chrootPath,omitempty"`
+}
+
+// BookkeeperReference references a BookkeeperCluster of the bookkeeper operator
+type BookkeeperReference struct {
+	// Name of the BookkeeperCluster
+	Name string `json:"name"`
+	// Namespace of the BookkeeperCluster. Defaults to the cluster namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// MetadataStoreType is the backend of the metadata store
+type MetadataStoreType string
+
+const (
+	// MetadataStoreZookeeper stores the metadata in zookeeper
+	MetadataStoreZookeeper MetadataStoreType = "zookeeper"
+	// MetadataStoreEtcd stores the metadata in etcd. It's supported since pulsar 2.10.0
+	MetadataStoreEtcd MetadataStoreType = "etcd"
+	// MetadataStoreRocksDB stores the metadata in a RocksDB database local to the broker.
+	// It's supported since pulsar 2.10.0 and requires a single broker
+	MetadataStoreRocksDB MetadataStoreType = "rocksdb"
+	// MetadataStoreOxia stores the metadata in oxia. It's supported since pulsar 3.1.0
+	MetadataStoreOxia MetadataStoreType = "oxia"
+)
+
+const (
+	// RocksDBMetadataStoreDirectory is the broker data directory the RocksDB metadata store must be in
+	RocksDBMetadataStoreDirectory  = "/data"
+	defaultRocksDBMetadataStoreURL = RocksDBMetadataStoreDirectory + "/metadata-store"
+)
+
+const (
+	// MetadataStoreCACertKey is the key of the CA certificate in the metadata store TLS secret
+	MetadataStoreCACertKey = "ca.crt"
+	// MetadataStoreKeyStoreKey is the key of the PEM client private key and certificate chain
+	// in the metadata store TLS secret. It's required by the client authentication
+	MetadataStoreKeyStoreKey = "tls.pem"
+)
+
+// MetadataStoreConfig defines the metadata and configuration stores of the cluster
+type MetadataStoreConfig struct {
+	// Type defines the metadata store backend. Defaults to zookeeper
+	// +kubebuilder:validation:Enum=zookeeper;etcd;rocksdb;oxia
+	// +optional
+	Type MetadataStoreType `json:"type,omitempty"`
+	// URL defines the address of the metadata store without the backend prefix:
+	//   - zookeeper: the connect string e.g. zk-0.zk:2181,zk-1.zk:2181/pulsar
+	//   - etcd: the comma separated endpoints e.g. http://etcd-0.etcd:2379,http://etcd-1.etcd:2379
+	//   - rocksdb: the database directory under /data. Defaults to /data/metadata-store
+	//   - oxia: the service address and the namespace e.g. oxia:6648/pulsar
+	// +optional
+	URL string `json:"url,omitempty"`
+	// ConfigurationURL defines the address of the configuration store in the same format. Defaults to the url
+	// +optional
+	ConfigurationURL string `json:"configurationURL,omitempty"`
+	// TLS configures TLS to the metadata store. It's only supported by zookeeper
+	// +optional
+	TLS *MetadataStoreTLS `json:"tls,omitempty"`
+}
+
+// MetadataStoreTLS defines TLS to the metadata store
+type MetadataStoreTLS struct {
+	// Enabled defines whether TLS is enabled or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// SecretName references the secret in the cluster namespace holding the `ca.crt` key
+	// and with client authentication, the `tls.pem` key
+	SecretName string `json:"secretName,omitempty"`
+	// ClientAuth enables the client certificate authentication
+	// +optional
+	ClientAuth bool `json:"clientAuth,omitempty"`
+}
+
+// IsEnabled checks whether TLS to the metadata store is enabled
+func (in *MetadataStoreTLS) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+// ConfigurationStoreURL returns the address of the configuration store
+func (in *MetadataStoreConfig) ConfigurationStoreURL() string {
+	if in.ConfigurationURL != "" {
+		return in.ConfigurationURL
+	}
+	return in.URL
+}
+
+// PrefixedURL returns the address in the `metadataStoreUrl` format e.g. zk:zk-0.zk:2181 or etcd:http://etcd:2379
+func (in *MetadataStoreConfig) PrefixedURL(address string) string {
+	switch in.Type {
+	case MetadataStoreEtcd:
+		return ensurePrefix(address, "etcd:")
+	case MetadataStoreRocksDB:
+		return ensurePrefix(address, "rocksdb:")
+	case MetadataStoreOxia:
+		return ensurePrefix(address, "oxia://")
+	}
+	// `zk:2181` is a host named zk, while `zk:zk:2181` is already a URL
+	firstServer := strings.FieldsFunc(address, func(r rune) bool { return r == ',' || r == '/' })
+	if len(firstServer) > 0 && strings.HasPrefix(address, "zk:") && strings.Count(firstServer[0], ":") > 1 {
+		return address
+	}
+	return "zk:" + address
+}
+
+func ensurePrefix(address, prefix string) string {
+	if strings.HasPrefix(address, prefix) {
+		return address
+	}
+	return prefix + address
+}
+
+func (in *MetadataStoreConfig) setDefaults() (changed bool) {
+	if in.Type == "" {
+		changed = true
+		in.Type = MetadataStoreZookeeper
+	}
+	if in.Type == MetadataStoreRocksDB && in.URL == "" {
+		changed = true
+		in.URL = defaultRocksDBMetadataStoreURL
+	}
+	return
+}
+
+// GetMetadataStore returns the metadata store of the cluster. The deprecated zookeeper
+// fields are converted if the spec has not been migrated by the webhook yet. The URL
+// of a referenced zookeeper cluster is only known once resolved; see PulsarCluster.MetadataStore
+func (in *PulsarClusterSpec) GetMetadataStore() *MetadataStoreConfig {
+	if in.MetadataStore != nil {
+		return in.MetadataStore
+	}
+	return &MetadataStoreConfig{
+		Type:             MetadataStoreZookeeper,
+		URL:              in.ZookeeperServers,
+		ConfigurationURL: in.ConfigurationStoreServers,
+	}
+}
+
+// migrateZookeeperServers moves the deprecated zookeeper fields into the metadata store
+func (in *PulsarClusterSpec) migrateZookeeperServers() (changed bool) {
+	if in.MetadataStore != nil || in.ZookeeperServers == "" {
+		return false
+	}
+	in.MetadataStore = in.GetMetadataStore()
+	if in.MetadataStore.ConfigurationURL == in.MetadataStore.URL {
+		in.MetadataStore.ConfigurationURL = ""
+	}
+	in.ZookeeperServers = ""
+	in.ConfigurationStoreServers = ""
+	return true
+}
+
+const (
+	defaultDrainTimeout = 5 * time.Minute
+	// minimumPreStopDrainSeconds is the preStop drain time below which a warning is issued
+	minimumPreStopDrainSeconds = 10
+	// brokerShutdownMargin is the part of the termination grace period left after the broker
+	// shutdown timeout for the JVM to exit before the pod is killed
+	brokerShutdownMargin int64 = 5
+)
+
+// DrainConfig defines the draining of the brokers. A broker is drained by unloading its
+// namespace bundles so that the load manager reassigns them to the other brokers
+type DrainConfig struct {
+	// PreStopHook enables a preStop hook draining the broker whenever its pod is terminated,
+	// including the evictions and restarts not driven by the operator. Defaults to true
+	// +optional
+	PreStopHook *bool `json:"preStopHook,omitempty"`
+	// Timeout defines how long the operator waits for the brokers removed by a scale down
+	// to be drained before removing them anyway. Defaults to 5m
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// IsPreStopHookEnabled checks whether the brokers are drained by a preStop hook
+func (in *DrainConfig) IsPreStopHookEnabled() bool {
+	return in == nil || in.PreStopHook == nil || *in.PreStopHook
+}
+
+// GetTimeout returns the timeout of the scale down drain
+func (in *DrainConfig) GetTimeout() time.Duration {
+	if in == nil || in.Timeout == nil {
+		return defaultDrainTimeout
+	}
+	return in.Timeout.Duration
+}
+
+// ShutdownBudget splits the termination grace period of the broker pod between the preStop
+// drain and the broker shutdown. It returns the preStop drain timeout and the brokerShutdownTimeoutMs
+func (in *PulsarClusterSpec) ShutdownBudget() (preStopSeconds int64, brokerShutdownTimeoutMs int64) {
+	grace := defaultTerminationGracePeriod
+	if in.PodConfig.Spec.TerminationGracePeriodSeconds != nil {
+		grace = *in.PodConfig.Spec.TerminationGracePeriodSeconds
+	}
+	if in.Drain.IsPreStopHookEnabled() {
+		preStopSeconds = grace / 2
+	}
+	shutdown := grace - preStopSeconds - brokerShutdownMargin
+	if shutdown < 1 {
+		shutdown = 1
+	}
+	return preStopSeconds, shutdown * 1000
+}
+
+// StorageConfig defines how the brokers store the topic data in bookkeeper
+type StorageConfig struct {
+	// Replication defines the default replication of the managed ledgers
+	// +optional
+	Replication *LedgerReplicationConfig `json:"replication,omitempty"`
+}
+
+// LedgerReplicationConfig defines the default ensemble and quorums of the managed ledgers.
+// The unset values are derived from the bookie count of the bookkeeperRef; with an unknown
+// bookie count, they're derived from 2 bookies. Without both the bookkeeperRef and the
+// replication, the ledgers are written to a single bookie as they were before it was configurable
+type LedgerReplicationConfig struct {
+	// EnsembleSize defines the number of bookies the ledger entries are striped over
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	EnsembleSize *int32 `json:"ensembleSize,omitempty"`
+	// WriteQuorum defines the number of bookies each entry is written to
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WriteQuorum *int32 `json:"writeQuorum,omitempty"`
+	// AckQuorum defines the number of bookies that must acknowledge each entry
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	AckQuorum *int32 `json:"ackQuorum,omitempty"`
+}
+
+// LedgerReplication is the resolved replication of the managed ledgers
+type LedgerReplication struct {
+	EnsembleSize int32
+	WriteQuorum  int32
+	AckQuorum    int32
+}
+
+// IsReplicated checks whether each entry is written to and acknowledged by more than one bookie
+func (in LedgerReplication) IsReplicated() bool {
+	return in.WriteQuorum > 1 && in.AckQuorum > 1
+}
+
+// defaultLedgerReplication returns the replication fitting the bookie count. Some bookies are
+// left out of the ensemble when possible so that a failed bookie can be replaced without stalling writes
+func defaultLedgerReplication(bookies int32) LedgerReplication {
+	switch {
+	case bookies == 1:
+		return LedgerReplication{EnsembleSize: 1, WriteQuorum: 1, AckQuorum: 1}
+	case bookies >= 4:
+		return LedgerReplication{EnsembleSize: 3, WriteQuorum: 3, AckQuorum: 2}
+	default: // 2 or 3 bookies, or an unknown count
+		return LedgerReplication{EnsembleSize: 2, WriteQuorum: 2, AckQuorum: 2}
+	}
+}
+
+// resolve returns the replication with the unset values derived from the bookie count
+// and adjusted to the set ones so that ensemble >= write quorum >= ack quorum holds
+func (in *LedgerReplicationConfig) resolve(bookies int32) LedgerReplication {
+	if in == nil && bookies == 0 {
+		// keeps the existing clusters from rolling and those with a single bookie from breaking
+		return LedgerReplication{EnsembleSize: 1, WriteQuorum: 1, AckQuorum: 1}
+	}
+	r := defaultLedgerReplication(bookies)
+	if in == nil {
+		return r
+	}
+	if in.EnsembleSize != nil {
+		r.EnsembleSize = *in.EnsembleSize
+	}
+	if in.WriteQuorum != nil {
+		r.WriteQuorum = *in.WriteQuorum
+	}
+	if in.AckQuorum != nil {
+		r.AckQuorum = *in.AckQuorum
+	}
+	if in.WriteQuorum == nil {
+		if r.WriteQuorum > r.EnsembleSize {
+			r.WriteQuorum = r.EnsembleSize
+		}
+		if in.AckQuorum != nil && r.WriteQuorum < r.AckQuorum {
+			r.WriteQuorum = r.AckQuorum
+		}
+	}
+	if in.AckQuorum == nil && r.AckQuorum > r.WriteQuorum {
+		r.AckQuorum = r.WriteQuorum
+	}
+	if in.EnsembleSize == nil && r.EnsembleSize < r.WriteQuorum {
+		r.EnsembleSize = r.WriteQuorum
+	}
+	return r
+}
+
+// OffloadDriver is the driver offloading the ledgers to the tiered storage
+type OffloadDriver string
+
+const (
+	// OffloadDriverS3 offloads to AWS S3 or any S3 compatible storage e.g. MinIO
+	OffloadDriverS3 OffloadDriver = "aws-s3"
+	// OffloadDriverGCS offloads to Google Cloud Storage
+	OffloadDriverGCS OffloadDriver = "google-cloud-storage"
+	// OffloadDriverAzureBlob offloads to Azure Blob Storage
+	OffloadDriverAzureBlob OffloadDriver = "azureblob"
+	// OffloadDriverFilesystem offloads to a filesystem volume
+	OffloadDriverFilesystem OffloadDriver = "filesystem"
+)
+
+const (
+	// OffloadS3AccessKeyIDKey is the key of the S3 access key id in the credentials secret
+	OffloadS3AccessKeyIDKey = "AWS_ACCESS_KEY_ID"
+	// OffloadS3SecretAccessKeyKey is the key of the S3 secret access key in the credentials secret
+	OffloadS3SecretAccessKeyKey = "AWS_SECRET_ACCESS_KEY"
+	// OffloadAzureStorageAccountKey is the key of the Azure storage account in the credentials secret
+	OffloadAzureStorageAccountKey = "AZURE_STORAGE_ACCOUNT"
+	// OffloadAzureStorageAccessKeyKey is the key of the Azure storage access key in the credentials secret
+	OffloadAzureStorageAccessKeyKey = "AZURE_STORAGE_ACCESS_KEY"
+	// OffloadGCSServiceAccountKey is the key of the GCS service account JSON key file in the credentials secret
+	OffloadGCSServiceAccountKey = "service-account.json"
+)
+
+// TieredStorageConfig defines the tiered storage the brokers offload the ledgers to. The brokers run the
+// apachepulsar/pulsar-all image which bundles the offloaders. The credentials are read from a secret and
+// never rendered into the broker configmap; the secret keys depend on the driver:
+//   - aws-s3: `AWS_ACCESS_KEY_ID` and `AWS_SECRET_ACCESS_KEY`. Without a secret, the default AWS credentials
+//     chain is used e.g. the IAM role of the service account
+//   - google-cloud-storage: `service-account.json`
+//   - azureblob: `AZURE_STORAGE_ACCOUNT` and `AZURE_STORAGE_ACCESS_KEY`
+type TieredStorageConfig struct {
+	// Enabled defines whether the tiered storage is enabled or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// Driver defines the offload driver
+	// +kubebuilder:validation:Enum=aws-s3;google-cloud-storage;azureblob;filesystem
+	Driver OffloadDriver `json:"driver,omitempty"`
+	// Bucket defines the bucket, or the container for azureblob, the ledgers are offloaded to
+	// +optional
+	Bucket string `json:"bucket,omitempty"`
+	// Region defines the region of the bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+	// Endpoint defines an alternative endpoint of the storage service e.g. a MinIO URL for aws-s3
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// CredentialsSecretName references the secret in the cluster namespace holding the credentials of the driver
+	// +optional
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty"`
+	// Filesystem configures the filesystem driver
+	// +optional
+	Filesystem *FilesystemOffloadConfig `json:"filesystem,omitempty"`
+	// SizeThreshold defines the size of a topic's backlog in bookkeeper above which the ledgers are
+	// automatically offloaded. Unset leaves the automatic offload to the namespace policies
+	// +optional
+	SizeThreshold *resource.Quantity `json:"sizeThreshold,omitempty"`
+	// TimeThreshold defines the age of the ledgers above which they're automatically offloaded.
+	// It's supported since pulsar 2.11.0
+	// +optional
+	TimeThreshold *metav1.Duration `json:"timeThreshold,omitempty"`
+	// DeletionLag defines how long the offloaded ledgers are kept in bookkeeper before they're deleted
+	// +optional
+	DeletionLag *metav1.Duration `json:"deletionLag,omitempty"`
+}
+
+// FilesystemOffloadConfig defines the volume the filesystem driver offloads the ledgers to
+type FilesystemOffloadConfig struct {
+	// ClaimName references an existing PersistentVolumeClaim in the cluster namespace.
+	// It must be ReadWriteMany since every broker mounts it
+	ClaimName string `json:"claimName"`
+}
+
+// IsEnabled checks whether the tiered storage is enabled
+func (in *TieredStorageConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+// ReplicationTokenKey is the key of the token in the authentication secret of a replication peer
+const ReplicationTokenKey = "token"
+
+// ReplicationConfig defines the geo-replication peers of the cluster. Every peer is registered in the
+// configuration store of the cluster so that the namespaces can list it in their replication clusters
+type ReplicationConfig struct {
+	// Peers defines the peer clusters
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Peers []ReplicationPeer `json:"peers,omitempty"`
+}
+
+// ReplicationPeer defines a peer cluster either by a PulsarCluster reference or by its service URLs
+type ReplicationPeer struct {
+	// Name defines the name of the peer cluster in pulsar. It must match the name
+	// the peer is registered with in its own configuration store
+	Name string `json:"name"`
+	// ClusterRef references a PulsarCluster in the same namespace. The service URLs
+	// and the broker token of the referenced cluster are used
+	// +optional
+	ClusterRef *ClusterReference `json:"clusterRef,omitempty"`
+	// ServiceURL defines the HTTP web service URL of the peer e.g. http://pulsar.eu-west:8080
+	// +optional
+	ServiceURL string `json:"serviceURL,omitempty"`
+	// ServiceURLTLS defines the HTTPS web service URL of the peer
+	// +optional
+	ServiceURLTLS string `json:"serviceURLTLS,omitempty"`
+	// BrokerServiceURL defines the binary protocol URL of the peer e.g. pulsar://pulsar.eu-west:6650
+	// +optional
+	BrokerServiceURL string `json:"brokerServiceURL,omitempty"`
+	// BrokerServiceURLTLS defines the TLS binary protocol URL of the peer
+	// +optional
+	BrokerServiceURLTLS string `json:"brokerServiceURLTLS,omitempty"`
+	// AuthenticationSecretName references a secret in the cluster namespace holding the `token`
+	// key; the token the brokers replicate to the peer with
+	// +optional
+	AuthenticationSecretName string `json:"authenticationSecretName,omitempty"`
+}
+
+const (
+	defaultMonitoringInterval            = "30s"
+	defaultDashboardLabel                = "grafana_dashboard"
+	defaultAlertBacklogThreshold   int64 = 100000
+	defaultAlertTopicsPerBroker    int64 = 10000
+	defaultAlertWriteLatencyMillis int64 = 1000
+)
+
+// MonitoringConfig defines the monitoring of the brokers by the prometheus operator. A PodMonitor
+// scrapes the broker metrics and a PrometheusRule defines the default alerts. They're not created
+// when the prometheus operator CRDs are not installed
 type MonitoringConfig struct {
 	// Enabled defines whether this monitoring is enabled or not.
 	Enabled bool `json:"enabled,omitempty"`
+	// Labels defines the labels of the PodMonitor and PrometheusRule
+	// e.g. to match the selectors of the prometheus instance
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Interval defines the scrape interval of the broker metrics. Defaults to 30s
+	// +optional
+	Interval string `json:"interval,omitempty"`
+	// Alerts configures the default alerts of the PrometheusRule
+	// +optional
+	Alerts *MonitoringAlertsConfig `json:"alerts,omitempty"`
+	// Dashboards configures the provisioning of the grafana dashboards of the cluster
+	// +optional
+	Dashboards *DashboardsConfig `json:"dashboards,omitempty"`
+}
+
+// DashboardsConfig defines the grafana dashboards shipped with the operator. Every dashboard is
+// published in a labelled ConfigMap so that the grafana dashboard sidecar discovers it
+type DashboardsConfig struct {
+	// Enabled defines whether the dashboards are published or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// Labels defines the labels the grafana sidecar discovers the ConfigMaps with. Defaults to grafana_dashboard: "1"
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations defines the annotations of the ConfigMaps e.g. to place the dashboards in a grafana folder
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// MonitoringAlertsConfig defines the default alerts and their thresholds
+type MonitoringAlertsConfig struct {
+	// Disabled defines whether the PrometheusRule is not created
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// BacklogThreshold defines the message backlog of a namespace above which an alert fires. Defaults to 100000
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	BacklogThreshold *int64 `json:"backlogThreshold,omitempty"`
+	// WriteLatencyMillis defines the storage write latency bucket above which the writes fire an alert.
+	// It's one of the buckets of the broker: 10, 20, 50, 100, 200 or 1000. Defaults to 1000
+	// +kubebuilder:validation:Enum=10;20;50;100;200;1000
+	// +optional
+	WriteLatencyMillis *int64 `json:"writeLatencyMillis,omitempty"`
+	// TopicsPerBroker defines the topic count of a broker above which an alert fires. Defaults to 10000
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TopicsPerBroker *int64 `json:"topicsPerBroker,omitempty"`
+}
+
+// IsEnabled checks whether the brokers are monitored
+func (in *MonitoringConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+// AreDashboardsEnabled checks whether the grafana dashboards are published
+func (in *MonitoringConfig) AreDashboardsEnabled() bool {
+	return in.IsEnabled() && in.Dashboards != nil && in.Dashboards.Enabled
+}
+
+// AreAlertsEnabled checks whether the default alerts are created
+func (in *MonitoringConfig) AreAlertsEnabled() bool {
+	return in.IsEnabled() && (in.Alerts == nil || !in.Alerts.Disabled)
+}
+
+func (in *MonitoringConfig) setDefaults() (changed bool) {
+	if in.Interval == "" {
+		changed = true
+		in.Interval = defaultMonitoringInterval
+	}
+	if in.Dashboards != nil && in.Dashboards.Enabled && len(in.Dashboards.Labels) == 0 {
+		changed = true
+		in.Dashboards.Labels = map[string]string{defaultDashboardLabel: "1"}
+	}
+	if in.Alerts == nil {
+		changed = true
+		in.Alerts = &MonitoringAlertsConfig{}
+	}
+	defaults := []struct {
+		value **int64
+		def   int64
+	}{
+		{&in.Alerts.BacklogThreshold, defaultAlertBacklogThreshold},
+		{&in.Alerts.WriteLatencyMillis, defaultAlertWriteLatencyMillis},
+		{&in.Alerts.TopicsPerBroker, defaultAlertTopicsPerBroker},
+	}
+	for _, d := range defaults {
+		if *d.value == nil {
+			changed = true
+			def := d.def
+			*d.value = &def
+		}
+	}
+	return
 }
 
 type Ports struct {
@@ -146,13 +954,23 @@ type Ports struct {
 type KOP struct {
 	// Enabled defines whether this KOP is enabled or not.
 	Enabled bool `json:"enabled,omitempty"`
+	// NarURL defines the URL the brokers download the KoP protocol handler nar from.
+	// It's required when KOP is enabled since the pulsar images don't bundle it
+	// +optional
+	NarURL string `json:"narURL,omitempty"`
 	// < 0 means disabled
 	// +optional
 	PlainTextPort int32 `json:"plainTextPort,omitempty"`
+	// SecuredPort is the SASL_SSL listener port; it's only opened when spec.tls is enabled.
 	// < 0 means disabled
 	SecuredPort int32 `json:"SecuredPort,omitempty"`
 }
 
+// IsKopSecuredPortEnabled checks whether the SASL_SSL listener of KOP is opened
+func (in *PulsarClusterSpec) IsKopSecuredPortEnabled() bool {
+	return in.KOP.Enabled && in.KOP.SecuredPort > 0 && in.TLS.IsEnabled()
+}
+
 type JVMOptions struct {
 	// Memory defines memory options
 	// +optional
@@ -231,6 +1049,85 @@ func (in *JVMOptions) setDefaults() (changed bool) {
 // setDefaults set the defaults for the cluster spec and returns true otherwise false
 //
 //nolint:cyclop
+const (
+	defaultAutoscalingCPUUtilization = 80
+	// minimumAutoscalingScaleDownPeriod is the minimum period between two broker removals of the autoscaler
+	minimumAutoscalingScaleDownPeriod int32 = 60
+	// maximumAutoscalingScalingPeriod is the maximum period of a scaling policy of the autoscaler
+	maximumAutoscalingScalingPeriod int32 = 1800
+)
+
+// AutoscalingConfig defines the horizontal autoscaling of the brokers. The autoscaler removes
+// one broker at a time so that the preStop hook drains it before the next one is removed
+type AutoscalingConfig struct {
+	// Enabled defines whether the brokers are autoscaled or not.
+	Enabled bool `json:"enabled,omitempty"`
+	// MinReplicas defines the minimum broker count. Defaults to the size
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas defines the maximum broker count
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage defines the target average CPU utilization of the brokers.
+	// Defaults to 80 when no other metric is configured
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// TargetMemoryUtilizationPercentage defines the target average memory utilization of the brokers
+	// +optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+	// Metrics defines additional metrics such as the message rate per broker exposed as
+	// a pods metric through a custom metrics API adapter
+	// +optional
+	Metrics []v2.MetricSpec `json:"metrics,omitempty"`
+	// Behavior overrides the scaling behavior. By default, the scale downs remove one broker
+	// per termination grace period
+	// +optional
+	Behavior *v2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// IsEnabled checks whether the brokers are autoscaled
+func (in *AutoscalingConfig) IsEnabled() bool {
+	return in != nil && in.Enabled
+}
+
+func (in *AutoscalingConfig) setDefaults(size int32) (changed bool) {
+	if in.MinReplicas == nil {
+		changed = true
+		in.MinReplicas = &size
+	}
+	if in.TargetCPUUtilizationPercentage == nil && in.TargetMemoryUtilizationPercentage == nil && len(in.Metrics) == 0 {
+		changed = true
+		cpu := int32(defaultAutoscalingCPUUtilization)
+		in.TargetCPUUtilizationPercentage = &cpu
+	}
+	return
+}
+
+// AutoscalingScaleDownPeriod returns the period between two broker removals of the autoscaler.
+// It's the termination grace period so that every removed broker is drained by its preStop hook
+func (in *PulsarClusterSpec) AutoscalingScaleDownPeriod() int32 {
+	period := int32(defaultTerminationGracePeriod)
+	if in.PodConfig.Spec.TerminationGracePeriodSeconds != nil {
+		period = int32(*in.PodConfig.Spec.TerminationGracePeriodSeconds)
+	}
+	if period < minimumAutoscalingScaleDownPeriod {
+		period = minimumAutoscalingScaleDownPeriod
+	}
+	if period > maximumAutoscalingScalingPeriod {
+		period = maximumAutoscalingScalingPeriod
+	}
+	return period
+}
+
+// MaxBrokers returns the maximum broker count of the cluster; it's the size unless the brokers are autoscaled
+func (in *PulsarClusterSpec) MaxBrokers() int32 {
+	if in.Autoscaling.IsEnabled() && in.Autoscaling.MaxReplicas > *in.Size {
+		return in.Autoscaling.MaxReplicas
+	}
+	return *in.Size
+}
+
 func (in *PulsarClusterSpec) setDefaults() (changed bool) {
 	if in.PulsarVersion == "" {
 		changed = true
@@ -245,8 +1142,11 @@ func (in *PulsarClusterSpec) setDefaults() (changed bool) {
 		size := &defaultClusterSize
 		in.Size = size
 	}
-	if in.ConfigurationStoreServers == "" {
-		in.ConfigurationStoreServers = in.ZookeeperServers
+	if in.migrateZookeeperServers() {
+		changed = true
+	}
+	if in.MetadataStore != nil && in.MetadataStore.setDefaults() {
+		changed = true
 	}
 	if in.MaxUnavailableNodes < 0 {
 		changed = true
@@ -300,6 +1200,18 @@ func (in *PulsarClusterSpec) setDefaults() (changed bool) {
 			},
 		}
 	}
+	if in.Authentication.IsEnabled() && in.Authentication.setDefaults() {
+		changed = true
+	}
+	if in.ExternalAccess.IsEnabled() && in.ExternalAccess.setDefaults() {
+		changed = true
+	}
+	if in.Monitoring.IsEnabled() && in.Monitoring.setDefaults() {
+		changed = true
+	}
+	if in.Autoscaling.IsEnabled() && in.Autoscaling.setDefaults(*in.Size) {
+		changed = true
+	}
 	if in.PodConfig.Spec.TerminationGracePeriodSeconds == nil {
 		changed = true
 		in.PodConfig.Spec.TerminationGracePeriodSeconds = &defaultTerminationGracePeriod
@@ -307,16 +1219,33 @@ func (in *PulsarClusterSpec) setDefaults() (changed bool) {
 	return changed
 }
 
-func (in *PulsarClusterSpec) VersionInt() int {
-	if in.PulsarVersion == "" || in.PulsarVersion == "latest" {
-		return math.MaxInt32
-	}
-	vsn := strings.ReplaceAll(in.PulsarVersion, ".", "")
-	v, err := strconv.ParseInt(vsn, 10, 64)
+// Version returns the parsed pulsar version. An unparsable version is
+// treated as floating; the admission webhook rejects such versions anyway
+func (in *PulsarClusterSpec) Version() version.Version {
+	v, err := version.Parse(in.PulsarVersion)
 	if err != nil {
-		return math.MaxInt32
+		return version.Version{Floating: true}
+	}
+	return v
+}
+
+// VersionLabel returns the pulsar version as a valid label value.
+// Image digests are not valid label values, so they're shortened
+func (in *PulsarClusterSpec) VersionLabel() string {
+	return versionLabel(in.PulsarVersion)
+}
+
+// versionLabel returns the pulsar version as a valid label value
+func versionLabel(pulsarVersion string) string {
+	v, err := version.Parse(pulsarVersion)
+	if err != nil || v.Digest == "" {
+		return pulsarVersion
+	}
+	label := strings.Replace(v.Digest, ":", "-", 1)
+	if len(label) > validation.LabelValueMaxLength {
+		label = label[:validation.LabelValueMaxLength]
 	}
-	return int(v)
+	return label
 }
 
 func (in *PulsarClusterSpec) createAnnotations() map[string]string {
@@ -332,10 +1261,10 @@ func (in *PulsarClusterSpec) createLabels(clusterName string, broker bool) map[s
 		labels["broker"] = "true"
 	}
 	labels["app"] = "pulsar"
-	labels["version"] = in.PulsarVersion
+	labels["version"] = in.VersionLabel()
 	labels[k8s.LabelAppName] = "pulsar"
 	labels[k8s.LabelAppInstance] = clusterName
-	labels[k8s.LabelAppVersion] = in.PulsarVersion
+	labels[k8s.LabelAppVersion] = in.VersionLabel()
 	labels[k8s.LabelAppManagedBy] = internal.OperatorName
 	return labels
 }
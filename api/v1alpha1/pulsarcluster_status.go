@@ -16,6 +16,11 @@
 
 package v1alpha1
 
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // ClusterStage represents the stage of the pulsar broker cluster
 type ClusterStage string
 
@@ -28,12 +33,147 @@ const (
 	ClusterStageRunning = "Running"
 )
 
+const (
+	// ConditionMetadataInitialized indicates whether the cluster metadata is initialized
+	ConditionMetadataInitialized = "MetadataInitialized"
+	// ConditionAvailable indicates whether the cluster has brokers ready to serve clients
+	ConditionAvailable = "Available"
+	// ConditionProgressing indicates whether the brokers are being created, scaled, rolled or upgraded
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded indicates whether some brokers are unready while nothing is in progress
+	ConditionDegraded = "Degraded"
+	// ConditionPeersReachable indicates whether the geo-replication peers are registered and reachable
+	ConditionPeersReachable = "PeersReachable"
+	// ConditionDependenciesReady indicates whether the referenced zookeeper and bookkeeper clusters are ready
+	ConditionDependenciesReady = "DependenciesReady"
+)
+
 // PulsarClusterStatus defines the observed state of PulsarCluster
 type PulsarClusterStatus struct {
 
 	// Metadata defines the metadata status of the cluster
 	// +optional
 	Metadata Metadata `json:"metadata,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the cluster spec observed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of ready broker pods
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// CurrentVersion is the pulsar version all the brokers are running
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// Conditions defines the latest observations of the cluster state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Upgrade defines the progress of the ongoing broker version upgrade if any
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// Replication defines the state of the geo-replication peers registered by the operator
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Replication []ReplicationPeerStatus `json:"replication,omitempty"`
+
+	// Dependencies defines the connection strings resolved from the zookeeperRef and bookkeeperRef
+	// +optional
+	Dependencies *DependenciesStatus `json:"dependencies,omitempty"`
+
+	// ScaleDown defines the progress of the ongoing broker scale down if any
+	// +optional
+	ScaleDown *ScaleDownStatus `json:"scaleDown,omitempty"`
+
+	// Maintenance defines the state of the broker taken out of rotation by the maintenance annotation if any
+	// +optional
+	Maintenance *MaintenanceStatus `json:"maintenance,omitempty"`
+}
+
+// MaintenanceState represents the state of the broker in maintenance
+type MaintenanceState string
+
+const (
+	// MaintenanceStateDraining - the broker is out of the client service and its bundles are being unloaded
+	MaintenanceStateDraining MaintenanceState = "Draining"
+	// MaintenanceStateDrained - the broker is out of the client service and owns no bundles
+	MaintenanceStateDrained MaintenanceState = "Drained"
+	// MaintenanceStateUnavailable - the broker pod doesn't exist or is not ready
+	MaintenanceStateUnavailable MaintenanceState = "Unavailable"
+)
+
+// MaintenanceStatus defines the state of the broker taken out of rotation. The
+// broker keeps running but it's excluded from the client service and drained
+type MaintenanceStatus struct {
+	// Pod is the name of the broker pod in maintenance
+	Pod string `json:"pod"`
+	// State is the maintenance state of the broker
+	State MaintenanceState `json:"state"`
+	// OwnedBundles is the number of bundles the broker owned when it was last drained
+	// +optional
+	OwnedBundles int32 `json:"ownedBundles,omitempty"`
+	// Message describes why the broker is not drained
+	// +optional
+	Message string `json:"message,omitempty"`
+	// StartTime is the time the broker was taken out of rotation
+	StartTime metav1.Time `json:"startTime"`
+}
+
+// ScaleDownStatus defines the progress of a broker scale down. The removed brokers
+// are drained before the statefulset is scaled down
+type ScaleDownStatus struct {
+	// FromReplicas is the broker count before the scale down
+	FromReplicas int32 `json:"fromReplicas"`
+	// ToReplicas is the broker count after the scale down
+	ToReplicas int32 `json:"toReplicas"`
+	// StartTime is the time the removed brokers started to be drained
+	StartTime metav1.Time `json:"startTime"`
+}
+
+// DependenciesStatus defines the connection strings resolved from the referenced clusters
+type DependenciesStatus struct {
+	// ZookeeperServers is the connect string resolved from the zookeeperRef
+	// +optional
+	ZookeeperServers string `json:"zookeeperServers,omitempty"`
+	// BookkeeperClusterURI is the metadata service URI resolved from the bookkeeperRef
+	// +optional
+	BookkeeperClusterURI string `json:"bookkeeperClusterUri,omitempty"`
+	// Bookies is the bookie count of the bookkeeperRef
+	// +optional
+	Bookies int32 `json:"bookies,omitempty"`
+}
+
+// ReplicationPeerStatus defines the state of a geo-replication peer
+type ReplicationPeerStatus struct {
+	// Name is the name of the peer cluster in pulsar
+	Name string `json:"name"`
+	// Registered indicates whether the peer is registered in the configuration store
+	Registered bool `json:"registered"`
+	// Reachable indicates whether the web service of the peer responded to the last probe
+	Reachable bool `json:"reachable"`
+	// Message describes why the peer is not registered or not reachable
+	// +optional
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the last time the peer became reachable or unreachable
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// UpgradeStatus defines the progress of a broker version upgrade.
+// The brokers are upgraded one at a time from the highest ordinal to the lowest
+type UpgradeStatus struct {
+	// FromVersion is the pulsar version the brokers are upgraded from
+	FromVersion string `json:"fromVersion,omitempty"`
+	// ToVersion is the pulsar version the brokers are upgraded to
+	ToVersion string `json:"toVersion,omitempty"`
+	// CurrentOrdinal is the ordinal of the broker currently being upgraded
+	CurrentOrdinal int32 `json:"currentOrdinal"`
 }
 
 // Metadata defines the metadata status of the cluster
@@ -44,3 +184,41 @@ type Metadata struct {
 func (in *PulsarClusterStatus) setDefaults() (changed bool) {
 	return
 }
+
+// IsMetadataInitialized checks whether the cluster metadata has been initialized
+func (in *PulsarClusterStatus) IsMetadataInitialized() bool {
+	return in.Metadata.Stage != ""
+}
+
+// IsAvailable checks whether the cluster has brokers ready to serve clients
+func (in *PulsarClusterStatus) IsAvailable() bool {
+	return meta.IsStatusConditionTrue(in.Conditions, ConditionAvailable)
+}
+
+// GetReplicationPeer returns the status of the replication peer or nil if it does not exist
+func (in *PulsarClusterStatus) GetReplicationPeer(name string) *ReplicationPeerStatus {
+	for i := range in.Replication {
+		if in.Replication[i].Name == name {
+			return &in.Replication[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the specified type.
+// The transition time is only changed when the condition status changes
+func (in *PulsarClusterStatus) SetCondition(conditionType string, status metav1.ConditionStatus,
+	reason, message string, generation int64) {
+	meta.SetStatusCondition(&in.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+// GetCondition returns the condition of the specified type or nil if it does not exist
+func (in *PulsarClusterStatus) GetCondition(conditionType string) *metav1.Condition {
+	return meta.FindStatusCondition(in.Conditions, conditionType)
+}
@@ -20,7 +20,11 @@ import (
 	"fmt"
 	"github.com/monimesl/operator-helper/basetype"
 	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strconv"
 	"strings"
 )
 
@@ -46,6 +50,14 @@ func init() {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type=string,priority=1,JSONPath=`.spec.clusterName`
+//+kubebuilder:printcolumn:name="Stage",type=string,JSONPath=`.status.metadata.stage`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`
+//+kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.spec.size`
+//+kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+//+kubebuilder:printcolumn:name="Degraded",type=string,priority=1,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // PulsarCluster is the Schema for the pulsarclusters API
 type PulsarCluster struct {
@@ -69,7 +81,12 @@ func (in *PulsarCluster) generateName() string {
 
 // SetSpecDefaults set the defaults for the cluster spec and returns true otherwise false
 func (in *PulsarCluster) SetSpecDefaults() bool {
-	return in.Spec.setDefaults()
+	changed := in.Spec.setDefaults()
+	if in.Spec.ClusterName == "" {
+		changed = true
+		in.Spec.ClusterName = in.Name
+	}
+	return changed
 }
 
 // SetStatusDefaults set the defaults for the cluster status and returns true otherwise false
@@ -87,6 +104,46 @@ func (in *PulsarCluster) StatefulSetName() string {
 	return in.generateName()
 }
 
+// MaintenanceBrokerOrdinal returns the ordinal of the broker pod named by the maintenance
+// annotation. It returns false if the annotation is not set or doesn't name a broker pod
+func (in *PulsarCluster) MaintenanceBrokerOrdinal() (int32, bool) {
+	name := strings.TrimSpace(in.Annotations[MaintenanceAnnotation])
+	prefix := in.StatefulSetName() + "-"
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(name, prefix), 10, 32)
+	if err != nil || ordinal < 0 || in.BrokerPodName(int32(ordinal)) != name {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
+
+// BrokerPodName defines the name of the broker pod with the specified ordinal
+func (in *PulsarCluster) BrokerPodName(ordinal int32) string {
+	return fmt.Sprintf("%s-%d", in.StatefulSetName(), ordinal)
+}
+
+// BrokerPodFQDN defines the FQDN of the broker pod with the specified ordinal
+func (in *PulsarCluster) BrokerPodFQDN(ordinal int32) string {
+	return fmt.Sprintf("%s.%s", in.BrokerPodName(ordinal), in.ClientHeadlessServiceFQDN())
+}
+
+// ExternalServiceName defines the name of the service exposing the broker with the specified ordinal
+func (in *PulsarCluster) ExternalServiceName(ordinal int32) string {
+	return fmt.Sprintf("%s-external", in.BrokerPodName(ordinal))
+}
+
+// ExternalAddressesConfigMapName defines the name of the configmap holding the external addresses of the brokers
+func (in *PulsarCluster) ExternalAddressesConfigMapName() string {
+	return fmt.Sprintf("%s-external-addresses", in.generateName())
+}
+
+// OffloadProfileConfigMapName defines the name of the configmap holding the filesystem offloader profile
+func (in *PulsarCluster) OffloadProfileConfigMapName() string {
+	return fmt.Sprintf("%s-offload-profile", in.generateName())
+}
+
 // ClientServiceName defines the name of the client service object
 func (in *PulsarCluster) ClientServiceName() string {
 	return in.generateName()
@@ -112,16 +169,116 @@ func (in *PulsarCluster) GenerateAnnotations() map[string]string {
 }
 
 func (in *PulsarCluster) GenerateLabels(broker bool) map[string]string {
-	return in.Spec.createLabels(in.Name, broker)
+	labels := in.Spec.createLabels(in.Name, broker)
+	labels[ClusterNameLabel] = in.PulsarClusterName()
+	return labels
 }
 
 // Image specifies the pulsar image to use
 func (in *PulsarCluster) Image() basetype.Image {
+	repository := imageRepository
+	if in.Spec.TieredStorage.IsEnabled() {
+		// the offloaders are only bundled in the pulsar-all image
+		repository = allImageRepository
+	}
+	return pulsarImage(repository, in.Spec.PulsarVersion, in.Spec.ImagePullPolicy)
+}
+
+// pulsarImage returns the pulsar image of the version; a semantic version, `latest` or an image digest
+func pulsarImage(repository, pulsarVersion string, pullPolicy v1.PullPolicy) basetype.Image {
+	if v, err := version.Parse(pulsarVersion); err == nil && v.Digest != "" {
+		// <repository>@sha256:<hex>
+		algorithm := strings.SplitN(v.Digest, ":", 2)
+		return basetype.Image{
+			Repository: fmt.Sprintf("%s@%s", repository, algorithm[0]),
+			PullPolicy: pullPolicy,
+			Tag:        algorithm[1],
+		}
+	}
 	return basetype.Image{
-		Repository: imageRepository,
-		PullPolicy: in.Spec.ImagePullPolicy,
-		Tag:        in.Spec.PulsarVersion,
+		Repository: repository,
+		PullPolicy: pullPolicy,
+		Tag:        pulsarVersion,
+	}
+}
+
+// PulsarClusterName defines the name the cluster is registered with in pulsar
+func (in *PulsarCluster) PulsarClusterName() string {
+	if in.Spec.ClusterName != "" {
+		return in.Spec.ClusterName
+	}
+	return in.GetName()
+}
+
+// MetadataStore returns the metadata store of the cluster with the zookeeperRef resolved
+func (in *PulsarCluster) MetadataStore() *MetadataStoreConfig {
+	if in.Spec.ZookeeperRef == nil {
+		return in.Spec.GetMetadataStore()
+	}
+	store := &MetadataStoreConfig{Type: MetadataStoreZookeeper}
+	if in.Status.Dependencies != nil {
+		store.URL = in.Status.Dependencies.ZookeeperServers
+	}
+	return store
+}
+
+// BookkeeperMetadataServiceURI returns the metadata service URI of the bookkeeper cluster with the bookkeeperRef resolved
+func (in *PulsarCluster) BookkeeperMetadataServiceURI() string {
+	if in.Spec.BookkeeperRef == nil {
+		return in.Spec.BookkeeperClusterUri
+	}
+	if in.Status.Dependencies != nil {
+		return in.Status.Dependencies.BookkeeperClusterURI
+	}
+	return ""
+}
+
+// LedgerReplication returns the default replication of the managed ledgers
+// with the unset values derived from the bookie count of the bookkeeperRef
+func (in *PulsarCluster) LedgerReplication() LedgerReplication {
+	var bookies int32
+	if in.Spec.BookkeeperRef != nil && in.Status.Dependencies != nil {
+		bookies = in.Status.Dependencies.Bookies
+	}
+	var config *LedgerReplicationConfig
+	if in.Spec.Storage != nil {
+		config = in.Spec.Storage.Replication
+	}
+	return config.resolve(bookies)
+}
+
+// DependenciesReady checks whether the referenced zookeeper and bookkeeper clusters are resolved and ready
+func (in *PulsarCluster) DependenciesReady() bool {
+	if in.Spec.ZookeeperRef == nil && in.Spec.BookkeeperRef == nil {
+		return true
+	}
+	return meta.IsStatusConditionTrue(in.Status.Conditions, ConditionDependenciesReady)
+}
+
+// TLSSecretName defines the name of the secret holding the broker TLS certificate
+func (in *PulsarCluster) TLSSecretName() string {
+	if in.Spec.TLS != nil && in.Spec.TLS.SecretName != "" {
+		return in.Spec.TLS.SecretName
+	}
+	return fmt.Sprintf("%s-tls", in.generateName())
+}
+
+// CertificateName defines the name of the cert-manager Certificate of the brokers
+func (in *PulsarCluster) CertificateName() string {
+	return in.generateName()
+}
+
+// TokenKeySecretName defines the name of the secret holding the token signing keys
+func (in *PulsarCluster) TokenKeySecretName() string {
+	if auth := in.Spec.Authentication; auth != nil && auth.Token != nil && auth.Token.KeySecretName != "" {
+		return auth.Token.KeySecretName
 	}
+	return fmt.Sprintf("%s-token-keys", in.generateName())
+}
+
+// TokenSecretName defines the name of the secret holding the tokens issued for the brokers and the operator
+func (in *PulsarCluster) TokenSecretName() string {
+	return fmt.Sprintf("%s-tokens", in.generateName())
 }
 
 func (in *PulsarCluster) BrokersDataPvcName() string {
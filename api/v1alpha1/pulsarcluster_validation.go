@@ -0,0 +1,883 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"fmt"
+	"github.com/monimesl/pulsar-operator/internal/token"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strconv"
+	"strings"
+)
+
+// brokerConfigEnvPrefix is the env prefix the broker configs may be set with; it's trimmed when they're rendered
+const brokerConfigEnvPrefix = "PULSAR_PREFIX_"
+
+var (
+	// See https://bookkeeper.apache.org/docs/reference/config#metadata-service-settings
+	bookkeeperURISchemes = []string{"zk", "zk+null", "zk+hierarchical", "zk+longhierarchical", "metadata-store"}
+	// The broker configs rendered from spec.authorization
+	authorizationBrokerConfigs = []string{"authorizationEnabled", "authorizationProvider", "superUserRoles", "proxyRoles"}
+	// The broker configs rendered from spec.externalAccess
+	listenerBrokerConfigs = []string{"advertisedAddress", "advertisedListeners", "internalListenerName"}
+	// The broker configs rendered from spec.storage.replication
+	replicationBrokerConfigs = []string{
+		"managedLedgerDefaultEnsembleSize", "managedLedgerDefaultWriteQuorum", "managedLedgerDefaultAckQuorum",
+	}
+	javaClassNameRegex = regexp.MustCompile(`^([a-zA-Z_$][a-zA-Z\d_$]*\.)*[a-zA-Z_$][a-zA-Z\d_$]*$`)
+	listenerNameRegex  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+	// The duration format of prometheus
+	prometheusDurationRegex = regexp.MustCompile(`^(0|(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`)
+	// The storage write latency buckets of the broker metrics in milliseconds
+	writeLatencyBucketMillis = []int64{10, 20, 50, 100, 200, 1000}
+)
+
+// validate validates the cluster spec and returns the admission warnings of risky settings
+func (in *PulsarCluster) validate() (admission.Warnings, error) {
+	spec := &in.Spec
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if spec.ClusterName != "" {
+		// the name is also a label value of the cluster objects
+		for _, msg := range validation.IsValidLabelValue(spec.ClusterName) {
+			errs = append(errs, field.Invalid(specPath.Child("clusterName"), spec.ClusterName, msg))
+		}
+	}
+	if name, ok := in.Annotations[MaintenanceAnnotation]; ok {
+		if _, isBroker := in.MaintenanceBrokerOrdinal(); !isBroker {
+			errs = append(errs, field.Invalid(field.NewPath("metadata", "annotations").Key(MaintenanceAnnotation),
+				name, fmt.Sprintf("must name a broker pod of the cluster such as %s", in.BrokerPodName(0))))
+		}
+	}
+	errs = append(errs, validateMetadataStore(spec, specPath)...)
+	errs = append(errs, validateBookkeeper(spec, specPath)...)
+	if _, err := version.Parse(spec.PulsarVersion); err != nil {
+		errs = append(errs, field.Invalid(specPath.Child("pulsarVersion"), spec.PulsarVersion, err.Error()))
+	}
+	errs = append(errs, validatePorts(spec, specPath)...)
+	errs = append(errs, validateKop(spec, specPath.Child("kop"))...)
+	if spec.TLS.IsCertManaged() && (spec.TLS.CertManager == nil || spec.TLS.CertManager.IssuerRef.Name == "") {
+		errs = append(errs, field.Required(specPath.Child("tls", "certManager", "issuerRef", "name"),
+			"either tls.secretName or the cert-manager issuer is required"))
+	}
+	errs = append(errs, validateAuthentication(spec.Authentication, specPath.Child("authentication"))...)
+	errs = append(errs, validateAuthorization(spec, specPath.Child("authorization"))...)
+	errs = append(errs, validateExternalAccess(spec, specPath.Child("externalAccess"))...)
+	errs = append(errs, validateAutoscaling(spec, specPath.Child("autoscaling"))...)
+	errs = append(errs, validateMonitoring(spec.Monitoring, specPath.Child("monitoring"))...)
+	if spec.Drain != nil && spec.Drain.Timeout != nil && spec.Drain.Timeout.Duration <= 0 {
+		errs = append(errs, field.Invalid(specPath.Child("drain", "timeout"), spec.Drain.Timeout.Duration.String(),
+			"must be positive"))
+	}
+	errs = append(errs, validateStorage(spec, specPath)...)
+	errs = append(errs, validateTieredStorage(spec, specPath.Child("tieredStorage"))...)
+	errs = append(errs, validateReplication(spec, in.PulsarClusterName(), specPath.Child("replication"))...)
+	if len(errs) > 0 {
+		return nil, in.invalidError(errs)
+	}
+	return in.warnings(), nil
+}
+
+// validateUpdate validates the changes from the old cluster spec
+func (in *PulsarCluster) validateUpdate(old *PulsarCluster) error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	// the clusters created before the field was added are registered with their object name
+	if oldName := old.PulsarClusterName(); in.PulsarClusterName() != oldName {
+		errs = append(errs, field.Forbidden(specPath.Child("clusterName"),
+			fmt.Sprintf("the cluster is registered in pulsar as %s and cannot be renamed", oldName)))
+	}
+	// the URLs are compared in the metadataStoreUrl format so that the migration of the zookeeper fields is allowed
+	store, oldStore := in.Spec.GetMetadataStore(), old.Spec.GetMetadataStore()
+	if store.PrefixedURL(store.URL) != oldStore.PrefixedURL(oldStore.URL) {
+		errs = append(errs, field.Forbidden(specPath.Child("metadataStore", "url"),
+			"the metadata store of an initialized cluster cannot be changed"))
+	}
+	if store.PrefixedURL(store.ConfigurationStoreURL()) != oldStore.PrefixedURL(oldStore.ConfigurationStoreURL()) {
+		errs = append(errs, field.Forbidden(specPath.Child("metadataStore", "configurationURL"),
+			"the configuration store of an initialized cluster cannot be changed"))
+	}
+	if !reflect.DeepEqual(in.Spec.ZookeeperRef, old.Spec.ZookeeperRef) {
+		errs = append(errs, field.Forbidden(specPath.Child("zookeeperRef"),
+			"the metadata store of an initialized cluster cannot be changed"))
+	}
+	if !reflect.DeepEqual(in.Spec.BookkeeperRef, old.Spec.BookkeeperRef) {
+		errs = append(errs, field.Forbidden(specPath.Child("bookkeeperRef"),
+			"the bookkeeper cluster of an initialized cluster cannot be changed"))
+	}
+	if in.Spec.ClusterDomain != old.Spec.ClusterDomain {
+		errs = append(errs, field.Forbidden(specPath.Child("clusterDomain"),
+			"the cluster service URLs registered in the metadata store are derived from the cluster domain"))
+	}
+	errs = append(errs, validatePersistenceUpdate(in.Spec.Persistence, old.Spec.Persistence, specPath.Child("persistence"))...)
+	errs = append(errs, validateAuthenticationUpdate(in.Spec.Authentication,
+		old.Spec.Authentication, specPath.Child("authentication"))...)
+	if len(errs) > 0 {
+		return in.invalidError(errs)
+	}
+	return nil
+}
+
+func (in *PulsarCluster) invalidError(errs field.ErrorList) error {
+	return apierrors.NewInvalid(GroupVersion.WithKind("PulsarCluster").GroupKind(), in.Name, errs)
+}
+
+func (in *PulsarCluster) warnings() admission.Warnings {
+	var warnings admission.Warnings
+	if in.Spec.Size == nil {
+		return warnings
+	}
+	if size := *in.Spec.Size; size < minimumClusterSize {
+		warnings = append(warnings, fmt.Sprintf("spec.size: %d is below the recommended minimum of %d brokers; "+
+			"the cluster may be unavailable during broker restarts", size, minimumClusterSize))
+	}
+	if *in.Spec.Size > 0 && in.Spec.MaxUnavailableNodes >= *in.Spec.Size {
+		warnings = append(warnings, fmt.Sprintf("spec.maxUnavailableNodes: %d allows all the brokers "+
+			"to be unavailable at once", in.Spec.MaxUnavailableNodes))
+	}
+	v := in.Spec.Version()
+	if v.Floating && v.Digest == "" {
+		warnings = append(warnings, "spec.pulsarVersion: latest makes the broker version unpredictable; "+
+			"pin the version to get orchestrated upgrades")
+	}
+	warnings = append(warnings, in.authorizationWarnings()...)
+	if in.Spec.ExternalAccess.IsEnabled() {
+		for _, key := range listenerBrokerConfigs {
+			if in.Spec.hasBrokerConfig(key) {
+				warnings = append(warnings, fmt.Sprintf("spec.brokerConfig.%s: it conflicts with the listeners "+
+					"rendered from spec.externalAccess", key))
+			}
+		}
+	}
+	warnings = append(warnings, in.storageWarnings()...)
+	if preStop, _ := in.Spec.ShutdownBudget(); in.Spec.Drain.IsPreStopHookEnabled() && preStop < minimumPreStopDrainSeconds {
+		warnings = append(warnings, fmt.Sprintf("spec.podConfig.spec.terminationGracePeriodSeconds: it leaves %ds "+
+			"to drain a terminated broker; the bundles not unloaded in time are reassigned by the load manager", preStop))
+	}
+	if in.Spec.hasBrokerConfig("brokerShutdownTimeoutMs") {
+		warnings = append(warnings, "spec.brokerConfig.brokerShutdownTimeoutMs: it overrides the value aligned "+
+			"with the termination grace period; the broker may be killed before its shutdown completes")
+	}
+	if monitoring := in.Spec.Monitoring; !monitoring.IsEnabled() && monitoring != nil &&
+		monitoring.Dashboards != nil && monitoring.Dashboards.Enabled {
+		warnings = append(warnings, "spec.monitoring.dashboards: the dashboards are only published "+
+			"when spec.monitoring is enabled")
+	}
+	if autoscaling := in.Spec.Autoscaling; autoscaling.IsEnabled() {
+		if in.Spec.ExternalAccess.IsEnabled() {
+			warnings = append(warnings, fmt.Sprintf("spec.externalAccess: a per-broker service is created "+
+				"for each of the %d brokers the autoscaler may scale up to", autoscaling.MaxReplicas))
+		}
+		size := *in.Spec.Size
+		if minReplicas := autoscaling.MinReplicas; minReplicas != nil && (size < *minReplicas || size > autoscaling.MaxReplicas) {
+			warnings = append(warnings, fmt.Sprintf("spec.size: %d is outside the autoscaling range [%d, %d]; "+
+				"the autoscaler moves the broker count into it", size, *minReplicas, autoscaling.MaxReplicas))
+		}
+	}
+	if ordinal, ok := in.MaintenanceBrokerOrdinal(); ok {
+		key := "metadata.annotations." + MaintenanceAnnotation
+		if ordinal >= *in.Spec.Size {
+			warnings = append(warnings, fmt.Sprintf("%s: the broker %s doesn't exist; "+
+				"it's taken out of rotation once created", key, in.BrokerPodName(ordinal)))
+		} else if *in.Spec.Size == 1 {
+			warnings = append(warnings, fmt.Sprintf("%s: it takes the only broker out of rotation; "+
+				"the client service will have no endpoints", key))
+		}
+	}
+	if in.Spec.TieredStorage.IsEnabled() && v.Digest != "" {
+		warnings = append(warnings, "spec.pulsarVersion: the digest must be of the apachepulsar/pulsar-all image "+
+			"since the tiered storage requires the bundled offloaders")
+	}
+	if in.Spec.KOP.Enabled && !v.Supports(version.KafkaProtocolHandler) {
+		warnings = append(warnings, fmt.Sprintf("spec.kop: the Kafka protocol handler is not supported "+
+			"by pulsar %s; it will not be configured", v))
+	}
+	if in.Spec.IsKopSecuredPortEnabled() {
+		warnings = append(warnings, "spec.kop.SecuredPort: the SASL_SSL listener requires the kopSsl* keystore "+
+			"configs in spec.brokerConfig")
+	}
+	return warnings
+}
+
+func validateAuthentication(auth *AuthenticationConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if !auth.IsEnabled() {
+		return errs
+	}
+	if auth.Provider != "" && auth.Provider != AuthenticationProviderToken {
+		errs = append(errs, field.NotSupported(path.Child("provider"), auth.Provider,
+			[]string{AuthenticationProviderToken}))
+	}
+	if auth.Token == nil {
+		return errs
+	}
+	tokenPath := path.Child("token")
+	if alg := auth.Token.SigningAlgorithm; alg != "" && !token.Algorithm(alg).IsValid() {
+		errs = append(errs, field.NotSupported(tokenPath.Child("signingAlgorithm"), alg,
+			[]string{string(token.HS256), string(token.RS256), string(token.ES256)}))
+	}
+	if auth.Token.BrokerRole != "" && auth.Token.BrokerRole == auth.Token.OperatorRole {
+		errs = append(errs, field.Invalid(tokenPath.Child("operatorRole"), auth.Token.OperatorRole,
+			"the operator and broker roles must be different"))
+	}
+	if proxyRole := auth.Token.ProxyRole; proxyRole != "" &&
+		(proxyRole == auth.Token.BrokerRole || proxyRole == auth.Token.OperatorRole) {
+		// the proxy role must not be a superuser role
+		errs = append(errs, field.Invalid(tokenPath.Child("proxyRole"), proxyRole,
+			"the proxy role must be different from the broker and operator roles"))
+	}
+	return errs
+}
+
+func validateAuthorization(spec *PulsarClusterSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	authz := spec.Authorization
+	if authz == nil {
+		return errs
+	}
+	if authz.Enabled && !spec.Authentication.IsEnabled() {
+		errs = append(errs, field.Invalid(path.Child("enabled"), authz.Enabled,
+			"authorization requires spec.authentication to be enabled"))
+	}
+	errs = append(errs, validateRoles(authz.SuperUserRoles, path.Child("superUserRoles"))...)
+	errs = append(errs, validateRoles(authz.ProxyRoles, path.Child("proxyRoles"))...)
+	if authz.Provider != "" && !javaClassNameRegex.MatchString(authz.Provider) {
+		errs = append(errs, field.Invalid(path.Child("provider"), authz.Provider,
+			"must be a fully qualified java class name"))
+	}
+	return errs
+}
+
+func validateExternalAccess(spec *PulsarClusterSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	external := spec.ExternalAccess
+	if !external.IsEnabled() {
+		return errs
+	}
+	if v := spec.Version(); !v.Supports(version.AdvertisedListeners) {
+		errs = append(errs, field.Invalid(path.Child("enabled"), external.Enabled,
+			fmt.Sprintf("the advertised listeners are not supported by pulsar %s", v)))
+	}
+	if name := external.ListenerName; name != "" {
+		if !listenerNameRegex.MatchString(name) {
+			errs = append(errs, field.Invalid(path.Child("listenerName"), name,
+				"must start with a letter and contain only letters, digits, '_' or '-'"))
+		} else if name == InternalListenerName {
+			errs = append(errs, field.Invalid(path.Child("listenerName"), name,
+				"is reserved for the in-cluster listener"))
+		}
+	}
+	return errs
+}
+
+// validateKop makes sure an enabled KoP has a nar to load and a listener to open
+func validateKop(spec *PulsarClusterSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if !spec.KOP.Enabled {
+		return errs
+	}
+	if spec.KOP.NarURL == "" {
+		errs = append(errs, field.Required(path.Child("narURL"),
+			"the pulsar images don't bundle the KoP protocol handler nar"))
+	} else if u, err := url.Parse(spec.KOP.NarURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("narURL"), spec.KOP.NarURL, "must be an http:// or https:// URL"))
+	}
+	if spec.KOP.PlainTextPort <= 0 && !spec.IsKopSecuredPortEnabled() {
+		errs = append(errs, field.Invalid(path.Child("plainTextPort"), spec.KOP.PlainTextPort,
+			"either the plain text port or the secured port with spec.tls enabled is required"))
+	}
+	return errs
+}
+
+// validateAutoscaling validates the replica range and the targets of the broker autoscaler
+func validateAutoscaling(spec *PulsarClusterSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	autoscaling := spec.Autoscaling
+	if !autoscaling.IsEnabled() {
+		return errs
+	}
+	if autoscaling.MaxReplicas < 1 {
+		errs = append(errs, field.Invalid(path.Child("maxReplicas"), autoscaling.MaxReplicas, "must be at least 1"))
+	}
+	if minReplicas := autoscaling.MinReplicas; minReplicas != nil {
+		if *minReplicas < 1 {
+			errs = append(errs, field.Invalid(path.Child("minReplicas"), *minReplicas, "must be at least 1"))
+		} else if *minReplicas > autoscaling.MaxReplicas {
+			errs = append(errs, field.Invalid(path.Child("minReplicas"), *minReplicas,
+				fmt.Sprintf("must not be greater than the maxReplicas: %d", autoscaling.MaxReplicas)))
+		}
+	}
+	targets := []struct {
+		name  string
+		value *int32
+	}{
+		{"targetCPUUtilizationPercentage", autoscaling.TargetCPUUtilizationPercentage},
+		{"targetMemoryUtilizationPercentage", autoscaling.TargetMemoryUtilizationPercentage},
+	}
+	for _, target := range targets {
+		if target.value != nil && *target.value < 1 {
+			errs = append(errs, field.Invalid(path.Child(target.name), *target.value, "must be at least 1"))
+		}
+	}
+	if !spec.Drain.IsPreStopHookEnabled() {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "drain", "preStopHook"),
+			"the brokers removed by the autoscaler are only drained by the preStop hook"))
+	}
+	if spec.MetadataStore != nil && spec.MetadataStore.Type == MetadataStoreRocksDB {
+		errs = append(errs, field.Forbidden(path.Child("enabled"),
+			"the rocksdb metadata store is local to the broker; it requires a single broker"))
+	}
+	return errs
+}
+
+// validateMonitoring validates the scrape interval and the alert thresholds
+func validateMonitoring(monitoring *MonitoringConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if !monitoring.IsEnabled() {
+		return errs
+	}
+	if monitoring.Interval != "" && !prometheusDurationRegex.MatchString(monitoring.Interval) {
+		errs = append(errs, field.Invalid(path.Child("interval"), monitoring.Interval, "must be a prometheus duration e.g. 30s"))
+	}
+	alerts := monitoring.Alerts
+	if alerts == nil {
+		return errs
+	}
+	thresholds := []struct {
+		name  string
+		value *int64
+	}{{"backlogThreshold", alerts.BacklogThreshold}, {"topicsPerBroker", alerts.TopicsPerBroker}}
+	for _, threshold := range thresholds {
+		if threshold.value != nil && *threshold.value < 1 {
+			errs = append(errs, field.Invalid(path.Child("alerts", threshold.name), *threshold.value, "must be at least 1"))
+		}
+	}
+	if latency := alerts.WriteLatencyMillis; latency != nil {
+		var supported []string
+		valid := false
+		for _, bucket := range writeLatencyBucketMillis {
+			valid = valid || bucket == *latency
+			supported = append(supported, strconv.FormatInt(bucket, 10))
+		}
+		if !valid {
+			errs = append(errs, field.NotSupported(path.Child("alerts", "writeLatencyMillis"), *latency, supported))
+		}
+	}
+	return errs
+}
+
+// validateMetadataStore validates the metadata store URLs in the format of the backend
+//
+//nolint:cyclop
+func validateMetadataStore(spec *PulsarClusterSpec, specPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.ZookeeperRef != nil {
+		if spec.ZookeeperRef.Name == "" {
+			errs = append(errs, field.Required(specPath.Child("zookeeperRef", "name"), "the zookeeper cluster name is required"))
+		}
+		if spec.ZookeeperRef.ChrootPath != "" && !strings.HasPrefix(spec.ZookeeperRef.ChrootPath, "/") {
+			errs = append(errs, field.Invalid(specPath.Child("zookeeperRef", "chrootPath"),
+				spec.ZookeeperRef.ChrootPath, "must be an absolute znode path"))
+		}
+		if spec.MetadataStore != nil || spec.ZookeeperServers != "" || spec.ConfigurationStoreServers != "" {
+			errs = append(errs, field.Forbidden(specPath.Child("zookeeperRef"),
+				"the referenced zookeeper cluster cannot be combined with metadataStore or zookeeperServers"))
+		}
+		return errs
+	}
+	if spec.MetadataStore == nil {
+		// a spec not migrated yet by the webhook
+		if err := validateZookeeperConnectString(spec.ZookeeperServers); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("zookeeperServers"), spec.ZookeeperServers,
+				fmt.Sprintf("%s; either metadataStore or the deprecated zookeeperServers is required", err)))
+		}
+		if spec.ConfigurationStoreServers != "" {
+			if err := validateMetadataStoreURL(MetadataStoreZookeeper, spec.ConfigurationStoreServers); err != nil {
+				errs = append(errs, field.Invalid(specPath.Child("configurationStoreServers"),
+					spec.ConfigurationStoreServers, err.Error()))
+			}
+		}
+		return errs
+	}
+	path := specPath.Child("metadataStore")
+	store := spec.MetadataStore
+	if spec.ZookeeperServers != "" || spec.ConfigurationStoreServers != "" {
+		errs = append(errs, field.Forbidden(specPath.Child("zookeeperServers"),
+			"the deprecated zookeeper fields cannot be combined with metadataStore"))
+	}
+	if err := validateMetadataStoreURL(store.Type, store.URL); err != nil {
+		errs = append(errs, field.Invalid(path.Child("url"), store.URL, err.Error()))
+	}
+	if store.ConfigurationURL != "" {
+		if err := validateMetadataStoreURL(store.Type, store.ConfigurationURL); err != nil {
+			errs = append(errs, field.Invalid(path.Child("configurationURL"), store.ConfigurationURL, err.Error()))
+		}
+	}
+	v := spec.Version()
+	switch {
+	case store.Type == MetadataStoreOxia && !v.Supports(version.OxiaMetadataStore),
+		store.Type != MetadataStoreZookeeper && !v.Supports(version.MetadataStoreURL):
+		errs = append(errs, field.Invalid(path.Child("type"), store.Type,
+			fmt.Sprintf("the %s metadata store is not supported by pulsar %s", store.Type, v)))
+	}
+	if store.Type == MetadataStoreRocksDB && spec.Size != nil && *spec.Size > 1 {
+		errs = append(errs, field.Invalid(specPath.Child("size"), *spec.Size,
+			"the rocksdb metadata store is local to the broker; it requires a single broker"))
+	}
+	if store.TLS.IsEnabled() {
+		if store.Type != MetadataStoreZookeeper {
+			errs = append(errs, field.Forbidden(path.Child("tls"),
+				fmt.Sprintf("TLS is not supported for the %s metadata store", store.Type)))
+		}
+		if store.TLS.SecretName == "" {
+			errs = append(errs, field.Required(path.Child("tls", "secretName"), "the TLS secret is required"))
+		}
+	}
+	return errs
+}
+
+// validateBookkeeper validates the bookkeeper cluster given either by URI or by reference
+func validateBookkeeper(spec *PulsarClusterSpec, specPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.BookkeeperRef == nil {
+		if err := validateBookkeeperURI(spec.BookkeeperClusterUri); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("bookkeeperClusterUri"), spec.BookkeeperClusterUri,
+				fmt.Sprintf("%s; either bookkeeperClusterUri or bookkeeperRef is required", err)))
+		}
+		return errs
+	}
+	if spec.BookkeeperRef.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("bookkeeperRef", "name"), "the bookkeeper cluster name is required"))
+	}
+	if spec.BookkeeperClusterUri != "" {
+		errs = append(errs, field.Forbidden(specPath.Child("bookkeeperRef"),
+			"the referenced bookkeeper cluster cannot be combined with bookkeeperClusterUri"))
+	}
+	return errs
+}
+
+// validateMetadataStoreURL validates the address of the metadata store with or without the backend prefix
+func validateMetadataStoreURL(storeType MetadataStoreType, address string) error {
+	if address == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	switch storeType {
+	case MetadataStoreEtcd:
+		for _, endpoint := range strings.Split(strings.TrimPrefix(address, "etcd:"), ",") {
+			if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("the etcd endpoint %q must be an http:// or https:// URL", endpoint)
+			}
+		}
+	case MetadataStoreRocksDB:
+		path := strings.TrimPrefix(address, "rocksdb:")
+		if !strings.HasPrefix(path, RocksDBMetadataStoreDirectory+"/") {
+			return fmt.Errorf("must be a directory under the broker data directory %s", RocksDBMetadataStoreDirectory)
+		}
+	case MetadataStoreOxia:
+		hostPort := strings.SplitN(strings.TrimPrefix(address, "oxia://"), "/", 2)[0]
+		return validateHostPorts([]string{hostPort})
+	default:
+		err := validateZookeeperConnectString(address)
+		if err != nil && strings.HasPrefix(address, "zk:") {
+			// the address may also be specified as a `zk:` URL
+			err = validateZookeeperConnectString(strings.TrimPrefix(address, "zk:"))
+		}
+		return err
+	}
+	return nil
+}
+
+// validateStorage validates that the set replication values hold ensemble >= write quorum >= ack quorum
+func validateStorage(spec *PulsarClusterSpec, specPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.Storage == nil || spec.Storage.Replication == nil {
+		return errs
+	}
+	r := spec.Storage.Replication
+	path := specPath.Child("storage", "replication")
+	values := []struct {
+		name  string
+		value *int32
+	}{{"ensembleSize", r.EnsembleSize}, {"writeQuorum", r.WriteQuorum}, {"ackQuorum", r.AckQuorum}}
+	for i, v := range values {
+		if v.value == nil {
+			continue
+		}
+		if *v.value < 1 {
+			errs = append(errs, field.Invalid(path.Child(v.name), *v.value, "must be at least 1"))
+			continue
+		}
+		for _, larger := range values[:i] {
+			if larger.value != nil && *larger.value < *v.value {
+				errs = append(errs, field.Invalid(path.Child(v.name), *v.value,
+					fmt.Sprintf("must not be greater than the %s: %d", larger.name, *larger.value)))
+			}
+		}
+	}
+	return errs
+}
+
+// validateTieredStorage validates the fields required by the offload driver
+//
+//nolint:cyclop
+func validateTieredStorage(spec *PulsarClusterSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	storage := spec.TieredStorage
+	if !storage.IsEnabled() {
+		return errs
+	}
+	driver := storage.Driver
+	switch driver {
+	case "":
+		return append(errs, field.Required(path.Child("driver"), "the offload driver is required"))
+	case OffloadDriverFilesystem:
+		if storage.Filesystem == nil || storage.Filesystem.ClaimName == "" {
+			errs = append(errs, field.Required(path.Child("filesystem", "claimName"),
+				"the filesystem driver requires the claim of the offload volume"))
+		}
+		for _, f := range []struct{ name, value string }{
+			{"bucket", storage.Bucket},
+			{"region", storage.Region},
+			{"endpoint", storage.Endpoint},
+			{"credentialsSecretName", storage.CredentialsSecretName},
+		} {
+			if f.value != "" {
+				errs = append(errs, field.Forbidden(path.Child(f.name), "not supported by the filesystem driver"))
+			}
+		}
+	case OffloadDriverS3, OffloadDriverGCS, OffloadDriverAzureBlob:
+		if storage.Bucket == "" {
+			errs = append(errs, field.Required(path.Child("bucket"), fmt.Sprintf("required by the %s driver", driver)))
+		}
+		if storage.Filesystem != nil {
+			errs = append(errs, field.Forbidden(path.Child("filesystem"), fmt.Sprintf("not supported by the %s driver", driver)))
+		}
+	default:
+		return append(errs, field.NotSupported(path.Child("driver"), driver, []string{string(OffloadDriverS3),
+			string(OffloadDriverGCS), string(OffloadDriverAzureBlob), string(OffloadDriverFilesystem)}))
+	}
+	if driver == OffloadDriverS3 && storage.Region == "" && storage.Endpoint == "" {
+		errs = append(errs, field.Required(path.Child("region"), "either the region or the endpoint is required"))
+	}
+	if driver == OffloadDriverGCS && storage.Region == "" {
+		errs = append(errs, field.Required(path.Child("region"), "required by the google-cloud-storage driver"))
+	}
+	if (driver == OffloadDriverGCS || driver == OffloadDriverAzureBlob) && storage.CredentialsSecretName == "" {
+		errs = append(errs, field.Required(path.Child("credentialsSecretName"),
+			fmt.Sprintf("the %s driver requires the credentials secret", driver)))
+	}
+	if storage.Endpoint != "" {
+		if u, err := url.Parse(storage.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, field.Invalid(path.Child("endpoint"), storage.Endpoint, "must be an absolute URL"))
+		}
+	}
+	if storage.SizeThreshold != nil && storage.SizeThreshold.Sign() < 0 {
+		errs = append(errs, field.Invalid(path.Child("sizeThreshold"), storage.SizeThreshold.String(), "must not be negative"))
+	}
+	if storage.TimeThreshold != nil {
+		if storage.TimeThreshold.Duration < 0 {
+			errs = append(errs, field.Invalid(path.Child("timeThreshold"), storage.TimeThreshold.String(), "must not be negative"))
+		} else if v := spec.Version(); !v.Supports(version.OffloadTimeThreshold) {
+			errs = append(errs, field.Invalid(path.Child("timeThreshold"), storage.TimeThreshold.String(),
+				fmt.Sprintf("the offload time threshold is not supported by pulsar %s", v)))
+		}
+	}
+	if storage.DeletionLag != nil && storage.DeletionLag.Duration < 0 {
+		errs = append(errs, field.Invalid(path.Child("deletionLag"), storage.DeletionLag.String(), "must not be negative"))
+	}
+	return errs
+}
+
+// validateReplication validates the peers are uniquely named and defined either by reference or by URLs
+func validateReplication(spec *PulsarClusterSpec, localName string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.Replication == nil {
+		return errs
+	}
+	names := map[string]bool{}
+	for i, peer := range spec.Replication.Peers {
+		peerPath := path.Child("peers").Index(i)
+		switch {
+		case peer.Name == "":
+			errs = append(errs, field.Required(peerPath.Child("name"), "the peer cluster name is required"))
+		case strings.ContainsAny(peer.Name, "/ "):
+			errs = append(errs, field.Invalid(peerPath.Child("name"), peer.Name, "must not contain '/' or spaces"))
+		case peer.Name == localName:
+			errs = append(errs, field.Invalid(peerPath.Child("name"), peer.Name, "the cluster cannot be its own peer"))
+		case names[peer.Name]:
+			errs = append(errs, field.Duplicate(peerPath.Child("name"), peer.Name))
+		}
+		names[peer.Name] = true
+		errs = append(errs, validateReplicationPeer(&peer, peerPath)...)
+	}
+	return errs
+}
+
+func validateReplicationPeer(peer *ReplicationPeer, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	urls := []struct {
+		name, value, scheme string
+	}{
+		{"serviceURL", peer.ServiceURL, "http"},
+		{"serviceURLTLS", peer.ServiceURLTLS, "https"},
+		{"brokerServiceURL", peer.BrokerServiceURL, "pulsar"},
+		{"brokerServiceURLTLS", peer.BrokerServiceURLTLS, "pulsar+ssl"},
+	}
+	if peer.ClusterRef != nil {
+		if peer.ClusterRef.Name == "" {
+			errs = append(errs, field.Required(path.Child("clusterRef", "name"), "the PulsarCluster name is required"))
+		}
+		for _, u := range urls {
+			if u.value != "" {
+				errs = append(errs, field.Forbidden(path.Child(u.name), "the URLs of a referenced cluster are derived"))
+			}
+		}
+		if peer.AuthenticationSecretName != "" {
+			errs = append(errs, field.Forbidden(path.Child("authenticationSecretName"),
+				"the broker token of the referenced cluster is used"))
+		}
+		return errs
+	}
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u.value); err != nil || parsed.Host == "" || parsed.Scheme != u.scheme {
+			errs = append(errs, field.Invalid(path.Child(u.name), u.value, fmt.Sprintf("must be a %s:// URL", u.scheme)))
+		}
+	}
+	if peer.ServiceURL == "" && peer.ServiceURLTLS == "" {
+		errs = append(errs, field.Required(path.Child("serviceURL"), "either a clusterRef or a service URL is required"))
+	}
+	if peer.BrokerServiceURL == "" && peer.BrokerServiceURLTLS == "" {
+		errs = append(errs, field.Required(path.Child("brokerServiceURL"),
+			"either a clusterRef or a broker service URL is required"))
+	}
+	return errs
+}
+
+// validateRoles validates the roles rendered as a comma separated broker config
+func validateRoles(roles []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[string]bool{}
+	for i, role := range roles {
+		switch {
+		case strings.TrimSpace(role) == "":
+			errs = append(errs, field.Required(path.Index(i), "the role must not be empty"))
+		case strings.ContainsAny(role, ", "):
+			errs = append(errs, field.Invalid(path.Index(i), role, "the role must not contain commas or spaces"))
+		case seen[role]:
+			errs = append(errs, field.Duplicate(path.Index(i), role))
+		}
+		seen[role] = true
+	}
+	return errs
+}
+
+// validateAuthenticationUpdate forbids the changes that invalidate the keys generated by the operator
+func validateAuthenticationUpdate(newAuth, oldAuth *AuthenticationConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if !newAuth.IsEnabled() || !oldAuth.IsEnabled() || newAuth.Token == nil || oldAuth.Token == nil {
+		return errs
+	}
+	if newAuth.Token.KeySecretName == "" && oldAuth.Token.KeySecretName == "" &&
+		newAuth.Token.SigningAlgorithm != oldAuth.Token.SigningAlgorithm {
+		errs = append(errs, field.Forbidden(path.Child("token", "signingAlgorithm"),
+			"the algorithm of the generated signing keys cannot be changed"))
+	}
+	return errs
+}
+
+func (in *PulsarCluster) authorizationWarnings() admission.Warnings {
+	var warnings admission.Warnings
+	authz := in.Spec.Authorization
+	if authz == nil {
+		return warnings
+	}
+	for _, role := range authz.ProxyRoles {
+		if containsString(authz.SuperUserRoles, role) {
+			warnings = append(warnings, fmt.Sprintf("spec.authorization.proxyRoles: %s is also a superuser; "+
+				"the clients of the proxy get the superuser permissions", role))
+		}
+	}
+	for _, key := range authorizationBrokerConfigs {
+		if in.Spec.hasBrokerConfig(key) {
+			warnings = append(warnings, fmt.Sprintf("spec.brokerConfig.%s: it overrides the value "+
+				"rendered from spec.authorization", key))
+		}
+	}
+	return warnings
+}
+
+// validateZookeeperConnectString validates the connect string in the format `host:port[,host:port][/chroot]`
+func validateZookeeperConnectString(connectString string) error {
+	if connectString == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	servers := connectString
+	if i := strings.Index(connectString, "/"); i >= 0 {
+		servers = connectString[:i]
+	}
+	return validateHostPorts(strings.Split(servers, ","))
+}
+
+// validateBookkeeperURI validates the metadata service URI in the format `scheme://host:port[;host:port]/path`
+func validateBookkeeperURI(uri string) error {
+	parts := strings.SplitN(uri, "://", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("must be in the format scheme://host:port[;host:port]/ledgers-root-path")
+	}
+	if !containsString(bookkeeperURISchemes, parts[0]) {
+		return fmt.Errorf("unsupported scheme: %q; expected one of %v", parts[0], bookkeeperURISchemes)
+	}
+	i := strings.Index(parts[1], "/")
+	if i < 0 || i == len(parts[1])-1 {
+		return fmt.Errorf("the ledgers root path is missing")
+	}
+	return validateHostPorts(strings.FieldsFunc(parts[1][:i], func(r rune) bool {
+		return r == ';' || r == ','
+	}))
+}
+
+func validateHostPorts(hostPorts []string) error {
+	if len(hostPorts) == 0 {
+		return fmt.Errorf("at least one host:port is required")
+	}
+	for _, hostPort := range hostPorts {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(hostPort))
+		if err != nil {
+			return fmt.Errorf("invalid host:port %q: %w", hostPort, err)
+		}
+		if host == "" {
+			return fmt.Errorf("invalid host:port %q: missing host", hostPort)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("invalid host:port %q: invalid port", hostPort)
+		}
+	}
+	return nil
+}
+
+// validatePorts makes sure no two enabled broker ports collide
+func validatePorts(spec *PulsarClusterSpec, specPath *field.Path) field.ErrorList {
+	if spec.Ports == nil {
+		return nil
+	}
+	type namedPort struct {
+		path *field.Path
+		port int32
+	}
+	ports := []namedPort{
+		{specPath.Child("ports", "client"), spec.Ports.Client},
+		{specPath.Child("ports", "clientTLS"), spec.Ports.ClientTLS},
+		{specPath.Child("ports", "web"), spec.Ports.Web},
+		{specPath.Child("ports", "WebTLS"), spec.Ports.WebTLS},
+	}
+	if spec.KOP.Enabled {
+		ports = append(ports,
+			namedPort{specPath.Child("kop", "plainTextPort"), spec.KOP.PlainTextPort},
+			namedPort{specPath.Child("kop", "SecuredPort"), spec.KOP.SecuredPort})
+	}
+	var errs field.ErrorList
+	used := map[int32]*field.Path{}
+	for _, p := range ports {
+		if p.port <= 0 {
+			continue
+		}
+		if other, ok := used[p.port]; ok {
+			errs = append(errs, field.Duplicate(p.path, fmt.Sprintf("%d (already used by %s)", p.port, other)))
+			continue
+		}
+		used[p.port] = p.path
+	}
+	return errs
+}
+
+func validatePersistenceUpdate(newPvc, oldPvc *v1.PersistentVolumeClaimSpec, path *field.Path) field.ErrorList {
+	if newPvc == nil || oldPvc == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	if storageClassName(newPvc) != storageClassName(oldPvc) {
+		errs = append(errs, field.Forbidden(path.Child("storageClassName"),
+			"the storage class of the broker volumes cannot be changed"))
+	}
+	newSize := newPvc.Resources.Requests[v1.ResourceStorage]
+	oldSize := oldPvc.Resources.Requests[v1.ResourceStorage]
+	if newSize.Cmp(oldSize) < 0 {
+		errs = append(errs, field.Forbidden(path.Child("resources", "requests", "storage"),
+			fmt.Sprintf("the broker volumes cannot be shrunk from %s to %s", oldSize.String(), newSize.String())))
+	}
+	return errs
+}
+
+func storageClassName(pvc *v1.PersistentVolumeClaimSpec) string {
+	if pvc.StorageClassName == nil {
+		return ""
+	}
+	return *pvc.StorageClassName
+}
+
+// hasBrokerConfig checks whether the broker config is set with or without the env prefix of the image
+func (in *PulsarClusterSpec) hasBrokerConfig(key string) bool {
+	for k := range in.BrokerConfig {
+		if strings.TrimPrefix(k, brokerConfigEnvPrefix) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func (in *PulsarCluster) storageWarnings() admission.Warnings {
+	var warnings admission.Warnings
+	var config *LedgerReplicationConfig
+	if in.Spec.Storage != nil {
+		config = in.Spec.Storage.Replication
+	}
+	if config == nil && in.Spec.BookkeeperRef == nil {
+		warnings = append(warnings, "spec.storage.replication: the bookie count is unknown without a bookkeeperRef; "+
+			"the ledger entries are written to a single bookie and a bookie failure loses data")
+	} else if r := config.resolve(0); config != nil && !r.IsReplicated() {
+		warnings = append(warnings, fmt.Sprintf("spec.storage.replication: the ledger entries are not replicated "+
+			"with a write quorum of %d and an ack quorum of %d; a single bookie failure loses data", r.WriteQuorum, r.AckQuorum))
+	}
+	for _, key := range replicationBrokerConfigs {
+		if in.Spec.hasBrokerConfig(key) {
+			warnings = append(warnings, fmt.Sprintf("spec.brokerConfig.%s: it overrides the value "+
+				"rendered from spec.storage.replication", key))
+		}
+	}
+	return warnings
+}
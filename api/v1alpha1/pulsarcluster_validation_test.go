@@ -0,0 +1,989 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+	"testing"
+)
+
+func TestValidateAuthentication(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		token   TokenAuthentication
+		wantErr bool
+	}{
+		{name: "default roles", token: TokenAuthentication{}},
+		{name: "same broker and operator roles", token: TokenAuthentication{BrokerRole: "admin", OperatorRole: "admin"}, wantErr: true},
+		{name: "proxy role is the broker role", token: TokenAuthentication{ProxyRole: defaultTokenBrokerRole}, wantErr: true},
+		{name: "proxy role is the operator role", token: TokenAuthentication{ProxyRole: defaultTokenOperatorRole}, wantErr: true},
+		{name: "custom proxy role", token: TokenAuthentication{ProxyRole: "edge"}},
+	}
+	for _, tt := range tests {
+		tokenSpec := tt.token
+		auth := &AuthenticationConfig{Enabled: true, Token: &tokenSpec}
+		auth.setDefaults()
+		errs := validateAuthentication(auth, field.NewPath("spec", "authentication"))
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateKop(t *testing.T) {
+	t.Parallel()
+	narURL := "https://repo.example.com/pulsar-protocol-handler-kafka.nar"
+	tests := []struct {
+		name    string
+		kop     KOP
+		tls     bool
+		wantErr bool
+	}{
+		{name: "disabled", kop: KOP{}},
+		{name: "valid", kop: KOP{Enabled: true, NarURL: narURL, PlainTextPort: 9092}},
+		{name: "no nar", kop: KOP{Enabled: true, PlainTextPort: 9092}, wantErr: true},
+		{name: "invalid nar URL", kop: KOP{Enabled: true, NarURL: "file:///kop.nar", PlainTextPort: 9092}, wantErr: true},
+		{name: "secured port only without TLS", kop: KOP{Enabled: true, NarURL: narURL, PlainTextPort: -1, SecuredPort: 9093}, wantErr: true},
+		{name: "secured port only with TLS", kop: KOP{Enabled: true, NarURL: narURL, PlainTextPort: -1, SecuredPort: 9093}, tls: true},
+	}
+	for _, tt := range tests {
+		spec := &PulsarClusterSpec{KOP: tt.kop}
+		if tt.tls {
+			spec.TLS = &TLSConfig{Enabled: true}
+		}
+		errs := validateKop(spec, field.NewPath("spec", "kop"))
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateAuthorization(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    PulsarClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "authorization without authentication",
+			spec: PulsarClusterSpec{
+				Authorization: &AuthorizationConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid authorization",
+			spec: PulsarClusterSpec{
+				Authentication: &AuthenticationConfig{Enabled: true},
+				Authorization: &AuthorizationConfig{
+					Enabled:        true,
+					SuperUserRoles: []string{"admin"},
+					ProxyRoles:     []string{"proxy"},
+					Provider:       "org.apache.pulsar.broker.authorization.PulsarAuthorizationProvider",
+				},
+			},
+		},
+		{
+			name: "role with a comma",
+			spec: PulsarClusterSpec{
+				Authentication: &AuthenticationConfig{Enabled: true},
+				Authorization:  &AuthorizationConfig{Enabled: true, SuperUserRoles: []string{"a,b"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate roles",
+			spec: PulsarClusterSpec{
+				Authentication: &AuthenticationConfig{Enabled: true},
+				Authorization:  &AuthorizationConfig{Enabled: true, ProxyRoles: []string{"proxy", "proxy"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid provider",
+			spec: PulsarClusterSpec{
+				Authentication: &AuthenticationConfig{Enabled: true},
+				Authorization:  &AuthorizationConfig{Enabled: true, Provider: "not a class"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		errs := validateAuthorization(&tt.spec, nil)
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateExternalAccess(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    PulsarClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "disabled on an old version",
+			spec: PulsarClusterSpec{PulsarVersion: "2.5.0", ExternalAccess: &ExternalAccessConfig{}},
+		},
+		{
+			name:    "unsupported version",
+			spec:    PulsarClusterSpec{PulsarVersion: "2.5.0", ExternalAccess: &ExternalAccessConfig{Enabled: true}},
+			wantErr: true,
+		},
+		{
+			name: "valid listener name",
+			spec: PulsarClusterSpec{
+				PulsarVersion:  "2.10.1",
+				ExternalAccess: &ExternalAccessConfig{Enabled: true, ListenerName: "public_lb"},
+			},
+		},
+		{
+			name: "reserved listener name",
+			spec: PulsarClusterSpec{
+				PulsarVersion:  "2.10.1",
+				ExternalAccess: &ExternalAccessConfig{Enabled: true, ListenerName: InternalListenerName},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid listener name",
+			spec: PulsarClusterSpec{
+				PulsarVersion:  "2.10.1",
+				ExternalAccess: &ExternalAccessConfig{Enabled: true, ListenerName: "1:lb"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		errs := validateExternalAccess(&tt.spec, nil)
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateTieredStorage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage TieredStorageConfig
+		wantErr bool
+	}{
+		{
+			name:    "missing driver",
+			storage: TieredStorageConfig{Enabled: true},
+			wantErr: true,
+		},
+		{
+			name:    "s3 with a MinIO endpoint",
+			storage: TieredStorageConfig{Enabled: true, Driver: OffloadDriverS3, Bucket: "b", Endpoint: "http://minio:9000"},
+		},
+		{
+			name:    "s3 without region or endpoint",
+			storage: TieredStorageConfig{Enabled: true, Driver: OffloadDriverS3, Bucket: "b"},
+			wantErr: true,
+		},
+		{
+			name:    "s3 without bucket",
+			storage: TieredStorageConfig{Enabled: true, Driver: OffloadDriverS3, Region: "eu-west-1"},
+			wantErr: true,
+		},
+		{
+			name:    "relative endpoint",
+			storage: TieredStorageConfig{Enabled: true, Driver: OffloadDriverS3, Bucket: "b", Endpoint: "minio:9000"},
+			wantErr: true,
+		},
+		{
+			name:    "gcs without credentials",
+			storage: TieredStorageConfig{Enabled: true, Driver: OffloadDriverGCS, Bucket: "b", Region: "europe-west3"},
+			wantErr: true,
+		},
+		{
+			name: "azureblob",
+			storage: TieredStorageConfig{
+				Enabled: true, Driver: OffloadDriverAzureBlob, Bucket: "c", CredentialsSecretName: "azure",
+			},
+		},
+		{
+			name: "filesystem",
+			storage: TieredStorageConfig{
+				Enabled: true, Driver: OffloadDriverFilesystem, Filesystem: &FilesystemOffloadConfig{ClaimName: "offload"},
+			},
+		},
+		{
+			name:    "filesystem without claim",
+			storage: TieredStorageConfig{Enabled: true, Driver: OffloadDriverFilesystem},
+			wantErr: true,
+		},
+		{
+			name: "filesystem with bucket",
+			storage: TieredStorageConfig{
+				Enabled: true, Driver: OffloadDriverFilesystem, Bucket: "b",
+				Filesystem: &FilesystemOffloadConfig{ClaimName: "offload"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		spec := PulsarClusterSpec{PulsarVersion: "2.10.1", TieredStorage: &tt.storage}
+		errs := validateTieredStorage(&spec, nil)
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateReplication(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		peers   []ReplicationPeer
+		wantErr bool
+	}{
+		{
+			name:  "referenced peer",
+			peers: []ReplicationPeer{{Name: "eu-west", ClusterRef: &ClusterReference{Name: "eu-west"}}},
+		},
+		{
+			name: "explicit peer",
+			peers: []ReplicationPeer{{
+				Name:                     "us-east",
+				ServiceURL:               "http://pulsar.us-east:8080",
+				BrokerServiceURLTLS:      "pulsar+ssl://pulsar.us-east:6651",
+				AuthenticationSecretName: "us-east-token",
+			}},
+		},
+		{
+			name:    "local cluster",
+			peers:   []ReplicationPeer{{Name: "local", ClusterRef: &ClusterReference{Name: "local"}}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate peers",
+			peers: []ReplicationPeer{
+				{Name: "eu-west", ClusterRef: &ClusterReference{Name: "eu-west"}},
+				{Name: "eu-west", ClusterRef: &ClusterReference{Name: "eu-west-2"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "reference with URLs",
+			peers: []ReplicationPeer{{
+				Name: "eu-west", ClusterRef: &ClusterReference{Name: "eu-west"}, ServiceURL: "http://eu-west:8080",
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "missing broker service URL",
+			peers:   []ReplicationPeer{{Name: "us-east", ServiceURL: "http://pulsar.us-east:8080"}},
+			wantErr: true,
+		},
+		{
+			name: "wrong scheme",
+			peers: []ReplicationPeer{{
+				Name: "us-east", ServiceURL: "pulsar://pulsar.us-east:8080", BrokerServiceURL: "pulsar://pulsar.us-east:6650",
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		spec := PulsarClusterSpec{Replication: &ReplicationConfig{Peers: tt.peers}}
+		errs := validateReplication(&spec, "local", nil)
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateClusterNameUpdate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		oldName string
+		newName string
+		wantErr bool
+	}{
+		{name: "defaulted to the object name", oldName: "", newName: "sample"},
+		{name: "unchanged", oldName: "eu-west", newName: "eu-west"},
+		{name: "renamed", oldName: "eu-west", newName: "us-east", wantErr: true},
+		{name: "renamed from the object name", oldName: "", newName: "us-east", wantErr: true},
+	}
+	for _, tt := range tests {
+		old := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+		old.Spec.ClusterName = tt.oldName
+		cluster := old.DeepCopy()
+		cluster.Spec.ClusterName = tt.newName
+		err := cluster.validateUpdate(old)
+		if got := err != nil; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestClusterNameDefault(t *testing.T) {
+	t.Parallel()
+	cluster := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+	cluster.SetSpecDefaults()
+	if cluster.Spec.ClusterName != "sample" || cluster.PulsarClusterName() != "sample" {
+		t.Errorf("expected the cluster name to default to the object name; got: %q", cluster.Spec.ClusterName)
+	}
+	cluster.Spec.ClusterName = "eu-west"
+	if labels := cluster.GenerateLabels(false); labels[ClusterNameLabel] != "eu-west" {
+		t.Errorf("expected the cluster name label; got: %v", labels)
+	}
+}
+
+func TestValidateMetadataStore(t *testing.T) {
+	t.Parallel()
+	one, three := int32(1), int32(3)
+	tests := []struct {
+		name    string
+		version string
+		size    *int32
+		store   *MetadataStoreConfig
+		legacy  string
+		wantErr bool
+	}{
+		{name: "legacy zookeeper", version: "2.9.0", legacy: "zk:2181"},
+		{name: "missing store", version: "2.10.1", wantErr: true},
+		{
+			name:    "zookeeper",
+			version: "2.10.1",
+			store:   &MetadataStoreConfig{Type: MetadataStoreZookeeper, URL: "zk-0.zk:2181,zk-1.zk:2181/pulsar"},
+		},
+		{
+			name:    "combined with the legacy field",
+			version: "2.10.1",
+			store:   &MetadataStoreConfig{Type: MetadataStoreZookeeper, URL: "zk:2181"},
+			legacy:  "zk:2181",
+			wantErr: true,
+		},
+		{
+			name:    "etcd",
+			version: "2.10.1",
+			store:   &MetadataStoreConfig{Type: MetadataStoreEtcd, URL: "http://etcd-0.etcd:2379,http://etcd-1.etcd:2379"},
+		},
+		{
+			name:    "etcd without scheme",
+			version: "2.10.1",
+			store:   &MetadataStoreConfig{Type: MetadataStoreEtcd, URL: "etcd:2379"},
+			wantErr: true,
+		},
+		{
+			name:    "etcd before 2.10",
+			version: "2.9.0",
+			store:   &MetadataStoreConfig{Type: MetadataStoreEtcd, URL: "http://etcd:2379"},
+			wantErr: true,
+		},
+		{
+			name:    "rocksdb",
+			version: "3.0.0",
+			size:    &one,
+			store:   &MetadataStoreConfig{Type: MetadataStoreRocksDB, URL: "/data/metadata-store"},
+		},
+		{
+			name:    "rocksdb with many brokers",
+			version: "3.0.0",
+			size:    &three,
+			store:   &MetadataStoreConfig{Type: MetadataStoreRocksDB, URL: "/data/metadata-store"},
+			wantErr: true,
+		},
+		{
+			name:    "rocksdb outside the data volume",
+			version: "3.0.0",
+			size:    &one,
+			store:   &MetadataStoreConfig{Type: MetadataStoreRocksDB, URL: "/tmp/metadata-store"},
+			wantErr: true,
+		},
+		{
+			name:    "oxia",
+			version: "3.1.0",
+			store:   &MetadataStoreConfig{Type: MetadataStoreOxia, URL: "oxia:6648/pulsar"},
+		},
+		{
+			name:    "oxia before 3.1",
+			version: "3.0.0",
+			store:   &MetadataStoreConfig{Type: MetadataStoreOxia, URL: "oxia:6648/pulsar"},
+			wantErr: true,
+		},
+		{
+			name:    "zookeeper TLS",
+			version: "3.0.0",
+			store: &MetadataStoreConfig{
+				Type: MetadataStoreZookeeper, URL: "zk:2281", TLS: &MetadataStoreTLS{Enabled: true, SecretName: "zk-tls"},
+			},
+		},
+		{
+			name:    "TLS without secret",
+			version: "3.0.0",
+			store:   &MetadataStoreConfig{Type: MetadataStoreZookeeper, URL: "zk:2281", TLS: &MetadataStoreTLS{Enabled: true}},
+			wantErr: true,
+		},
+		{
+			name:    "etcd TLS",
+			version: "3.0.0",
+			store: &MetadataStoreConfig{
+				Type: MetadataStoreEtcd, URL: "https://etcd:2379", TLS: &MetadataStoreTLS{Enabled: true, SecretName: "etcd-tls"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		spec := PulsarClusterSpec{PulsarVersion: tt.version, Size: tt.size, MetadataStore: tt.store, ZookeeperServers: tt.legacy}
+		errs := validateMetadataStore(&spec, field.NewPath("spec"))
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestMetadataStoreMigration(t *testing.T) {
+	t.Parallel()
+	cluster := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+	cluster.Spec.ZookeeperServers = "zk:2181"
+	cluster.Spec.ConfigurationStoreServers = "zk:2181"
+	cluster.SetSpecDefaults()
+	// the spec stored before the migration
+	old := cluster.DeepCopy()
+	old.Spec.MetadataStore = nil
+	old.Spec.ZookeeperServers = "zk:2181"
+	old.Spec.ConfigurationStoreServers = "zk:2181"
+	store := cluster.Spec.MetadataStore
+	if store == nil || store.Type != MetadataStoreZookeeper || store.URL != "zk:2181" || store.ConfigurationURL != "" {
+		t.Fatalf("expected the zookeeper fields to be migrated; got: %+v", store)
+	}
+	if cluster.Spec.ZookeeperServers != "" || cluster.Spec.ConfigurationStoreServers != "" {
+		t.Errorf("expected the deprecated fields to be cleared; got: %+v", cluster.Spec)
+	}
+	if err := cluster.validateUpdate(old); err != nil {
+		t.Errorf("expected the migration to be allowed; got: %v", err)
+	}
+	moved := cluster.DeepCopy()
+	moved.Spec.MetadataStore = &MetadataStoreConfig{Type: MetadataStoreEtcd, URL: "http://etcd:2379"}
+	if err := moved.validateUpdate(cluster); err == nil {
+		t.Errorf("expected the metadata store change to be rejected")
+	}
+}
+
+func TestValidateDependencyReferences(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    PulsarClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "references",
+			spec: PulsarClusterSpec{
+				ZookeeperRef:  &ZookeeperReference{Name: "zk", ChrootPath: "/pulsar"},
+				BookkeeperRef: &BookkeeperReference{Name: "bk"},
+			},
+		},
+		{
+			name: "zookeeper reference with a bookkeeper URI",
+			spec: PulsarClusterSpec{
+				ZookeeperRef:         &ZookeeperReference{Name: "zk"},
+				BookkeeperClusterUri: "zk+null://zk:2181/ledgers",
+			},
+		},
+		{
+			name: "zookeeper reference with servers",
+			spec: PulsarClusterSpec{
+				ZookeeperRef:         &ZookeeperReference{Name: "zk"},
+				ZookeeperServers:     "zk:2181",
+				BookkeeperClusterUri: "zk+null://zk:2181/ledgers",
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative chroot",
+			spec: PulsarClusterSpec{
+				ZookeeperRef:  &ZookeeperReference{Name: "zk", ChrootPath: "pulsar"},
+				BookkeeperRef: &BookkeeperReference{Name: "bk"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bookkeeper reference with URI",
+			spec: PulsarClusterSpec{
+				ZookeeperServers:     "zk:2181",
+				BookkeeperRef:        &BookkeeperReference{Name: "bk"},
+				BookkeeperClusterUri: "zk+null://zk:2181/ledgers",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "missing bookkeeper",
+			spec:    PulsarClusterSpec{ZookeeperServers: "zk:2181"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		path := field.NewPath("spec")
+		errs := append(validateMetadataStore(&tt.spec, path), validateBookkeeper(&tt.spec, path)...)
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	t.Parallel()
+	i32 := func(v int32) *int32 { return &v }
+	tests := []struct {
+		name    string
+		config  LedgerReplicationConfig
+		wantErr bool
+	}{
+		{name: "all set", config: LedgerReplicationConfig{EnsembleSize: i32(3), WriteQuorum: i32(3), AckQuorum: i32(2)}},
+		{name: "partially set", config: LedgerReplicationConfig{AckQuorum: i32(3)}},
+		{name: "write above ensemble", config: LedgerReplicationConfig{EnsembleSize: i32(2), WriteQuorum: i32(3)}, wantErr: true},
+		{name: "ack above write", config: LedgerReplicationConfig{WriteQuorum: i32(2), AckQuorum: i32(3)}, wantErr: true},
+		{name: "ack above ensemble", config: LedgerReplicationConfig{EnsembleSize: i32(2), AckQuorum: i32(3)}, wantErr: true},
+		{name: "zero", config: LedgerReplicationConfig{EnsembleSize: i32(0)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		spec := PulsarClusterSpec{Storage: &StorageConfig{Replication: &tt.config}}
+		errs := validateStorage(&spec, field.NewPath("spec"))
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestLedgerReplication(t *testing.T) {
+	t.Parallel()
+	i32 := func(v int32) *int32 { return &v }
+	tests := []struct {
+		name    string
+		config  *LedgerReplicationConfig
+		bookies int32
+		want    LedgerReplication
+	}{
+		{name: "unknown bookies", want: LedgerReplication{EnsembleSize: 1, WriteQuorum: 1, AckQuorum: 1}},
+		{
+			name:   "unknown bookies with an empty replication",
+			config: &LedgerReplicationConfig{},
+			want:   LedgerReplication{EnsembleSize: 2, WriteQuorum: 2, AckQuorum: 2},
+		},
+		{name: "single bookie", bookies: 1, want: LedgerReplication{EnsembleSize: 1, WriteQuorum: 1, AckQuorum: 1}},
+		{name: "three bookies", bookies: 3, want: LedgerReplication{EnsembleSize: 2, WriteQuorum: 2, AckQuorum: 2}},
+		{name: "five bookies", bookies: 5, want: LedgerReplication{EnsembleSize: 3, WriteQuorum: 3, AckQuorum: 2}},
+		{
+			name:    "ensemble only",
+			config:  &LedgerReplicationConfig{EnsembleSize: i32(1)},
+			bookies: 5,
+			want:    LedgerReplication{EnsembleSize: 1, WriteQuorum: 1, AckQuorum: 1},
+		},
+		{
+			name:   "ack quorum only",
+			config: &LedgerReplicationConfig{AckQuorum: i32(3)},
+			want:   LedgerReplication{EnsembleSize: 3, WriteQuorum: 3, AckQuorum: 3},
+		},
+		{
+			name:   "write quorum only",
+			config: &LedgerReplicationConfig{WriteQuorum: i32(3)},
+			want:   LedgerReplication{EnsembleSize: 3, WriteQuorum: 3, AckQuorum: 2},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.config.resolve(tt.bookies); got != tt.want {
+			t.Errorf("%s: expected: %+v; got: %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestStorageWarnings(t *testing.T) {
+	t.Parallel()
+	one := int32(1)
+	cluster := &PulsarCluster{}
+	cluster.Spec.Storage = &StorageConfig{Replication: &LedgerReplicationConfig{WriteQuorum: &one}}
+	if warnings := cluster.storageWarnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "not replicated") {
+		t.Errorf("expected the non-replicated warning; got: %v", warnings)
+	}
+	cluster.Spec.Storage = nil
+	if warnings := cluster.storageWarnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "single bookie") {
+		t.Errorf("expected the unknown bookie count warning; got: %v", warnings)
+	}
+	cluster.Spec.BookkeeperRef = &BookkeeperReference{Name: "bk"}
+	if warnings := cluster.storageWarnings(); len(warnings) != 0 {
+		t.Errorf("expected no warning; got: %v", warnings)
+	}
+}
+
+func TestBrokerConfigWarnings(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		key     string
+		warning string
+	}{
+		{name: "authorization", key: "superUserRoles", warning: "spec.brokerConfig.superUserRoles"},
+		{name: "prefixed authorization", key: "PULSAR_PREFIX_superUserRoles", warning: "spec.brokerConfig.superUserRoles"},
+		{name: "listeners", key: "advertisedListeners", warning: "spec.brokerConfig.advertisedListeners"},
+		{name: "prefixed listeners", key: "PULSAR_PREFIX_advertisedListeners", warning: "spec.brokerConfig.advertisedListeners"},
+		{name: "replication", key: "managedLedgerDefaultAckQuorum", warning: "spec.brokerConfig.managedLedgerDefaultAckQuorum"},
+		{
+			name:    "prefixed replication",
+			key:     "PULSAR_PREFIX_managedLedgerDefaultAckQuorum",
+			warning: "spec.brokerConfig.managedLedgerDefaultAckQuorum",
+		},
+		{name: "shutdown timeout", key: "brokerShutdownTimeoutMs", warning: "spec.brokerConfig.brokerShutdownTimeoutMs"},
+		{
+			name:    "prefixed shutdown timeout",
+			key:     "PULSAR_PREFIX_brokerShutdownTimeoutMs",
+			warning: "spec.brokerConfig.brokerShutdownTimeoutMs",
+		},
+	}
+	for _, tt := range tests {
+		cluster := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+		cluster.Spec.Authentication = &AuthenticationConfig{Enabled: true}
+		cluster.Spec.Authorization = &AuthorizationConfig{Enabled: true}
+		cluster.Spec.ExternalAccess = &ExternalAccessConfig{Enabled: true}
+		cluster.Spec.BrokerConfig = map[string]string{tt.key: "value"}
+		cluster.SetSpecDefaults()
+		var found bool
+		for _, w := range cluster.warnings() {
+			found = found || strings.HasPrefix(w, tt.warning+":")
+		}
+		if !found {
+			t.Errorf("%s: expected the %s warning; got: %v", tt.name, tt.warning, cluster.warnings())
+		}
+	}
+}
+
+func TestDrainWarnings(t *testing.T) {
+	t.Parallel()
+	gracePeriod := int64(10)
+	cluster := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+	cluster.Spec.PodConfig.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	cluster.SetSpecDefaults()
+	var found bool
+	for _, w := range cluster.warnings() {
+		found = found || strings.Contains(w, "terminationGracePeriodSeconds")
+	}
+	if !found {
+		t.Errorf("expected the short preStop drain warning; got: %v", cluster.warnings())
+	}
+	if preStop, shutdownMs := cluster.Spec.ShutdownBudget(); preStop != 5 || shutdownMs != 1000 {
+		t.Errorf("expected the grace period to be split into 5s and 1000ms; got: %ds and %dms", preStop, shutdownMs)
+	}
+}
+
+func TestMaintenanceBrokerOrdinal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		annotation  string
+		wantOrdinal int32
+		wantOk      bool
+	}{
+		{name: "broker pod", annotation: "sample-pls-2", wantOrdinal: 2, wantOk: true},
+		{name: "spaces", annotation: " sample-pls-0 ", wantOk: true},
+		{name: "not set"},
+		{name: "other pod", annotation: "other-pls-1"},
+		{name: "padded ordinal", annotation: "sample-pls-01"},
+		{name: "negative ordinal", annotation: "sample-pls--1"},
+		{name: "statefulset", annotation: "sample-pls"},
+	}
+	for _, tt := range tests {
+		cluster := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+		if tt.annotation != "" {
+			cluster.Annotations = map[string]string{MaintenanceAnnotation: tt.annotation}
+		}
+		ordinal, ok := cluster.MaintenanceBrokerOrdinal()
+		if ok != tt.wantOk || ordinal != tt.wantOrdinal {
+			t.Errorf("%s: expected ordinal: %d, %v; got: %d, %v", tt.name, tt.wantOrdinal, tt.wantOk, ordinal, ok)
+		}
+	}
+}
+
+func TestMaintenanceWarnings(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		size        int32
+		annotation  string
+		wantWarning string
+	}{
+		{name: "in rotation", size: 3},
+		{name: "existing broker", size: 3, annotation: "sample-pls-1"},
+		{name: "missing broker", size: 3, annotation: "sample-pls-3", wantWarning: "doesn't exist"},
+		{name: "only broker", size: 1, annotation: "sample-pls-0", wantWarning: "no endpoints"},
+	}
+	for _, tt := range tests {
+		size := tt.size
+		cluster := &PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "sample"}}
+		cluster.Spec.Size = &size
+		if tt.annotation != "" {
+			cluster.Annotations = map[string]string{MaintenanceAnnotation: tt.annotation}
+		}
+		cluster.SetSpecDefaults()
+		var found []string
+		for _, w := range cluster.warnings() {
+			if strings.Contains(w, MaintenanceAnnotation) {
+				found = append(found, w)
+			}
+		}
+		if tt.wantWarning == "" && len(found) > 0 {
+			t.Errorf("%s: expected no maintenance warning; got: %v", tt.name, found)
+		} else if tt.wantWarning != "" && (len(found) != 1 || !strings.Contains(found[0], tt.wantWarning)) {
+			t.Errorf("%s: expected the %q warning; got: %v", tt.name, tt.wantWarning, found)
+		}
+	}
+}
+
+func TestValidateAutoscaling(t *testing.T) {
+	t.Parallel()
+	i32 := func(v int32) *int32 { return &v }
+	disabled := false
+	tests := []struct {
+		name        string
+		autoscaling AutoscalingConfig
+		drain       *DrainConfig
+		wantErr     bool
+	}{
+		{name: "disabled", autoscaling: AutoscalingConfig{MaxReplicas: 0}},
+		{name: "valid", autoscaling: AutoscalingConfig{Enabled: true, MinReplicas: i32(2), MaxReplicas: 5}},
+		{name: "no max", autoscaling: AutoscalingConfig{Enabled: true}, wantErr: true},
+		{name: "min above max", autoscaling: AutoscalingConfig{Enabled: true, MinReplicas: i32(6), MaxReplicas: 5}, wantErr: true},
+		{name: "zero target", autoscaling: AutoscalingConfig{Enabled: true, MaxReplicas: 5,
+			TargetCPUUtilizationPercentage: i32(0)}, wantErr: true},
+		{name: "no preStop drain", autoscaling: AutoscalingConfig{Enabled: true, MaxReplicas: 5},
+			drain: &DrainConfig{PreStopHook: &disabled}, wantErr: true},
+	}
+	for _, tt := range tests {
+		autoscaling := tt.autoscaling
+		spec := PulsarClusterSpec{Autoscaling: &autoscaling, Drain: tt.drain}
+		errs := validateAutoscaling(&spec, field.NewPath("spec", "autoscaling"))
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateMonitoring(t *testing.T) {
+	t.Parallel()
+	i64 := func(v int64) *int64 { return &v }
+	tests := []struct {
+		name       string
+		monitoring MonitoringConfig
+		wantErr    bool
+	}{
+		{name: "disabled", monitoring: MonitoringConfig{Interval: "soon"}},
+		{name: "defaults", monitoring: MonitoringConfig{Enabled: true}},
+		{name: "valid", monitoring: MonitoringConfig{Enabled: true, Interval: "1m30s",
+			Alerts: &MonitoringAlertsConfig{BacklogThreshold: i64(10), WriteLatencyMillis: i64(200)}}},
+		{name: "invalid interval", monitoring: MonitoringConfig{Enabled: true, Interval: "30 seconds"}, wantErr: true},
+		{name: "zero threshold", monitoring: MonitoringConfig{Enabled: true,
+			Alerts: &MonitoringAlertsConfig{TopicsPerBroker: i64(0)}}, wantErr: true},
+		{name: "unknown latency bucket", monitoring: MonitoringConfig{Enabled: true,
+			Alerts: &MonitoringAlertsConfig{WriteLatencyMillis: i64(500)}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		monitoring := tt.monitoring
+		errs := validateMonitoring(&monitoring, field.NewPath("spec", "monitoring"))
+		if got := len(errs) > 0; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, errs)
+		}
+	}
+}
+
+func TestValidateZookeeperConnectString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "single server", input: "zk:2181"},
+		{name: "ensemble with chroot", input: "zk-0:2181,zk-1:2181,zk-2:2181/pulsar"},
+		{name: "empty", input: "", wantErr: true},
+		{name: "missing port", input: "zk", wantErr: true},
+		{name: "missing host", input: ":2181", wantErr: true},
+		{name: "invalid port", input: "zk:65536", wantErr: true},
+		{name: "non numeric port", input: "zk:abc", wantErr: true},
+		{name: "empty server in the ensemble", input: "zk-0:2181,/pulsar", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateZookeeperConnectString(tt.input)
+		if got := err != nil; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidateBookkeeperURI(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "zookeeper", input: "zk://zk:2181/ledgers"},
+		{name: "null layout with several servers", input: "zk+null://zk-0:2181;zk-1:2181/ledgers"},
+		{name: "metadata store", input: "metadata-store://zk:2181/ledgers"},
+		{name: "no scheme", input: "zk:2181/ledgers", wantErr: true},
+		{name: "unsupported scheme", input: "http://zk:2181/ledgers", wantErr: true},
+		{name: "no ledgers root path", input: "zk://zk:2181", wantErr: true},
+		{name: "empty ledgers root path", input: "zk://zk:2181/", wantErr: true},
+		{name: "invalid server", input: "zk://zk/ledgers", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateBookkeeperURI(tt.input)
+		if got := err != nil; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		ports    Ports
+		kop      KOP
+		wantErrs int
+	}{
+		{name: "defaults", ports: Ports{}},
+		{name: "client and web collide", ports: Ports{Web: 6650}, wantErrs: 1},
+		{name: "disabled ports never collide", ports: Ports{ClientTLS: -1, WebTLS: -1}},
+		{name: "kop collides with the web port", ports: Ports{Web: 9092}, kop: KOP{Enabled: true}, wantErrs: 1},
+		{name: "disabled kop is ignored", ports: Ports{Web: 9092}, kop: KOP{}},
+		{name: "kop ports collide", kop: KOP{Enabled: true, PlainTextPort: 9093}, wantErrs: 1},
+		{name: "kop and tls ports collide", kop: KOP{Enabled: true, PlainTextPort: 6651, SecuredPort: 8443}, wantErrs: 2},
+	}
+	for _, tt := range tests {
+		spec := &PulsarClusterSpec{Ports: &tt.ports, KOP: tt.kop}
+		spec.Ports.setDefaults()
+		if spec.KOP.PlainTextPort == 0 {
+			spec.KOP.PlainTextPort = defaultKopPlainPort
+		}
+		if spec.KOP.SecuredPort == 0 {
+			spec.KOP.SecuredPort = defaultKopSSLPort
+		}
+		errs := validatePorts(spec, field.NewPath("spec"))
+		if len(errs) != tt.wantErrs {
+			t.Errorf("%s: expected %d errors; got: %v", tt.name, tt.wantErrs, errs)
+		}
+	}
+}
+
+func TestValidateImmutableFields(t *testing.T) {
+	t.Parallel()
+	i32 := func(v int32) *int32 { return &v }
+	tests := []struct {
+		name    string
+		update  func(c *PulsarCluster)
+		wantErr bool
+	}{
+		{name: "unchanged", update: func(c *PulsarCluster) {}},
+		{name: "scaled", update: func(c *PulsarCluster) { c.Spec.Size = i32(5) }},
+		{name: "metadata store", update: func(c *PulsarCluster) { c.Spec.MetadataStore.URL = "zk-other:2181" }, wantErr: true},
+		{
+			name:    "configuration store",
+			update:  func(c *PulsarCluster) { c.Spec.MetadataStore.ConfigurationURL = "zk-global:2181" },
+			wantErr: true,
+		},
+		{
+			name: "zookeeper servers migrated to the metadata store",
+			update: func(c *PulsarCluster) {
+				c.Spec.MetadataStore = nil
+				c.Spec.ZookeeperServers = "zk:2181"
+			},
+		},
+		{
+			name:    "zookeeper reference",
+			update:  func(c *PulsarCluster) { c.Spec.ZookeeperRef = &ZookeeperReference{Name: "zk"} },
+			wantErr: true,
+		},
+		{
+			name:    "bookkeeper reference",
+			update:  func(c *PulsarCluster) { c.Spec.BookkeeperRef = &BookkeeperReference{Name: "bk"} },
+			wantErr: true,
+		},
+		{name: "cluster domain", update: func(c *PulsarCluster) { c.Spec.ClusterDomain = "example.org" }, wantErr: true},
+		{
+			name: "signing algorithm of the generated keys",
+			update: func(c *PulsarCluster) {
+				c.Spec.Authentication = &AuthenticationConfig{Enabled: true,
+					Token: &TokenAuthentication{SigningAlgorithm: "HS256"}}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		old := &PulsarCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample"},
+			Spec: PulsarClusterSpec{
+				ZookeeperServers:     "zk:2181",
+				BookkeeperClusterUri: "zk+null://zk:2181/ledgers",
+				Authentication:       &AuthenticationConfig{Enabled: true},
+			},
+		}
+		old.SetSpecDefaults()
+		cluster := old.DeepCopy()
+		tt.update(cluster)
+		cluster.SetSpecDefaults()
+		err := cluster.validateUpdate(old)
+		if got := err != nil; got != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestValidatePersistenceUpdate(t *testing.T) {
+	t.Parallel()
+	pvc := func(storageClass, size string) *v1.PersistentVolumeClaimSpec {
+		spec := &v1.PersistentVolumeClaimSpec{
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		}
+		if storageClass != "" {
+			spec.StorageClassName = &storageClass
+		}
+		return spec
+	}
+	tests := []struct {
+		name     string
+		old      *v1.PersistentVolumeClaimSpec
+		new      *v1.PersistentVolumeClaimSpec
+		wantErrs int
+	}{
+		{name: "unchanged", old: pvc("standard", "10Gi"), new: pvc("standard", "10Gi")},
+		{name: "expanded", old: pvc("standard", "10Gi"), new: pvc("standard", "20Gi")},
+		{name: "shrunk", old: pvc("standard", "10Gi"), new: pvc("standard", "5Gi"), wantErrs: 1},
+		{name: "storage class changed", old: pvc("", "10Gi"), new: pvc("fast", "10Gi"), wantErrs: 1},
+		{name: "shrunk on another storage class", old: pvc("standard", "10Gi"), new: pvc("fast", "1Gi"), wantErrs: 2},
+		{name: "not defaulted yet", old: nil, new: pvc("standard", "1Gi")},
+	}
+	for _, tt := range tests {
+		errs := validatePersistenceUpdate(tt.new, tt.old, field.NewPath("spec", "persistence"))
+		if len(errs) != tt.wantErrs {
+			t.Errorf("%s: expected %d errors; got: %v", tt.name, tt.wantErrs, errs)
+		}
+	}
+}
@@ -18,6 +18,7 @@
 package v1alpha1
 
 import (
+	"fmt"
 	"github.com/monimesl/operator-helper/config"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -53,13 +54,21 @@ var _ webhook.Validator = &PulsarCluster{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (in *PulsarCluster) ValidateCreate() (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate create]", "name", in.Name)
-	return nil, nil
+	return in.validate()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (in *PulsarCluster) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
+func (in *PulsarCluster) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate update]", "name", in.Name)
-	return nil, nil
+	oldCluster, ok := old.(*PulsarCluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulsarCluster but got a %T", old)
+	}
+	warnings, err := in.validate()
+	if err != nil {
+		return warnings, err
+	}
+	return warnings, in.validateUpdate(oldCluster)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
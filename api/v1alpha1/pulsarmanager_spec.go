@@ -13,20 +13,234 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package v1alpha1
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+import (
+	"github.com/monimesl/operator-helper/basetype"
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/pulsar-operator/internal"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	managerImageRepository    = "apachepulsar/pulsar-manager"
+	defaultManagerVersion     = "v0.4.0"
+	defaultManagerUIPort      = 9527
+	defaultManagerBackendPort = 7750
+	defaultManagerSuperuser   = "admin"
+)
+
+const (
+	// ManagerUIPortName is the name of the port serving the pulsar manager UI
+	ManagerUIPortName = "http-ui"
+	// ManagerBackendPortName is the name of the port serving the pulsar manager API
+	ManagerBackendPortName = "http-backend"
+)
+
+// ManagerStoreType is the type of the store backing the pulsar manager
+type ManagerStoreType string
+
+const (
+	// ManagerStoreEmbedded uses the postgres server embedded in the pulsar manager image
+	ManagerStoreEmbedded ManagerStoreType = "embedded"
+	// ManagerStorePostgres uses an external postgres server
+	ManagerStorePostgres ManagerStoreType = "postgres"
+)
+
+const (
+	// ManagerPostgresURLKey is the key of the JDBC URL in the postgres secret
+	ManagerPostgresURLKey = "url"
+	// ManagerUsernameKey is the key of the username in the postgres and superuser secrets
+	ManagerUsernameKey = "username"
+	// ManagerPasswordKey is the key of the password in the postgres and superuser secrets
+	ManagerPasswordKey = "password"
+)
 
 // PulsarManagerSpec defines the desired state of PulsarManager
 type PulsarManagerSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Version defines the version of the pulsar manager image. Defaults to v0.4.0
+	// +optional
+	Version string `json:"version,omitempty"`
+	// ImagePullPolicy describes a policy for if/when to pull the image
+	// +optional
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Store defines the store backing the pulsar manager
+	// +optional
+	Store ManagerStore `json:"store,omitempty"`
+	// Ports defines the service ports of the pulsar manager UI and API
+	// +optional
+	Ports *ManagerPorts `json:"ports,omitempty"`
+	// ServiceType defines the type of the pulsar manager service. Defaults to ClusterIP
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	ServiceType v1.ServiceType `json:"serviceType,omitempty"`
+	// Ingress exposes the pulsar manager UI through an ingress if it's set
+	// +optional
+	Ingress *ManagerIngress `json:"ingress,omitempty"`
+	// SuperuserSecretName references the secret holding the `username` and `password`
+	// of the superuser bootstrapped in the pulsar manager. A secret with a random
+	// password for the `admin` user is generated if it's not set
+	// +optional
+	SuperuserSecretName string `json:"superuserSecretName,omitempty"`
+	// Environments defines the clusters registered as environments in the pulsar manager
+	// +optional
+	Environments []ManagerEnvironment `json:"environments,omitempty"`
+	// PodConfig defines common configuration for the pulsar manager pod
+	// +optional
+	PodConfig basetype.PodConfig `json:"podConfig,omitempty"`
+	// Labels defines the labels to attach to the pulsar manager objects
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations defines the annotations to attach to the pulsar manager objects
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ManagerStore defines the store backing the pulsar manager
+type ManagerStore struct {
+	// Type defines the type of the store. Defaults to embedded
+	// +kubebuilder:validation:Enum=embedded;postgres
+	// +optional
+	Type ManagerStoreType `json:"type,omitempty"`
+	// Embedded defines the settings of the embedded store
+	// +optional
+	Embedded *EmbeddedManagerStore `json:"embedded,omitempty"`
+	// Postgres defines the external postgres store. Required if the type is postgres
+	// +optional
+	Postgres *PostgresManagerStore `json:"postgres,omitempty"`
+}
+
+// EmbeddedManagerStore defines the settings of the embedded store
+type EmbeddedManagerStore struct {
+	// StorageSize defines the size of the volume persisting the embedded store.
+	// The store is kept in an emptyDir volume and lost with the pod if it's not set
+	// +optional
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+	// StorageClassName defines the storage class of the volume persisting the embedded store
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// PostgresManagerStore defines an external postgres store
+type PostgresManagerStore struct {
+	// SecretName references the secret holding the JDBC `url` e.g.
+	// jdbc:postgresql://postgres:5432/pulsar_manager, the `username` and the `password`
+	SecretName string `json:"secretName"`
+}
 
+// ManagerPorts defines the ports of the pulsar manager
+type ManagerPorts struct {
+	// UI defines the port of the pulsar manager UI. Defaults to 9527
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UI int32 `json:"ui,omitempty"`
+	// Backend defines the port of the pulsar manager API. Defaults to 7750
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Backend int32 `json:"backend,omitempty"`
 }
 
-// setDefaults set the defaults for the cluster spec and returns true otherwise false
+// ManagerIngress defines the ingress exposing the pulsar manager UI
+type ManagerIngress struct {
+	// Host defines the host the UI is served on. All the hosts are matched if it's not set
+	// +optional
+	Host string `json:"host,omitempty"`
+	// IngressClassName defines the class of the ingress
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+	// TLSSecretName references the TLS secret of the host. The ingress serves plaintext HTTP if it's not set
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	// Annotations defines the annotations to attach to the ingress
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ManagerEnvironment defines a cluster registered as an environment in the pulsar manager
+type ManagerEnvironment struct {
+	// ClusterRef references the PulsarCluster of the environment
+	ClusterRef ClusterReference `json:"clusterRef"`
+	// Name defines the name of the environment. Defaults to the name of the cluster
+	// +optional
+	Name string `json:"name,omitempty"`
+	// BookieURL defines the HTTP URL of a bookie of the cluster shown by the UI
+	// +optional
+	BookieURL string `json:"bookieURL,omitempty"`
+}
+
+// EnvironmentName returns the name of the environment
+func (in *ManagerEnvironment) EnvironmentName() string {
+	if in.Name != "" {
+		return in.Name
+	}
+	return in.ClusterRef.Name
+}
+
+func (in *ManagerPorts) setDefaults() (changed bool) {
+	if in.UI == 0 {
+		changed = true
+		in.UI = defaultManagerUIPort
+	}
+	if in.Backend == 0 {
+		changed = true
+		in.Backend = defaultManagerBackendPort
+	}
+	return
+}
+
+// setDefaults set the defaults for the manager spec and returns true otherwise false
 func (in *PulsarManagerSpec) setDefaults() (changed bool) {
+	if in.Version == "" {
+		changed = true
+		in.Version = defaultManagerVersion
+	}
+	if in.ImagePullPolicy == "" {
+		changed = true
+		in.ImagePullPolicy = v1.PullIfNotPresent
+	}
+	if in.Store.Type == "" {
+		changed = true
+		in.Store.Type = ManagerStoreEmbedded
+	}
+	if in.Ports == nil {
+		changed = true
+		in.Ports = &ManagerPorts{}
+	}
+	if in.Ports.setDefaults() {
+		changed = true
+	}
+	if in.ServiceType == "" {
+		changed = true
+		in.ServiceType = v1.ServiceTypeClusterIP
+	}
+	if in.PodConfig.Spec.TerminationGracePeriodSeconds == nil {
+		changed = true
+		gracePeriod := defaultTerminationGracePeriod
+		in.PodConfig.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	}
 	return
 }
+
+func (in *PulsarManagerSpec) createLabels(managerName string) map[string]string {
+	labels := map[string]string{}
+	for k, v := range in.Labels {
+		labels[k] = v
+	}
+	for k, v := range managerSelectorLabels(managerName) {
+		labels[k] = v
+	}
+	return labels
+}
+
+// managerSelectorLabels returns the labels selecting the pulsar manager pod. They're
+// immutable since the deployment selector cannot be changed
+func managerSelectorLabels(managerName string) map[string]string {
+	return map[string]string{
+		"app":                 "pulsar",
+		"component":           "manager",
+		k8s.LabelAppName:      "pulsar-manager",
+		k8s.LabelAppInstance:  managerName,
+		k8s.LabelAppManagedBy: internal.OperatorName,
+	}
+}
@@ -13,19 +13,65 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package v1alpha1
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionEnvironmentsRegistered indicates whether the environments are registered in the pulsar manager
+	ConditionEnvironmentsRegistered = "EnvironmentsRegistered"
+	// ConditionEnvironmentsAccessible indicates whether the pulsar manager can call the brokers of the
+	// registered environments. The clusters with token authentication require the manager backend token
+	ConditionEnvironmentsAccessible = "EnvironmentsAccessible"
+)
 
 // PulsarManagerStatus defines the observed state of PulsarManager
 type PulsarManagerStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the most recent generation of the manager spec observed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of the ready pulsar manager pods
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// URL is the in-cluster URL of the pulsar manager UI
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Environments are the names of the environments registered by the operator
+	// +optional
+	Environments []string `json:"environments,omitempty"`
+
+	// Conditions defines the latest observations of the pulsar manager state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
-// setDefaults set the defaults for the cluster spec and returns true otherwise false
+// setDefaults set the defaults for the manager status and returns true otherwise false
 func (in *PulsarManagerStatus) setDefaults() (changed bool) {
 	return
 }
+
+// SetCondition adds or updates the condition of the specified type.
+// The transition time is only changed when the condition status changes
+func (in *PulsarManagerStatus) SetCondition(conditionType string, status metav1.ConditionStatus,
+	reason, message string, generation int64) {
+	meta.SetStatusCondition(&in.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+// GetCondition returns the condition of the specified type or nil if it does not exist
+func (in *PulsarManagerStatus) GetCondition(conditionType string) *metav1.Condition {
+	return meta.FindStatusCondition(in.Conditions, conditionType)
+}
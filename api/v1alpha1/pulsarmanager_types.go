@@ -13,15 +13,36 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package v1alpha1
 
 import (
+	"fmt"
+	"github.com/monimesl/operator-helper/basetype"
+	"github.com/monimesl/operator-helper/reconciler"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+var (
+	_ reconciler.Defaulting = &PulsarManager{}
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Store",type=string,JSONPath=`.spec.store.type`
+//+kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+//+kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PulsarManager is the Schema for the pulsarmanagers API
+type PulsarManager struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PulsarManagerSpec   `json:"spec,omitempty"`
+	Status PulsarManagerStatus `json:"status,omitempty"`
+}
 
 //+kubebuilder:object:root=true
 
@@ -36,24 +57,87 @@ func init() {
 	SchemeBuilder.Register(&PulsarManager{}, &PulsarManagerList{})
 }
 
-//+kubebuilder:object:root=true
-//+kubebuilder:subresource:status
-
-// PulsarManager is the Schema for the pulsarmanagers API
-type PulsarManager struct {
-	metav1.TypeMeta   `json:",inline"`
-	metav1.ObjectMeta `json:"metadata,omitempty"`
-
-	Spec   PulsarManagerSpec   `json:"spec,omitempty"`
-	Status PulsarManagerStatus `json:"status,omitempty"`
-}
-
-// SetSpecDefaults set the defaults for the cluster spec and returns true otherwise false
+// SetSpecDefaults set the defaults for the manager spec and returns true otherwise false
 func (in *PulsarManager) SetSpecDefaults() bool {
 	return in.Spec.setDefaults()
 }
 
-// SetStatusDefaults set the defaults for the cluster status and returns true otherwise false
+// SetStatusDefaults set the defaults for the manager status and returns true otherwise false
 func (in *PulsarManager) SetStatusDefaults() bool {
 	return in.Status.setDefaults()
 }
+
+func (in *PulsarManager) generateName() string {
+	if strings.Contains(in.Name, "manager") {
+		return in.Name
+	}
+	return fmt.Sprintf("%s-manager", in.Name)
+}
+
+// DeploymentName defines the name of the deployment object
+func (in *PulsarManager) DeploymentName() string {
+	return in.generateName()
+}
+
+// ServiceName defines the name of the service object
+func (in *PulsarManager) ServiceName() string {
+	return in.generateName()
+}
+
+// IngressName defines the name of the ingress object
+func (in *PulsarManager) IngressName() string {
+	return in.generateName()
+}
+
+// StoreClaimName defines the name of the persistent volume claim of the embedded store
+func (in *PulsarManager) StoreClaimName() string {
+	return fmt.Sprintf("%s-data", in.generateName())
+}
+
+// SuperuserSecretName defines the name of the secret holding the superuser credentials
+func (in *PulsarManager) SuperuserSecretName() string {
+	if in.Spec.SuperuserSecretName != "" {
+		return in.Spec.SuperuserSecretName
+	}
+	return fmt.Sprintf("%s-superuser", in.generateName())
+}
+
+// IsStorePersisted checks whether the embedded store is persisted in a volume
+func (in *PulsarManager) IsStorePersisted() bool {
+	store := in.Spec.Store
+	return store.Type == ManagerStoreEmbedded && store.Embedded != nil && store.Embedded.StorageSize != nil
+}
+
+// Image specifies the pulsar manager image
+func (in *PulsarManager) Image() basetype.Image {
+	return basetype.Image{
+		Repository: managerImageRepository,
+		PullPolicy: in.Spec.ImagePullPolicy,
+		Tag:        in.Spec.Version,
+	}
+}
+
+// BackendURL returns the in-cluster URL of the pulsar manager API
+func (in *PulsarManager) BackendURL() string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", in.ServiceName(), in.Namespace, in.Spec.Ports.Backend)
+}
+
+// UIURL returns the in-cluster URL of the pulsar manager UI
+func (in *PulsarManager) UIURL() string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", in.ServiceName(), in.Namespace, in.Spec.Ports.UI)
+}
+
+// GenerateLabels generates the labels of the pulsar manager objects
+func (in *PulsarManager) GenerateLabels() map[string]string {
+	return in.Spec.createLabels(in.Name)
+}
+
+// SelectorLabels returns the labels selecting the pulsar manager pod
+func (in *PulsarManager) SelectorLabels() map[string]string {
+	return managerSelectorLabels(in.Name)
+}
+
+// GenerateAnnotations generates the annotations of the pulsar manager objects
+func (in *PulsarManager) GenerateAnnotations() map[string]string {
+	return in.Spec.Annotations
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validate validates the manager spec
+func (in *PulsarManager) validate() error {
+	spec := &in.Spec
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	storePath := specPath.Child("store")
+	switch spec.Store.Type {
+	case ManagerStorePostgres:
+		if spec.Store.Postgres == nil || spec.Store.Postgres.SecretName == "" {
+			errs = append(errs, field.Required(storePath.Child("postgres", "secretName"),
+				"the postgres secret is required by the postgres store"))
+		}
+		if spec.Store.Embedded != nil {
+			errs = append(errs, field.Forbidden(storePath.Child("embedded"), "is only allowed with the embedded store"))
+		}
+	case ManagerStoreEmbedded:
+		if spec.Store.Postgres != nil {
+			errs = append(errs, field.Forbidden(storePath.Child("postgres"), "is only allowed with the postgres store"))
+		}
+	}
+	if ports := spec.Ports; ports != nil && ports.UI > 0 && ports.UI == ports.Backend {
+		errs = append(errs, field.Duplicate(specPath.Child("ports", "backend"), ports.Backend))
+	}
+	names := map[string]bool{}
+	for i := range spec.Environments {
+		env := &spec.Environments[i]
+		envPath := specPath.Child("environments").Index(i)
+		if env.ClusterRef.Name == "" {
+			errs = append(errs, field.Required(envPath.Child("clusterRef", "name"), "the PulsarCluster is required"))
+			continue
+		}
+		if names[env.EnvironmentName()] {
+			errs = append(errs, field.Duplicate(envPath.Child("name"), env.EnvironmentName()))
+		}
+		names[env.EnvironmentName()] = true
+	}
+	if len(errs) > 0 {
+		return in.invalidError(errs)
+	}
+	return nil
+}
+
+// validateUpdate validates the changes of the manager spec
+func (in *PulsarManager) validateUpdate(old *PulsarManager) error {
+	if old.Spec.Store.Type != "" && in.Spec.Store.Type != old.Spec.Store.Type {
+		return in.invalidError(field.ErrorList{field.Forbidden(field.NewPath("spec", "store", "type"),
+			"cannot be changed since the manager data is not migrated between the stores")})
+	}
+	return nil
+}
+
+func (in *PulsarManager) invalidError(errs field.ErrorList) error {
+	return apierrors.NewInvalid(GroupVersion.WithKind("PulsarManager").GroupKind(), in.Name, errs)
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestPulsarManagerValidate(t *testing.T) {
+	t.Parallel()
+	cluster := ClusterReference{Name: "pulsar"}
+	tests := []struct {
+		name    string
+		spec    PulsarManagerSpec
+		wantErr bool
+	}{
+		{name: "embedded", spec: PulsarManagerSpec{Store: ManagerStore{Type: ManagerStoreEmbedded},
+			Environments: []ManagerEnvironment{{ClusterRef: cluster}, {ClusterRef: cluster, Name: "prod"}}}},
+		{name: "postgres", spec: PulsarManagerSpec{Store: ManagerStore{Type: ManagerStorePostgres,
+			Postgres: &PostgresManagerStore{SecretName: "postgres"}}}},
+		{name: "postgres without secret", spec: PulsarManagerSpec{Store: ManagerStore{Type: ManagerStorePostgres}}, wantErr: true},
+		{name: "embedded with postgres", spec: PulsarManagerSpec{Store: ManagerStore{Type: ManagerStoreEmbedded,
+			Postgres: &PostgresManagerStore{SecretName: "postgres"}}}, wantErr: true},
+		{name: "same ports", spec: PulsarManagerSpec{Ports: &ManagerPorts{UI: 8080, Backend: 8080}}, wantErr: true},
+		{name: "missing cluster", spec: PulsarManagerSpec{Environments: []ManagerEnvironment{{Name: "prod"}}}, wantErr: true},
+		{name: "duplicate environment", spec: PulsarManagerSpec{Environments: []ManagerEnvironment{
+			{ClusterRef: cluster}, {ClusterRef: ClusterReference{Name: "other"}, Name: "pulsar"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		manager := &PulsarManager{ObjectMeta: metav1.ObjectMeta{Name: "console"}, Spec: tt.spec}
+		if err := manager.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestPulsarManagerValidateUpdate(t *testing.T) {
+	t.Parallel()
+	old := &PulsarManager{ObjectMeta: metav1.ObjectMeta{Name: "console"}}
+	old.SetSpecDefaults()
+	updated := old.DeepCopyObject().(*PulsarManager)
+	updated.Spec.Store = ManagerStore{Type: ManagerStorePostgres, Postgres: &PostgresManagerStore{SecretName: "postgres"}}
+	if err := updated.validateUpdate(old); err == nil {
+		t.Error("expected an error changing the store type")
+	}
+}
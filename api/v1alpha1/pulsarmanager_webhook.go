@@ -18,6 +18,7 @@
 package v1alpha1
 
 import (
+	"fmt"
 	"github.com/monimesl/operator-helper/config"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,8 +33,6 @@ func (in *PulsarManager) SetupWebhookWithManager(mgr ctrl.Manager) error {
 		Complete()
 }
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-
 //+kubebuilder:webhook:path=/mutate-pulsar-monime-sl-v1alpha1-pulsarmanager,mutating=true,failurePolicy=fail,sideEffects=None,groups=pulsar.monime.sl,resources=pulsarmanagers,verbs=create;update,versions=v1alpha1,name=mpulsarmanager.kb.io,admissionReviewVersions={v1,v1beta1}
 
 var _ webhook.Defaulter = &PulsarManager{}
@@ -45,7 +44,6 @@ func (in *PulsarManager) Default() {
 	in.SetStatusDefaults()
 }
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
 //+kubebuilder:webhook:path=/validate-pulsar-monime-sl-v1alpha1-pulsarmanager,mutating=false,failurePolicy=fail,sideEffects=None,groups=pulsar.monime.sl,resources=pulsarmanagers,verbs=create;update,versions=v1alpha1,name=vpulsarmanager.kb.io,admissionReviewVersions={v1,v1beta1}
 
 var _ webhook.Validator = &PulsarManager{}
@@ -53,13 +51,20 @@ var _ webhook.Validator = &PulsarManager{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (in *PulsarManager) ValidateCreate() (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate create]", "name", in.Name)
-	return nil, nil
+	return nil, in.validate()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (in *PulsarManager) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate update]", "name", in.Name)
-	return nil, nil
+	oldManager, ok := old.(*PulsarManager)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulsarManager but got a %T", old)
+	}
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	return nil, in.validateUpdate(oldManager)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// PulsarNamespaceSpec defines the desired state of PulsarNamespace
+type PulsarNamespaceSpec struct {
+	// ClusterRef references the PulsarCluster hosting the namespace
+	ClusterRef ClusterReference `json:"clusterRef"`
+	// Tenant defines the pulsar tenant of the namespace
+	Tenant string `json:"tenant"`
+	// Name defines the name of the namespace in the tenant. Defaults to the object name
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Bundles defines the number of bundles the namespace is created with.
+	// It defaults to the cluster's default and cannot be changed afterwards
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Bundles *int32 `json:"bundles,omitempty"`
+	// Policies defines the namespace policies. The unset policies are not
+	// managed by the operator and keep their value in pulsar
+	// +optional
+	Policies NamespacePolicies `json:"policies,omitempty"`
+	// DriftPolicy defines how the changes made to the namespace outside the operator are handled.
+	// Defaults to Revert
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+}
+
+// NamespacePolicies defines the policies of a pulsar namespace
+type NamespacePolicies struct {
+	// Retention defines the retention of the acknowledged messages
+	// +optional
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// MessageTTLSeconds defines the time after which the unacknowledged messages expire. 0 disables it
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MessageTTLSeconds *int32 `json:"messageTTLSeconds,omitempty"`
+	// BacklogQuota defines the size limit of the unacknowledged backlog
+	// +optional
+	BacklogQuota *BacklogQuota `json:"backlogQuota,omitempty"`
+	// PublishRate defines the publish rate limit of each topic
+	// +optional
+	PublishRate *PublishRate `json:"publishRate,omitempty"`
+	// DispatchRate defines the dispatch rate limit of each topic
+	// +optional
+	DispatchRate *DispatchRate `json:"dispatchRate,omitempty"`
+	// Deduplication enables the message deduplication
+	// +optional
+	Deduplication *bool `json:"deduplication,omitempty"`
+	// SchemaCompatibilityStrategy defines the compatibility strategy of the schema changes
+	// +kubebuilder:validation:Enum=ALWAYS_INCOMPATIBLE;ALWAYS_COMPATIBLE;BACKWARD;FORWARD;FULL;BACKWARD_TRANSITIVE;FORWARD_TRANSITIVE;FULL_TRANSITIVE
+	// +optional
+	SchemaCompatibilityStrategy string `json:"schemaCompatibilityStrategy,omitempty"`
+	// ReplicationClusters defines the pulsar clusters the namespace is replicated to
+	// +listType=set
+	// +optional
+	ReplicationClusters []string `json:"replicationClusters,omitempty"`
+	// Permissions defines the actions granted to the roles on the namespace. When set,
+	// the permissions of the roles not listed are revoked
+	// +listType=map
+	// +listMapKey=role
+	// +optional
+	Permissions []NamespacePermission `json:"permissions,omitempty"`
+}
+
+// RetentionPolicy defines the retention of the acknowledged messages. -1 means infinite
+type RetentionPolicy struct {
+	// +kubebuilder:validation:Minimum=-1
+	TimeInMinutes int32 `json:"timeInMinutes"`
+	// +kubebuilder:validation:Minimum=-1
+	SizeInMB int64 `json:"sizeInMB"`
+}
+
+// BacklogQuota defines the size limit of the unacknowledged backlog
+type BacklogQuota struct {
+	// Limit defines the size limit of the backlog
+	Limit resource.Quantity `json:"limit"`
+	// Policy defines what happens once the limit is reached
+	// +kubebuilder:validation:Enum=producer_request_hold;producer_exception;consumer_backlog_eviction
+	Policy string `json:"policy"`
+}
+
+// PublishRate defines the publish rate limit. -1 means unlimited
+type PublishRate struct {
+	// +kubebuilder:validation:Minimum=-1
+	MessagesPerSecond int32 `json:"messagesPerSecond"`
+	// +kubebuilder:validation:Minimum=-1
+	BytesPerSecond int64 `json:"bytesPerSecond"`
+}
+
+// DispatchRate defines the dispatch rate limit. -1 means unlimited
+type DispatchRate struct {
+	// +kubebuilder:validation:Minimum=-1
+	MessagesPerPeriod int32 `json:"messagesPerPeriod"`
+	// +kubebuilder:validation:Minimum=-1
+	BytesPerPeriod int64 `json:"bytesPerPeriod"`
+	// PeriodSeconds defines the period of the rate. Defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+}
+
+// NamespacePermission defines the actions granted to a role
+type NamespacePermission struct {
+	// Role defines the granted role
+	Role string `json:"role"`
+	// Actions defines the granted actions
+	// +listType=set
+	Actions []string `json:"actions"`
+}
+
+// setDefaults set the defaults for the namespace spec and returns true otherwise false
+func (in *PulsarNamespaceSpec) setDefaults() (changed bool) {
+	if in.DriftPolicy == "" {
+		changed = true
+		in.DriftPolicy = DriftPolicyRevert
+	}
+	if rate := in.Policies.DispatchRate; rate != nil && rate.PeriodSeconds == 0 {
+		changed = true
+		rate.PeriodSeconds = 1
+	}
+	return
+}
@@ -0,0 +1,26 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+// PulsarNamespaceStatus defines the observed state of PulsarNamespace
+type PulsarNamespaceStatus struct {
+	SyncStatus `json:",inline"`
+}
+
+// setDefaults set the defaults for the namespace status and returns true otherwise false
+func (in *PulsarNamespaceStatus) setDefaults() (changed bool) {
+	return
+}
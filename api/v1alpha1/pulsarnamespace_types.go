@@ -0,0 +1,82 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"github.com/monimesl/operator-helper/reconciler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	_ reconciler.Defaulting = &PulsarNamespace{}
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Tenant",type=string,JSONPath=`.spec.tenant`
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterRef.name`
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Drifted",type=string,priority=1,JSONPath=`.status.conditions[?(@.type=="Drifted")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PulsarNamespace is the Schema for the pulsarnamespaces API
+type PulsarNamespace struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PulsarNamespaceSpec   `json:"spec,omitempty"`
+	Status PulsarNamespaceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PulsarNamespaceList contains a list of PulsarNamespace
+type PulsarNamespaceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulsarNamespace `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulsarNamespace{}, &PulsarNamespaceList{})
+}
+
+// SetSpecDefaults set the defaults for the namespace spec and returns true otherwise false
+func (in *PulsarNamespace) SetSpecDefaults() bool {
+	return in.Spec.setDefaults()
+}
+
+// SetStatusDefaults set the defaults for the namespace status and returns true otherwise false
+func (in *PulsarNamespace) SetStatusDefaults() bool {
+	return in.Status.setDefaults()
+}
+
+// NamespaceName returns the name of the namespace in pulsar in the format `tenant/namespace`
+func (in *PulsarNamespace) NamespaceName() string {
+	return in.Spec.Tenant + "/" + in.namespaceLocalName()
+}
+
+// GetSyncStatus returns the sync status of the namespace
+func (in *PulsarNamespace) GetSyncStatus() *SyncStatus {
+	return &in.Status.SyncStatus
+}
+
+// ClusterKey returns the namespaced name of the referenced PulsarCluster
+func (in *PulsarNamespace) ClusterKey() types.NamespacedName {
+	return types.NamespacedName{Name: in.Spec.ClusterRef.Name, Namespace: in.Namespace}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// namespaceActions are the actions pulsar grants on a namespace
+var namespaceActions = []string{"produce", "consume", "functions", "sources", "sinks", "packages"}
+
+// validate validates the namespace spec
+func (in *PulsarNamespace) validate() error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if in.Spec.ClusterRef.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("clusterRef", "name"), "the PulsarCluster is required"))
+	}
+	if in.Spec.Tenant == "" {
+		errs = append(errs, field.Required(specPath.Child("tenant"), "the tenant of the namespace is required"))
+	} else if !pulsarNameRegex.MatchString(in.Spec.Tenant) {
+		errs = append(errs, field.Invalid(specPath.Child("tenant"), in.Spec.Tenant,
+			"must be a valid pulsar tenant name"))
+	}
+	if name := in.namespaceLocalName(); !pulsarNameRegex.MatchString(name) {
+		errs = append(errs, field.Invalid(specPath.Child("name"), name,
+			"the namespace name may only contain letters, digits and the characters: - = : . _"))
+	}
+	if in.Spec.Bundles != nil && *in.Spec.Bundles < 1 {
+		errs = append(errs, field.Invalid(specPath.Child("bundles"), *in.Spec.Bundles, "must be at least 1"))
+	}
+	errs = append(errs, in.Spec.Policies.validate(specPath.Child("policies"))...)
+	return in.invalidError(errs)
+}
+
+func (in *NamespacePolicies) validate(path *field.Path) field.ErrorList {
+	errs := validateMessagePolicies(in.Retention, in.MessageTTLSeconds, in.BacklogQuota, path)
+	for i, cluster := range in.ReplicationClusters {
+		if !pulsarNameRegex.MatchString(cluster) {
+			errs = append(errs, field.Invalid(path.Child("replicationClusters").Index(i), cluster,
+				"must be a valid pulsar cluster name"))
+		}
+	}
+	roles := make([]string, 0, len(in.Permissions))
+	for i, permission := range in.Permissions {
+		roles = append(roles, permission.Role)
+		if len(permission.Actions) == 0 {
+			errs = append(errs, field.Required(path.Child("permissions").Index(i).Child("actions"),
+				"at least one action is required"))
+		}
+		for j, action := range permission.Actions {
+			if !containsString(namespaceActions, action) {
+				errs = append(errs, field.NotSupported(path.Child("permissions").Index(i).Child("actions").Index(j),
+					action, namespaceActions))
+			}
+		}
+	}
+	return append(errs, validateRoles(roles, path.Child("permissions"))...)
+}
+
+// validateMessagePolicies validates the message policies shared by the namespaces and the topics
+func validateMessagePolicies(retention *RetentionPolicy, ttl *int32, quota *BacklogQuota, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if retention != nil {
+		if retention.TimeInMinutes < -1 {
+			errs = append(errs, field.Invalid(path.Child("retention", "timeInMinutes"),
+				retention.TimeInMinutes, "must be -1 (infinite) or greater"))
+		}
+		if retention.SizeInMB < -1 {
+			errs = append(errs, field.Invalid(path.Child("retention", "sizeInMB"),
+				retention.SizeInMB, "must be -1 (infinite) or greater"))
+		}
+	}
+	if ttl != nil && *ttl < 0 {
+		errs = append(errs, field.Invalid(path.Child("messageTTLSeconds"), *ttl, "must not be negative"))
+	}
+	if quota != nil {
+		if quota.Limit.Sign() <= 0 {
+			errs = append(errs, field.Invalid(path.Child("backlogQuota", "limit"), quota.Limit.String(), "must be positive"))
+		} else if retention != nil && retention.SizeInMB >= 0 &&
+			quota.Limit.Value() > retention.SizeInMB*1024*1024 {
+			// pulsar rejects a backlog quota exceeding the retention size
+			errs = append(errs, field.Invalid(path.Child("backlogQuota", "limit"), quota.Limit.String(),
+				"must not exceed the retention size"))
+		}
+	}
+	return errs
+}
+
+// validateUpdate validates the changes from the old namespace spec
+func (in *PulsarNamespace) validateUpdate(old *PulsarNamespace) error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if in.NamespaceName() != old.NamespaceName() {
+		errs = append(errs, field.Forbidden(specPath.Child("name"), "the namespace name and tenant cannot be changed"))
+	}
+	if in.Spec.ClusterRef != old.Spec.ClusterRef {
+		errs = append(errs, field.Forbidden(specPath.Child("clusterRef"), "the namespace cannot be moved to another cluster"))
+	}
+	if !int32PtrEqual(in.Spec.Bundles, old.Spec.Bundles) {
+		errs = append(errs, field.Forbidden(specPath.Child("bundles"), "the bundles cannot be changed once created"))
+	}
+	return in.invalidError(errs)
+}
+
+// namespaceLocalName returns the name of the namespace without the tenant
+func (in *PulsarNamespace) namespaceLocalName() string {
+	if in.Spec.Name != "" {
+		return in.Spec.Name
+	}
+	return in.Name
+}
+
+func (in *PulsarNamespace) invalidError(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("PulsarNamespace").GroupKind(), in.Name, errs)
+}
+
+func int32PtrEqual(a, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestPulsarNamespaceValidate(t *testing.T) {
+	t.Parallel()
+	cluster := ClusterReference{Name: "pulsar"}
+	tests := []struct {
+		name    string
+		spec    PulsarNamespaceSpec
+		wantErr bool
+	}{
+		{name: "valid", spec: PulsarNamespaceSpec{ClusterRef: cluster, Tenant: "team-a", Policies: NamespacePolicies{
+			Retention:    &RetentionPolicy{TimeInMinutes: 60, SizeInMB: 1024},
+			BacklogQuota: &BacklogQuota{Limit: resource.MustParse("512Mi"), Policy: "producer_request_hold"},
+			Permissions:  []NamespacePermission{{Role: "app", Actions: []string{"produce", "consume"}}},
+		}}},
+		{name: "missing tenant", spec: PulsarNamespaceSpec{ClusterRef: cluster}, wantErr: true},
+		{name: "invalid name", spec: PulsarNamespaceSpec{ClusterRef: cluster, Tenant: "team-a", Name: "a/b"}, wantErr: true},
+		{name: "backlog exceeds retention", spec: PulsarNamespaceSpec{ClusterRef: cluster, Tenant: "team-a", Policies: NamespacePolicies{
+			Retention:    &RetentionPolicy{TimeInMinutes: 60, SizeInMB: 100},
+			BacklogQuota: &BacklogQuota{Limit: resource.MustParse("1Gi"), Policy: "producer_request_hold"},
+		}}, wantErr: true},
+		{name: "unknown action", spec: PulsarNamespaceSpec{ClusterRef: cluster, Tenant: "team-a", Policies: NamespacePolicies{
+			Permissions: []NamespacePermission{{Role: "app", Actions: []string{"write"}}},
+		}}, wantErr: true},
+		{name: "duplicate roles", spec: PulsarNamespaceSpec{ClusterRef: cluster, Tenant: "team-a", Policies: NamespacePolicies{
+			Permissions: []NamespacePermission{{Role: "app", Actions: []string{"produce"}}, {Role: "app", Actions: []string{"consume"}}},
+		}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		namespace := &PulsarNamespace{ObjectMeta: metav1.ObjectMeta{Name: "orders"}, Spec: tt.spec}
+		if err := namespace.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestPulsarNamespaceValidateUpdate(t *testing.T) {
+	t.Parallel()
+	bundles, deduplication := int32(4), true
+	old := &PulsarNamespace{ObjectMeta: metav1.ObjectMeta{Name: "orders"},
+		Spec: PulsarNamespaceSpec{ClusterRef: ClusterReference{Name: "pulsar"}, Tenant: "team-a", Bundles: &bundles}}
+	updated := old.DeepCopyObject().(*PulsarNamespace)
+	updated.Spec.Policies.Deduplication = &deduplication
+	if err := updated.validateUpdate(old); err != nil {
+		t.Errorf("expected the policy change to be allowed; got: %v", err)
+	}
+	moved := old.DeepCopyObject().(*PulsarNamespace)
+	moved.Spec.Tenant = "team-b"
+	if err := moved.validateUpdate(old); err == nil {
+		t.Error("expected the tenant change to be rejected")
+	}
+	resized := old.DeepCopyObject().(*PulsarNamespace)
+	resized.Spec.Bundles = nil
+	if err := resized.validateUpdate(old); err == nil {
+		t.Error("expected the bundles change to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+//nolint:dupl
+package v1alpha1
+
+import (
+	"fmt"
+	"github.com/monimesl/operator-helper/config"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupWebhookWithManager needed for webhook test suite
+func (in *PulsarNamespace) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(in).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-pulsar-monime-sl-v1alpha1-pulsarnamespace,mutating=true,failurePolicy=fail,sideEffects=None,groups=pulsar.monime.sl,resources=pulsarnamespaces,verbs=create;update,versions=v1alpha1,name=mpulsarnamespace.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &PulsarNamespace{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (in *PulsarNamespace) Default() {
+	config.RequireRootLogger().Info("[Webhook] Setting defaults", "name", in.Name)
+	in.SetSpecDefaults()
+	in.SetStatusDefaults()
+}
+
+//+kubebuilder:webhook:path=/validate-pulsar-monime-sl-v1alpha1-pulsarnamespace,mutating=false,failurePolicy=fail,sideEffects=None,groups=pulsar.monime.sl,resources=pulsarnamespaces,verbs=create;update,versions=v1alpha1,name=vpulsarnamespace.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &PulsarNamespace{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (in *PulsarNamespace) ValidateCreate() (admission.Warnings, error) {
+	config.RequireRootLogger().Info("[validate create]", "name", in.Name)
+	return nil, in.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (in *PulsarNamespace) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	config.RequireRootLogger().Info("[validate update]", "name", in.Name)
+	oldNamespace, ok := old.(*PulsarNamespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a PulsarNamespace but got a %T", old)
+	}
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	return nil, in.validateUpdate(oldNamespace)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (in *PulsarNamespace) ValidateDelete() (admission.Warnings, error) {
+	config.RequireRootLogger().Info("[validate delete]", "name", in.Name)
+	return nil, nil
+}
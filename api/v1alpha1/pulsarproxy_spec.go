@@ -13,20 +13,158 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package v1alpha1
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+import (
+	"github.com/monimesl/operator-helper/basetype"
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/pulsar-operator/internal"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultProxyReplicas       = int32(2)
+	defaultProxyMaxUnavailable = int32(1)
+)
 
 // PulsarProxySpec defines the desired state of PulsarProxy
 type PulsarProxySpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ClusterRef references the PulsarCluster whose brokers the proxy fronts
+	ClusterRef ClusterReference `json:"clusterRef"`
+	// Replicas defines the number of the proxy pods. Defaults to 2
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// PulsarVersion defines the version of the proxy. It's either a semantic version
+	// e.g. 3.0.1, `latest` or an image digest e.g. sha256:<hex>. Defaults to the
+	// version of the referenced cluster so that the proxies follow the broker upgrades
+	// +optional
+	PulsarVersion string `json:"pulsarVersion,omitempty"`
+	// ImagePullPolicy describes a policy for if/when to pull the image
+	// +optional
+	ImagePullPolicy v1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Ports defines the ports the proxy serves the clients on
+	// +optional
+	Ports *ProxyPorts `json:"ports,omitempty"`
+	// ServiceType defines the type of the proxy service. Defaults to ClusterIP
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	ServiceType v1.ServiceType `json:"serviceType,omitempty"`
+	// MaxUnavailableReplicas defines the maximum number of the proxy pods that
+	// can be unavailable as per kubernetes PodDisruptionBudget. Defaults to 1
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxUnavailableReplicas *int32 `json:"maxUnavailableReplicas,omitempty"`
+	// ProxyConfig defines the configurations to override the proxy.conf. The broker
+	// URLs and the cluster name are derived from the referenced cluster and cannot be overridden
+	// +optional
+	ProxyConfig map[string]string `json:"proxyConfig,omitempty"`
+	// JVMOptions defines the JVM options for the proxy. If unspecified, a reasonable defaults will be set
+	// +optional
+	JVMOptions JVMOptions `json:"jvmOptions"`
+	// PodConfig defines common configuration for the proxy pods
+	// +optional
+	PodConfig basetype.PodConfig `json:"podConfig,omitempty"`
+	// ProbeConfig defines the probing settings for the proxy containers
+	// +optional
+	ProbeConfig *pod.Probes `json:"probeConfig,omitempty"`
+	// Labels defines the labels to attach to the proxy deployment
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations defines the annotations to attach to the proxy deployment and service
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ProxyPorts defines the ports of the proxy
+type ProxyPorts struct {
+	// Client defines the port of the pulsar binary protocol. Defaults to 6650
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Client int32 `json:"client,omitempty"`
+	// Web defines the port of the admin and lookup HTTP endpoints. Defaults to 8080
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Web int32 `json:"web,omitempty"`
+}
 
+func (in *ProxyPorts) setDefaults() (changed bool) {
+	if in.Client == 0 {
+		changed = true
+		in.Client = defaultClientPort
+	}
+	if in.Web == 0 {
+		changed = true
+		in.Web = defaultWebPort
+	}
+	return
 }
 
-// setDefaults set the defaults for the cluster spec and returns true otherwise false
+// setDefaults set the defaults for the proxy spec and returns true otherwise false
 func (in *PulsarProxySpec) setDefaults() (changed bool) {
+	if in.Replicas == nil {
+		changed = true
+		replicas := defaultProxyReplicas
+		in.Replicas = &replicas
+	}
+	if in.ImagePullPolicy == "" {
+		changed = true
+		in.ImagePullPolicy = v1.PullIfNotPresent
+	}
+	if in.Ports == nil {
+		changed = true
+		in.Ports = &ProxyPorts{}
+	}
+	if in.Ports.setDefaults() {
+		changed = true
+	}
+	if in.ServiceType == "" {
+		changed = true
+		in.ServiceType = v1.ServiceTypeClusterIP
+	}
+	if in.MaxUnavailableReplicas == nil {
+		changed = true
+		maxUnavailable := defaultProxyMaxUnavailable
+		in.MaxUnavailableReplicas = &maxUnavailable
+	}
+	if in.ProbeConfig == nil {
+		changed = true
+		in.ProbeConfig = &pod.Probes{}
+		in.ProbeConfig.SetDefault()
+	} else if in.ProbeConfig.SetDefault() {
+		changed = true
+	}
+	if in.JVMOptions.setDefaults() {
+		changed = true
+	}
+	if in.PodConfig.Spec.TerminationGracePeriodSeconds == nil {
+		changed = true
+		gracePeriod := defaultTerminationGracePeriod
+		in.PodConfig.Spec.TerminationGracePeriodSeconds = &gracePeriod
+	}
 	return
 }
+
+func (in *PulsarProxySpec) createLabels(proxyName string) map[string]string {
+	labels := map[string]string{}
+	for k, v := range in.Labels {
+		labels[k] = v
+	}
+	for k, v := range proxySelectorLabels(proxyName) {
+		labels[k] = v
+	}
+	return labels
+}
+
+// proxySelectorLabels returns the labels selecting the proxy pods. They're immutable
+// since the deployment selector cannot be changed
+func proxySelectorLabels(proxyName string) map[string]string {
+	return map[string]string{
+		"app":                 "pulsar",
+		"component":           "proxy",
+		k8s.LabelAppName:      "pulsar-proxy",
+		k8s.LabelAppInstance:  proxyName,
+		k8s.LabelAppManagedBy: internal.OperatorName,
+	}
+}
@@ -13,19 +13,69 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package v1alpha1
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // PulsarProxyStatus defines the observed state of PulsarProxy
 type PulsarProxyStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ObservedGeneration is the most recent generation of the proxy spec observed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Replicas is the number of the proxy pods
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of the ready proxy pods
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// CurrentVersion is the pulsar version the proxies are running
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// ServiceURL is the URL the clients connect to the proxy with
+	// +optional
+	ServiceURL string `json:"serviceURL,omitempty"`
+
+	// BrokerServiceURL is the URL the proxy connects to the brokers with
+	// +optional
+	BrokerServiceURL string `json:"brokerServiceURL,omitempty"`
+
+	// BrokerWebServiceURL is the URL the proxy forwards the HTTP requests to
+	// +optional
+	BrokerWebServiceURL string `json:"brokerWebServiceURL,omitempty"`
+
+	// Conditions defines the latest observations of the proxy state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
-// setDefaults set the defaults for the cluster spec and returns true otherwise false
+// setDefaults set the defaults for the proxy status and returns true otherwise false
 func (in *PulsarProxyStatus) setDefaults() (changed bool) {
 	return
 }
+
+// SetCondition adds or updates the condition of the specified type.
+// The transition time is only changed when the condition status changes
+func (in *PulsarProxyStatus) SetCondition(conditionType string, status metav1.ConditionStatus,
+	reason, message string, generation int64) {
+	meta.SetStatusCondition(&in.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+// GetCondition returns the condition of the specified type or nil if it does not exist
+func (in *PulsarProxyStatus) GetCondition(conditionType string) *metav1.Condition {
+	return meta.FindStatusCondition(in.Conditions, conditionType)
+}
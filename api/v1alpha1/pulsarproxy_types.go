@@ -13,18 +13,29 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package v1alpha1
 
 import (
+	"fmt"
+	"github.com/monimesl/operator-helper/basetype"
+	"github.com/monimesl/operator-helper/reconciler"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+var (
+	_ reconciler.Defaulting = &PulsarProxy{}
+)
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterRef.name`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`
+//+kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.spec.replicas`
+//+kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+//+kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // PulsarProxy is the Schema for the pulsarproxies API
 type PulsarProxy struct {
@@ -48,12 +59,77 @@ func init() {
 	SchemeBuilder.Register(&PulsarProxy{}, &PulsarProxyList{})
 }
 
-// SetSpecDefaults set the defaults for the cluster spec and returns true otherwise false
+// SetSpecDefaults set the defaults for the proxy spec and returns true otherwise false
 func (in *PulsarProxy) SetSpecDefaults() bool {
 	return in.Spec.setDefaults()
 }
 
-// SetStatusDefaults set the defaults for the cluster status and returns true otherwise false
+// SetStatusDefaults set the defaults for the proxy status and returns true otherwise false
 func (in *PulsarProxy) SetStatusDefaults() bool {
 	return in.Status.setDefaults()
 }
+
+func (in *PulsarProxy) generateName() string {
+	if strings.Contains(in.Name, "proxy") {
+		return in.Name
+	}
+	return fmt.Sprintf("%s-proxy", in.Name)
+}
+
+// ConfigMapName defines the name of the configmap object
+func (in *PulsarProxy) ConfigMapName() string {
+	return in.generateName()
+}
+
+// DeploymentName defines the name of the deployment object
+func (in *PulsarProxy) DeploymentName() string {
+	return in.generateName()
+}
+
+// ServiceName defines the name of the service object
+func (in *PulsarProxy) ServiceName() string {
+	return in.generateName()
+}
+
+// PodDisruptionBudgetName defines the name of the poddisruptionbudget object
+func (in *PulsarProxy) PodDisruptionBudgetName() string {
+	return in.generateName()
+}
+
+// ClusterKey returns the namespaced name of the referenced PulsarCluster
+func (in *PulsarProxy) ClusterKey() types.NamespacedName {
+	return types.NamespacedName{Name: in.Spec.ClusterRef.Name, Namespace: in.Namespace}
+}
+
+// PulsarVersion returns the version of the proxy; the version of the cluster unless it's set
+func (in *PulsarProxy) PulsarVersion(cluster *PulsarCluster) string {
+	if in.Spec.PulsarVersion != "" {
+		return in.Spec.PulsarVersion
+	}
+	return cluster.Spec.PulsarVersion
+}
+
+// VersionLabel returns the version of the proxy as a valid label value
+func (in *PulsarProxy) VersionLabel(cluster *PulsarCluster) string {
+	return versionLabel(in.PulsarVersion(cluster))
+}
+
+// Image specifies the pulsar image of the proxy
+func (in *PulsarProxy) Image(cluster *PulsarCluster) basetype.Image {
+	return pulsarImage(imageRepository, in.PulsarVersion(cluster), in.Spec.ImagePullPolicy)
+}
+
+// GenerateLabels generates the labels of the proxy objects
+func (in *PulsarProxy) GenerateLabels() map[string]string {
+	return in.Spec.createLabels(in.Name)
+}
+
+// SelectorLabels returns the labels selecting the proxy pods
+func (in *PulsarProxy) SelectorLabels() map[string]string {
+	return proxySelectorLabels(in.Name)
+}
+
+// GenerateAnnotations generates the annotations of the proxy objects
+func (in *PulsarProxy) GenerateAnnotations() map[string]string {
+	return in.Spec.Annotations
+}
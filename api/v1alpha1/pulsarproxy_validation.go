@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"fmt"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sort"
+)
+
+// proxyDerivedConfigs are the proxy configs derived from the spec and the referenced cluster
+var proxyDerivedConfigs = []string{
+	"clusterName", "brokerServiceURL", "brokerWebServiceURL",
+	"servicePort", "webServicePort", "statusFilePath",
+}
+
+// validate validates the proxy spec and returns the admission warnings of the ignored settings
+func (in *PulsarProxy) validate() (admission.Warnings, error) {
+	spec := &in.Spec
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if spec.ClusterRef.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("clusterRef", "name"), "the PulsarCluster is required"))
+	}
+	if spec.PulsarVersion != "" {
+		if _, err := version.Parse(spec.PulsarVersion); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("pulsarVersion"), spec.PulsarVersion, err.Error()))
+		}
+	}
+	if ports := spec.Ports; ports != nil && ports.Client > 0 && ports.Client == ports.Web {
+		errs = append(errs, field.Duplicate(specPath.Child("ports", "web"), ports.Web))
+	}
+	if len(errs) > 0 {
+		return nil, in.invalidError(errs)
+	}
+	return in.warnings(), nil
+}
+
+func (in *PulsarProxy) warnings() admission.Warnings {
+	var warnings admission.Warnings
+	keys := make([]string, 0, len(in.Spec.ProxyConfig))
+	for key := range in.Spec.ProxyConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if containsString(proxyDerivedConfigs, key) {
+			warnings = append(warnings, fmt.Sprintf("spec.proxyConfig.%s: is ignored since it's derived "+
+				"from the spec and the referenced cluster", key))
+		}
+	}
+	return warnings
+}
+
+func (in *PulsarProxy) invalidError(errs field.ErrorList) error {
+	return apierrors.NewInvalid(GroupVersion.WithKind("PulsarProxy").GroupKind(), in.Name, errs)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestPulsarProxyValidate(t *testing.T) {
+	t.Parallel()
+	cluster := ClusterReference{Name: "pulsar"}
+	tests := []struct {
+		name         string
+		spec         PulsarProxySpec
+		wantErr      bool
+		wantWarnings int
+	}{
+		{name: "valid", spec: PulsarProxySpec{ClusterRef: cluster, PulsarVersion: "3.0.1"}},
+		{name: "missing cluster", spec: PulsarProxySpec{}, wantErr: true},
+		{name: "invalid version", spec: PulsarProxySpec{ClusterRef: cluster, PulsarVersion: "three"}, wantErr: true},
+		{name: "same ports", spec: PulsarProxySpec{ClusterRef: cluster, Ports: &ProxyPorts{Client: 8080, Web: 8080}}, wantErr: true},
+		{name: "derived configs", spec: PulsarProxySpec{ClusterRef: cluster, ProxyConfig: map[string]string{
+			"brokerServiceURL": "pulsar://broker:6650", "clusterName": "other", "numIOThreads": "4"}}, wantWarnings: 2},
+	}
+	for _, tt := range tests {
+		proxy := &PulsarProxy{ObjectMeta: metav1.ObjectMeta{Name: "edge"}, Spec: tt.spec}
+		warnings, err := proxy.validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+		if len(warnings) != tt.wantWarnings {
+			t.Errorf("%s: expected %d warnings; got: %v", tt.name, tt.wantWarnings, warnings)
+		}
+	}
+}
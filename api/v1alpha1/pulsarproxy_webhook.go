@@ -32,8 +32,6 @@ func (in *PulsarProxy) SetupWebhookWithManager(mgr ctrl.Manager) error {
 		Complete()
 }
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-
 //+kubebuilder:webhook:path=/mutate-pulsar-monime-sl-v1alpha1-pulsarproxy,mutating=true,failurePolicy=fail,sideEffects=None,groups=pulsar.monime.sl,resources=pulsarproxies,verbs=create;update,versions=v1alpha1,name=mpulsarproxy.kb.io,admissionReviewVersions={v1,v1beta1}
 
 var _ webhook.Defaulter = &PulsarProxy{}
@@ -45,7 +43,6 @@ func (in *PulsarProxy) Default() {
 	in.SetStatusDefaults()
 }
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
 //+kubebuilder:webhook:path=/validate-pulsar-monime-sl-v1alpha1-pulsarproxy,mutating=false,failurePolicy=fail,sideEffects=None,groups=pulsar.monime.sl,resources=pulsarproxies,verbs=create;update,versions=v1alpha1,name=vpulsarproxy.kb.io,admissionReviewVersions={v1,v1beta1}
 
 var _ webhook.Validator = &PulsarProxy{}
@@ -53,13 +50,13 @@ var _ webhook.Validator = &PulsarProxy{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (in *PulsarProxy) ValidateCreate() (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate create]", "name", in.Name)
-	return nil, nil
+	return in.validate()
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (in *PulsarProxy) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+func (in *PulsarProxy) ValidateUpdate(_ runtime.Object) (admission.Warnings, error) {
 	config.RequireRootLogger().Info("[validate update]", "name", in.Name)
-	return nil, nil
+	return in.validate()
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
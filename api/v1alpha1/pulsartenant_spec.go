@@ -0,0 +1,53 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+// ClusterReference references a PulsarCluster in the namespace of the referencing object
+type ClusterReference struct {
+	// Name of the PulsarCluster
+	Name string `json:"name"`
+}
+
+// PulsarTenantSpec defines the desired state of PulsarTenant
+type PulsarTenantSpec struct {
+	// ClusterRef references the PulsarCluster hosting the tenant
+	ClusterRef ClusterReference `json:"clusterRef"`
+	// Name defines the name of the tenant in pulsar. Defaults to the object name
+	// +optional
+	Name string `json:"name,omitempty"`
+	// AdminRoles defines the roles allowed to administer the tenant
+	// +listType=set
+	// +optional
+	AdminRoles []string `json:"adminRoles,omitempty"`
+	// AllowedClusters defines the pulsar clusters the tenant can use.
+	// Defaults to the referenced cluster
+	// +listType=set
+	// +optional
+	AllowedClusters []string `json:"allowedClusters,omitempty"`
+	// DriftPolicy defines how the changes made to the tenant outside the operator are handled.
+	// Defaults to Revert
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+}
+
+// setDefaults set the defaults for the tenant spec and returns true otherwise false
+func (in *PulsarTenantSpec) setDefaults() (changed bool) {
+	if in.DriftPolicy == "" {
+		changed = true
+		in.DriftPolicy = DriftPolicyRevert
+	}
+	return
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"github.com/monimesl/operator-helper/reconciler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	_ reconciler.Defaulting = &PulsarTenant{}
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Tenant",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterRef.name`
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Drifted",type=string,priority=1,JSONPath=`.status.conditions[?(@.type=="Drifted")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PulsarTenant is the Schema for the pulsartenants API
+type PulsarTenant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PulsarTenantSpec   `json:"spec,omitempty"`
+	Status PulsarTenantStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PulsarTenantList contains a list of PulsarTenant
+type PulsarTenantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulsarTenant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulsarTenant{}, &PulsarTenantList{})
+}
+
+// SetSpecDefaults set the defaults for the tenant spec and returns true otherwise false
+func (in *PulsarTenant) SetSpecDefaults() bool {
+	return in.Spec.setDefaults()
+}
+
+// SetStatusDefaults set the defaults for the tenant status and returns true otherwise false
+func (in *PulsarTenant) SetStatusDefaults() bool {
+	return in.Status.setDefaults()
+}
+
+// TenantName returns the name of the tenant in pulsar
+func (in *PulsarTenant) TenantName() string {
+	if in.Spec.Name != "" {
+		return in.Spec.Name
+	}
+	return in.Name
+}
+
+// GetSyncStatus returns the sync status of the tenant
+func (in *PulsarTenant) GetSyncStatus() *SyncStatus {
+	return &in.Status.SyncStatus
+}
+
+// ClusterKey returns the namespaced name of the referenced PulsarCluster
+func (in *PulsarTenant) ClusterKey() types.NamespacedName {
+	return types.NamespacedName{Name: in.Spec.ClusterRef.Name, Namespace: in.Namespace}
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"regexp"
+)
+
+// pulsarNameRegex matches the names pulsar accepts for tenants, namespaces and topics
+var pulsarNameRegex = regexp.MustCompile(`^[-=:.\w]+$`)
+
+// validate validates the tenant spec
+func (in *PulsarTenant) validate() error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if in.Spec.ClusterRef.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("clusterRef", "name"), "the PulsarCluster is required"))
+	}
+	if name := in.TenantName(); !pulsarNameRegex.MatchString(name) {
+		errs = append(errs, field.Invalid(specPath.Child("name"), name,
+			"the tenant name may only contain letters, digits and the characters: - = : . _"))
+	}
+	errs = append(errs, validateRoles(in.Spec.AdminRoles, specPath.Child("adminRoles"))...)
+	for i, cluster := range in.Spec.AllowedClusters {
+		if !pulsarNameRegex.MatchString(cluster) {
+			errs = append(errs, field.Invalid(specPath.Child("allowedClusters").Index(i), cluster,
+				"must be a valid pulsar cluster name"))
+		}
+	}
+	return in.invalidError(errs)
+}
+
+// validateUpdate validates the changes from the old tenant spec
+func (in *PulsarTenant) validateUpdate(old *PulsarTenant) error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if in.TenantName() != old.TenantName() {
+		errs = append(errs, field.Forbidden(specPath.Child("name"), "the tenant name cannot be changed"))
+	}
+	if in.Spec.ClusterRef != old.Spec.ClusterRef {
+		errs = append(errs, field.Forbidden(specPath.Child("clusterRef"), "the tenant cannot be moved to another cluster"))
+	}
+	return in.invalidError(errs)
+}
+
+func (in *PulsarTenant) invalidError(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("PulsarTenant").GroupKind(), in.Name, errs)
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestPulsarTenantValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		spec    PulsarTenantSpec
+		wantErr bool
+	}{
+		{name: "valid", spec: PulsarTenantSpec{ClusterRef: ClusterReference{Name: "pulsar"}, AdminRoles: []string{"admin"}}},
+		{name: "missing cluster", spec: PulsarTenantSpec{}, wantErr: true},
+		{name: "invalid name", spec: PulsarTenantSpec{ClusterRef: ClusterReference{Name: "pulsar"}, Name: "team/a"}, wantErr: true},
+		{name: "duplicate roles", spec: PulsarTenantSpec{ClusterRef: ClusterReference{Name: "pulsar"}, AdminRoles: []string{"a", "a"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		tenant := &PulsarTenant{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}, Spec: tt.spec}
+		if err := tenant.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+	old := &PulsarTenant{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}, Spec: PulsarTenantSpec{ClusterRef: ClusterReference{Name: "pulsar"}}}
+	renamed := old.DeepCopyObject().(*PulsarTenant)
+	renamed.Spec.Name = "team-b"
+	if err := renamed.validateUpdate(old); err == nil {
+		t.Error("expected the tenant rename to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// TopicPersistence defines whether the messages of a topic are persisted
+// +kubebuilder:validation:Enum=persistent;non-persistent
+type TopicPersistence string
+
+const (
+	// TopicPersistent persists the messages to bookkeeper
+	TopicPersistent TopicPersistence = "persistent"
+	// TopicNonPersistent keeps the messages in the broker memory only
+	TopicNonPersistent TopicPersistence = "non-persistent"
+)
+
+// DeletionPolicy defines what happens to the pulsar resource when its object is deleted
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps the resource and its data in pulsar
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete deletes the resource from pulsar
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// PulsarTopicSpec defines the desired state of PulsarTopic
+type PulsarTopicSpec struct {
+	// ClusterRef references the PulsarCluster hosting the topic
+	ClusterRef ClusterReference `json:"clusterRef"`
+	// Namespace defines the pulsar namespace of the topic in the format `tenant/namespace`
+	Namespace string `json:"namespace"`
+	// Name defines the name of the topic in the namespace. Defaults to the object name
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Persistence defines whether the messages are persisted. Defaults to persistent.
+	// A non-partitioned non-persistent topic only exists while loaded on a broker; it's
+	// created on demand by its clients and its absence while idle is not a drift
+	// +optional
+	Persistence TopicPersistence `json:"persistence,omitempty"`
+	// Partitions defines the partitions of the topic. 0 creates a non-partitioned topic.
+	// The partitions can be increased but never decreased
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Partitions int32 `json:"partitions,omitempty"`
+	// Policies defines the topic level policies. The unset policies are not managed
+	// by the operator and are inherited from the namespace. The topic level policies
+	// require the brokers to run with `topicLevelPoliciesEnabled=true`
+	// +optional
+	Policies TopicPolicies `json:"policies,omitempty"`
+	// DeletionPolicy defines whether the topic is deleted from pulsar when the object is deleted.
+	// Defaults to Retain so that deleting the object never drops the messages
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// DriftPolicy defines how the changes made to the topic outside the operator are handled.
+	// Defaults to Revert
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+}
+
+// TopicPolicies defines the topic level policies
+type TopicPolicies struct {
+	// Retention defines the retention of the acknowledged messages
+	// +optional
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// MessageTTLSeconds defines the time after which the unacknowledged messages expire. 0 disables it
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MessageTTLSeconds *int32 `json:"messageTTLSeconds,omitempty"`
+	// BacklogQuota defines the size limit of the unacknowledged backlog
+	// +optional
+	BacklogQuota *BacklogQuota `json:"backlogQuota,omitempty"`
+	// PublishRate defines the publish rate limit of the topic
+	// +optional
+	PublishRate *PublishRate `json:"publishRate,omitempty"`
+	// DispatchRate defines the dispatch rate limit of the topic
+	// +optional
+	DispatchRate *DispatchRate `json:"dispatchRate,omitempty"`
+	// Deduplication enables the message deduplication
+	// +optional
+	Deduplication *bool `json:"deduplication,omitempty"`
+	// CompactionThreshold defines the backlog size that triggers the topic compaction. 0 disables it
+	// +optional
+	CompactionThreshold *resource.Quantity `json:"compactionThreshold,omitempty"`
+}
+
+// setDefaults set the defaults for the topic spec and returns true otherwise false
+func (in *PulsarTopicSpec) setDefaults() (changed bool) {
+	if in.Persistence == "" {
+		changed = true
+		in.Persistence = TopicPersistent
+	}
+	if in.DeletionPolicy == "" {
+		changed = true
+		in.DeletionPolicy = DeletionPolicyRetain
+	}
+	if in.DriftPolicy == "" {
+		changed = true
+		in.DriftPolicy = DriftPolicyRevert
+	}
+	if rate := in.Policies.DispatchRate; rate != nil && rate.PeriodSeconds == 0 {
+		changed = true
+		rate.PeriodSeconds = 1
+	}
+	return
+}
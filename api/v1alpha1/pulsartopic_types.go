@@ -0,0 +1,91 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"github.com/monimesl/operator-helper/reconciler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	_ reconciler.Defaulting = &PulsarTopic{}
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.namespace`
+//+kubebuilder:printcolumn:name="Topic",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Partitions",type=integer,JSONPath=`.status.partitions`
+//+kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.clusterRef.name`
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Drifted",type=string,priority=1,JSONPath=`.status.conditions[?(@.type=="Drifted")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PulsarTopic is the Schema for the pulsartopics API
+type PulsarTopic struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PulsarTopicSpec   `json:"spec,omitempty"`
+	Status PulsarTopicStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PulsarTopicList contains a list of PulsarTopic
+type PulsarTopicList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PulsarTopic `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PulsarTopic{}, &PulsarTopicList{})
+}
+
+// SetSpecDefaults set the defaults for the topic spec and returns true otherwise false
+func (in *PulsarTopic) SetSpecDefaults() bool {
+	return in.Spec.setDefaults()
+}
+
+// SetStatusDefaults set the defaults for the topic status and returns true otherwise false
+func (in *PulsarTopic) SetStatusDefaults() bool {
+	return in.Status.setDefaults()
+}
+
+// TopicName returns the name of the topic in pulsar in the format `persistence://tenant/namespace/topic`
+func (in *PulsarTopic) TopicName() string {
+	return string(in.Spec.Persistence) + "://" + in.Spec.Namespace + "/" + in.topicLocalName()
+}
+
+// topicLocalName returns the name of the topic without the namespace
+func (in *PulsarTopic) topicLocalName() string {
+	if in.Spec.Name != "" {
+		return in.Spec.Name
+	}
+	return in.Name
+}
+
+// GetSyncStatus returns the sync status of the topic
+func (in *PulsarTopic) GetSyncStatus() *SyncStatus {
+	return &in.Status.SyncStatus
+}
+
+// ClusterKey returns the namespaced name of the referenced PulsarCluster
+func (in *PulsarTopic) ClusterKey() types.NamespacedName {
+	return types.NamespacedName{Name: in.Spec.ClusterRef.Name, Namespace: in.Namespace}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"strings"
+)
+
+// validate validates the topic spec
+func (in *PulsarTopic) validate() error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if in.Spec.ClusterRef.Name == "" {
+		errs = append(errs, field.Required(specPath.Child("clusterRef", "name"), "the PulsarCluster is required"))
+	}
+	if parts := strings.Split(in.Spec.Namespace, "/"); len(parts) != 2 ||
+		!pulsarNameRegex.MatchString(parts[0]) || !pulsarNameRegex.MatchString(parts[1]) {
+		errs = append(errs, field.Invalid(specPath.Child("namespace"), in.Spec.Namespace,
+			"must be a pulsar namespace in the format tenant/namespace"))
+	}
+	if name := in.topicLocalName(); !pulsarNameRegex.MatchString(name) {
+		errs = append(errs, field.Invalid(specPath.Child("name"), name,
+			"the topic name may only contain letters, digits and the characters: - = : . _"))
+	}
+	if in.Spec.Partitions < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("partitions"), in.Spec.Partitions, "must not be negative"))
+	}
+	policies := in.Spec.Policies
+	policiesPath := specPath.Child("policies")
+	errs = append(errs, validateMessagePolicies(policies.Retention, policies.MessageTTLSeconds, policies.BacklogQuota, policiesPath)...)
+	if threshold := policies.CompactionThreshold; threshold != nil {
+		if threshold.Sign() < 0 {
+			errs = append(errs, field.Invalid(policiesPath.Child("compactionThreshold"), threshold.String(), "must not be negative"))
+		} else if in.Spec.Persistence == TopicNonPersistent {
+			errs = append(errs, field.Forbidden(policiesPath.Child("compactionThreshold"),
+				"the non-persistent topics cannot be compacted"))
+		}
+	}
+	return in.invalidError(errs)
+}
+
+// validateUpdate validates the changes from the old topic spec
+func (in *PulsarTopic) validateUpdate(old *PulsarTopic) error {
+	specPath := field.NewPath("spec")
+	var errs field.ErrorList
+	if in.TopicName() != old.TopicName() {
+		errs = append(errs, field.Forbidden(specPath.Child("name"),
+			"the topic name, namespace and persistence cannot be changed"))
+	}
+	if in.Spec.ClusterRef != old.Spec.ClusterRef {
+		errs = append(errs, field.Forbidden(specPath.Child("clusterRef"), "the topic cannot be moved to another cluster"))
+	}
+	partitionsPath := specPath.Child("partitions")
+	switch {
+	case (old.Spec.Partitions == 0) != (in.Spec.Partitions == 0):
+		errs = append(errs, field.Forbidden(partitionsPath,
+			"a non-partitioned topic cannot be changed to a partitioned one and vice versa"))
+	case in.Spec.Partitions < old.Spec.Partitions:
+		errs = append(errs, field.Invalid(partitionsPath, in.Spec.Partitions,
+			"the partitions cannot be decreased"))
+	}
+	return in.invalidError(errs)
+}
+
+func (in *PulsarTopic) invalidError(errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("PulsarTopic").GroupKind(), in.Name, errs)
+}
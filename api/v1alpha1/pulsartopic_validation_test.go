@@ -0,0 +1,69 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestPulsarTopicValidate(t *testing.T) {
+	t.Parallel()
+	cluster := ClusterReference{Name: "pulsar"}
+	threshold := resource.MustParse("100Mi")
+	tests := []struct {
+		name    string
+		spec    PulsarTopicSpec
+		wantErr bool
+	}{
+		{name: "valid", spec: PulsarTopicSpec{ClusterRef: cluster, Namespace: "team-a/orders", Persistence: TopicPersistent,
+			Partitions: 3, Policies: TopicPolicies{CompactionThreshold: &threshold}}},
+		{name: "invalid namespace", spec: PulsarTopicSpec{ClusterRef: cluster, Namespace: "orders", Persistence: TopicPersistent}, wantErr: true},
+		{name: "invalid name", spec: PulsarTopicSpec{ClusterRef: cluster, Namespace: "team-a/orders", Name: "a/b"}, wantErr: true},
+		{name: "non-persistent compaction", spec: PulsarTopicSpec{ClusterRef: cluster, Namespace: "team-a/orders",
+			Persistence: TopicNonPersistent, Policies: TopicPolicies{CompactionThreshold: &threshold}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		topic := &PulsarTopic{ObjectMeta: metav1.ObjectMeta{Name: "payments"}, Spec: tt.spec}
+		if err := topic.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestPulsarTopicValidateUpdate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		old, new int32
+		wantErr  bool
+	}{
+		{name: "increase", old: 2, new: 4},
+		{name: "decrease", old: 4, new: 2, wantErr: true},
+		{name: "to partitioned", old: 0, new: 2, wantErr: true},
+		{name: "to non-partitioned", old: 2, new: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		old := &PulsarTopic{ObjectMeta: metav1.ObjectMeta{Name: "payments"}, Spec: PulsarTopicSpec{
+			ClusterRef: ClusterReference{Name: "pulsar"}, Namespace: "team-a/orders", Partitions: tt.old}}
+		updated := old.DeepCopyObject().(*PulsarTopic)
+		updated.Spec.Partitions = tt.new
+		if err := updated.validateUpdate(old); (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error: %v; got: %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
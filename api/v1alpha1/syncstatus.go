@@ -0,0 +1,79 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package v1alpha1
+
+import (
+	"github.com/monimesl/pulsar-operator/internal"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionSynced indicates whether the last sync of the resource to pulsar succeeded
+	ConditionSynced = "Synced"
+	// ConditionDrifted indicates whether the resource was modified in pulsar outside the operator
+	ConditionDrifted = "Drifted"
+)
+
+// ForceDeleteAnnotation set to "true" removes the finalizer of a deleted resource without
+// deleting it from pulsar. It unblocks the deletion while the cluster is degraded
+const ForceDeleteAnnotation = internal.Domain + "/force-delete"
+
+// DriftPolicy defines how the changes made in pulsar outside the operator are handled
+// +kubebuilder:validation:Enum=Revert;Report
+type DriftPolicy string
+
+const (
+	// DriftPolicyRevert reverts the out-of-band changes to the spec
+	DriftPolicyRevert DriftPolicy = "Revert"
+	// DriftPolicyReport keeps the out-of-band changes and reports them in the Drifted condition
+	DriftPolicyReport DriftPolicy = "Report"
+)
+
+// SyncStatus defines the sync state of a resource managed through the pulsar admin API
+type SyncStatus struct {
+	// ObservedGeneration is the most recent generation of the spec synced to pulsar
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is the last time the resource was synced to pulsar
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// Conditions defines the latest observations of the resource state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SetCondition adds or updates the condition of the specified type.
+// The transition time is only changed when the condition status changes
+func (in *SyncStatus) SetCondition(conditionType string, status metav1.ConditionStatus,
+	reason, message string, generation int64) {
+	meta.SetStatusCondition(&in.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+// GetCondition returns the condition of the specified type or nil if it does not exist
+func (in *SyncStatus) GetCondition(conditionType string) *metav1.Condition {
+	return meta.FindStatusCondition(in.Conditions, conditionType)
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package admin provides a minimal client of the pulsar admin REST API
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxErrorBody   = 1024
+)
+
+// Error is returned when the admin API responds with a non 2xx status code
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pulsar admin api error; status: %d, message: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound checks whether the error is an admin API 404 error
+func IsNotFound(err error) bool {
+	return hasStatusCode(err, http.StatusNotFound)
+}
+
+// IsConflict checks whether the error is an admin API 409 error e.g. deleting a non-empty tenant
+func IsConflict(err error) bool {
+	return hasStatusCode(err, http.StatusConflict)
+}
+
+// IsUnreachable checks whether the error is not a response of the admin API e.g. a connection failure
+func IsUnreachable(err error) bool {
+	var apiErr *Error
+	return err != nil && !errors.As(err, &apiErr)
+}
+
+func hasStatusCode(err error, statusCode int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// Client is a client of the pulsar admin REST API of a broker or cluster
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a new admin client for the web service URL e.g http://broker:8080
+func NewClient(webServiceURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(webServiceURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// WithToken sets the token the client authenticates to the admin API with
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// WithCACert makes the client trust the PEM encoded CA certificate for the https web service URLs
+func (c *Client) WithCACert(caCert []byte) (*Client, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("the CA certificate has no valid PEM certificate")
+	}
+	c.httpClient.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}
+	return c, nil
+}
+
+// HealthCheck runs the broker health check. It returns nil if the broker is healthy
+func (c *Client) HealthCheck() error {
+	return c.do(http.MethodGet, "/admin/v2/brokers/health", nil, nil)
+}
+
+// Ready checks whether the broker is initialized and serving. It's lighter than the health check
+// which produces and consumes a message; it's used to probe the reachability of the peer clusters
+func (c *Client) Ready() error {
+	return c.do(http.MethodGet, "/admin/v2/brokers/ready", nil, nil)
+}
+
+// OwnedNamespaceBundles returns the namespace bundles owned by the broker in the format `tenant/namespace/bundle`
+func (c *Client) OwnedNamespaceBundles(cluster, broker string) ([]string, error) {
+	owned := map[string]interface{}{}
+	path := fmt.Sprintf("/admin/v2/brokers/%s/%s/ownedNamespaces", cluster, broker)
+	if err := c.do(http.MethodGet, path, nil, &owned); err != nil {
+		return nil, err
+	}
+	bundles := make([]string, 0, len(owned))
+	for bundle := range owned {
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+// UnloadNamespaceBundle unloads the namespace bundle in the format `tenant/namespace/bundle`
+// from its current owner broker so that it's reassigned to another broker
+func (c *Client) UnloadNamespaceBundle(namespaceBundle string) error {
+	return c.do(http.MethodPut, fmt.Sprintf("/admin/v2/namespaces/%s/unload", namespaceBundle), nil, nil)
+}
+
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(context.TODO(), method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		return &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	// the unset policies are returned as an empty body by some pulsar versions
+	if err = json.NewDecoder(res.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package admin
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// ClusterData defines the service URLs and the replication client settings of a cluster
+type ClusterData struct {
+	ServiceURL               string `json:"serviceUrl,omitempty"`
+	ServiceURLTLS            string `json:"serviceUrlTls,omitempty"`
+	BrokerServiceURL         string `json:"brokerServiceUrl,omitempty"`
+	BrokerServiceURLTLS      string `json:"brokerServiceUrlTls,omitempty"`
+	AuthenticationPlugin     string `json:"authenticationPlugin,omitempty"`
+	AuthenticationParameters string `json:"authenticationParameters,omitempty"`
+}
+
+// GetCluster returns the data of the cluster
+func (c *Client) GetCluster(cluster string) (*ClusterData, error) {
+	data := &ClusterData{}
+	if err := c.do(http.MethodGet, "/admin/v2/clusters/"+url.PathEscape(cluster), nil, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// CreateCluster registers the cluster in the configuration store
+func (c *Client) CreateCluster(cluster string, data *ClusterData) error {
+	return c.do(http.MethodPut, "/admin/v2/clusters/"+url.PathEscape(cluster), data, nil)
+}
+
+// UpdateCluster updates the service URLs and the replication client settings of the cluster
+func (c *Client) UpdateCluster(cluster string, data *ClusterData) error {
+	return c.do(http.MethodPost, "/admin/v2/clusters/"+url.PathEscape(cluster), data, nil)
+}
+
+// DeleteCluster removes the cluster from the configuration store. It fails
+// if the cluster is still in the replication clusters of a namespace
+func (c *Client) DeleteCluster(cluster string) error {
+	return c.do(http.MethodDelete, "/admin/v2/clusters/"+url.PathEscape(cluster), nil, nil)
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BacklogQuotaDestinationStorage is the backlog quota type limiting the backlog size
+const BacklogQuotaDestinationStorage = "destination_storage"
+
+// NamespacePolicies is the subset of the pulsar namespace policies managed by the operator
+type NamespacePolicies struct {
+	AuthPolicies                AuthPolicies            `json:"auth_policies"`
+	ReplicationClusters         []string                `json:"replication_clusters"`
+	BacklogQuotaMap             map[string]BacklogQuota `json:"backlog_quota_map"`
+	ClusterDispatchRate         map[string]DispatchRate `json:"clusterDispatchRate"`
+	PublishMaxMessageRate       map[string]PublishRate  `json:"publishMaxMessageRate"`
+	DeduplicationEnabled        *bool                   `json:"deduplicationEnabled"`
+	MessageTTLInSeconds         *int32                  `json:"message_ttl_in_seconds"`
+	RetentionPolicies           *RetentionPolicies      `json:"retention_policies"`
+	SchemaCompatibilityStrategy string                  `json:"schema_compatibility_strategy"`
+}
+
+// AuthPolicies defines the permissions granted on the namespace
+type AuthPolicies struct {
+	NamespaceAuth map[string][]string `json:"namespace_auth"`
+}
+
+// RetentionPolicies defines the retention of the acknowledged messages
+type RetentionPolicies struct {
+	RetentionTimeInMinutes int32 `json:"retentionTimeInMinutes"`
+	RetentionSizeInMB      int64 `json:"retentionSizeInMB"`
+}
+
+// BacklogQuota defines the limit of the unacknowledged backlog
+type BacklogQuota struct {
+	// Limit is the size limit of the pulsar versions before 2.9
+	Limit     int64  `json:"limit,omitempty"`
+	LimitSize int64  `json:"limitSize,omitempty"`
+	Policy    string `json:"policy"`
+}
+
+// SizeLimit returns the size limit of the backlog quota regardless of the pulsar version
+func (q BacklogQuota) SizeLimit() int64 {
+	if q.LimitSize > 0 {
+		return q.LimitSize
+	}
+	return q.Limit
+}
+
+// PublishRate defines the publish rate limit
+type PublishRate struct {
+	PublishThrottlingRateInMsg  int32 `json:"publishThrottlingRateInMsg"`
+	PublishThrottlingRateInByte int64 `json:"publishThrottlingRateInByte"`
+}
+
+// DispatchRate defines the dispatch rate limit
+type DispatchRate struct {
+	DispatchThrottlingRateInMsg  int32 `json:"dispatchThrottlingRateInMsg"`
+	DispatchThrottlingRateInByte int64 `json:"dispatchThrottlingRateInByte"`
+	RatePeriodInSecond           int32 `json:"ratePeriodInSecond"`
+	RelativeToPublishRate        bool  `json:"relativeToPublishRate"`
+}
+
+// GetNamespacePolicies returns the policies of the namespace in the format `tenant/namespace`
+func (c *Client) GetNamespacePolicies(namespace string) (*NamespacePolicies, error) {
+	policies := &NamespacePolicies{}
+	if err := c.do(http.MethodGet, namespacePath(namespace, ""), nil, policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// CreateNamespace creates the namespace. The default bundle count of the cluster is used if bundles is zero
+func (c *Client) CreateNamespace(namespace string, bundles int32) error {
+	policies := map[string]interface{}{}
+	if bundles > 0 {
+		policies["bundles"] = map[string]interface{}{"numBundles": bundles}
+	}
+	return c.do(http.MethodPut, namespacePath(namespace, ""), policies, nil)
+}
+
+// DeleteNamespace deletes the namespace. It fails with a conflict if the namespace still has topics
+func (c *Client) DeleteNamespace(namespace string) error {
+	return c.do(http.MethodDelete, namespacePath(namespace, ""), nil, nil)
+}
+
+// SetRetention sets the retention policies of the namespace
+func (c *Client) SetRetention(namespace string, retention RetentionPolicies) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "retention"), retention, nil)
+}
+
+// SetMessageTTL sets the message TTL of the namespace
+func (c *Client) SetMessageTTL(namespace string, ttlSeconds int32) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "messageTTL"), ttlSeconds, nil)
+}
+
+// SetBacklogQuota sets the destination storage backlog quota of the namespace
+func (c *Client) SetBacklogQuota(namespace string, quota BacklogQuota) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "backlogQuota"), quota, nil)
+}
+
+// SetPublishRate sets the publish rate limit of the namespace
+func (c *Client) SetPublishRate(namespace string, rate PublishRate) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "publishRate"), rate, nil)
+}
+
+// SetDispatchRate sets the dispatch rate limit of the namespace
+func (c *Client) SetDispatchRate(namespace string, rate DispatchRate) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "dispatchRate"), rate, nil)
+}
+
+// SetDeduplication enables or disables the message deduplication of the namespace
+func (c *Client) SetDeduplication(namespace string, enabled bool) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "deduplication"), enabled, nil)
+}
+
+// SetSchemaCompatibilityStrategy sets the schema compatibility strategy of the namespace
+func (c *Client) SetSchemaCompatibilityStrategy(namespace, strategy string) error {
+	return c.do(http.MethodPut, namespacePath(namespace, "schemaCompatibilityStrategy"), strategy, nil)
+}
+
+// SetReplicationClusters sets the clusters the namespace is replicated to
+func (c *Client) SetReplicationClusters(namespace string, clusters []string) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "replication"), clusters, nil)
+}
+
+// GrantPermission grants the actions on the namespace to the role
+func (c *Client) GrantPermission(namespace, role string, actions []string) error {
+	return c.do(http.MethodPost, namespacePath(namespace, "permissions/"+url.PathEscape(role)), actions, nil)
+}
+
+// RevokePermission revokes all the permissions of the role on the namespace
+func (c *Client) RevokePermission(namespace, role string) error {
+	return c.do(http.MethodDelete, namespacePath(namespace, "permissions/"+url.PathEscape(role)), nil, nil)
+}
+
+// namespacePath returns the admin path of the namespace in the format `tenant/namespace`
+func namespacePath(namespace, subPath string) string {
+	parts := strings.SplitN(namespace, "/", 2)
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	path := "/admin/v2/namespaces/" + strings.Join(parts, "/")
+	if subPath != "" {
+		path += "/" + subPath
+	}
+	return path
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package admin
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// TenantInfo defines the admin roles and the allowed clusters of a tenant
+type TenantInfo struct {
+	AdminRoles      []string `json:"adminRoles"`
+	AllowedClusters []string `json:"allowedClusters"`
+}
+
+// GetTenant returns the info of the tenant
+func (c *Client) GetTenant(tenant string) (*TenantInfo, error) {
+	info := &TenantInfo{}
+	if err := c.do(http.MethodGet, "/admin/v2/tenants/"+url.PathEscape(tenant), nil, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// CreateTenant creates the tenant
+func (c *Client) CreateTenant(tenant string, info *TenantInfo) error {
+	return c.do(http.MethodPut, "/admin/v2/tenants/"+url.PathEscape(tenant), info, nil)
+}
+
+// UpdateTenant updates the admin roles and allowed clusters of the tenant
+func (c *Client) UpdateTenant(tenant string, info *TenantInfo) error {
+	return c.do(http.MethodPost, "/admin/v2/tenants/"+url.PathEscape(tenant), info, nil)
+}
+
+// DeleteTenant deletes the tenant. It fails with a conflict if the tenant still has namespaces
+func (c *Client) DeleteTenant(tenant string) error {
+	return c.do(http.MethodDelete, "/admin/v2/tenants/"+url.PathEscape(tenant), nil, nil)
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PartitionedTopicMetadata defines the partition count of a partitioned topic
+type PartitionedTopicMetadata struct {
+	Partitions int32 `json:"partitions"`
+}
+
+// ListTopics returns the non-partitioned topics and the partitions of the partitioned
+// topics in the namespace. The domain is either `persistent` or `non-persistent`
+func (c *Client) ListTopics(domain, namespace string) ([]string, error) {
+	var topics []string
+	if err := c.do(http.MethodGet, domainNamespacePath(domain, namespace, ""), nil, &topics); err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+// ListPartitionedTopics returns the partitioned topics of the namespace
+func (c *Client) ListPartitionedTopics(domain, namespace string) ([]string, error) {
+	var topics []string
+	if err := c.do(http.MethodGet, domainNamespacePath(domain, namespace, "partitioned"), nil, &topics); err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+// GetPartitionedTopicMetadata returns the metadata of the partitioned topic
+func (c *Client) GetPartitionedTopicMetadata(topic string) (*PartitionedTopicMetadata, error) {
+	metadata := &PartitionedTopicMetadata{}
+	if err := c.do(http.MethodGet, topicPath(topic, "partitions"), nil, metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// CreateTopic creates the non-partitioned topic
+func (c *Client) CreateTopic(topic string) error {
+	return c.do(http.MethodPut, topicPath(topic, ""), nil, nil)
+}
+
+// CreatePartitionedTopic creates the partitioned topic with the partitions
+func (c *Client) CreatePartitionedTopic(topic string, partitions int32) error {
+	return c.do(http.MethodPut, topicPath(topic, "partitions"), partitions, nil)
+}
+
+// UpdatePartitions increases the partitions of the partitioned topic
+func (c *Client) UpdatePartitions(topic string, partitions int32) error {
+	return c.do(http.MethodPost, topicPath(topic, "partitions"), partitions, nil)
+}
+
+// DeleteTopic deletes the topic. It fails if the topic has connected producers or consumers
+func (c *Client) DeleteTopic(topic string, partitioned bool) error {
+	subPath := ""
+	if partitioned {
+		subPath = "partitions"
+	}
+	return c.do(http.MethodDelete, topicPath(topic, subPath), nil, nil)
+}
+
+// GetTopicRetention returns the retention of the topic, or nil if it's not set at the topic level
+func (c *Client) GetTopicRetention(topic string) (*RetentionPolicies, error) {
+	var retention *RetentionPolicies
+	if err := c.do(http.MethodGet, topicPath(topic, "retention"), nil, &retention); err != nil {
+		return nil, err
+	}
+	return retention, nil
+}
+
+// SetTopicRetention sets the retention of the topic
+func (c *Client) SetTopicRetention(topic string, retention RetentionPolicies) error {
+	return c.do(http.MethodPost, topicPath(topic, "retention"), retention, nil)
+}
+
+// GetTopicMessageTTL returns the message TTL of the topic, or nil if it's not set at the topic level
+func (c *Client) GetTopicMessageTTL(topic string) (*int32, error) {
+	var ttl *int32
+	if err := c.do(http.MethodGet, topicPath(topic, "messageTTL"), nil, &ttl); err != nil {
+		return nil, err
+	}
+	return ttl, nil
+}
+
+// SetTopicMessageTTL sets the message TTL of the topic
+func (c *Client) SetTopicMessageTTL(topic string, ttlSeconds int32) error {
+	return c.do(http.MethodPost, topicPath(topic, fmt.Sprintf("messageTTL?messageTTL=%d", ttlSeconds)), nil, nil)
+}
+
+// GetTopicBacklogQuotas returns the backlog quotas of the topic keyed by the quota type
+func (c *Client) GetTopicBacklogQuotas(topic string) (map[string]BacklogQuota, error) {
+	quotas := map[string]BacklogQuota{}
+	if err := c.do(http.MethodGet, topicPath(topic, "backlogQuotaMap"), nil, &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// SetTopicBacklogQuota sets the destination storage backlog quota of the topic
+func (c *Client) SetTopicBacklogQuota(topic string, quota BacklogQuota) error {
+	return c.do(http.MethodPost, topicPath(topic, "backlogQuota"), quota, nil)
+}
+
+// GetTopicPublishRate returns the publish rate limit of the topic, or nil if it's not set at the topic level
+func (c *Client) GetTopicPublishRate(topic string) (*PublishRate, error) {
+	var rate *PublishRate
+	if err := c.do(http.MethodGet, topicPath(topic, "publishRate"), nil, &rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// SetTopicPublishRate sets the publish rate limit of the topic
+func (c *Client) SetTopicPublishRate(topic string, rate PublishRate) error {
+	return c.do(http.MethodPost, topicPath(topic, "publishRate"), rate, nil)
+}
+
+// GetTopicDispatchRate returns the dispatch rate limit of the topic, or nil if it's not set at the topic level
+func (c *Client) GetTopicDispatchRate(topic string) (*DispatchRate, error) {
+	var rate *DispatchRate
+	if err := c.do(http.MethodGet, topicPath(topic, "dispatchRate"), nil, &rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// SetTopicDispatchRate sets the dispatch rate limit of the topic
+func (c *Client) SetTopicDispatchRate(topic string, rate DispatchRate) error {
+	return c.do(http.MethodPost, topicPath(topic, "dispatchRate"), rate, nil)
+}
+
+// GetTopicDeduplication returns whether the deduplication of the topic is enabled, or nil if it's not set at the topic level
+func (c *Client) GetTopicDeduplication(topic string) (*bool, error) {
+	var enabled *bool
+	if err := c.do(http.MethodGet, topicPath(topic, "deduplicationEnabled"), nil, &enabled); err != nil {
+		return nil, err
+	}
+	return enabled, nil
+}
+
+// SetTopicDeduplication enables or disables the message deduplication of the topic
+func (c *Client) SetTopicDeduplication(topic string, enabled bool) error {
+	return c.do(http.MethodPost, topicPath(topic, "deduplicationEnabled"), enabled, nil)
+}
+
+// GetCompactionThreshold returns the backlog size in bytes that triggers the topic compaction,
+// or nil if it's not set at the topic level
+func (c *Client) GetCompactionThreshold(topic string) (*int64, error) {
+	var threshold *int64
+	if err := c.do(http.MethodGet, topicPath(topic, "compactionThreshold"), nil, &threshold); err != nil {
+		return nil, err
+	}
+	return threshold, nil
+}
+
+// SetCompactionThreshold sets the backlog size in bytes that triggers the topic compaction. 0 disables it
+func (c *Client) SetCompactionThreshold(topic string, threshold int64) error {
+	return c.do(http.MethodPost, topicPath(topic, "compactionThreshold"), threshold, nil)
+}
+
+// topicPath returns the admin path of the topic in the format `domain://tenant/namespace/topic`
+func topicPath(topic, subPath string) string {
+	domain, name := "persistent", topic
+	if i := strings.Index(topic, "://"); i >= 0 {
+		domain, name = topic[:i], topic[i+3:]
+	}
+	parts := strings.SplitN(name, "/", 3)
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	path := "/admin/v2/" + domain + "/" + strings.Join(parts, "/")
+	if subPath != "" {
+		path += "/" + subPath
+	}
+	return path
+}
+
+// domainNamespacePath returns the admin path of the topics of the domain in the namespace
+func domainNamespacePath(domain, namespace, subPath string) string {
+	return strings.Replace(namespacePath(namespace, subPath), "/namespaces/", "/"+domain+"/", 1)
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const tlsCaCertKey = "ca.crt"
+
+// AdminClient returns the admin API client of the cluster's client service authenticated
+// as the operator. The https web service is used when the brokers have TLS enabled
+func AdminClient(ctx reconciler.Context, c *v1alpha1.PulsarCluster) (*admin.Client, error) {
+	return newAdminClient(ctx, c, c.ClientServiceFQDN())
+}
+
+// newAdminClient returns the admin API client of the web service at the host authenticated as
+// the operator. The https web service is used, trusting the cluster CA, when TLS is enabled
+func newAdminClient(ctx reconciler.Context, c *v1alpha1.PulsarCluster, host string) (*admin.Client, error) {
+	tok, err := operatorToken(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	client := admin.NewClient(webServiceURL(c, host)).WithToken(tok)
+	if !c.Spec.TLS.IsEnabled() {
+		return client, nil
+	}
+	sec := &v12.Secret{}
+	if err = ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      c.TLSSecretName(),
+		Namespace: c.Namespace,
+	}, sec); err != nil {
+		return nil, err
+	}
+	return client.WithCACert(sec.Data[tlsCaCertKey])
+}
+
+// webServiceURL returns the URL of the broker web service at the host; the https one when TLS is enabled
+func webServiceURL(c *v1alpha1.PulsarCluster, host string) string {
+	if c.Spec.TLS.IsEnabled() {
+		return fmt.Sprintf("https://%s:%d", host, c.Spec.Ports.WebTLS)
+	}
+	return fmt.Sprintf("http://%s:%d", host, c.Spec.Ports.Web)
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/secret"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/token"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	tokenKeysVolumeName   = "token-keys"
+	tokenKeysMountPath    = "/pulsar/keys/token"
+	brokerTokenVolumeName = "broker-token"
+	brokerTokenMountPath  = "/pulsar/tokens/broker"
+	proxyTokenVolumeName  = "proxy-token"
+	proxyTokenMountPath   = "/pulsar/tokens/proxy"
+
+	tokenSecretKeyKey  = "secret.key"
+	tokenPrivateKeyKey = "private.key"
+	tokenPublicKeyKey  = "public.key"
+	brokerTokenKey     = "broker"
+	operatorTokenKey   = "operator"
+	proxyTokenKey      = "proxy"
+
+	tokenAuthenticationProvider = "org.apache.pulsar.broker.authentication.AuthenticationProviderToken"
+	tokenAuthenticationPlugin   = "org.apache.pulsar.client.impl.auth.AuthenticationToken"
+)
+
+// ReconcileAuthentication reconcile the token signing keys and the tokens issued for the brokers, the operator and the proxies
+func ReconcileAuthentication(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	if !cluster.Spec.Authentication.IsEnabled() {
+		return nil
+	}
+	keys, err := reconcileTokenKeys(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	return reconcileTokens(ctx, cluster, keys)
+}
+
+// reconcileTokenKeys returns the signing keys, generating them if the cluster doesn't reference its own secret.
+// The generated keys are never regenerated since that would invalidate every token issued with them
+func reconcileTokenKeys(ctx reconciler.Context, c *v1alpha1.PulsarCluster) (*token.Keys, error) {
+	alg := tokenAlgorithm(c)
+	sec := &v12.Secret{}
+	err := ctx.GetResource(types.NamespacedName{
+		Name:      c.TokenKeySecretName(),
+		Namespace: c.Namespace,
+	}, sec,
+		// Found
+		func() error { return nil },
+		// Not Found
+		func() error {
+			if c.Spec.Authentication.Token.KeySecretName != "" {
+				return Requeue(defaultRequeueDelay,
+					fmt.Sprintf("waiting for the token key secret: %s", c.TokenKeySecretName()))
+			}
+			keys, err := token.GenerateKeys(alg)
+			if err != nil {
+				return err
+			}
+			sec = secret.New(c.Namespace, c.TokenKeySecretName(), tokenKeysSecretData(keys))
+			sec.Labels = c.GenerateLabels(false)
+			if err = ctx.SetOwnershipReference(c, sec); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the token signing keys secret.",
+				"Secret.Name", sec.GetName(),
+				"Secret.Namespace", sec.GetNamespace(),
+				"Algorithm", alg)
+			return ctx.Client().Create(context.TODO(), sec)
+		})
+	if err != nil {
+		return nil, err
+	}
+	keys := &token.Keys{
+		Secret:  sec.Data[tokenSecretKeyKey],
+		Private: sec.Data[tokenPrivateKeyKey],
+		Public:  sec.Data[tokenPublicKeyKey],
+	}
+	if alg.IsSymmetric() && len(keys.Secret) == 0 {
+		return nil, fmt.Errorf("the token key secret %s has no %s key", sec.Name, tokenSecretKeyKey)
+	} else if !alg.IsSymmetric() && (len(keys.Private) == 0 || len(keys.Public) == 0) {
+		return nil, fmt.Errorf("the token key secret %s requires both the %s and %s keys",
+			sec.Name, tokenPrivateKeyKey, tokenPublicKeyKey)
+	}
+	return keys, nil
+}
+
+// reconcileTokens issues the broker, operator and proxy tokens. They're reissued whenever the keys or the roles change
+func reconcileTokens(ctx reconciler.Context, c *v1alpha1.PulsarCluster, keys *token.Keys) error {
+	tokenSpec := c.Spec.Authentication.Token
+	hash := ComputeHash(tokenAlgorithm(c), keys, tokenSpec.BrokerRole, tokenSpec.OperatorRole, tokenSpec.ProxyRole)
+	sec := &v12.Secret{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      c.TokenSecretName(),
+		Namespace: c.Namespace,
+	}, sec,
+		// Found
+		func() error {
+			if sec.Annotations[configHashAnnotation] == hash {
+				return nil
+			}
+			data, err := issueTokens(c, keys)
+			if err != nil {
+				return err
+			}
+			sec.Data = data
+			sec.Annotations = StampConfigHash(sec.Annotations, hash)
+			ctx.Logger().Info("Reissuing the broker, operator and proxy tokens.",
+				"Secret.Name", sec.GetName(),
+				"Secret.Namespace", sec.GetNamespace())
+			return ctx.Client().Update(context.TODO(), sec)
+		},
+		// Not Found
+		func() error {
+			data, err := issueTokens(c, keys)
+			if err != nil {
+				return err
+			}
+			sec = secret.New(c.Namespace, c.TokenSecretName(), data)
+			sec.Labels = c.GenerateLabels(false)
+			sec.Annotations = StampConfigHash(nil, hash)
+			if err = ctx.SetOwnershipReference(c, sec); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Issuing the broker, operator and proxy tokens.",
+				"Secret.Name", sec.GetName(),
+				"Secret.Namespace", sec.GetNamespace())
+			return ctx.Client().Create(context.TODO(), sec)
+		})
+}
+
+func issueTokens(c *v1alpha1.PulsarCluster, keys *token.Keys) (map[string][]byte, error) {
+	alg := tokenAlgorithm(c)
+	tokenSpec := c.Spec.Authentication.Token
+	brokerToken, err := token.Issue(alg, keys, tokenSpec.BrokerRole)
+	if err != nil {
+		return nil, err
+	}
+	operatorToken, err := token.Issue(alg, keys, tokenSpec.OperatorRole)
+	if err != nil {
+		return nil, err
+	}
+	proxyToken, err := token.Issue(alg, keys, tokenSpec.ProxyRole)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		brokerTokenKey:   []byte(brokerToken),
+		operatorTokenKey: []byte(operatorToken),
+		proxyTokenKey:    []byte(proxyToken),
+	}, nil
+}
+
+// tokenKeysSecretData returns the keys to store. The private key is stored
+// so that the operator can issue tokens; it's never mounted into the brokers
+func tokenKeysSecretData(keys *token.Keys) map[string][]byte {
+	if len(keys.Secret) > 0 {
+		return map[string][]byte{tokenSecretKeyKey: keys.Secret}
+	}
+	return map[string][]byte{
+		tokenPrivateKeyKey: keys.Private,
+		tokenPublicKeyKey:  keys.Public,
+	}
+}
+
+func tokenAlgorithm(c *v1alpha1.PulsarCluster) token.Algorithm {
+	return token.Algorithm(c.Spec.Authentication.Token.SigningAlgorithm)
+}
+
+// operatorToken returns the superuser token the operator calls the admin API with, or empty if authentication is disabled
+func operatorToken(ctx reconciler.Context, c *v1alpha1.PulsarCluster) (string, error) {
+	if !c.Spec.Authentication.IsEnabled() {
+		return "", nil
+	}
+	sec := &v12.Secret{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      c.TokenSecretName(),
+		Namespace: c.Namespace,
+	}, sec)
+	if errors.IsNotFound(err) {
+		return "", Requeue(defaultRequeueDelay, "waiting for the operator token to be issued")
+	} else if err != nil {
+		return "", err
+	}
+	return string(sec.Data[operatorTokenKey]), nil
+}
+
+// createAuthenticationConfigs creates the broker authentication configs
+func createAuthenticationConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	if !c.Spec.Authentication.IsEnabled() {
+		return nil
+	}
+	configs := map[string]string{
+		"authenticationEnabled":                "true",
+		"authenticationProviders":              tokenAuthenticationProvider,
+		"brokerClientAuthenticationPlugin":     tokenAuthenticationPlugin,
+		"brokerClientAuthenticationParameters": fmt.Sprintf("file://%s/%s", brokerTokenMountPath, brokerTokenKey),
+	}
+	addTokenVerificationConfigs(c, configs)
+	return configs
+}
+
+// ProxyAuthenticationConfigs creates the authentication configs of the proxies of the cluster.
+// The proxies verify the client tokens like the brokers do and connect to the brokers with the proxy token
+func ProxyAuthenticationConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	if !c.Spec.Authentication.IsEnabled() {
+		return nil
+	}
+	configs := map[string]string{
+		"authenticationEnabled":                "true",
+		"authenticationProviders":              tokenAuthenticationProvider,
+		"brokerClientAuthenticationPlugin":     tokenAuthenticationPlugin,
+		"brokerClientAuthenticationParameters": fmt.Sprintf("file://%s/%s", proxyTokenMountPath, proxyTokenKey),
+	}
+	if c.Spec.Authorization.IsEnabled() {
+		// the brokers authorize the client roles forwarded by the proxy
+		configs["forwardAuthorizationCredentials"] = "true"
+	}
+	addTokenVerificationConfigs(c, configs)
+	return configs
+}
+
+func addTokenVerificationConfigs(c *v1alpha1.PulsarCluster, configs map[string]string) {
+	alg := tokenAlgorithm(c)
+	if alg.IsSymmetric() {
+		configs["tokenSecretKey"] = fmt.Sprintf("file://%s/%s", tokenKeysMountPath, tokenSecretKeyKey)
+	} else {
+		configs["tokenPublicKey"] = fmt.Sprintf("file://%s/%s", tokenKeysMountPath, tokenPublicKeyKey)
+		configs["tokenPublicAlg"] = string(alg)
+	}
+}
+
+func createAuthenticationVolumes(c *v1alpha1.PulsarCluster) ([]v12.Volume, []v12.VolumeMount) {
+	return createTokenVolumes(c, brokerTokenVolumeName, brokerTokenMountPath, brokerTokenKey)
+}
+
+// ProxyAuthenticationVolumes returns the volumes of the token verification key and of the proxy token
+func ProxyAuthenticationVolumes(c *v1alpha1.PulsarCluster) ([]v12.Volume, []v12.VolumeMount) {
+	return createTokenVolumes(c, proxyTokenVolumeName, proxyTokenMountPath, proxyTokenKey)
+}
+
+func createTokenVolumes(c *v1alpha1.PulsarCluster, tokenVolumeName, tokenMountPath, tokenKey string) ([]v12.Volume, []v12.VolumeMount) {
+	if !c.Spec.Authentication.IsEnabled() {
+		return nil, nil
+	}
+	verificationKey := tokenPublicKeyKey
+	if tokenAlgorithm(c).IsSymmetric() {
+		verificationKey = tokenSecretKeyKey
+	}
+	volumes := []v12.Volume{
+		{
+			Name: tokenKeysVolumeName,
+			VolumeSource: v12.VolumeSource{
+				Secret: &v12.SecretVolumeSource{
+					SecretName: c.TokenKeySecretName(),
+					Items:      []v12.KeyToPath{{Key: verificationKey, Path: verificationKey}},
+				},
+			},
+		},
+		{
+			Name: tokenVolumeName,
+			VolumeSource: v12.VolumeSource{
+				Secret: &v12.SecretVolumeSource{
+					SecretName: c.TokenSecretName(),
+					Items:      []v12.KeyToPath{{Key: tokenKey, Path: tokenKey}},
+				},
+			},
+		},
+	}
+	mounts := []v12.VolumeMount{
+		{Name: tokenKeysVolumeName, MountPath: tokenKeysMountPath, ReadOnly: true},
+		{Name: tokenVolumeName, MountPath: tokenMountPath, ReadOnly: true},
+	}
+	return volumes, mounts
+}
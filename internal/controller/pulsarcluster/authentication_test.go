@@ -0,0 +1,74 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"testing"
+)
+
+func TestCreateAuthenticationConfigs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		algorithm string
+		want      map[string]string
+		notWant   []string
+	}{
+		{
+			algorithm: "HS256",
+			want:      map[string]string{"tokenSecretKey": "file:///pulsar/keys/token/secret.key"},
+			notWant:   []string{"tokenPublicKey", "tokenPublicAlg"},
+		},
+		{
+			algorithm: "ES256",
+			want: map[string]string{
+				"tokenPublicKey": "file:///pulsar/keys/token/public.key",
+				"tokenPublicAlg": "ES256",
+			},
+			notWant: []string{"tokenSecretKey"},
+		},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.Authentication = &v1alpha1.AuthenticationConfig{
+				Enabled: true,
+				Token:   &v1alpha1.TokenAuthentication{SigningAlgorithm: tt.algorithm},
+			}
+		})
+		configs := createAuthenticationConfigs(c)
+		tt.want["authenticationEnabled"] = "true"
+		tt.want["brokerClientAuthenticationParameters"] = "file:///pulsar/tokens/broker/broker"
+		for k, v := range tt.want {
+			if configs[k] != v {
+				t.Errorf("%s: expected the config %q to be %q; got: %q", tt.algorithm, k, v, configs[k])
+			}
+		}
+		for _, k := range tt.notWant {
+			if _, ok := configs[k]; ok {
+				t.Errorf("%s: expected no %q config", tt.algorithm, k)
+			}
+		}
+		volumes, _ := createAuthenticationVolumes(c)
+		for _, vol := range volumes {
+			for _, item := range vol.Secret.Items {
+				if item.Key == tokenPrivateKeyKey {
+					t.Errorf("%s: the private key must not be mounted into the brokers", tt.algorithm)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/operator-helper/oputil"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"strings"
+)
+
+// superUserRoles returns the superuser roles of the cluster; the roles the
+// operator and the brokers authenticate with come first, followed by the
+// roles specified by the user
+func superUserRoles(c *v1alpha1.PulsarCluster) []string {
+	roles := make([]string, 0)
+	if c.Spec.Authentication.IsEnabled() && c.Spec.Authentication.Token != nil {
+		roles = append(roles, c.Spec.Authentication.Token.BrokerRole, c.Spec.Authentication.Token.OperatorRole)
+	}
+	if c.Spec.Authorization != nil {
+		for _, role := range c.Spec.Authorization.SuperUserRoles {
+			if !oputil.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+// proxyRoles returns the proxy roles of the cluster; the role the operator
+// managed proxies authenticate with comes first, followed by the roles
+// specified by the user
+func proxyRoles(c *v1alpha1.PulsarCluster) []string {
+	roles := make([]string, 0)
+	if c.Spec.Authentication.IsEnabled() && c.Spec.Authentication.Token != nil {
+		roles = append(roles, c.Spec.Authentication.Token.ProxyRole)
+	}
+	for _, role := range c.Spec.Authorization.ProxyRoles {
+		if !oputil.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// createAuthorizationConfigs creates the broker authorization configs
+func createAuthorizationConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	configs := map[string]string{}
+	if roles := superUserRoles(c); len(roles) > 0 {
+		configs["superUserRoles"] = strings.Join(roles, ",")
+	}
+	authz := c.Spec.Authorization
+	if !authz.IsEnabled() {
+		return configs
+	}
+	configs["authorizationEnabled"] = "true"
+	if roles := proxyRoles(c); len(roles) > 0 {
+		configs["proxyRoles"] = strings.Join(roles, ",")
+	}
+	if authz.Provider != "" {
+		configs["authorizationProvider"] = authz.Provider
+	}
+	return configs
+}
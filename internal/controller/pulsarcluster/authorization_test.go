@@ -0,0 +1,56 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"testing"
+)
+
+func TestCreateAuthorizationConfigs(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.Authentication = &v1alpha1.AuthenticationConfig{Enabled: true}
+		c.Spec.Authorization = &v1alpha1.AuthorizationConfig{
+			Enabled:        true,
+			SuperUserRoles: []string{"admin", "pulsar-operator"},
+			ProxyRoles:     []string{"edge-proxy", "proxy"},
+		}
+	})
+	configs := createAuthorizationConfigs(c)
+	want := map[string]string{
+		"authorizationEnabled": "true",
+		"superUserRoles":       "broker,pulsar-operator,admin",
+		"proxyRoles":           "proxy,edge-proxy",
+	}
+	for k, v := range want {
+		if configs[k] != v {
+			t.Errorf("expected the config %q to be %q; got: %q", k, v, configs[k])
+		}
+	}
+	if _, ok := configs["authorizationProvider"]; ok {
+		t.Error("expected the default authorization provider")
+	}
+	c.Spec.Authorization.Enabled = false
+	configs = createAuthorizationConfigs(c)
+	if _, ok := configs["authorizationEnabled"]; ok {
+		t.Error("expected no authorizationEnabled config when authorization is disabled")
+	}
+	if configs["superUserRoles"] == "" {
+		t.Error("expected the operator roles to be superusers even when authorization is disabled")
+	}
+}
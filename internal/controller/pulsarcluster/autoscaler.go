@@ -0,0 +1,158 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// autoscalingScaleDownStabilizationSeconds is the default window the autoscaler
+// looks back over before removing a broker so that short load dips don't move bundles
+const autoscalingScaleDownStabilizationSeconds int32 = 300
+
+// ReconcileHorizontalPodAutoscaler reconcile the autoscaler of the brokers of the specified cluster
+func ReconcileHorizontalPodAutoscaler(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	hpa := &v2.HorizontalPodAutoscaler{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      cluster.StatefulSetName(),
+		Namespace: cluster.Namespace,
+	}, hpa,
+		// Found
+		func() error {
+			if !metav1.IsControlledBy(hpa, cluster) {
+				return nil
+			}
+			if !cluster.Spec.Autoscaling.IsEnabled() {
+				ctx.Logger().Info("Deleting the pulsar broker autoscaler.",
+					"HorizontalPodAutoscaler.Name", hpa.GetName(),
+					"HorizontalPodAutoscaler.Namespace", hpa.GetNamespace())
+				return client.IgnoreNotFound(ctx.Client().Delete(context.TODO(), hpa))
+			}
+			desired := createHorizontalPodAutoscaler(cluster)
+			if hpa.Annotations[configHashAnnotation] == desired.Annotations[configHashAnnotation] {
+				return nil
+			}
+			hpa.Labels = desired.Labels
+			hpa.Annotations = desired.Annotations
+			hpa.Spec = desired.Spec
+			ctx.Logger().Info("Updating the pulsar broker autoscaler.",
+				"HorizontalPodAutoscaler.Name", hpa.GetName(),
+				"HorizontalPodAutoscaler.Namespace", hpa.GetNamespace(),
+				"MinReplicas", *hpa.Spec.MinReplicas,
+				"MaxReplicas", hpa.Spec.MaxReplicas)
+			return ctx.Client().Update(context.TODO(), hpa)
+		},
+		// Not Found
+		func() error {
+			if !cluster.Spec.Autoscaling.IsEnabled() {
+				return nil
+			}
+			hpa = createHorizontalPodAutoscaler(cluster)
+			if err := ctx.SetOwnershipReference(cluster, hpa); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar broker autoscaler.",
+				"HorizontalPodAutoscaler.Name", hpa.GetName(),
+				"HorizontalPodAutoscaler.Namespace", hpa.GetNamespace(),
+				"MinReplicas", *hpa.Spec.MinReplicas,
+				"MaxReplicas", hpa.Spec.MaxReplicas)
+			return ctx.Client().Create(context.TODO(), hpa)
+		})
+}
+
+// createHorizontalPodAutoscaler creates the autoscaler of the broker statefulset. The spec is hashed
+// into an annotation since the API server defaults the unset parts of the behavior
+func createHorizontalPodAutoscaler(c *v1alpha1.PulsarCluster) *v2.HorizontalPodAutoscaler {
+	autoscaling := c.Spec.Autoscaling
+	hpa := &v2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.StatefulSetName(),
+			Namespace: c.Namespace,
+			Labels:    c.GenerateLabels(true),
+		},
+		Spec: v2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: v2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "StatefulSet",
+				Name:       c.StatefulSetName(),
+			},
+			MinReplicas: autoscaling.MinReplicas,
+			MaxReplicas: autoscaling.MaxReplicas,
+			Metrics:     autoscalingMetrics(autoscaling),
+			Behavior:    autoscalingBehavior(c),
+		},
+	}
+	hpa.Annotations = StampConfigHash(c.GenerateAnnotations(), ComputeHash(hpa.Spec))
+	return hpa
+}
+
+// autoscalingMetrics returns the resource utilization targets followed by the custom metrics
+func autoscalingMetrics(autoscaling *v1alpha1.AutoscalingConfig) []v2.MetricSpec {
+	var metrics []v2.MetricSpec
+	targets := []struct {
+		resource v1.ResourceName
+		value    *int32
+	}{
+		{v1.ResourceCPU, autoscaling.TargetCPUUtilizationPercentage},
+		{v1.ResourceMemory, autoscaling.TargetMemoryUtilizationPercentage},
+	}
+	for _, target := range targets {
+		if target.value == nil {
+			continue
+		}
+		metrics = append(metrics, v2.MetricSpec{
+			Type: v2.ResourceMetricSourceType,
+			Resource: &v2.ResourceMetricSource{
+				Name: target.resource,
+				Target: v2.MetricTarget{
+					Type:               v2.UtilizationMetricType,
+					AverageUtilization: target.value,
+				},
+			},
+		})
+	}
+	return append(metrics, autoscaling.Metrics...)
+}
+
+// autoscalingBehavior returns the configured behavior with the scale down defaulting to the removal
+// of one broker per scale down period. The removed broker is drained by its preStop hook so removing
+// the next one before its bundles are reassigned would move them twice
+func autoscalingBehavior(c *v1alpha1.PulsarCluster) *v2.HorizontalPodAutoscalerBehavior {
+	behavior := &v2.HorizontalPodAutoscalerBehavior{}
+	if c.Spec.Autoscaling.Behavior != nil {
+		behavior = c.Spec.Autoscaling.Behavior.DeepCopy()
+	}
+	if behavior.ScaleDown == nil {
+		stabilization := autoscalingScaleDownStabilizationSeconds
+		behavior.ScaleDown = &v2.HPAScalingRules{
+			StabilizationWindowSeconds: &stabilization,
+			Policies: []v2.HPAScalingPolicy{{
+				Type:          v2.PodsScalingPolicy,
+				Value:         1,
+				PeriodSeconds: c.Spec.AutoscalingScaleDownPeriod(),
+			}},
+		}
+	}
+	return behavior
+}
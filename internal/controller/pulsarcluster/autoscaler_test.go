@@ -0,0 +1,117 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v2 "k8s.io/api/autoscaling/v2"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func TestCreateHorizontalPodAutoscaler(t *testing.T) {
+	t.Parallel()
+	memory := int32(75)
+	noWindow := int32(0)
+	tests := []struct {
+		name            string
+		autoscaling     *v1alpha1.AutoscalingConfig
+		wantMetrics     []v1.ResourceName
+		wantCustom      bool
+		wantScaleUp     bool
+		wantMinReplicas int32
+	}{
+		{
+			name:            "defaults",
+			autoscaling:     &v1alpha1.AutoscalingConfig{Enabled: true, MaxReplicas: 6},
+			wantMetrics:     []v1.ResourceName{v1.ResourceCPU},
+			wantMinReplicas: 3,
+		},
+		{
+			name: "memory and custom metrics",
+			autoscaling: &v1alpha1.AutoscalingConfig{
+				Enabled:                           true,
+				MaxReplicas:                       6,
+				TargetMemoryUtilizationPercentage: &memory,
+				Metrics: []v2.MetricSpec{{
+					Type: v2.PodsMetricSourceType,
+					Pods: &v2.PodsMetricSource{Metric: v2.MetricIdentifier{Name: "pulsar_rate_in"}},
+				}},
+				Behavior: &v2.HorizontalPodAutoscalerBehavior{
+					ScaleUp: &v2.HPAScalingRules{StabilizationWindowSeconds: &noWindow},
+				},
+			},
+			wantMetrics:     []v1.ResourceName{v1.ResourceMemory, ""},
+			wantCustom:      true,
+			wantScaleUp:     true,
+			wantMinReplicas: 3,
+		},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.Autoscaling = tt.autoscaling
+		})
+		hpa := createHorizontalPodAutoscaler(c)
+		if ref := hpa.Spec.ScaleTargetRef; ref.Kind != "StatefulSet" || ref.Name != c.StatefulSetName() {
+			t.Errorf("%s: expected the broker statefulset to be the target; got: %+v", tt.name, ref)
+		}
+		if *hpa.Spec.MinReplicas != tt.wantMinReplicas || hpa.Spec.MaxReplicas != 6 {
+			t.Errorf("%s: expected the replicas range [%d, 6]; got: [%d, %d]", tt.name,
+				tt.wantMinReplicas, *hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas)
+		}
+		if len(hpa.Spec.Metrics) != len(tt.wantMetrics) {
+			t.Fatalf("%s: expected %d metrics; got: %+v", tt.name, len(tt.wantMetrics), hpa.Spec.Metrics)
+		}
+		for i, want := range tt.wantMetrics {
+			if metric := hpa.Spec.Metrics[i]; want != "" && (metric.Resource == nil || metric.Resource.Name != want) {
+				t.Errorf("%s: expected the %s utilization metric; got: %+v", tt.name, want, metric)
+			}
+		}
+		if tt.wantCustom && hpa.Spec.Metrics[len(hpa.Spec.Metrics)-1].Pods == nil {
+			t.Errorf("%s: expected the custom metric to be kept", tt.name)
+		}
+		if got := hpa.Spec.Behavior.ScaleUp != nil; got != tt.wantScaleUp {
+			t.Errorf("%s: expected the scale up behavior: %v; got: %v", tt.name, tt.wantScaleUp, got)
+		}
+		policies := hpa.Spec.Behavior.ScaleDown.Policies
+		if len(policies) != 1 || policies[0].Type != v2.PodsScalingPolicy || policies[0].Value != 1 ||
+			policies[0].PeriodSeconds != c.Spec.AutoscalingScaleDownPeriod() {
+			t.Errorf("%s: expected one broker to be removed per scale down period; got: %+v", tt.name, policies)
+		}
+	}
+}
+
+func TestAutoscaledStatefulSetReplicas(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(nil)
+	sts := createStatefulSet(c, "")
+	replicas := *c.Spec.Size + 2
+	sts.Spec.Replicas = &replicas
+	if !shouldUpdateStatefulSet(c.Spec, sts, createStatefulSet(c, "")) {
+		t.Errorf("expected the replicas to be reset to the size without autoscaling")
+	}
+	c.Spec.Autoscaling = &v1alpha1.AutoscalingConfig{Enabled: true, MaxReplicas: 6}
+	c.SetSpecDefaults()
+	desired := createStatefulSet(c, "")
+	desired.Spec.Replicas = sts.Spec.Replicas
+	if shouldUpdateStatefulSet(c.Spec, sts, desired) {
+		t.Errorf("expected the replicas set by the autoscaler to be kept")
+	}
+	if got := len(certificateDNSNames(c)); got != 5+6 {
+		t.Errorf("expected the certificate to cover the 6 brokers the autoscaler may scale up to; got: %d names", got)
+	}
+}
@@ -18,6 +18,7 @@ package pulsarcluster
 
 import (
 	"context"
+	"fmt"
 	"github.com/monimesl/operator-helper/k8s/configmap"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/pulsar-operator/api/v1alpha1"
@@ -75,20 +76,44 @@ func updateConfigmap(ctx reconciler.Context, cm *v1.ConfigMap, c *v1alpha1.Pulsa
 
 func createConfigmapData(c *v1alpha1.PulsarCluster) map[string]string {
 	jvmOptions := c.Spec.JVMOptions
-	data := processEnvVarMap(map[string]string{
-		"managedLedgerDefaultEnsembleSize": "1",
-		"managedLedgerDefaultWriteQuorum":  "1",
-		"managedLedgerDefaultAckQuorum":    "1",
+	replication := c.LedgerReplication()
+	_, brokerShutdownTimeoutMs := c.Spec.ShutdownBudget()
+	data := map[string]string{
+		"managedLedgerDefaultEnsembleSize": fmt.Sprint(replication.EnsembleSize),
+		"managedLedgerDefaultWriteQuorum":  fmt.Sprint(replication.WriteQuorum),
+		"managedLedgerDefaultAckQuorum":    fmt.Sprint(replication.AckQuorum),
 		"statusFilePath":                   "/pulsar/status",
-		"clusterName":                      c.GetName(),
-		"zookeeperServers":                 c.Spec.ZookeeperServers,
-		"bookkeeperMetadataServiceUri":     c.Spec.BookkeeperClusterUri,
-		"configurationStoreServers":        c.Spec.ConfigurationStoreServers,
+		"brokerShutdownTimeoutMs":          fmt.Sprint(brokerShutdownTimeoutMs),
+		"clusterName":                      c.PulsarClusterName(),
+		"bookkeeperMetadataServiceUri":     c.BookkeeperMetadataServiceURI(),
 		"PULSAR_GC":                        strings.Join(jvmOptions.Gc, " "),
 		"PULSAR_EXTRA_OPTS":                strings.Join(jvmOptions.Extra, " "),
 		"PULSAR_MEM":                       strings.Join(jvmOptions.Memory, " "),
 		"PULSAR_GC_LOG":                    strings.Join(jvmOptions.GcLogging, " "),
-	}, false)
+	}
+	v := c.Spec.Version()
+	for k, val := range createMetadataStoreConfigs(c, v) {
+		data[k] = val
+	}
+	for k, val := range createProtocolHandlerConfigs(c, v) {
+		data[k] = val
+	}
+	for k, val := range createTLSConfigs(c) {
+		data[k] = val
+	}
+	for k, val := range createAuthenticationConfigs(c) {
+		data[k] = val
+	}
+	for k, val := range createAuthorizationConfigs(c) {
+		data[k] = val
+	}
+	for k, val := range createListenerConfigs(c) {
+		data[k] = val
+	}
+	for k, val := range createTieredStorageConfigs(c) {
+		data[k] = val
+	}
+	data = processEnvVarMap(data, false)
 	for k, v := range processEnvVarMap(c.Spec.BrokerConfig, true) {
 		data[k] = v
 	}
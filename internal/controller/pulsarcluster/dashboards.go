@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	dashboardsDirectory = "dashboards"
+	// dashboardLabel labels the dashboard configmaps with the name of their cluster
+	dashboardLabel = internal.Domain + "/dashboard"
+)
+
+// dashboardFiles are the grafana dashboards shipped with the operator. Their
+// __PULSAR_CLUSTER__, __NAMESPACE__, __JOB__ and __UID__ placeholders are replaced per cluster
+//
+//go:embed dashboards/*.json
+var dashboardFiles embed.FS
+
+// ReconcileDashboards publishes the grafana dashboards of the specified cluster in labelled configmaps. They're
+// rendered from the embedded dashboards on every reconciliation so that the dashboards shipped by a new operator
+// version replace the existing ones, and the ones it doesn't ship anymore are deleted
+func ReconcileDashboards(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	var desired []*v1.ConfigMap
+	if cluster.Spec.Monitoring.AreDashboardsEnabled() {
+		var err error
+		if desired, err = createDashboardConfigMaps(cluster); err != nil {
+			return err
+		}
+	}
+	existing := &v1.ConfigMapList{}
+	if err := ctx.Client().List(context.TODO(), existing, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{dashboardLabel: cluster.Name}); err != nil {
+		return err
+	}
+	current := map[string]*v1.ConfigMap{}
+	for i := range existing.Items {
+		cm := &existing.Items[i]
+		current[cm.Name] = cm
+	}
+	for _, cm := range desired {
+		if err := reconcileDashboardConfigMap(ctx, cluster, current[cm.Name], cm); err != nil {
+			return err
+		}
+		delete(current, cm.Name)
+	}
+	for _, cm := range current {
+		if !metav1.IsControlledBy(cm, cluster) {
+			continue
+		}
+		ctx.Logger().Info("Deleting the pulsar dashboard configmap.",
+			"ConfigMap.Name", cm.GetName(),
+			"ConfigMap.Namespace", cm.GetNamespace())
+		if err := ctx.Client().Delete(context.TODO(), cm); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func reconcileDashboardConfigMap(ctx reconciler.Context, c *v1alpha1.PulsarCluster, cm, desired *v1.ConfigMap) error {
+	if cm == nil {
+		if err := ctx.SetOwnershipReference(c, desired); err != nil {
+			return err
+		}
+		ctx.Logger().Info("Creating the pulsar dashboard configmap.",
+			"ConfigMap.Name", desired.GetName(),
+			"ConfigMap.Namespace", desired.GetNamespace())
+		return ctx.Client().Create(context.TODO(), desired)
+	}
+	// the semantic equality treats the empty annotations of the desired configmap as the unset ones
+	if equality.Semantic.DeepEqual(cm.Data, desired.Data) && equality.Semantic.DeepEqual(cm.Labels, desired.Labels) &&
+		equality.Semantic.DeepEqual(cm.Annotations, desired.Annotations) {
+		return nil
+	}
+	cm.Labels = desired.Labels
+	cm.Annotations = desired.Annotations
+	cm.Data = desired.Data
+	ctx.Logger().Info("Updating the pulsar dashboard configmap.",
+		"ConfigMap.Name", cm.GetName(),
+		"ConfigMap.Namespace", cm.GetNamespace())
+	return ctx.Client().Update(context.TODO(), cm)
+}
+
+// createDashboardConfigMaps creates a configmap per embedded dashboard
+func createDashboardConfigMaps(c *v1alpha1.PulsarCluster) ([]*v1.ConfigMap, error) {
+	entries, err := dashboardFiles.ReadDir(dashboardsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	dashboards := c.Spec.Monitoring.Dashboards
+	configMaps := make([]*v1.ConfigMap, 0, len(entries))
+	for _, entry := range entries {
+		raw, err := dashboardFiles.ReadFile(fmt.Sprintf("%s/%s", dashboardsDirectory, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		labels := mergeMaps(c.GenerateLabels(true), dashboards.Labels, map[string]string{dashboardLabel: c.Name})
+		annotations := mergeMaps(c.GenerateAnnotations(), dashboards.Annotations)
+		configMaps = append(configMaps, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("%s-%s", c.StatefulSetName(), strings.TrimSuffix(entry.Name(), ".json")),
+				Namespace:   c.Namespace,
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Data: map[string]string{entry.Name(): renderDashboard(c, string(raw))},
+		})
+	}
+	return configMaps, nil
+}
+
+// renderDashboard templates the dashboard with the cluster. The uid is derived from the cluster
+// namespace and name so that the dashboards of the clusters don't overwrite each other in grafana
+func renderDashboard(c *v1alpha1.PulsarCluster, dashboard string) string {
+	return strings.NewReplacer(
+		"__PULSAR_CLUSTER__", c.PulsarClusterName(),
+		"__NAMESPACE__", c.Namespace,
+		"__JOB__", podMonitorJob(c),
+		"__UID__", ComputeHash(c.Namespace, c.Name)[:12],
+	).Replace(dashboard)
+}
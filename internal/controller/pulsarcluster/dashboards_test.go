@@ -0,0 +1,74 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"encoding/json"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"strings"
+	"testing"
+)
+
+func TestCreateDashboardConfigMaps(t *testing.T) {
+	t.Parallel()
+	uids := map[string]string{}
+	for _, namespace := range []string{"default", "staging"} {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Namespace = namespace
+			c.Spec.Labels = map[string]string{"team": "messaging"}
+			c.Spec.Monitoring = &v1alpha1.MonitoringConfig{Enabled: true, Dashboards: &v1alpha1.DashboardsConfig{Enabled: true}}
+		})
+		configMaps, err := createDashboardConfigMaps(c)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", namespace, err)
+		}
+		if len(configMaps) == 0 {
+			t.Fatalf("%s: expected the embedded dashboards to be published", namespace)
+		}
+		if _, ok := c.Spec.Labels["grafana_dashboard"]; ok {
+			t.Errorf("%s: expected the cluster labels not to be mutated; got: %v", namespace, c.Spec.Labels)
+		}
+		for _, cm := range configMaps {
+			if cm.Labels["grafana_dashboard"] != "1" || cm.Labels[dashboardLabel] != c.Name || cm.Labels["team"] != "messaging" {
+				t.Errorf("%s: expected the sidecar, dashboard and cluster labels; got: %v", cm.Name, cm.Labels)
+			}
+			for key, content := range cm.Data {
+				if strings.Contains(content, "__") {
+					t.Errorf("%s: expected all the placeholders to be replaced in %s", cm.Name, key)
+				}
+				dashboard := struct {
+					UID   string `json:"uid"`
+					Title string `json:"title"`
+				}{}
+				if err := json.Unmarshal([]byte(content), &dashboard); err != nil {
+					t.Fatalf("%s: expected %s to be valid JSON; got: %v", cm.Name, key, err)
+				}
+				if len(dashboard.UID) > 40 || !strings.Contains(dashboard.Title, namespace) {
+					t.Errorf("%s: expected a grafana uid and a title with the namespace; got: %q, %q",
+						cm.Name, dashboard.UID, dashboard.Title)
+				}
+				if other, ok := uids[dashboard.UID]; ok {
+					t.Errorf("%s: expected a uid unique across the clusters; it's also used by %s", cm.Name, other)
+				}
+				uids[dashboard.UID] = cm.Name
+				if !strings.Contains(content, `job=\"`+namespace+`/test-pls\"`) {
+					t.Errorf("%s: expected the queries to select the targets of the PodMonitor", cm.Name)
+				}
+			}
+		}
+	}
+}
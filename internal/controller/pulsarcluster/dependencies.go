@@ -0,0 +1,285 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+var (
+	// ZookeeperClusterGVK is the kind of the clusters managed by the zookeeper operator
+	ZookeeperClusterGVK = schema.GroupVersionKind{Group: "zookeeper.monime.sl", Version: "v1alpha1", Kind: "ZookeeperCluster"}
+	// BookkeeperClusterGVK is the kind of the clusters managed by the bookkeeper operator
+	BookkeeperClusterGVK = schema.GroupVersionKind{Group: "bookkeeper.monime.sl", Version: "v1alpha1", Kind: "BookkeeperCluster"}
+)
+
+const (
+	zookeeperClientPortName         = "client"
+	defaultZookeeperClientPort      = 2181
+	defaultBookkeeperLedgersPath    = "/ledgers"
+	bookkeeperConfigEnvPrefix       = "BK_"
+	bookkeeperMetadataServiceURIKey = "metadataServiceUri"
+	bookkeeperZkServersKey          = "zkServers"
+	bookkeeperZkLedgersRootPathKey  = "zkLedgersRootPath"
+)
+
+// ReconcileDependencies resolves the connection strings of the referenced zookeeper and bookkeeper
+// clusters into the status. The last resolved values are kept while a referenced cluster is unavailable
+// so that a restarting dependency does not re-render the broker configs
+func ReconcileDependencies(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	zkRef, bkRef := cluster.Spec.ZookeeperRef, cluster.Spec.BookkeeperRef
+	if zkRef == nil && bkRef == nil {
+		cluster.Status.Dependencies = nil
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1alpha1.ConditionDependenciesReady)
+		return nil
+	}
+	resolved := &v1alpha1.DependenciesStatus{}
+	if cluster.Status.Dependencies != nil {
+		*resolved = *cluster.Status.Dependencies
+	}
+	var notReady []string
+	bookies := resolved.Bookies
+	if zkRef != nil {
+		servers, ready, err := resolveZookeeperServers(ctx, cluster, zkRef)
+		if err != nil {
+			return err
+		}
+		if servers != "" {
+			resolved.ZookeeperServers = servers
+		}
+		if !ready || resolved.ZookeeperServers == "" {
+			notReady = append(notReady, fmt.Sprintf("zookeeper cluster %s", dependencyKey(cluster, zkRef.Namespace, zkRef.Name)))
+		}
+	} else {
+		resolved.ZookeeperServers = ""
+	}
+	if bkRef != nil {
+		uri, count, ready, err := resolveBookkeeperURI(ctx, cluster, bkRef)
+		if err != nil {
+			return err
+		}
+		if uri != "" {
+			resolved.BookkeeperClusterURI = uri
+		}
+		if count > 0 {
+			resolved.Bookies = count
+		}
+		if !ready || resolved.BookkeeperClusterURI == "" {
+			notReady = append(notReady, fmt.Sprintf("bookkeeper cluster %s", dependencyKey(cluster, bkRef.Namespace, bkRef.Name)))
+		}
+	} else {
+		resolved.BookkeeperClusterURI = ""
+		resolved.Bookies = 0
+	}
+	cluster.Status.Dependencies = resolved
+	if resolved.Bookies != bookies {
+		logLedgerReplication(ctx, cluster)
+	}
+	if len(notReady) > 0 {
+		msg := fmt.Sprintf("waiting for the %s to be ready", strings.Join(notReady, " and the "))
+		cluster.Status.SetCondition(v1alpha1.ConditionDependenciesReady, metav1.ConditionFalse,
+			"DependenciesNotReady", msg, cluster.Generation)
+		return Requeue(defaultRequeueDelay, msg)
+	}
+	cluster.Status.SetCondition(v1alpha1.ConditionDependenciesReady, metav1.ConditionTrue,
+		"DependenciesReady", "the referenced clusters are ready", cluster.Generation)
+	return nil
+}
+
+// resolveZookeeperServers resolves the connect string from the client service owned by the zookeeper cluster
+func resolveZookeeperServers(ctx reconciler.Context, c *v1alpha1.PulsarCluster,
+	ref *v1alpha1.ZookeeperReference) (servers string, ready bool, err error) {
+	zk, err := getDependency(ctx, ZookeeperClusterGVK, namespaceOrDefault(ref.Namespace, c.Namespace), ref.Name)
+	if zk == nil || err != nil {
+		return "", false, err
+	}
+	services := &v12.ServiceList{}
+	if err = ctx.Client().List(context.TODO(), services, client.InNamespace(zk.GetNamespace())); err != nil {
+		return "", false, err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		// the headless service resolves to the individual pods; the client service is the stable one
+		if !isOwnedBy(svc, zk) || svc.Spec.ClusterIP == v12.ClusterIPNone {
+			continue
+		}
+		port := zookeeperClientPort(svc)
+		if port == 0 {
+			continue
+		}
+		servers = fmt.Sprintf("%s.%s.svc.%s:%d%s", svc.Name, svc.Namespace, c.Spec.ClusterDomain, port, ref.ChrootPath)
+		return servers, isDependencyReady(zk), nil
+	}
+	return "", false, nil
+}
+
+// zookeeperClientPort returns the client port of the zookeeper service or zero if it has none
+func zookeeperClientPort(svc *v12.Service) int32 {
+	for _, port := range svc.Spec.Ports {
+		if port.Name == zookeeperClientPortName || port.Port == defaultZookeeperClientPort {
+			return port.Port
+		}
+	}
+	return 0
+}
+
+// resolveBookkeeperURI resolves the metadata service URI from the bookie configs owned by the bookkeeper
+// cluster and its bookie count from its size. The bookies without a metadataServiceUri are addressed through their zkServers
+func resolveBookkeeperURI(ctx reconciler.Context, c *v1alpha1.PulsarCluster,
+	ref *v1alpha1.BookkeeperReference) (uri string, bookies int32, ready bool, err error) {
+	bk, err := getDependency(ctx, BookkeeperClusterGVK, namespaceOrDefault(ref.Namespace, c.Namespace), ref.Name)
+	if bk == nil || err != nil {
+		return "", 0, false, err
+	}
+	if size, found, _ := unstructured.NestedInt64(bk.Object, "spec", "size"); found {
+		bookies = int32(size)
+	}
+	configMaps := &v12.ConfigMapList{}
+	if err = ctx.Client().List(context.TODO(), configMaps, client.InNamespace(bk.GetNamespace())); err != nil {
+		return "", 0, false, err
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		if !isOwnedBy(cm, bk) {
+			continue
+		}
+		if uri = bookkeeperMetadataServiceURI(cm.Data); uri != "" {
+			return uri, bookies, isDependencyReady(bk), nil
+		}
+	}
+	return "", bookies, false, nil
+}
+
+// logLedgerReplication logs the managed ledger replication derived from the new bookie count
+func logLedgerReplication(ctx reconciler.Context, c *v1alpha1.PulsarCluster) {
+	replication := c.LedgerReplication()
+	ctx.Logger().Info("The bookie count of the referenced bookkeeper cluster changed.",
+		"PulsarCluster.Name", c.Name,
+		"PulsarCluster.Namespace", c.Namespace,
+		"Bookies", c.Status.Dependencies.Bookies,
+		"EnsembleSize", replication.EnsembleSize,
+		"WriteQuorum", replication.WriteQuorum,
+		"AckQuorum", replication.AckQuorum)
+	if !replication.IsReplicated() {
+		ctx.Logger().Info("WARNING: the ledger entries are not replicated; a single bookie failure loses data.",
+			"PulsarCluster.Name", c.Name,
+			"PulsarCluster.Namespace", c.Namespace)
+	}
+}
+
+// bookkeeperMetadataServiceURI returns the metadata service URI of the bookie configs
+// with or without the BK_ env prefix of the bookkeeper image
+func bookkeeperMetadataServiceURI(data map[string]string) string {
+	get := func(key string) string {
+		if v := data[bookkeeperConfigEnvPrefix+key]; v != "" {
+			return v
+		}
+		return data[key]
+	}
+	if uri := get(bookkeeperMetadataServiceURIKey); uri != "" {
+		return uri
+	}
+	zkServers := get(bookkeeperZkServersKey)
+	if zkServers == "" {
+		return ""
+	}
+	ledgersPath := get(bookkeeperZkLedgersRootPathKey)
+	if ledgersPath == "" {
+		ledgersPath = defaultBookkeeperLedgersPath
+	}
+	return fmt.Sprintf("zk+null://%s%s", strings.ReplaceAll(zkServers, ",", ";"), ledgersPath)
+}
+
+// getDependency gets the referenced cluster or nil if it does not exist or its kind is not installed
+func getDependency(ctx reconciler.Context, gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, obj)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// isDependencyReady checks the readiness of the referenced cluster from its Ready or Available condition,
+// or its ready replicas. A cluster publishing neither is considered ready once its connection string resolves
+func isDependencyReady(obj *unstructured.Unstructured) bool {
+	conditions, found, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if found {
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if t := condition["type"]; t == "Ready" || t == v1alpha1.ConditionAvailable {
+				return condition["status"] == string(metav1.ConditionTrue)
+			}
+		}
+	}
+	readyReplicas, found, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	if !found {
+		return true
+	}
+	size, found, _ := unstructured.NestedInt64(obj.Object, "spec", "size")
+	return !found || readyReplicas >= size
+}
+
+func isOwnedBy(obj metav1.Object, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceOrDefault(namespace, defaultNamespace string) string {
+	if namespace == "" {
+		return defaultNamespace
+	}
+	return namespace
+}
+
+func dependencyKey(c *v1alpha1.PulsarCluster, namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespaceOrDefault(namespace, c.Namespace), name)
+}
+
+// ReferencesDependency checks whether the cluster references the zookeeper or bookkeeper cluster
+func ReferencesDependency(c *v1alpha1.PulsarCluster, gvk schema.GroupVersionKind, namespace, name string) bool {
+	if ref := c.Spec.ZookeeperRef; ref != nil && gvk == ZookeeperClusterGVK {
+		return namespaceOrDefault(ref.Namespace, c.Namespace) == namespace && ref.Name == name
+	}
+	if ref := c.Spec.BookkeeperRef; ref != nil && gvk == BookkeeperClusterGVK {
+		return namespaceOrDefault(ref.Namespace, c.Namespace) == namespace && ref.Name == name
+	}
+	return false
+}
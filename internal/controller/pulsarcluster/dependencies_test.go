@@ -0,0 +1,159 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"strings"
+	"testing"
+)
+
+func TestBookkeeperMetadataServiceURI(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data map[string]string
+		want string
+	}{
+		{
+			name: "metadata service URI",
+			data: map[string]string{"BK_metadataServiceUri": "zk+hierarchical://zk:2181/ledgers"},
+			want: "zk+hierarchical://zk:2181/ledgers",
+		},
+		{
+			name: "zookeeper servers",
+			data: map[string]string{"BK_zkServers": "zk-0:2181,zk-1:2181", "BK_zkLedgersRootPath": "/bk/ledgers"},
+			want: "zk+null://zk-0:2181;zk-1:2181/bk/ledgers",
+		},
+		{
+			name: "unprefixed zookeeper servers",
+			data: map[string]string{"zkServers": "zk:2181"},
+			want: "zk+null://zk:2181/ledgers",
+		},
+		{
+			name: "not a bookie config",
+			data: map[string]string{"PULSAR_PREFIX_clusterName": "test"},
+		},
+	}
+	for _, tt := range tests {
+		if got := bookkeeperMetadataServiceURI(tt.data); got != tt.want {
+			t.Errorf("%s: expected: %q; got: %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestIsDependencyReady(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		object map[string]interface{}
+		want   bool
+	}{
+		{
+			name: "ready condition",
+			object: map[string]interface{}{"status": map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+			}},
+			want: true,
+		},
+		{
+			name: "unavailable condition",
+			object: map[string]interface{}{"status": map[string]interface{}{
+				"conditions":    []interface{}{map[string]interface{}{"type": "Available", "status": "False"}},
+				"readyReplicas": int64(3),
+			}},
+		},
+		{
+			name: "ready replicas",
+			object: map[string]interface{}{
+				"spec":   map[string]interface{}{"size": int64(3)},
+				"status": map[string]interface{}{"readyReplicas": int64(3)},
+			},
+			want: true,
+		},
+		{
+			name: "scaling up",
+			object: map[string]interface{}{
+				"spec":   map[string]interface{}{"size": int64(3)},
+				"status": map[string]interface{}{"readyReplicas": int64(1)},
+			},
+		},
+		{name: "no status", object: map[string]interface{}{}, want: true},
+	}
+	for _, tt := range tests {
+		if got := isDependencyReady(&unstructured.Unstructured{Object: tt.object}); got != tt.want {
+			t.Errorf("%s: expected ready: %v; got: %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestResolvedDependencies(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.ZookeeperServers = ""
+		c.Spec.BookkeeperClusterUri = ""
+		c.Spec.ZookeeperRef = &v1alpha1.ZookeeperReference{Name: "zk", ChrootPath: "/pulsar"}
+		c.Spec.BookkeeperRef = &v1alpha1.BookkeeperReference{Name: "bk", Namespace: "bookkeeper"}
+		c.Spec.PulsarVersion = "3.0.0"
+	})
+	if c.DependenciesReady() {
+		t.Errorf("expected the unresolved dependencies not to be ready")
+	}
+	c.Status.Dependencies = &v1alpha1.DependenciesStatus{
+		ZookeeperServers:     "zk.default.svc.cluster.local:2181/pulsar",
+		BookkeeperClusterURI: "zk+null://zk.default.svc.cluster.local:2181/ledgers",
+	}
+	data := createConfigmapData(c)
+	if got := data[pulsarConfigEnvPrefix+"metadataStoreUrl"]; got != "zk:zk.default.svc.cluster.local:2181/pulsar" {
+		t.Errorf("expected the resolved metadata store URL; got: %q", got)
+	}
+	if got := data[pulsarConfigEnvPrefix+"bookkeeperMetadataServiceUri"]; got != c.Status.Dependencies.BookkeeperClusterURI {
+		t.Errorf("expected the resolved bookkeeper URI; got: %q", got)
+	}
+	args := createJobPodContainerArguments(c)[0]
+	if !strings.Contains(args, "--existing-bk-metadata-service-uri \"zk+null://zk.default.svc.cluster.local:2181/ledgers\"") {
+		t.Errorf("expected the job to use the resolved bookkeeper URI; args: %s", args)
+	}
+	if !ReferencesDependency(c, BookkeeperClusterGVK, "bookkeeper", "bk") || ReferencesDependency(c, BookkeeperClusterGVK, "default", "bk") {
+		t.Errorf("expected the bookkeeper reference to default to the given namespace")
+	}
+	if !ReferencesDependency(c, ZookeeperClusterGVK, "default", "zk") {
+		t.Errorf("expected the zookeeper reference to default to the cluster namespace")
+	}
+}
+
+func TestLedgerReplicationConfigs(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.BookkeeperClusterUri = ""
+		c.Spec.BookkeeperRef = &v1alpha1.BookkeeperReference{Name: "bk"}
+		c.Spec.PulsarVersion = "3.0.0"
+		c.Status.Dependencies = &v1alpha1.DependenciesStatus{BookkeeperClusterURI: "zk+null://zk:2181/ledgers", Bookies: 4}
+	})
+	data := createConfigmapData(c)
+	want := map[string]string{
+		"managedLedgerDefaultEnsembleSize": "3",
+		"managedLedgerDefaultWriteQuorum":  "3",
+		"managedLedgerDefaultAckQuorum":    "2",
+	}
+	for k, v := range want {
+		if got := data[pulsarConfigEnvPrefix+k]; got != v {
+			t.Errorf("expected %s=%q; got: %q", k, v, got)
+		}
+	}
+}
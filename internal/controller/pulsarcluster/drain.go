@@ -0,0 +1,189 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
+)
+
+// preStopDrainScript unloads the bundles owned by the terminating broker through its admin
+// API and waits until it owns none or the timeout passes. It never fails the termination
+const preStopDrainScript = `set -- -sf; [ -f %[1]s ] && set -- "$@" -H "Authorization: Bearer $(cat %[1]s)"
+URL=%[2]s; BROKER="${HOSTNAME}.%[3]s"
+bundles() { curl -k "$@" "$URL/admin/v2/brokers/%[4]s/$BROKER/ownedNamespaces" | grep -o '"[^"]*/0x[0-9a-f]*_0x[0-9a-f]*"' | tr -d '"' | grep -v "$BROKER"; }
+for b in $(bundles "$@"); do curl -k "$@" -X PUT "$URL/admin/v2/namespaces/$b/unload"; done
+END=$(( $(date +%%s) + %[5]d )); while [ -n "$(bundles "$@")" ] && [ "$(date +%%s)" -lt "$END" ]; do sleep 2; done; exit 0`
+
+// brokerID returns the identifier of the broker as registered by pulsar: `<advertisedAddress>:<webPort>`
+func brokerID(c *v1alpha1.PulsarCluster, ordinal int32) string {
+	return fmt.Sprintf("%s:%d", c.BrokerPodFQDN(ordinal), brokerWebPort(c))
+}
+
+// brokerWebPort returns the web port the broker registers with; the
+// TLS one when the plain web port is disabled
+func brokerWebPort(c *v1alpha1.PulsarCluster) int32 {
+	if c.Spec.Ports.Web > 0 {
+		return c.Spec.Ports.Web
+	}
+	return c.Spec.Ports.WebTLS
+}
+
+// brokerAdminClient returns the admin API client of the broker with the specified ordinal
+func brokerAdminClient(ctx reconciler.Context, c *v1alpha1.PulsarCluster, ordinal int32) (*admin.Client, error) {
+	return newAdminClient(ctx, c, c.BrokerPodFQDN(ordinal))
+}
+
+// getBrokerPod returns the broker pod with the specified ordinal or nil if it does not exist
+func getBrokerPod(ctx reconciler.Context, c *v1alpha1.PulsarCluster, ordinal int32) (*v12.Pod, error) {
+	p := &v12.Pod{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      c.BrokerPodName(ordinal),
+		Namespace: c.Namespace,
+	}, p)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// isBrokerPodReady checks whether the broker pod with the specified ordinal exists and is ready
+func isBrokerPodReady(ctx reconciler.Context, c *v1alpha1.PulsarCluster, ordinal int32) (bool, error) {
+	p, err := getBrokerPod(ctx, c, ordinal)
+	if err != nil || p == nil {
+		return false, err
+	}
+	return pod.IsReady(p), nil
+}
+
+// drainBroker unloads the namespace bundles owned by the broker so that the load
+// manager reassigns them to the other brokers. It returns the number of bundles
+// the broker owned before the unload; zero means the broker is drained.
+func drainBroker(ctx reconciler.Context, c *v1alpha1.PulsarCluster, ordinal int32) (int, error) {
+	broker := brokerID(c, ordinal)
+	client, err := brokerAdminClient(ctx, c, ordinal)
+	if err != nil {
+		return 0, err
+	}
+	bundles, err := client.OwnedNamespaceBundles(c.PulsarClusterName(), broker)
+	if err != nil {
+		return 0, err
+	}
+	owned := 0
+	for _, bundle := range bundles {
+		if isBrokerSystemBundle(bundle, broker) {
+			continue
+		}
+		owned++
+		ctx.Logger().Info("Unloading the namespace bundle from the broker",
+			"cluster", c.GetName(),
+			"broker", broker,
+			"bundle", bundle)
+		if err = client.UnloadNamespaceBundle(bundle); err != nil && !admin.IsNotFound(err) {
+			return owned, err
+		}
+	}
+	return owned, nil
+}
+
+// isBrokerSystemBundle checks whether the bundle belongs to the broker's own heartbeat
+// or SLA monitor namespace; such bundles are always owned by the broker and can't be moved
+func isBrokerSystemBundle(bundle, broker string) bool {
+	return strings.Contains(bundle, broker)
+}
+
+// createPreStopHook creates the preStop hook draining the broker within its part of the termination grace period
+func createPreStopHook(c *v1alpha1.PulsarCluster) *v12.Lifecycle {
+	preStopSeconds, _ := c.Spec.ShutdownBudget()
+	if !c.Spec.Drain.IsPreStopHookEnabled() || preStopSeconds <= 0 {
+		return nil
+	}
+	port := brokerWebPort(c)
+	url := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if port != c.Spec.Ports.Web {
+		url = fmt.Sprintf("https://127.0.0.1:%d", port)
+	}
+	// the broker is registered with its pod FQDN; the pod hostname is its name
+	suffix := fmt.Sprintf("%s:%d", c.ClientHeadlessServiceFQDN(), port)
+	script := fmt.Sprintf(preStopDrainScript, fmt.Sprintf("%s/%s", brokerTokenMountPath, brokerTokenKey),
+		url, suffix, c.PulsarClusterName(), preStopSeconds)
+	return &v12.Lifecycle{
+		PreStop: &v12.LifecycleHandler{
+			Exec: &v12.ExecAction{Command: []string{"sh", "-c", script}},
+		},
+	}
+}
+
+// drainRemovedBrokers drains the brokers whose ordinals are removed by scaling down from the
+// replicas to the size. They're drained together so that their bundles aren't moved from one
+// of them to another. The scale down proceeds once they own no bundle or the drain timed out
+func drainRemovedBrokers(ctx reconciler.Context, c *v1alpha1.PulsarCluster, replicas, size int32) error {
+	scaleDown := c.Status.ScaleDown
+	if scaleDown == nil || scaleDown.FromReplicas != replicas || scaleDown.ToReplicas != size {
+		scaleDown = &v1alpha1.ScaleDownStatus{FromReplicas: replicas, ToReplicas: size, StartTime: metav1.Now()}
+		ctx.Logger().Info("Draining the brokers removed by the scale down",
+			"cluster", c.GetName(),
+			"FromReplicas", replicas,
+			"ToReplicas", size)
+		c.Status.ScaleDown = scaleDown
+		if err := ctx.Client().Status().Update(context.TODO(), c); err != nil {
+			return err
+		}
+	}
+	owned := 0
+	var drainErr error
+	for ordinal := size; ordinal < replicas; ordinal++ {
+		ready, err := isBrokerPodReady(ctx, c, ordinal)
+		if err != nil {
+			return err
+		}
+		if !ready {
+			// A broker which is not ready owns no bundles; they're reassigned by the load manager
+			continue
+		}
+		n, err := drainBroker(ctx, c, ordinal)
+		if err != nil {
+			drainErr = err
+		}
+		owned += n
+	}
+	if owned == 0 && drainErr == nil {
+		return nil
+	}
+	if elapsed := time.Since(scaleDown.StartTime.Time); elapsed < c.Spec.Drain.GetTimeout() {
+		if drainErr != nil {
+			return drainErr
+		}
+		return Requeue(defaultRequeueDelay, "waiting for the bundles of the removed brokers to be reassigned")
+	}
+	ctx.Logger().Info("The removed brokers are not drained within the timeout; scaling down anyway",
+		"cluster", c.GetName(),
+		"OwnedBundles", owned,
+		"Timeout", c.Spec.Drain.GetTimeout().String(),
+		"reason", fmt.Sprint(drainErr))
+	return nil
+}
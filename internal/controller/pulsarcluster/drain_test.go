@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"fmt"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"strings"
+	"testing"
+)
+
+func TestBrokerShutdownBudget(t *testing.T) {
+	t.Parallel()
+	disabled := false
+	tests := []struct {
+		name            string
+		gracePeriod     int64
+		drain           *v1alpha1.DrainConfig
+		wantPreStop     bool
+		wantShutdownMs  string
+		wantScriptParts []string
+	}{
+		{
+			name:            "default grace period",
+			gracePeriod:     30,
+			wantPreStop:     true,
+			wantShutdownMs:  "10000",
+			wantScriptParts: []string{"+ 15 ))", "/admin/v2/brokers/test/", "test-pls-headless.default.svc.cluster.local:8080"},
+		},
+		{
+			name:           "without the preStop hook",
+			gracePeriod:    60,
+			drain:          &v1alpha1.DrainConfig{PreStopHook: &disabled},
+			wantShutdownMs: "55000",
+		},
+		{
+			name:           "short grace period",
+			gracePeriod:    2,
+			wantPreStop:    true,
+			wantShutdownMs: "1000",
+		},
+	}
+	for _, tt := range tests {
+		gracePeriod := tt.gracePeriod
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.PulsarVersion = "3.0.0"
+			c.Spec.Drain = tt.drain
+			c.Spec.PodConfig.Spec.TerminationGracePeriodSeconds = &gracePeriod
+		})
+		if got := createConfigmapData(c)[pulsarConfigEnvPrefix+"brokerShutdownTimeoutMs"]; got != tt.wantShutdownMs {
+			t.Errorf("%s: expected brokerShutdownTimeoutMs=%s; got: %s", tt.name, tt.wantShutdownMs, got)
+		}
+		hook := createPreStopHook(c)
+		if got := hook != nil; got != tt.wantPreStop {
+			t.Errorf("%s: expected the preStop hook: %v; got: %+v", tt.name, tt.wantPreStop, hook)
+			continue
+		}
+		for _, part := range tt.wantScriptParts {
+			if script := hook.PreStop.Exec.Command[2]; !strings.Contains(script, part) {
+				t.Errorf("%s: expected the preStop script to contain %q; script: %s", tt.name, part, script)
+			}
+		}
+	}
+}
+
+func TestBrokerWebService(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		tls      bool
+		webPort  int32
+		wantURL  string
+		wantPort string
+		wantHook string
+	}{
+		{name: "plain", webPort: 8080, wantURL: "http://%s:8080", wantPort: ":8080", wantHook: "http://127.0.0.1:8080"},
+		{name: "tls", tls: true, webPort: 8080, wantURL: "https://%s:8443", wantPort: ":8080", wantHook: "http://127.0.0.1:8080"},
+		{name: "tls only", tls: true, webPort: -1, wantURL: "https://%s:8443", wantPort: ":8443", wantHook: "https://127.0.0.1:8443"},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.Ports = &v1alpha1.Ports{Web: tt.webPort, WebTLS: 8443}
+			if tt.tls {
+				c.Spec.TLS = &v1alpha1.TLSConfig{Enabled: true, SecretName: "pulsar-tls"}
+			}
+		})
+		host := c.BrokerPodFQDN(1)
+		if wantURL := fmt.Sprintf(tt.wantURL, host); webServiceURL(c, host) != wantURL {
+			t.Errorf("%s: expected the broker web service URL %q; got: %q", tt.name, wantURL, webServiceURL(c, host))
+		}
+		if got := brokerID(c, 1); got != host+tt.wantPort {
+			t.Errorf("%s: expected the broker id %q; got: %q", tt.name, host+tt.wantPort, got)
+		}
+		script := createPreStopHook(c).PreStop.Exec.Command[2]
+		if !strings.Contains(script, "URL="+tt.wantHook) || !strings.Contains(script, c.ClientHeadlessServiceFQDN()+tt.wantPort) {
+			t.Errorf("%s: expected the preStop hook to drain the broker %s through %s; got: %s", tt.name, tt.wantPort, tt.wantHook, script)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/configmap"
+	"github.com/monimesl/operator-helper/k8s/service"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	externalAddressesVolumeName = "external-addresses"
+	externalAddressesMountPath  = "/pulsar/external-addresses"
+	// externalAccessLabel labels the per-broker services with the name of their cluster
+	externalAccessLabel = internal.Domain + "/external-access"
+	podNameLabel        = "statefulset.kubernetes.io/pod-name"
+	hostIPEnvVar        = "HOST_IP"
+
+	// the external address of a broker is stored in the <pod>.host, <pod>.port and <pod>.tlsPort keys
+	externalHostKeySuffix    = ".host"
+	externalPortKeySuffix    = ".port"
+	externalTLSPortKeySuffix = ".tlsPort"
+)
+
+// ReconcileExternalAccess reconcile the services exposing every broker and records their external addresses
+// in a configmap mounted into the brokers. A broker waits for its address at startup to advertise it on the
+// external listener; the addresses are not part of the config hash so that they don't roll the brokers
+func ReconcileExternalAccess(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	services, err := reconcileExternalServices(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if !cluster.Spec.ExternalAccess.IsEnabled() {
+		return deleteExternalAddresses(ctx, cluster)
+	}
+	data := map[string]string{}
+	var pending []string
+	for ordinal, svc := range services {
+		addresses, ok := externalAddresses(cluster, int32(ordinal), svc)
+		if !ok {
+			pending = append(pending, svc.Name)
+			continue
+		}
+		for k, v := range addresses {
+			data[k] = v
+		}
+	}
+	if err = reconcileExternalAddresses(ctx, cluster, data); err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return Requeue(defaultRequeueDelay,
+			fmt.Sprintf("waiting for the external addresses of the services: %s", strings.Join(pending, ", ")))
+	}
+	return nil
+}
+
+// reconcileExternalServices creates or updates the service of every broker and deletes the
+// services of the removed brokers, or all of them if the external access is disabled
+func reconcileExternalServices(ctx reconciler.Context, c *v1alpha1.PulsarCluster) ([]*v1.Service, error) {
+	existing := &v1.ServiceList{}
+	if err := ctx.Client().List(context.TODO(), existing, client.InNamespace(c.Namespace),
+		client.MatchingLabels{externalAccessLabel: c.Name}); err != nil {
+		return nil, err
+	}
+	size := int32(0)
+	if c.Spec.ExternalAccess.IsEnabled() {
+		// with autoscaling, the brokers added by the autoscaler are exposed right away
+		size = c.Spec.MaxBrokers()
+	}
+	current := map[string]*v1.Service{}
+	for i := range existing.Items {
+		svc := &existing.Items[i]
+		current[svc.Name] = svc
+	}
+	services := make([]*v1.Service, 0, size)
+	for ordinal := int32(0); ordinal < size; ordinal++ {
+		desired := createExternalService(c, ordinal)
+		svc, ok := current[desired.Name]
+		delete(current, desired.Name)
+		if !ok {
+			if err := ctx.SetOwnershipReference(c, desired); err != nil {
+				return nil, err
+			}
+			ctx.Logger().Info("Creating the pulsar broker external service.",
+				"Service.Name", desired.GetName(),
+				"Service.Namespace", desired.GetNamespace(),
+				"Type", desired.Spec.Type)
+			if err := ctx.Client().Create(context.TODO(), desired); err != nil {
+				return nil, err
+			}
+			services = append(services, desired)
+			continue
+		}
+		if shouldUpdateExternalService(svc, desired) {
+			svc.Labels = desired.Labels
+			svc.Annotations = desired.Annotations
+			svc.Spec.Type = desired.Spec.Type
+			svc.Spec.Ports = keepNodePorts(desired.Spec.Ports, svc.Spec.Ports)
+			svc.Spec.LoadBalancerSourceRanges = desired.Spec.LoadBalancerSourceRanges
+			ctx.Logger().Info("Updating the pulsar broker external service.",
+				"Service.Name", svc.GetName(),
+				"Service.Namespace", svc.GetNamespace(),
+				"Type", svc.Spec.Type)
+			if err := ctx.Client().Update(context.TODO(), svc); err != nil {
+				return nil, err
+			}
+		}
+		services = append(services, svc)
+	}
+	for _, svc := range current {
+		ctx.Logger().Info("Deleting the pulsar broker external service.",
+			"Service.Name", svc.GetName(),
+			"Service.Namespace", svc.GetNamespace())
+		if err := ctx.Client().Delete(context.TODO(), svc); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+	return services, nil
+}
+
+func createExternalService(c *v1alpha1.PulsarCluster, ordinal int32) *v1.Service {
+	external := c.Spec.ExternalAccess
+	labels := c.GenerateLabels(true)
+	labels[externalAccessLabel] = c.Name
+	spec := v1.ServiceSpec{
+		Type:     v1.ServiceType(external.Type),
+		Selector: map[string]string{podNameLabel: c.BrokerPodName(ordinal)},
+		Ports:    externalServicePorts(c),
+		// the traffic is kept on the node of the broker; with NodePort, it's the address the broker advertises
+		ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+	}
+	if external.Type == v1alpha1.ExternalAccessLoadBalancer {
+		spec.LoadBalancerSourceRanges = external.LoadBalancerSourceRanges
+	}
+	svc := service.New(c.Namespace, c.ExternalServiceName(ordinal), labels, spec)
+	svc.Annotations = external.Annotations
+	return svc
+}
+
+func externalServicePorts(c *v1alpha1.PulsarCluster) []v1.ServicePort {
+	ports := []v1.ServicePort{{Name: v1alpha1.ClientPortName, Port: c.Spec.Ports.Client}}
+	if c.Spec.TLS.IsEnabled() {
+		ports = append(ports, v1.ServicePort{Name: v1alpha1.ClientTLSPortName, Port: c.Spec.Ports.ClientTLS})
+	}
+	return ports
+}
+
+func shouldUpdateExternalService(svc, desired *v1.Service) bool {
+	if svc.Spec.Type != desired.Spec.Type || len(svc.Spec.Ports) != len(desired.Spec.Ports) {
+		return true
+	}
+	for i, port := range desired.Spec.Ports {
+		if svc.Spec.Ports[i].Name != port.Name || svc.Spec.Ports[i].Port != port.Port {
+			return true
+		}
+	}
+	return !equality.Semantic.DeepEqual(svc.Labels, desired.Labels) ||
+		!equality.Semantic.DeepEqual(svc.Annotations, desired.Annotations) ||
+		!equality.Semantic.DeepEqual(svc.Spec.LoadBalancerSourceRanges, desired.Spec.LoadBalancerSourceRanges)
+}
+
+// keepNodePorts copies the node ports allocated to the current ports into the desired ones
+// so that the updates don't reallocate them and invalidate the advertised addresses
+func keepNodePorts(desired, current []v1.ServicePort) []v1.ServicePort {
+	ports := make([]v1.ServicePort, len(desired))
+	for i, port := range desired {
+		for _, cur := range current {
+			if cur.Name == port.Name {
+				port.NodePort = cur.NodePort
+			}
+		}
+		ports[i] = port
+	}
+	return ports
+}
+
+// externalAddresses returns the external address of the broker exposed by the service. The host is
+// left out for NodePort since the broker advertises the IP of the node it's scheduled on
+func externalAddresses(c *v1alpha1.PulsarCluster, ordinal int32, svc *v1.Service) (map[string]string, bool) {
+	pod := c.BrokerPodName(ordinal)
+	data := map[string]string{}
+	nodePort := svc.Spec.Type == v1.ServiceTypeNodePort
+	if !nodePort {
+		ingress := svc.Status.LoadBalancer.Ingress
+		if len(ingress) == 0 {
+			return nil, false
+		}
+		host := ingress[0].IP
+		if host == "" {
+			host = ingress[0].Hostname
+		}
+		data[pod+externalHostKeySuffix] = host
+	}
+	for _, port := range svc.Spec.Ports {
+		value := port.Port
+		if nodePort {
+			value = port.NodePort
+		}
+		if value == 0 {
+			return nil, false
+		}
+		switch port.Name {
+		case v1alpha1.ClientPortName:
+			data[pod+externalPortKeySuffix] = fmt.Sprint(value)
+		case v1alpha1.ClientTLSPortName:
+			data[pod+externalTLSPortKeySuffix] = fmt.Sprint(value)
+		}
+	}
+	return data, true
+}
+
+func reconcileExternalAddresses(ctx reconciler.Context, c *v1alpha1.PulsarCluster, data map[string]string) error {
+	cm := &v1.ConfigMap{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      c.ExternalAddressesConfigMapName(),
+		Namespace: c.Namespace,
+	}, cm,
+		// Found
+		func() error {
+			if equality.Semantic.DeepEqual(cm.Data, data) || (len(cm.Data) == 0 && len(data) == 0) {
+				return nil
+			}
+			cm.Data = data
+			ctx.Logger().Info("Updating the broker external addresses.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return ctx.Client().Update(context.TODO(), cm)
+		},
+		// Not Found
+		func() error {
+			cm = configmap.New(c.Namespace, c.ExternalAddressesConfigMapName(), data)
+			cm.Labels = c.GenerateLabels(false)
+			if err := ctx.SetOwnershipReference(c, cm); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the broker external addresses configmap.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return ctx.Client().Create(context.TODO(), cm)
+		})
+}
+
+func deleteExternalAddresses(ctx reconciler.Context, c *v1alpha1.PulsarCluster) error {
+	cm := &v1.ConfigMap{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      c.ExternalAddressesConfigMapName(),
+		Namespace: c.Namespace,
+	}, cm,
+		// Found
+		func() error {
+			ctx.Logger().Info("Deleting the broker external addresses configmap.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return client.IgnoreNotFound(ctx.Client().Delete(context.TODO(), cm))
+		},
+		// Not Found
+		func() error { return nil })
+}
+
+// createListenerConfigs creates the broker listener configs. The advertised listeners are
+// exported by the startup script since the external address differs for every broker
+func createListenerConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	if !c.Spec.ExternalAccess.IsEnabled() || !c.Spec.Version().Supports(version.AdvertisedListeners) {
+		return nil
+	}
+	return map[string]string{"internalListenerName": v1alpha1.InternalListenerName}
+}
+
+// createListenerScript creates the startup commands waiting for the external address of
+// the broker and exporting the advertised listeners applied by apply-config-from-env.py
+func createListenerScript(c *v1alpha1.PulsarCluster) []string {
+	if createListenerConfigs(c) == nil {
+		return nil
+	}
+	external := c.Spec.ExternalAccess.ListenerName
+	file := func(suffix string) string {
+		return fmt.Sprintf("%s/$HOSTNAME%s", externalAddressesMountPath, suffix)
+	}
+	internalHost := fmt.Sprintf("$HOSTNAME.%s", c.ClientHeadlessServiceFQDN())
+	listeners := fmt.Sprintf("%s:pulsar://%s:%d,%s:pulsar://$EXTERNAL_HOST:$(cat %s)",
+		v1alpha1.InternalListenerName, internalHost, c.Spec.Ports.Client, external, file(externalPortKeySuffix))
+	if c.Spec.TLS.IsEnabled() {
+		listeners += fmt.Sprintf(",%s:pulsar+ssl://%s:%d,%s:pulsar+ssl://$EXTERNAL_HOST:$(cat %s)",
+			v1alpha1.InternalListenerName, internalHost, c.Spec.Ports.ClientTLS, external, file(externalTLSPortKeySuffix))
+	}
+	return []string{
+		fmt.Sprintf("until [ -f %s ]; do echo \"waiting for the external address of $HOSTNAME\"; sleep 5; done",
+			file(externalPortKeySuffix)),
+		fmt.Sprintf("EXTERNAL_HOST=$(cat %s 2>/dev/null || echo $%s)", file(externalHostKeySuffix), hostIPEnvVar),
+		fmt.Sprintf("export %sadvertisedListeners=\"%s\"", pulsarConfigEnvPrefix, listeners),
+	}
+}
+
+// createExternalAccessVolumes creates the volume of the external addresses. It's optional
+// since the configmap is only created once the per-broker services are
+func createExternalAccessVolumes(c *v1alpha1.PulsarCluster) ([]v1.Volume, []v1.VolumeMount, []v1.EnvVar) {
+	if createListenerConfigs(c) == nil {
+		return nil, nil, nil
+	}
+	optional := true
+	volumes := []v1.Volume{
+		{
+			Name: externalAddressesVolumeName,
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: c.ExternalAddressesConfigMapName()},
+					Optional:             &optional,
+				},
+			},
+		},
+	}
+	mounts := []v1.VolumeMount{
+		{Name: externalAddressesVolumeName, MountPath: externalAddressesMountPath, ReadOnly: true},
+	}
+	envs := []v1.EnvVar{
+		{
+			Name: hostIPEnvVar,
+			ValueFrom: &v1.EnvVarSource{
+				FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.hostIP"},
+			},
+		},
+	}
+	return volumes, mounts, envs
+}
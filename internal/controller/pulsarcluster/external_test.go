@@ -0,0 +1,129 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+	"testing"
+)
+
+func newExternalAccessCluster(accessType v1alpha1.ExternalAccessType) *v1alpha1.PulsarCluster {
+	return newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.ExternalAccess = &v1alpha1.ExternalAccessConfig{Enabled: true, Type: accessType}
+	})
+}
+
+func TestExternalAddresses(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		accessType v1alpha1.ExternalAccessType
+		service    v1.Service
+		want       func(pod string) map[string]string
+	}{
+		{
+			name:       "pending load balancer",
+			accessType: v1alpha1.ExternalAccessLoadBalancer,
+			service: v1.Service{Spec: v1.ServiceSpec{
+				Type:  v1.ServiceTypeLoadBalancer,
+				Ports: []v1.ServicePort{{Name: v1alpha1.ClientPortName, Port: 6650}},
+			}},
+		},
+		{
+			name:       "load balancer hostname",
+			accessType: v1alpha1.ExternalAccessLoadBalancer,
+			service: v1.Service{
+				Spec: v1.ServiceSpec{
+					Type:  v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{{Name: v1alpha1.ClientPortName, Port: 6650, NodePort: 30000}},
+				},
+				Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{
+					Ingress: []v1.LoadBalancerIngress{{Hostname: "lb.example.com"}},
+				}},
+			},
+			want: func(pod string) map[string]string {
+				return map[string]string{pod + ".host": "lb.example.com", pod + ".port": "6650"}
+			},
+		},
+		{
+			name:       "node port",
+			accessType: v1alpha1.ExternalAccessNodePort,
+			service: v1.Service{Spec: v1.ServiceSpec{
+				Type: v1.ServiceTypeNodePort,
+				Ports: []v1.ServicePort{
+					{Name: v1alpha1.ClientPortName, Port: 6650, NodePort: 30000},
+					{Name: v1alpha1.ClientTLSPortName, Port: 6651, NodePort: 30001},
+				},
+			}},
+			want: func(pod string) map[string]string {
+				return map[string]string{pod + ".port": "30000", pod + ".tlsPort": "30001"}
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newExternalAccessCluster(tt.accessType)
+			got, ok := externalAddresses(c, 1, &tt.service)
+			if ok != (tt.want != nil) {
+				t.Fatalf("expected the address to be resolved: %v; got: %v", tt.want != nil, ok)
+			}
+			if tt.want == nil {
+				return
+			}
+			want := tt.want(c.BrokerPodName(1))
+			if len(got) != len(want) {
+				t.Fatalf("expected the addresses %v; got: %v", want, got)
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("expected the address %q to be %q; got: %q", k, v, got[k])
+				}
+			}
+		})
+	}
+}
+
+func TestCreateListenerScript(t *testing.T) {
+	t.Parallel()
+	c := newExternalAccessCluster(v1alpha1.ExternalAccessNodePort)
+	if configs := createListenerConfigs(c); configs["internalListenerName"] != v1alpha1.InternalListenerName {
+		t.Errorf("expected the internal listener to be configured; got: %v", configs)
+	}
+	script := strings.Join(createListenerScript(c), "; ")
+	wantListeners := "internal:pulsar://$HOSTNAME." + c.ClientHeadlessServiceFQDN() + ":6650," +
+		"external:pulsar://$EXTERNAL_HOST:$(cat " + externalAddressesMountPath + "/$HOSTNAME.port)"
+	if !strings.Contains(script, wantListeners) {
+		t.Errorf("expected the script to advertise %q; got: %s", wantListeners, script)
+	}
+	if strings.Contains(script, "pulsar+ssl") {
+		t.Errorf("expected no TLS listener when TLS is disabled; got: %s", script)
+	}
+	if _, _, envs := createExternalAccessVolumes(c); len(envs) != 1 || envs[0].Name != hostIPEnvVar {
+		t.Errorf("expected the %s env; got: %v", hostIPEnvVar, envs)
+	}
+	c.Spec.TLS = &v1alpha1.TLSConfig{Enabled: true, Internal: true}
+	if script = strings.Join(createListenerScript(c), "; "); !strings.Contains(script, "external:pulsar+ssl://") {
+		t.Errorf("expected the external TLS listener; got: %s", script)
+	}
+	c.Spec.ExternalAccess.Enabled = false
+	if script := createListenerScript(c); len(script) != 0 {
+		t.Errorf("expected no listener script when the external access is disabled; got: %v", script)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/monimesl/operator-helper/k8s/job"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/version"
 	v1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,7 +36,13 @@ func ReconcileJob(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error
 }
 
 func reconcileClusterMetadataInitJob(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
-	if cluster.Status.Metadata.Stage == "" { // the cluster is just created
+	if !cluster.Status.IsMetadataInitialized() && !cluster.DependenciesReady() {
+		return Requeue(defaultRequeueDelay, "waiting for the referenced zookeeper and bookkeeper clusters")
+	}
+	if !cluster.Status.IsMetadataInitialized() && isLocalMetadataStore(cluster) {
+		return markLocalMetadataStoreInitialized(ctx, cluster)
+	}
+	if !cluster.Status.IsMetadataInitialized() { // the cluster is just created
 		jb := &v1.Job{}
 		return ctx.GetResource(types.NamespacedName{
 			Namespace: cluster.Namespace,
@@ -43,8 +50,10 @@ func reconcileClusterMetadataInitJob(ctx reconciler.Context, cluster *v1alpha1.P
 		}, jb,
 			func() (err error) { // Job already exists
 				if jb.Status.Succeeded > 0 {
-					// Update the PulsarCluster Status Stage to Launching
+					// Update the PulsarCluster Status Stage to Initialized
 					cluster.Status.Metadata.Stage = v1alpha1.ClusterStageInitialized
+					cluster.Status.SetCondition(v1alpha1.ConditionMetadataInitialized, metav1.ConditionTrue,
+						"JobSucceeded", "the cluster metadata is initialized", cluster.Generation)
 					if err = ctx.Client().Status().Update(context.TODO(), cluster); err == nil {
 						ctx.Logger().Info("Pulsar cluster metadata initialization successful. ",
 							"cluster", cluster.GetName(),
@@ -54,6 +63,13 @@ func reconcileClusterMetadataInitJob(ctx reconciler.Context, cluster *v1alpha1.P
 				} else if jb.Status.Failed > 0 {
 					err1 := fmt.Errorf("pulsar cluster metadata "+
 						"initialization error: %s", jb.GetName())
+					cluster.Status.SetCondition(v1alpha1.ConditionMetadataInitialized, metav1.ConditionFalse,
+						"JobFailed", err1.Error(), cluster.Generation)
+					cluster.Status.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue,
+						"MetadataInitializationFailed", err1.Error(), cluster.Generation)
+					if err = ctx.Client().Status().Update(context.TODO(), cluster); err != nil {
+						return err
+					}
 					ctx.Logger().Error(err,
 						err1.Error(),
 
@@ -80,8 +96,23 @@ func reconcileClusterMetadataInitJob(ctx reconciler.Context, cluster *v1alpha1.P
 	return nil
 }
 
+// markLocalMetadataStoreInitialized skips the Job of a local metadata store; the brokers initialize it instead
+func markLocalMetadataStoreInitialized(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	cluster.Status.Metadata.Stage = v1alpha1.ClusterStageInitialized
+	cluster.Status.SetCondition(v1alpha1.ConditionMetadataInitialized, metav1.ConditionTrue,
+		"LocalMetadataStore", "the cluster metadata is initialized by the brokers", cluster.Generation)
+	if err := ctx.Client().Status().Update(context.TODO(), cluster); err != nil {
+		return err
+	}
+	ctx.Logger().Info("Pulsar cluster metadata is initialized by the brokers.",
+		"cluster", cluster.GetName(),
+		"MetadataStore.Type", cluster.MetadataStore().Type)
+	return nil
+}
+
 func createClusterMetadataInitJob(c *v1alpha1.PulsarCluster) *v1.Job {
 	labels := c.GenerateLabels(false)
+	volumes, _, _ := createMetadataStoreTLSVolumes(c)
 	return job.New(jobNamespace(c), initializeClusterMetadata(c), labels,
 		v1.JobSpec{
 			Template: coreV1.PodTemplateSpec{
@@ -93,12 +124,14 @@ func createClusterMetadataInitJob(c *v1alpha1.PulsarCluster) *v1.Job {
 				Spec: coreV1.PodSpec{
 					RestartPolicy: coreV1.RestartPolicyOnFailure,
 					Containers:    createJobPodSpecContainers(c),
+					Volumes:       volumes,
 				},
 			},
 		})
 }
 
 func createJobPodSpecContainers(c *v1alpha1.PulsarCluster) []coreV1.Container {
+	_, volumeMounts, envs := createMetadataStoreTLSVolumes(c)
 	return []coreV1.Container{
 		{
 			Name:            "cluster-metadata-init",
@@ -106,6 +139,8 @@ func createJobPodSpecContainers(c *v1alpha1.PulsarCluster) []coreV1.Container {
 			ImagePullPolicy: c.Image().PullPolicy,
 			Command:         k8s.ContainerShellCommand(),
 			Args:            createJobPodContainerArguments(c),
+			VolumeMounts:    volumeMounts,
+			Env:             envs,
 			EnvFrom: []coreV1.EnvFromSource{
 				{
 					ConfigMapRef: &coreV1.ConfigMapEnvSource{
@@ -120,32 +155,42 @@ func createJobPodSpecContainers(c *v1alpha1.PulsarCluster) []coreV1.Container {
 }
 
 func createJobPodContainerArguments(c *v1alpha1.PulsarCluster) []string {
+	args := append(createInitializeClusterMetadataArguments(c),
+		// In case we have istio sidecar injected into the Job
+		" && curl -sf -X POST http://127.0.0.1:15020/quitquitquit",
+	)
+	return []string{strings.Join(args, " ")}
+}
+
+// createInitializeClusterMetadataArguments creates the initialize-cluster-metadata command
+func createInitializeClusterMetadataArguments(c *v1alpha1.PulsarCluster) []string {
 	serviceUrl := c.ClientHeadlessServiceFQDN()
+	v := c.Spec.Version()
 	args := []string{
 		"bin/pulsar initialize-cluster-metadata",
-		fmt.Sprintf("--cluster %s", c.GetName()),
-		fmt.Sprintf("--zookeeper %s ", c.Spec.ZookeeperServers),
-		fmt.Sprintf("--configuration-store %s", c.Spec.ConfigurationStoreServers),
+		fmt.Sprintf("--cluster %s", c.PulsarClusterName()),
+	}
+	args = append(args, createMetadataStoreArguments(c, v)...)
+	args = append(args,
 		fmt.Sprintf("--web-service-url %s:%d", serviceUrl, c.Spec.Ports.Web),
 		fmt.Sprintf("--web-service-url-tls %s:%d", serviceUrl, c.Spec.Ports.WebTLS),
 		fmt.Sprintf("--broker-service-url %s:%d", serviceUrl, c.Spec.Ports.Client),
 		fmt.Sprintf("--broker-service-url-tls %s:%d", serviceUrl, c.Spec.Ports.ClientTLS),
-	}
-	if c.Spec.BookkeeperClusterUri != "" && c.Spec.VersionInt() >= 270 {
-		args = append(args,
-			fmt.Sprintf("--existing-bk-metadata-service-uri \"%s\"", c.Spec.BookkeeperClusterUri),
-		)
-	} else if c.Spec.BookkeeperClusterUri != "" && c.Spec.VersionInt() >= 262 {
-		args = append(args,
-			//  For compatibility of the command, we're passing the old flag to mean the same thing
-			fmt.Sprintf("--bookkeeper-metadata-service-uri \"%s\"", c.Spec.BookkeeperClusterUri),
-		)
-	}
-	args = append(args,
-		// In case we have istio sidecar injected into the Job
-		" && curl -sf -X POST http://127.0.0.1:15020/quitquitquit",
 	)
-	return []string{strings.Join(args, " ")}
+	if bookkeeperURI := c.BookkeeperMetadataServiceURI(); bookkeeperURI != "" {
+		switch {
+		case v.Supports(version.ExistingBookkeeperMetadataServiceURIFlag):
+			args = append(args,
+				fmt.Sprintf("--existing-bk-metadata-service-uri \"%s\"", bookkeeperURI),
+			)
+		case v.Supports(version.BookkeeperMetadataServiceURIFlag):
+			args = append(args,
+				//  For compatibility of the command, we're passing the old flag to mean the same thing
+				fmt.Sprintf("--bookkeeper-metadata-service-uri \"%s\"", bookkeeperURI),
+			)
+		}
+	}
+	return args
 }
 
 func initializeClusterMetadata(c *v1alpha1.PulsarCluster) string {
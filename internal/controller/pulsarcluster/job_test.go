@@ -0,0 +1,74 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"strings"
+	"testing"
+)
+
+func TestCreateJobPodContainerArguments(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		version string
+		want    []string
+		notWant []string
+	}{
+		{
+			version: "2.6.3",
+			want:    []string{"--zookeeper zk:2181", "--configuration-store zk:2181", "--bookkeeper-metadata-service-uri"},
+			notWant: []string{"--existing-bk-metadata-service-uri", "--metadata-store"},
+		},
+		{
+			version: "2.9.0",
+			want:    []string{"--zookeeper zk:2181", "--existing-bk-metadata-service-uri"},
+			notWant: []string{"--bookkeeper-metadata-service-uri", "--metadata-store"},
+		},
+		{
+			version: "2.10.1",
+			want:    []string{"--metadata-store zk:zk:2181", "--configuration-metadata-store zk:zk:2181", "--existing-bk-metadata-service-uri"},
+			notWant: []string{"--zookeeper", "--configuration-store "},
+		},
+		{
+			version: "3.0.0",
+			want:    []string{"--metadata-store zk:zk:2181", "--existing-bk-metadata-service-uri"},
+			notWant: []string{"--zookeeper"},
+		},
+		{
+			version: "4.0.1",
+			want:    []string{"--metadata-store zk:zk:2181", "--existing-bk-metadata-service-uri"},
+			notWant: []string{"--zookeeper"},
+		},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.PulsarVersion = tt.version
+		})
+		args := createJobPodContainerArguments(c)[0]
+		for _, w := range tt.want {
+			if !strings.Contains(args, w) {
+				t.Errorf("%s: expected the args to contain %q; args: %s", tt.version, w, args)
+			}
+		}
+		for _, nw := range tt.notWant {
+			if strings.Contains(args, nw) {
+				t.Errorf("%s: expected the args not to contain %q; args: %s", tt.version, nw, args)
+			}
+		}
+	}
+}
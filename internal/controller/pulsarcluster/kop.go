@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"fmt"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	v12 "k8s.io/api/core/v1"
+	"strings"
+)
+
+const (
+	protocolHandlerDirectory = "./protocols"
+	kopNarURLEnvVar          = "KOP_NAR_URL"
+)
+
+// createProtocolHandlerConfigs creates the broker configs of the enabled protocol handlers.
+// KoP is only configured when it has a nar to load and at least one listener to open
+func createProtocolHandlerConfigs(c *v1alpha1.PulsarCluster, v version.Version) map[string]string {
+	kop := c.Spec.KOP
+	if !kop.Enabled || kop.NarURL == "" || !v.Supports(version.ProtocolHandlers) || !v.Supports(version.KafkaProtocolHandler) {
+		return nil
+	}
+	listeners := kafkaListeners(c)
+	if len(listeners) == 0 {
+		return nil
+	}
+	configs := map[string]string{
+		"messagingProtocols":         "kafka",
+		"protocolHandlerDirectory":   protocolHandlerDirectory,
+		"allowAutoTopicCreationType": "partitioned",
+		"kafkaListeners":             strings.Join(listeners, ","),
+	}
+	if c.Spec.IsKopSecuredPortEnabled() && c.Spec.Authentication.IsEnabled() {
+		// the kafka clients authenticate with the pulsar tokens as the SASL/PLAIN password
+		configs["saslAllowedMechanisms"] = "PLAIN"
+	}
+	if v.Supports(version.BrokerEntryMetadata) {
+		configs["brokerEntryMetadataInterceptors"] = "org.apache.pulsar.common.intercept.AppendIndexMetadataInterceptor"
+	}
+	return configs
+}
+
+// kafkaListeners returns the KoP listeners of the enabled ports
+func kafkaListeners(c *v1alpha1.PulsarCluster) []string {
+	listeners := make([]string, 0)
+	if c.Spec.KOP.PlainTextPort > 0 {
+		listeners = append(listeners, fmt.Sprintf("PLAINTEXT://0.0.0.0:%d", c.Spec.KOP.PlainTextPort))
+	}
+	if c.Spec.IsKopSecuredPortEnabled() {
+		listeners = append(listeners, fmt.Sprintf("SASL_SSL://0.0.0.0:%d", c.Spec.KOP.SecuredPort))
+	}
+	return listeners
+}
+
+// createProtocolHandlerScript downloads the KoP nar into the protocol handler directory before the broker starts
+func createProtocolHandlerScript(c *v1alpha1.PulsarCluster) []string {
+	if createProtocolHandlerConfigs(c, c.Spec.Version()) == nil {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("mkdir -p %s", protocolHandlerDirectory),
+		fmt.Sprintf("python3 -c 'import sys, urllib.request; urllib.request.urlretrieve(sys.argv[1], sys.argv[2])' "+
+			"\"$%s\" %s/kop.nar", kopNarURLEnvVar, protocolHandlerDirectory),
+	}
+}
+
+// createProtocolHandlerEnvs passes the nar URL through the environment so that it's never interpreted by the shell
+func createProtocolHandlerEnvs(c *v1alpha1.PulsarCluster) []v12.EnvVar {
+	if createProtocolHandlerConfigs(c, c.Spec.Version()) == nil {
+		return nil
+	}
+	return []v12.EnvVar{{Name: kopNarURLEnvVar, Value: c.Spec.KOP.NarURL}}
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"testing"
+)
+
+func TestCreateProtocolHandlerConfigs(t *testing.T) {
+	t.Parallel()
+	narURL := "https://repo.example.com/pulsar-protocol-handler-kafka.nar"
+	tests := []struct {
+		name          string
+		version       string
+		kop           v1alpha1.KOP
+		tls           bool
+		auth          bool
+		wantListeners string
+		wantSASL      bool
+	}{
+		{name: "disabled", version: "2.10.1", kop: v1alpha1.KOP{NarURL: narURL}},
+		{name: "no nar", version: "2.10.1", kop: v1alpha1.KOP{Enabled: true}},
+		{name: "unsupported version", version: "2.7.4", kop: v1alpha1.KOP{Enabled: true, NarURL: narURL}},
+		{
+			name:          "plain text only without TLS",
+			version:       "2.10.1",
+			kop:           v1alpha1.KOP{Enabled: true, NarURL: narURL},
+			wantListeners: "PLAINTEXT://0.0.0.0:9092",
+		},
+		{
+			name:          "plain text and SASL_SSL with TLS",
+			version:       "2.10.1",
+			kop:           v1alpha1.KOP{Enabled: true, NarURL: narURL},
+			tls:           true,
+			auth:          true,
+			wantListeners: "PLAINTEXT://0.0.0.0:9092,SASL_SSL://0.0.0.0:9093",
+			wantSASL:      true,
+		},
+		{
+			name:          "disabled plain text port",
+			version:       "2.10.1",
+			kop:           v1alpha1.KOP{Enabled: true, NarURL: narURL, PlainTextPort: -1},
+			tls:           true,
+			wantListeners: "SASL_SSL://0.0.0.0:9093",
+		},
+		{
+			name:    "no enabled port",
+			version: "2.10.1",
+			kop:     v1alpha1.KOP{Enabled: true, NarURL: narURL, PlainTextPort: -1, SecuredPort: -1},
+			tls:     true,
+		},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.PulsarVersion = tt.version
+			c.Spec.KOP = tt.kop
+			if tt.tls {
+				c.Spec.TLS = &v1alpha1.TLSConfig{Enabled: true, SecretName: "pulsar-tls"}
+			}
+			if tt.auth {
+				c.Spec.Authentication = &v1alpha1.AuthenticationConfig{Enabled: true}
+			}
+		})
+		configs := createProtocolHandlerConfigs(c, c.Spec.Version())
+		script := createProtocolHandlerScript(c)
+		if tt.wantListeners == "" {
+			if configs != nil || script != nil {
+				t.Errorf("%s: expected no protocol handler; got: %v", tt.name, configs)
+			}
+			continue
+		}
+		if configs["kafkaListeners"] != tt.wantListeners {
+			t.Errorf("%s: expected the listeners: %q; got: %q", tt.name, tt.wantListeners, configs["kafkaListeners"])
+		}
+		if configs["messagingProtocols"] != "kafka" || configs["protocolHandlerDirectory"] != protocolHandlerDirectory {
+			t.Errorf("%s: expected the kafka protocol handler; got: %v", tt.name, configs)
+		}
+		if _, ok := configs["saslAllowedMechanisms"]; ok != tt.wantSASL {
+			t.Errorf("%s: expected the SASL mechanisms: %v; got: %v", tt.name, tt.wantSASL, ok)
+		}
+		if len(script) == 0 {
+			t.Errorf("%s: expected the nar download script", tt.name)
+		}
+		envs := createProtocolHandlerEnvs(c)
+		if len(envs) != 1 || envs[0].Value != narURL {
+			t.Errorf("%s: expected the nar URL env; got: %v", tt.name, envs)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// maintenanceRecheckDelay is the delay between the unloads of the bundles the load
+// manager may keep assigning to the drained broker since it's still an active broker
+const maintenanceRecheckDelay = time.Minute
+
+// ReconcileMaintenance takes the broker named by the maintenance annotation out of rotation; its pod
+// is excluded from the client service and its bundles are unloaded while it keeps running. The other
+// brokers are put back in rotation. It runs before the services are reconciled so that the pods are
+// labelled before the client service selects on the label.
+func ReconcileMaintenance(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	ordinal, inMaintenance := cluster.MaintenanceBrokerOrdinal()
+	if err := labelBrokersRotation(ctx, cluster, ordinal, inMaintenance); err != nil {
+		return err
+	}
+	if !inMaintenance {
+		if cluster.Status.Maintenance != nil {
+			ctx.Logger().Info("Putting the broker back in rotation.",
+				"cluster", cluster.GetName(),
+				"Pod", cluster.Status.Maintenance.Pod)
+			cluster.Status.Maintenance = nil
+		}
+		return nil
+	}
+	status := cluster.Status.Maintenance
+	if status == nil || status.Pod != cluster.BrokerPodName(ordinal) {
+		status = &v1alpha1.MaintenanceStatus{Pod: cluster.BrokerPodName(ordinal), StartTime: metav1.Now()}
+		ctx.Logger().Info("Taking the broker out of rotation for maintenance.",
+			"cluster", cluster.GetName(),
+			"Pod", status.Pod)
+		cluster.Status.Maintenance = status
+	}
+	return drainMaintenanceBroker(ctx, cluster, ordinal, status)
+}
+
+// labelBrokersRotation labels the broker pods in rotation except the one in maintenance if any
+func labelBrokersRotation(ctx reconciler.Context, c *v1alpha1.PulsarCluster, ordinal int32, inMaintenance bool) error {
+	pods := &v12.PodList{}
+	if err := ctx.Client().List(context.TODO(), pods, client.InNamespace(c.Namespace),
+		client.MatchingLabels(getBrokerSelectorLabels(c, true))); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		inRotation := brokerInRotation(c, p.Name, ordinal, inMaintenance)
+		if p.Labels[v1alpha1.InRotationLabel] == inRotation {
+			continue
+		}
+		patch := client.MergeFrom(p.DeepCopy())
+		if p.Labels == nil {
+			p.Labels = map[string]string{}
+		}
+		p.Labels[v1alpha1.InRotationLabel] = inRotation
+		ctx.Logger().Info("Labelling the broker pod rotation.",
+			"Pod.Name", p.GetName(),
+			"Pod.Namespace", p.GetNamespace(),
+			"InRotation", inRotation)
+		if err := ctx.Client().Patch(context.TODO(), p, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// brokerInRotation returns the value of the rotation label of the broker pod with the specified name
+func brokerInRotation(c *v1alpha1.PulsarCluster, name string, ordinal int32, inMaintenance bool) string {
+	if inMaintenance && name == c.BrokerPodName(ordinal) {
+		return "false"
+	}
+	return "true"
+}
+
+// drainMaintenanceBroker unloads the bundles of the broker in maintenance. The broker is
+// still active in the cluster so it's drained again periodically until it's put back in rotation
+func drainMaintenanceBroker(ctx reconciler.Context, c *v1alpha1.PulsarCluster, ordinal int32,
+	status *v1alpha1.MaintenanceStatus) error {
+	p, err := getBrokerPod(ctx, c, ordinal)
+	if err != nil {
+		return err
+	}
+	if p == nil || !pod.IsReady(p) || !c.Status.IsMetadataInitialized() {
+		// a broker which is not ready owns no bundles; the statefulset status changes trigger a new reconciliation
+		status.State = v1alpha1.MaintenanceStateUnavailable
+		status.OwnedBundles = 0
+		status.Message = "the broker pod doesn't exist or is not ready"
+		return nil
+	}
+	owned, err := drainBroker(ctx, c, ordinal)
+	status.OwnedBundles = int32(owned)
+	if err != nil {
+		status.State = v1alpha1.MaintenanceStateDraining
+		status.Message = fmt.Sprintf("unloading the bundles failed: %s", err)
+		return err
+	}
+	if owned > 0 {
+		status.State = v1alpha1.MaintenanceStateDraining
+		status.Message = fmt.Sprintf("unloading %d bundles", owned)
+		return Requeue(defaultRequeueDelay, "waiting for the bundles of the broker in maintenance to be reassigned")
+	}
+	status.State = v1alpha1.MaintenanceStateDrained
+	status.Message = ""
+	return Requeue(maintenanceRecheckDelay, "unloading the bundles reassigned to the broker in maintenance")
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"testing"
+)
+
+func TestBrokerRotationSelectors(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(nil)
+	if got := createClientService(c).Spec.Selector[v1alpha1.InRotationLabel]; got != "true" {
+		t.Errorf("expected the client service to select the brokers in rotation; got: %q", got)
+	}
+	if _, ok := createHeadlessService(c).Spec.Selector[v1alpha1.InRotationLabel]; ok {
+		t.Errorf("expected the headless service to select the brokers in maintenance too")
+	}
+	sts := createStatefulSet(c, "")
+	if _, ok := sts.Spec.Selector.MatchLabels[v1alpha1.InRotationLabel]; ok {
+		t.Errorf("expected the statefulset selector not to select on the rotation label")
+	}
+	if got := sts.Spec.Template.Labels[v1alpha1.InRotationLabel]; got != "true" {
+		t.Errorf("expected the broker pods to be created in rotation; got: %q", got)
+	}
+}
+
+func TestBrokerInRotation(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(nil)
+	tests := []struct {
+		name          string
+		pod           string
+		inMaintenance bool
+		want          string
+	}{
+		{name: "no maintenance", pod: c.BrokerPodName(1), want: "true"},
+		{name: "broker in maintenance", pod: c.BrokerPodName(1), inMaintenance: true, want: "false"},
+		{name: "other broker", pod: c.BrokerPodName(0), inMaintenance: true, want: "true"},
+	}
+	for _, tt := range tests {
+		if got := brokerInRotation(c, tt.pod, 1, tt.inMaintenance); got != tt.want {
+			t.Errorf("%s: expected in rotation: %s; got: %s", tt.name, tt.want, got)
+		}
+	}
+}
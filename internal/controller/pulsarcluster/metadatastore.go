@@ -0,0 +1,129 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"fmt"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/version"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+const (
+	metadataStoreTLSVolumeName = "metadata-store-tls"
+	metadataStoreTLSMountPath  = "/pulsar/certs/metadata-store"
+	// metadataInitializedMarker marks the local rocksdb metadata store as initialized
+	metadataInitializedMarker = dataVolumeMouthPath + "/.metadata-initialized"
+)
+
+// createMetadataStoreConfigs creates the broker configs of the metadata and configuration stores.
+// Before pulsar 2.10.0 only zookeeper is supported, configured without the backend prefix
+func createMetadataStoreConfigs(c *v1alpha1.PulsarCluster, v version.Version) map[string]string {
+	store := c.MetadataStore()
+	if v.Supports(version.MetadataStoreURL) {
+		return map[string]string{
+			"metadataStoreUrl":              store.PrefixedURL(store.URL),
+			"configurationMetadataStoreUrl": store.PrefixedURL(store.ConfigurationStoreURL()),
+		}
+	}
+	return map[string]string{
+		"zookeeperServers":          store.URL,
+		"configurationStoreServers": store.ConfigurationStoreURL(),
+	}
+}
+
+// createMetadataStoreArguments creates the metadata store flags of the initialize-cluster-metadata command
+func createMetadataStoreArguments(c *v1alpha1.PulsarCluster, v version.Version) []string {
+	store := c.MetadataStore()
+	if v.Supports(version.MetadataStoreURL) {
+		return []string{
+			fmt.Sprintf("--metadata-store %s", store.PrefixedURL(store.URL)),
+			fmt.Sprintf("--configuration-metadata-store %s", store.PrefixedURL(store.ConfigurationStoreURL())),
+		}
+	}
+	return []string{
+		fmt.Sprintf("--zookeeper %s", store.URL),
+		fmt.Sprintf("--configuration-store %s", store.ConfigurationStoreURL()),
+	}
+}
+
+// isLocalMetadataStore checks whether the metadata store lives in the broker data volume. Such
+// a store cannot be initialized by the Job; the broker initializes it before starting instead
+func isLocalMetadataStore(c *v1alpha1.PulsarCluster) bool {
+	return c.MetadataStore().Type == v1alpha1.MetadataStoreRocksDB
+}
+
+// createMetadataStoreTLSVolumes creates the volume of the metadata store TLS secret and the
+// PULSAR_EXTRA_OPTS env carrying the zookeeper client TLS properties. The env is set explicitly
+// on the container since the JVM options of the configmap are not read by the pulsar scripts
+func createMetadataStoreTLSVolumes(c *v1alpha1.PulsarCluster) ([]v1.Volume, []v1.VolumeMount, []v1.EnvVar) {
+	tls := c.MetadataStore().TLS
+	if !tls.IsEnabled() {
+		return nil, nil, nil
+	}
+	items := []v1.KeyToPath{{Key: v1alpha1.MetadataStoreCACertKey, Path: v1alpha1.MetadataStoreCACertKey}}
+	options := append([]string{}, c.Spec.JVMOptions.Extra...)
+	options = append(options,
+		"-Dzookeeper.clientCnxnSocket=org.apache.zookeeper.ClientCnxnSocketNetty",
+		"-Dzookeeper.client.secure=true",
+		fmt.Sprintf("-Dzookeeper.ssl.trustStore.location=%s/%s", metadataStoreTLSMountPath, v1alpha1.MetadataStoreCACertKey),
+		"-Dzookeeper.ssl.trustStore.type=PEM",
+	)
+	if tls.ClientAuth {
+		items = append(items, v1.KeyToPath{Key: v1alpha1.MetadataStoreKeyStoreKey, Path: v1alpha1.MetadataStoreKeyStoreKey})
+		options = append(options,
+			fmt.Sprintf("-Dzookeeper.ssl.keyStore.location=%s/%s", metadataStoreTLSMountPath, v1alpha1.MetadataStoreKeyStoreKey),
+			"-Dzookeeper.ssl.keyStore.type=PEM",
+		)
+	}
+	volumes := []v1.Volume{
+		{
+			Name: metadataStoreTLSVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{SecretName: tls.SecretName, Items: items},
+			},
+		},
+	}
+	mounts := []v1.VolumeMount{{Name: metadataStoreTLSVolumeName, MountPath: metadataStoreTLSMountPath, ReadOnly: true}}
+	envs := []v1.EnvVar{{Name: "PULSAR_EXTRA_OPTS", Value: strings.Join(options, " ")}}
+	return volumes, mounts, envs
+}
+
+// createLocalMetadataInitContainer creates the broker init container initializing the local
+// metadata store once; the marker file in the data volume keeps the restarts from re-running it
+func createLocalMetadataInitContainer(c *v1alpha1.PulsarCluster, mounts []v1.VolumeMount) v1.Container {
+	command := fmt.Sprintf("[ -f %s ] || (%s && touch %s)", metadataInitializedMarker,
+		strings.Join(createInitializeClusterMetadataArguments(c), " "), metadataInitializedMarker)
+	return v1.Container{
+		Name:            "cluster-metadata-init",
+		Image:           c.Image().ToString(),
+		ImagePullPolicy: c.Image().PullPolicy,
+		Command:         []string{"sh", "-c"},
+		Args:            []string{command},
+		VolumeMounts:    mounts,
+		EnvFrom: []v1.EnvFromSource{
+			{
+				ConfigMapRef: &v1.ConfigMapEnvSource{
+					LocalObjectReference: v1.LocalObjectReference{
+						Name: c.ConfigMapName(),
+					},
+				},
+			},
+		},
+	}
+}
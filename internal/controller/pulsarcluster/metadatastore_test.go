@@ -0,0 +1,144 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"strings"
+	"testing"
+)
+
+func TestCreateMetadataStoreConfigs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		version string
+		store   v1alpha1.MetadataStoreConfig
+		want    map[string]string
+	}{
+		{
+			name:    "zookeeper before 2.10",
+			version: "2.9.0",
+			store:   v1alpha1.MetadataStoreConfig{URL: "zk:2181", ConfigurationURL: "global-zk:2181"},
+			want:    map[string]string{"zookeeperServers": "zk:2181", "configurationStoreServers": "global-zk:2181"},
+		},
+		{
+			name:    "zookeeper",
+			version: "3.0.0",
+			store:   v1alpha1.MetadataStoreConfig{URL: "zk:2181"},
+			want:    map[string]string{"metadataStoreUrl": "zk:zk:2181", "configurationMetadataStoreUrl": "zk:zk:2181"},
+		},
+		{
+			name:    "etcd",
+			version: "3.0.0",
+			store:   v1alpha1.MetadataStoreConfig{Type: v1alpha1.MetadataStoreEtcd, URL: "http://etcd:2379"},
+			want: map[string]string{
+				"metadataStoreUrl":              "etcd:http://etcd:2379",
+				"configurationMetadataStoreUrl": "etcd:http://etcd:2379",
+			},
+		},
+		{
+			name:    "rocksdb",
+			version: "3.0.0",
+			store:   v1alpha1.MetadataStoreConfig{Type: v1alpha1.MetadataStoreRocksDB},
+			want: map[string]string{
+				"metadataStoreUrl":              "rocksdb:/data/metadata-store",
+				"configurationMetadataStoreUrl": "rocksdb:/data/metadata-store",
+			},
+		},
+		{
+			name:    "oxia",
+			version: "3.1.0",
+			store:   v1alpha1.MetadataStoreConfig{Type: v1alpha1.MetadataStoreOxia, URL: "oxia:6648/pulsar"},
+			want: map[string]string{
+				"metadataStoreUrl":              "oxia://oxia:6648/pulsar",
+				"configurationMetadataStoreUrl": "oxia://oxia:6648/pulsar",
+			},
+		},
+	}
+	for _, tt := range tests {
+		store := tt.store
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.ZookeeperServers = ""
+			c.Spec.MetadataStore = &store
+			c.Spec.PulsarVersion = tt.version
+		})
+		data := createConfigmapData(c)
+		for k, v := range tt.want {
+			if data[pulsarConfigEnvPrefix+k] != v {
+				t.Errorf("%s: expected %s=%q; got: %q", tt.name, k, v, data[pulsarConfigEnvPrefix+k])
+			}
+		}
+		args := createJobPodContainerArguments(c)[0]
+		for _, v := range tt.want {
+			if !strings.Contains(args, v) {
+				t.Errorf("%s: expected the args to contain %q; args: %s", tt.name, v, args)
+			}
+		}
+	}
+}
+
+func TestMetadataStoreTLS(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.ZookeeperServers = ""
+		c.Spec.MetadataStore = &v1alpha1.MetadataStoreConfig{
+			URL: "zk:2281",
+			TLS: &v1alpha1.MetadataStoreTLS{Enabled: true, SecretName: "zk-tls", ClientAuth: true},
+		}
+		c.Spec.PulsarVersion = "3.0.0"
+	})
+	volumes, mounts, envs := createMetadataStoreTLSVolumes(c)
+	if len(volumes) != 1 || volumes[0].Secret.SecretName != "zk-tls" || len(volumes[0].Secret.Items) != 2 {
+		t.Errorf("expected the TLS secret volume with the CA and the key store; got: %+v", volumes)
+	}
+	if len(mounts) != 1 || mounts[0].MountPath != metadataStoreTLSMountPath {
+		t.Errorf("expected the TLS secret mount; got: %+v", mounts)
+	}
+	for _, w := range []string{"-Dzookeeper.client.secure=true", "ssl.trustStore.location=", "ssl.keyStore.location="} {
+		if len(envs) != 1 || !strings.Contains(envs[0].Value, w) {
+			t.Errorf("expected PULSAR_EXTRA_OPTS to contain %q; got: %+v", w, envs)
+		}
+	}
+	if names := ReferencedSecretNames(c); len(names) != 1 || names[0] != "zk-tls" {
+		t.Errorf("expected the TLS secret to be referenced; got: %v", names)
+	}
+}
+
+func TestLocalMetadataStoreInitContainer(t *testing.T) {
+	t.Parallel()
+	one := int32(1)
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.ZookeeperServers = ""
+		c.Spec.Size = &one
+		c.Spec.MetadataStore = &v1alpha1.MetadataStoreConfig{Type: v1alpha1.MetadataStoreRocksDB}
+		c.Spec.PulsarVersion = "3.0.0"
+	})
+	spec := createPodSpec(c)
+	var found bool
+	for _, container := range spec.InitContainers {
+		if container.Name == "cluster-metadata-init" {
+			found = true
+			if !strings.Contains(container.Args[0], "--metadata-store rocksdb:/data/metadata-store") {
+				t.Errorf("expected the init container to initialize the local store; got: %s", container.Args[0])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected the broker to initialize the local metadata store; got: %+v", spec.InitContainers)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+var (
+	// PodMonitorGVK is the kind of the prometheus operator objects scraping the broker pods
+	PodMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PodMonitor"}
+	// PrometheusRuleGVK is the kind of the prometheus operator objects holding the broker alerts
+	PrometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+)
+
+// writeLatencyBuckets are the buckets of the pulsar_storage_write_latency_le_* metrics in milliseconds
+var writeLatencyBuckets = []string{"0_5", "1", "5", "10", "20", "50", "100", "200", "1000", "overflow"}
+
+// ReconcileMonitoring reconcile the PodMonitor and PrometheusRule of the specified cluster. They're
+// rendered as unstructured objects so that the operator works without the prometheus operator CRDs
+func ReconcileMonitoring(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	monitoring := cluster.Spec.Monitoring
+	var podMonitor, rule *unstructured.Unstructured
+	if monitoring.IsEnabled() {
+		podMonitor = createPodMonitor(cluster)
+	}
+	if monitoring.AreAlertsEnabled() {
+		rule = createPrometheusRule(cluster)
+	}
+	if err := reconcileMonitoringObject(ctx, cluster, PodMonitorGVK, podMonitor); err != nil {
+		return err
+	}
+	return reconcileMonitoringObject(ctx, cluster, PrometheusRuleGVK, rule)
+}
+
+// reconcileMonitoringObject creates or updates the desired object, or deletes the
+// existing one if it's nil. Nothing is done if the kind is not installed
+func reconcileMonitoringObject(ctx reconciler.Context, c *v1alpha1.PulsarCluster,
+	gvk schema.GroupVersionKind, desired *unstructured.Unstructured) error {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      c.StatefulSetName(),
+		Namespace: c.Namespace,
+	}, existing)
+	switch {
+	case meta.IsNoMatchError(err):
+		if desired != nil {
+			ctx.Logger().Info("The prometheus operator kind is not installed; the brokers are not monitored.",
+				"Kind", gvk.Kind, "Group", gvk.Group)
+		}
+		return nil
+	case errors.IsNotFound(err):
+		if desired == nil {
+			return nil
+		}
+		if err = ctx.SetOwnershipReference(c, desired); err != nil {
+			return err
+		}
+		ctx.Logger().Info("Creating the pulsar broker monitoring object.",
+			"Kind", gvk.Kind,
+			"Name", desired.GetName(),
+			"Namespace", desired.GetNamespace())
+		return ctx.Client().Create(context.TODO(), desired)
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(existing, c):
+		return nil
+	case desired == nil:
+		ctx.Logger().Info("Deleting the pulsar broker monitoring object.",
+			"Kind", gvk.Kind,
+			"Name", existing.GetName(),
+			"Namespace", existing.GetNamespace())
+		return client.IgnoreNotFound(ctx.Client().Delete(context.TODO(), existing))
+	case existing.GetAnnotations()[configHashAnnotation] == desired.GetAnnotations()[configHashAnnotation]:
+		return nil
+	}
+	existing.SetLabels(desired.GetLabels())
+	existing.SetAnnotations(desired.GetAnnotations())
+	existing.Object["spec"] = desired.Object["spec"]
+	ctx.Logger().Info("Updating the pulsar broker monitoring object.",
+		"Kind", gvk.Kind,
+		"Name", existing.GetName(),
+		"Namespace", existing.GetNamespace())
+	return ctx.Client().Update(context.TODO(), existing)
+}
+
+// newMonitoringObject creates the object with the cluster labels, the monitoring labels and the spec hash
+func newMonitoringObject(c *v1alpha1.PulsarCluster, gvk schema.GroupVersionKind,
+	spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(c.StatefulSetName())
+	obj.SetNamespace(c.Namespace)
+	obj.SetLabels(mergeMaps(c.GenerateLabels(true), c.Spec.Monitoring.Labels))
+	obj.SetAnnotations(StampConfigHash(c.GenerateAnnotations(), ComputeHash(spec)))
+	return obj
+}
+
+// createPodMonitor creates the PodMonitor scraping the /metrics endpoint of the brokers on the web
+// port. The pulsar labels are honored since the broker metrics label the pulsar namespace as namespace
+func createPodMonitor(c *v1alpha1.PulsarCluster) *unstructured.Unstructured {
+	endpoint := map[string]interface{}{
+		"port":        v1alpha1.WebPortName,
+		"path":        "/metrics",
+		"interval":    c.Spec.Monitoring.Interval,
+		"honorLabels": true,
+	}
+	if c.Spec.Ports.Web <= 0 {
+		endpoint["port"] = v1alpha1.WebTLSPortName
+		endpoint["scheme"] = "https"
+		endpoint["tlsConfig"] = map[string]interface{}{"insecureSkipVerify": true}
+	}
+	return newMonitoringObject(c, PodMonitorGVK, map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": stringMap(getBrokerSelectorLabels(c, true)),
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{c.Namespace},
+		},
+		"podMetricsEndpoints": []interface{}{endpoint},
+	})
+}
+
+// createPrometheusRule creates the PrometheusRule with the default alerts of the brokers
+func createPrometheusRule(c *v1alpha1.PulsarCluster) *unstructured.Unstructured {
+	alerts := c.Spec.Monitoring.Alerts
+	selector := fmt.Sprintf(`job="%s"`, podMonitorJob(c))
+	rules := []interface{}{
+		alertRule("PulsarBrokerDown", fmt.Sprintf("up{%s} == 0", selector), "1m", "critical",
+			"The pulsar broker {{ $labels.pod }} is down",
+			"The broker metrics endpoint of {{ $labels.pod }} has not been reachable for 1 minute."),
+		alertRule("PulsarHighBacklog",
+			fmt.Sprintf("sum by (namespace) (pulsar_msg_backlog{%s}) > %d", selector, *alerts.BacklogThreshold), "5m", "warning",
+			"The pulsar namespace {{ $labels.namespace }} has a high backlog",
+			fmt.Sprintf("The message backlog of {{ $labels.namespace }} is {{ $value }}; above %d.", *alerts.BacklogThreshold)),
+		alertRule("PulsarHighStorageWriteLatency", writeLatencyExpr(selector, *alerts.WriteLatencyMillis), "5m", "warning",
+			"The storage writes of the pulsar namespace {{ $labels.namespace }} are slow",
+			fmt.Sprintf("{{ $value | humanizePercentage }} of the writes of {{ $labels.namespace }} take over %dms.",
+				*alerts.WriteLatencyMillis)),
+		alertRule("PulsarTooManyTopicsPerBroker",
+			fmt.Sprintf("sum by (pod) (pulsar_topics_count{%s}) > %d", selector, *alerts.TopicsPerBroker), "5m", "warning",
+			"The pulsar broker {{ $labels.pod }} serves too many topics",
+			fmt.Sprintf("The broker {{ $labels.pod }} serves {{ $value }} topics; above %d.", *alerts.TopicsPerBroker)),
+	}
+	return newMonitoringObject(c, PrometheusRuleGVK, map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name":  fmt.Sprintf("pulsar-broker.%s", c.PulsarClusterName()),
+				"rules": rules,
+			},
+		},
+	})
+}
+
+// writeLatencyExpr returns the ratio of the storage writes slower than the specified latency. The
+// broker exposes the write rate of every latency bucket; the ones above the latency are the slow writes
+func writeLatencyExpr(selector string, latencyMillis int64) string {
+	var slow, all []string
+	for _, bucket := range writeLatencyBuckets {
+		metric := fmt.Sprintf("pulsar_storage_write_latency_le_%s{%s}", bucket, selector)
+		if bucket == "overflow" {
+			metric = fmt.Sprintf("pulsar_storage_write_latency_overflow{%s}", selector)
+		}
+		all = append(all, metric)
+		// the overflow bucket doesn't parse; it's above all the latencies
+		millis, err := strconv.ParseFloat(strings.Replace(bucket, "_", ".", 1), 64)
+		if err != nil || millis > float64(latencyMillis) {
+			slow = append(slow, metric)
+		}
+	}
+	return fmt.Sprintf("sum by (namespace) (%s) / sum by (namespace) (%s) > 0.01",
+		strings.Join(slow, " + "), strings.Join(all, " + "))
+}
+
+// podMonitorJob returns the job the PodMonitor labels the broker targets with
+func podMonitorJob(c *v1alpha1.PulsarCluster) string {
+	return fmt.Sprintf("%s/%s", c.Namespace, c.StatefulSetName())
+}
+
+func alertRule(name, expr, duration, severity, summary, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"alert": name,
+		"expr":  expr,
+		"for":   duration,
+		"labels": map[string]interface{}{
+			"severity": severity,
+		},
+		"annotations": map[string]interface{}{
+			"summary":     summary,
+			"description": description,
+		},
+	}
+}
+
+// stringMap converts the map to the form of the unstructured objects
+func stringMap(m map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"strings"
+	"testing"
+)
+
+func TestCreatePodMonitor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		webPort    int32
+		wantPort   string
+		wantScheme string
+	}{
+		{name: "web port", webPort: 8080, wantPort: v1alpha1.WebPortName},
+		{name: "TLS web port only", webPort: -1, wantPort: v1alpha1.WebTLSPortName, wantScheme: "https"},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+			c.Spec.Ports = &v1alpha1.Ports{Web: tt.webPort, WebTLS: 8443}
+			c.Spec.Monitoring = &v1alpha1.MonitoringConfig{Enabled: true, Labels: map[string]string{"release": "prometheus"}}
+		})
+		monitor := createPodMonitor(c)
+		if monitor.GetKind() != "PodMonitor" || monitor.GetLabels()["release"] != "prometheus" {
+			t.Errorf("%s: expected a PodMonitor with the monitoring labels; got: %s %v", tt.name, monitor.GetKind(), monitor.GetLabels())
+		}
+		endpoints, _, _ := unstructured.NestedSlice(monitor.Object, "spec", "podMetricsEndpoints")
+		if len(endpoints) != 1 {
+			t.Fatalf("%s: expected a single endpoint; got: %v", tt.name, endpoints)
+		}
+		endpoint := endpoints[0].(map[string]interface{})
+		if endpoint["port"] != tt.wantPort || endpoint["path"] != "/metrics" || endpoint["interval"] != "30s" {
+			t.Errorf("%s: expected /metrics to be scraped every 30s on %s; got: %v", tt.name, tt.wantPort, endpoint)
+		}
+		if scheme, _ := endpoint["scheme"].(string); scheme != tt.wantScheme {
+			t.Errorf("%s: expected the scheme: %q; got: %q", tt.name, tt.wantScheme, scheme)
+		}
+		selector, _, _ := unstructured.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+		if _, ok := selector[v1alpha1.InRotationLabel]; ok || len(selector) == 0 {
+			t.Errorf("%s: expected the brokers in maintenance to be scraped too; got: %v", tt.name, selector)
+		}
+	}
+}
+
+func TestCreatePrometheusRule(t *testing.T) {
+	t.Parallel()
+	latency := int64(100)
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.Monitoring = &v1alpha1.MonitoringConfig{Enabled: true,
+			Alerts: &v1alpha1.MonitoringAlertsConfig{WriteLatencyMillis: &latency}}
+	})
+	groups, _, _ := unstructured.NestedSlice(createPrometheusRule(c).Object, "spec", "groups")
+	rules := groups[0].(map[string]interface{})["rules"].([]interface{})
+	exprs := map[string]string{}
+	for _, r := range rules {
+		rule := r.(map[string]interface{})
+		exprs[rule["alert"].(string)] = rule["expr"].(string)
+	}
+	want := map[string][]string{
+		"PulsarBrokerDown":             {`up{job="default/test-pls"} == 0`},
+		"PulsarHighBacklog":            {"pulsar_msg_backlog", "> 100000"},
+		"PulsarTooManyTopicsPerBroker": {"sum by (pod) (pulsar_topics_count", "> 10000"},
+		"PulsarHighStorageWriteLatency": {
+			"(pulsar_storage_write_latency_le_200{job=\"default/test-pls\"} + " +
+				"pulsar_storage_write_latency_le_1000{job=\"default/test-pls\"} + " +
+				"pulsar_storage_write_latency_overflow{job=\"default/test-pls\"}) / ",
+		},
+	}
+	for alert, parts := range want {
+		for _, part := range parts {
+			if !strings.Contains(exprs[alert], part) {
+				t.Errorf("expected the %s alert to contain %q; got: %q", alert, part, exprs[alert])
+			}
+		}
+	}
+	c.Spec.Monitoring.Alerts.Disabled = true
+	if c.Spec.Monitoring.AreAlertsEnabled() {
+		t.Errorf("expected the alerts to be disabled")
+	}
+}
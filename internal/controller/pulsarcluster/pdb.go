@@ -112,7 +112,7 @@ func updatePodDisruptionBudget(ctx reconciler.Context, pdb *v1.PodDisruptionBudg
 }
 
 func shouldUpdatePDB(spec v1alpha1.PulsarClusterSpec, pdb *v1.PodDisruptionBudget) bool {
-	if spec.PulsarVersion != pdb.Labels[k8s.LabelAppVersion] {
+	if spec.VersionLabel() != pdb.Labels[k8s.LabelAppVersion] {
 		return true
 	}
 	newMaxFailureNodes := intstr.FromInt32(spec.MaxUnavailableNodes)
@@ -0,0 +1,246 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strings"
+	"time"
+)
+
+// peerProbeInterval is the interval the replication peers are re-synced and probed at
+const peerProbeInterval = time.Minute
+
+// ReconcileReplication registers the geo-replication peers in the configuration store of the cluster,
+// keeps their service URLs and tokens in sync, deregisters the removed ones and records their reachability
+func ReconcileReplication(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	var peers []v1alpha1.ReplicationPeer
+	if cluster.Spec.Replication != nil {
+		peers = cluster.Spec.Replication.Peers
+	}
+	if len(peers) == 0 && len(cluster.Status.Replication) == 0 {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, v1alpha1.ConditionPeersReachable)
+		return nil
+	}
+	if !cluster.Status.IsAvailable() {
+		return Requeue(defaultRequeueDelay, "waiting for the cluster to be available to register the replication peers")
+	}
+	adminClient, err := AdminClient(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	statuses := make([]v1alpha1.ReplicationPeerStatus, 0, len(peers))
+	desired := map[string]bool{}
+	for i := range peers {
+		desired[peers[i].Name] = true
+		statuses = append(statuses, syncReplicationPeer(ctx, cluster, adminClient, &peers[i]))
+	}
+	// only the peers registered by the operator are deregistered
+	for _, peer := range cluster.Status.Replication {
+		if desired[peer.Name] || !peer.Registered {
+			continue
+		}
+		if err = adminClient.DeleteCluster(peer.Name); err != nil && !admin.IsNotFound(err) {
+			statuses = append(statuses, v1alpha1.ReplicationPeerStatus{
+				Name:       peer.Name,
+				Registered: true,
+				Message:    fmt.Sprintf("cannot deregister the removed peer: %s", err),
+			})
+			continue
+		}
+		ctx.Logger().Info("Deregistered the replication peer.",
+			"Cluster.Name", cluster.GetName(),
+			"Cluster.Namespace", cluster.GetNamespace(),
+			"Peer", peer.Name)
+	}
+	setReplicationStatus(cluster, statuses, metav1.Now())
+	return Requeue(peerProbeInterval, "re-sync and probe the replication peers")
+}
+
+// syncReplicationPeer registers or updates the peer and probes its reachability
+func syncReplicationPeer(ctx reconciler.Context, c *v1alpha1.PulsarCluster,
+	adminClient *admin.Client, peer *v1alpha1.ReplicationPeer) v1alpha1.ReplicationPeerStatus {
+	status := v1alpha1.ReplicationPeerStatus{Name: peer.Name}
+	data, probe, err := peerClusterData(ctx, c, peer)
+	if err != nil {
+		status.Message = err.Error()
+		return status
+	}
+	current, err := adminClient.GetCluster(peer.Name)
+	switch {
+	case admin.IsNotFound(err):
+		ctx.Logger().Info("Registering the replication peer.",
+			"Cluster.Name", c.GetName(),
+			"Cluster.Namespace", c.GetNamespace(),
+			"Peer", peer.Name)
+		err = adminClient.CreateCluster(peer.Name, data)
+	case err == nil && *current != *data:
+		ctx.Logger().Info("Updating the replication peer.",
+			"Cluster.Name", c.GetName(),
+			"Cluster.Namespace", c.GetNamespace(),
+			"Peer", peer.Name)
+		err = adminClient.UpdateCluster(peer.Name, data)
+	}
+	if err != nil {
+		status.Message = fmt.Sprintf("cannot register the peer: %s", err)
+		return status
+	}
+	status.Registered = true
+	if err = probe.Ready(); err != nil {
+		status.Message = fmt.Sprintf("the peer is unreachable: %s", err)
+		return status
+	}
+	status.Reachable = true
+	return status
+}
+
+// peerClusterData returns the data the peer is registered with and the admin client probing it
+func peerClusterData(ctx reconciler.Context, c *v1alpha1.PulsarCluster,
+	peer *v1alpha1.ReplicationPeer) (*admin.ClusterData, *admin.Client, error) {
+	if peer.ClusterRef == nil {
+		token, err := peerToken(ctx, c.Namespace, peer.AuthenticationSecretName, v1alpha1.ReplicationTokenKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		probeURL := peer.ServiceURL
+		if probeURL == "" {
+			probeURL = peer.ServiceURLTLS
+		}
+		return explicitPeerData(peer, token), admin.NewClient(probeURL).WithToken(token), nil
+	}
+	referenced := &v1alpha1.PulsarCluster{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      peer.ClusterRef.Name,
+		Namespace: c.Namespace,
+	}, referenced); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("the PulsarCluster %s does not exist", peer.ClusterRef.Name)
+		}
+		return nil, nil, err
+	}
+	if referenced.Spec.Ports == nil {
+		return nil, nil, fmt.Errorf("the PulsarCluster %s is not initialized", referenced.Name)
+	}
+	if name := referenced.PulsarClusterName(); name != peer.Name {
+		return nil, nil, fmt.Errorf("the PulsarCluster %s is named %s in pulsar", referenced.Name, name)
+	}
+	token := ""
+	if referenced.Spec.Authentication.IsEnabled() {
+		var err error
+		if token, err = peerToken(ctx, c.Namespace, referenced.TokenSecretName(), brokerTokenKey); err != nil {
+			return nil, nil, err
+		}
+	}
+	probe, err := AdminClient(ctx, referenced)
+	if err != nil {
+		return nil, nil, err
+	}
+	return referencedPeerData(referenced, token), probe, nil
+}
+
+// peerToken reads the token the brokers replicate to the peer with; it's empty if there's no secret
+func peerToken(ctx reconciler.Context, namespace, secretName, key string) (string, error) {
+	if secretName == "" {
+		return "", nil
+	}
+	sec := &v12.Secret{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      secretName,
+		Namespace: namespace,
+	}, sec); err != nil {
+		if errors.IsNotFound(err) {
+			return "", fmt.Errorf("the peer token secret %s does not exist", secretName)
+		}
+		return "", err
+	}
+	token := strings.TrimSpace(string(sec.Data[key]))
+	if token == "" {
+		return "", fmt.Errorf("the peer token secret %s has no %s key", secretName, key)
+	}
+	return token, nil
+}
+
+func explicitPeerData(peer *v1alpha1.ReplicationPeer, token string) *admin.ClusterData {
+	data := &admin.ClusterData{
+		ServiceURL:          peer.ServiceURL,
+		ServiceURLTLS:       peer.ServiceURLTLS,
+		BrokerServiceURL:    peer.BrokerServiceURL,
+		BrokerServiceURLTLS: peer.BrokerServiceURLTLS,
+	}
+	setPeerToken(data, token)
+	return data
+}
+
+// referencedPeerData returns the in-cluster URLs of the referenced cluster. The TLS URLs are
+// only registered when the peer has TLS enabled
+func referencedPeerData(peer *v1alpha1.PulsarCluster, token string) *admin.ClusterData {
+	host := peer.ClientServiceFQDN()
+	data := &admin.ClusterData{
+		ServiceURL:       fmt.Sprintf("http://%s:%d", host, peer.Spec.Ports.Web),
+		BrokerServiceURL: fmt.Sprintf("pulsar://%s:%d", host, peer.Spec.Ports.Client),
+	}
+	if peer.Spec.TLS.IsEnabled() {
+		data.ServiceURLTLS = fmt.Sprintf("https://%s:%d", host, peer.Spec.Ports.WebTLS)
+		data.BrokerServiceURLTLS = fmt.Sprintf("pulsar+ssl://%s:%d", host, peer.Spec.Ports.ClientTLS)
+	}
+	setPeerToken(data, token)
+	return data
+}
+
+func setPeerToken(data *admin.ClusterData, token string) {
+	if token == "" {
+		return
+	}
+	data.AuthenticationPlugin = tokenAuthenticationPlugin
+	data.AuthenticationParameters = "token:" + token
+}
+
+// setReplicationStatus records the peer statuses and the PeersReachable condition. The transition
+// time only changes when the reachability does so that the periodic probes don't rewrite the status
+func setReplicationStatus(c *v1alpha1.PulsarCluster, statuses []v1alpha1.ReplicationPeerStatus, now metav1.Time) {
+	var unreachable []string
+	for i := range statuses {
+		status := &statuses[i]
+		status.LastTransitionTime = now
+		if previous := c.Status.GetReplicationPeer(status.Name); previous != nil && previous.Reachable == status.Reachable {
+			status.LastTransitionTime = previous.LastTransitionTime
+		}
+		if !status.Reachable {
+			unreachable = append(unreachable, status.Name)
+		}
+	}
+	c.Status.Replication = statuses
+	switch {
+	case len(statuses) == 0:
+		meta.RemoveStatusCondition(&c.Status.Conditions, v1alpha1.ConditionPeersReachable)
+	case len(unreachable) == 0:
+		c.Status.SetCondition(v1alpha1.ConditionPeersReachable, metav1.ConditionTrue, "Reachable",
+			fmt.Sprintf("%d peers are registered and reachable", len(statuses)), c.Generation)
+	default:
+		c.Status.SetCondition(v1alpha1.ConditionPeersReachable, metav1.ConditionFalse, "Unreachable",
+			fmt.Sprintf("the peers are not registered or unreachable: %s", strings.Join(unreachable, ", ")),
+			c.Generation)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestReferencedPeerData(t *testing.T) {
+	t.Parallel()
+	peer := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Name = "eu-west"
+		c.Namespace = "pulsar"
+	})
+	host := peer.ClientServiceFQDN()
+	want := admin.ClusterData{
+		ServiceURL:       "http://" + host + ":8080",
+		BrokerServiceURL: "pulsar://" + host + ":6650",
+	}
+	if data := referencedPeerData(peer, ""); *data != want {
+		t.Errorf("expected the peer data %+v; got: %+v", want, *data)
+	}
+	peer.Spec.TLS = &v1alpha1.TLSConfig{Enabled: true}
+	want.ServiceURLTLS = "https://" + host + ":8443"
+	want.BrokerServiceURLTLS = "pulsar+ssl://" + host + ":6651"
+	want.AuthenticationPlugin = tokenAuthenticationPlugin
+	want.AuthenticationParameters = "token:jwt"
+	if data := referencedPeerData(peer, "jwt"); *data != want {
+		t.Errorf("expected the peer data %+v; got: %+v", want, *data)
+	}
+}
+
+func TestExplicitPeerData(t *testing.T) {
+	t.Parallel()
+	peer := &v1alpha1.ReplicationPeer{
+		Name:             "us-east",
+		ServiceURL:       "http://pulsar.us-east:8080",
+		BrokerServiceURL: "pulsar://pulsar.us-east:6650",
+	}
+	data := explicitPeerData(peer, "")
+	if data.ServiceURL != peer.ServiceURL || data.BrokerServiceURL != peer.BrokerServiceURL {
+		t.Errorf("expected the URLs of the peer; got: %+v", *data)
+	}
+	if data.AuthenticationPlugin != "" || data.AuthenticationParameters != "" {
+		t.Errorf("expected no authentication without a token; got: %+v", *data)
+	}
+}
+
+func TestSetReplicationStatus(t *testing.T) {
+	t.Parallel()
+	before := metav1.NewTime(time.Now().Add(-time.Hour))
+	now := metav1.Now()
+	c := &v1alpha1.PulsarCluster{}
+	c.Status.Replication = []v1alpha1.ReplicationPeerStatus{
+		{Name: "a", Registered: true, Reachable: true, LastTransitionTime: before},
+		{Name: "b", Registered: true, Reachable: true, LastTransitionTime: before},
+	}
+	setReplicationStatus(c, []v1alpha1.ReplicationPeerStatus{
+		{Name: "a", Registered: true, Reachable: true},
+		{Name: "b", Registered: true, Message: "the peer is unreachable"},
+	}, now)
+	if got := c.Status.GetReplicationPeer("a").LastTransitionTime; !got.Equal(&before) {
+		t.Errorf("expected the transition time of the unchanged peer to be kept; got: %v", got)
+	}
+	if got := c.Status.GetReplicationPeer("b").LastTransitionTime; !got.Equal(&now) {
+		t.Errorf("expected the transition time of the unreachable peer to be updated; got: %v", got)
+	}
+	condition := c.Status.GetCondition(v1alpha1.ConditionPeersReachable)
+	if condition == nil || condition.Status != metav1.ConditionFalse {
+		t.Errorf("expected the peers to be reported unreachable; got: %v", condition)
+	}
+	setReplicationStatus(c, nil, now)
+	if condition = c.Status.GetCondition(v1alpha1.ConditionPeersReachable); condition != nil {
+		t.Errorf("expected no condition without peers; got: %v", condition)
+	}
+}
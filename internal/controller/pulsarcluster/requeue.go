@@ -0,0 +1,55 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultRequeueDelay is the delay used while waiting on brokers to progress
+	defaultRequeueDelay = 10 * time.Second
+	// shortRequeueDelay is the delay used when the next step can proceed right away
+	shortRequeueDelay = time.Second
+)
+
+// requeueError signals that the reconciliation is waiting on the cluster to
+// progress and should be retried after a while. It's not a failure.
+type requeueError struct {
+	after  time.Duration
+	reason string
+}
+
+func (e *requeueError) Error() string {
+	return fmt.Sprintf("requeue after %s: %s", e.after, e.reason)
+}
+
+// Requeue returns an error requesting the reconciliation to be retried after the delay
+func Requeue(after time.Duration, reason string) error {
+	return &requeueError{after: after, reason: reason}
+}
+
+// RequeueAfter returns the delay of the requeue request and true if the error is one
+func RequeueAfter(err error) (time.Duration, bool) {
+	var re *requeueError
+	if errors.As(err, &re) {
+		return re.after, true
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReferencedSecretNames returns the names of the secrets mounted or read by the brokers
+func ReferencedSecretNames(c *v1alpha1.PulsarCluster) []string {
+	names := make([]string, 0)
+	if c.Spec.TLS.IsEnabled() {
+		names = append(names, c.TLSSecretName())
+	}
+	if c.Spec.Authentication.IsEnabled() {
+		names = append(names, c.TokenKeySecretName(), c.TokenSecretName())
+	}
+	if c.Spec.TieredStorage.IsEnabled() && c.Spec.TieredStorage.CredentialsSecretName != "" {
+		names = append(names, c.Spec.TieredStorage.CredentialsSecretName)
+	}
+	if tls := c.MetadataStore().TLS; tls.IsEnabled() {
+		names = append(names, tls.SecretName)
+	}
+	return names
+}
+
+// computeSecretsHash hashes the data of the secrets referenced by the brokers so
+// that the brokers are rolled whenever any of them changes e.g. on certificate rotation
+func computeSecretsHash(ctx reconciler.Context, c *v1alpha1.PulsarCluster) (string, error) {
+	names := ReferencedSecretNames(c)
+	if len(names) == 0 {
+		return "", nil
+	}
+	data := make([]map[string][]byte, 0, len(names))
+	for _, name := range names {
+		secret := &v12.Secret{}
+		err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+			Name:      name,
+			Namespace: c.Namespace,
+		}, secret)
+		if errors.IsNotFound(err) {
+			return "", Requeue(defaultRequeueDelay, fmt.Sprintf("waiting for the secret: %s", name))
+		} else if err != nil {
+			return "", err
+		}
+		data = append(data, secret.Data)
+	}
+	return ComputeHash(data), nil
+}
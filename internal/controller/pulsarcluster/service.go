@@ -24,6 +24,7 @@ import (
 	"github.com/monimesl/pulsar-operator/api/v1alpha1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"reflect"
 )
 
 // ReconcileServices reconcile the services of the specified cluster
@@ -43,8 +44,9 @@ func reconcileClientService(ctx reconciler.Context, cluster *v1alpha1.PulsarClus
 	}, svc,
 		// Found
 		func() error {
-			if shouldUpdateService(cluster.Spec, svc) {
-				if err := updateService(ctx, svc, cluster); err != nil {
+			selector := getClientSelectorLabels(cluster)
+			if shouldUpdateService(cluster.Spec, svc) || !reflect.DeepEqual(svc.Spec.Selector, selector) {
+				if err := updateService(ctx, svc, cluster, selector); err != nil {
 					return err
 				}
 			}
@@ -77,7 +79,7 @@ func reconcileHeadlessService(ctx reconciler.Context, cluster *v1alpha1.PulsarCl
 		// Found
 		func() error {
 			if shouldUpdateService(cluster.Spec, svc) {
-				if err := updateService(ctx, svc, cluster); err != nil {
+				if err := updateService(ctx, svc, cluster, getBrokerSelectorLabels(cluster, true)); err != nil {
 					return err
 				}
 			}
@@ -101,33 +103,35 @@ func reconcileHeadlessService(ctx reconciler.Context, cluster *v1alpha1.PulsarCl
 }
 
 func createClientService(c *v1alpha1.PulsarCluster) *v1.Service {
-	return createService(c, c.ClientServiceName(), true, servicePorts(c))
+	return createService(c, c.ClientServiceName(), true, getClientSelectorLabels(c), servicePorts(c))
 }
 
 func createHeadlessService(c *v1alpha1.PulsarCluster) *v1.Service {
-	return createService(c, c.HeadlessServiceName(), false, servicePorts(c))
+	// the headless service selects the brokers in maintenance too since it backs their DNS records
+	return createService(c, c.HeadlessServiceName(), false, getBrokerSelectorLabels(c, true), servicePorts(c))
 }
 
-func createService(c *v1alpha1.PulsarCluster, name string, hasClusterIP bool, servicePorts []v1.ServicePort) *v1.Service {
+func createService(c *v1alpha1.PulsarCluster, name string, hasClusterIP bool,
+	selector map[string]string, servicePorts []v1.ServicePort) *v1.Service {
 	clusterIP := ""
 	if !hasClusterIP {
 		clusterIP = v1.ClusterIPNone
 	}
 	srv := service.New(c.Namespace, name, c.GenerateLabels(true), v1.ServiceSpec{
 		ClusterIP: clusterIP,
-		Selector:  getBrokerSelectorLabels(c, true),
+		Selector:  selector,
 		Ports:     servicePorts,
 	})
 	srv.Annotations = c.GenerateAnnotations()
 	return srv
 }
 
-func updateService(ctx reconciler.Context, svc *v1.Service, c *v1alpha1.PulsarCluster) error {
+func updateService(ctx reconciler.Context, svc *v1.Service, c *v1alpha1.PulsarCluster, selector map[string]string) error {
 	ctx.Logger().Info("Updating the bookkeeper service.",
 		"service.Name", svc.GetName(),
 		"Service.Namespace", svc.GetNamespace(), "NewReplicas", c.Spec.Size)
 	svc.Labels = c.GenerateLabels(true)
-	svc.Spec.Selector = getBrokerSelectorLabels(c, true)
+	svc.Spec.Selector = selector
 	return ctx.Client().Update(context.TODO(), svc)
 }
 
@@ -149,7 +153,7 @@ func servicePorts(c *v1alpha1.PulsarCluster) []v1.ServicePort {
 		if kop.PlainTextPort > 0 {
 			svcPorts = append(svcPorts, v1.ServicePort{Name: v1alpha1.KopPlainTextPortName, Port: kop.PlainTextPort})
 		}
-		if kop.SecuredPort > 0 {
+		if c.Spec.IsKopSecuredPortEnabled() {
 			svcPorts = append(svcPorts, v1.ServicePort{Name: v1alpha1.KopSecuredPortName, Port: kop.SecuredPort})
 		}
 	}
@@ -157,5 +161,5 @@ func servicePorts(c *v1alpha1.PulsarCluster) []v1.ServicePort {
 }
 
 func shouldUpdateService(spec v1alpha1.PulsarClusterSpec, sts *v1.Service) bool {
-	return spec.PulsarVersion != sts.Labels[k8s.LabelAppVersion]
+	return spec.VersionLabel() != sts.Labels[k8s.LabelAppVersion]
 }
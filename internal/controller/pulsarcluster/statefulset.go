@@ -25,6 +25,7 @@ import (
 	"github.com/monimesl/operator-helper/k8s/statefulset"
 	"github.com/monimesl/operator-helper/reconciler"
 	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
 	v1 "k8s.io/api/apps/v1"
 	v12 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,13 +35,20 @@ import (
 
 const (
 	dataVolumeMouthPath = "/data"
+	// configHashAnnotation holds the hash of the rendered broker configs and pod template.
+	// Any change to it causes the statefulset controller to roll the broker pods
+	configHashAnnotation = internal.Domain + "/config-hash"
 )
 
 // ReconcileStatefulSet reconcile the statefulset of the specified cluster
 func ReconcileStatefulSet(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
-	if cluster.Status.Metadata.Stage != v1alpha1.ClusterStageInitialized {
+	if !cluster.Status.IsMetadataInitialized() {
 		return nil
 	}
+	secretsHash, err := computeSecretsHash(ctx, cluster)
+	if err != nil {
+		return err
+	}
 	sts := &v1.StatefulSet{}
 	return ctx.GetResource(types.NamespacedName{
 		Name:      cluster.StatefulSetName(),
@@ -48,16 +56,31 @@ func ReconcileStatefulSet(ctx reconciler.Context, cluster *v1alpha1.PulsarCluste
 	}, sts,
 		// Found
 		func() error {
-			if shouldUpdateStatefulSet(cluster.Spec, sts) {
-				if err := updateStatefulset(ctx, sts, cluster); err != nil {
+			desired := createStatefulSet(cluster, secretsHash)
+			if cluster.Spec.Autoscaling.IsEnabled() {
+				// the replicas are managed by the autoscaler which must not be fought
+				desired.Spec.Replicas = sts.Spec.Replicas
+			}
+			if isUpgrading(cluster, sts) {
+				return reconcileUpgrade(ctx, sts, desired, cluster)
+			}
+			if shouldUpdateStatefulSet(cluster.Spec, sts, desired) {
+				if replicas := *sts.Spec.Replicas; *desired.Spec.Replicas < replicas {
+					if err := drainRemovedBrokers(ctx, cluster, replicas, *desired.Spec.Replicas); err != nil {
+						return err
+					}
+				}
+				if err := updateStatefulset(ctx, sts, desired, cluster); err != nil {
 					return err
 				}
 			}
+			// the status is written by ReconcileStatus
+			cluster.Status.ScaleDown = nil
 			return nil
 		},
 		// Not Found
 		func() error {
-			sts = createStatefulSet(cluster)
+			sts = createStatefulSet(cluster, secretsHash)
 			if err := ctx.SetOwnershipReference(cluster, sts); err != nil {
 				return err
 			}
@@ -74,37 +97,69 @@ func ReconcileStatefulSet(ctx reconciler.Context, cluster *v1alpha1.PulsarCluste
 		})
 }
 
-func shouldUpdateStatefulSet(spec v1alpha1.PulsarClusterSpec, sts *v1.StatefulSet) bool {
-	if *spec.Size != *sts.Spec.Replicas {
+func shouldUpdateStatefulSet(spec v1alpha1.PulsarClusterSpec, sts, desired *v1.StatefulSet) bool {
+	if *desired.Spec.Replicas != *sts.Spec.Replicas {
 		return true
 	}
-	if spec.PulsarVersion != sts.Labels[k8s.LabelAppVersion] {
+	if spec.VersionLabel() != sts.Labels[k8s.LabelAppVersion] {
 		return true
 	}
-	return false
+	return sts.Spec.Template.Annotations[configHashAnnotation] !=
+		desired.Spec.Template.Annotations[configHashAnnotation]
 }
 
-func updateStatefulset(ctx reconciler.Context, sts *v1.StatefulSet, cluster *v1alpha1.PulsarCluster) error {
-	sts.Spec.Replicas = cluster.Spec.Size
-	sts.Labels = cluster.GenerateLabels(true)
-	sts.Spec.Selector.MatchLabels = getBrokerSelectorLabels(cluster, true)
+// updateStatefulset replaces the mutable parts of the existing statefulset spec
+// with the desired one. The selector, volume claim templates and service name
+// are immutable so they're left as they are.
+func updateStatefulset(ctx reconciler.Context, sts, desired *v1.StatefulSet, cluster *v1alpha1.PulsarCluster) error {
+	sts.Spec.Replicas = desired.Spec.Replicas
+	sts.Spec.Template = desired.Spec.Template
+	sts.Labels = desired.Labels
+	sts.Annotations = desired.Annotations
 	ctx.Logger().Info("Updating the pulsar broker  statefulset.",
 		"StatefulSet.Name", sts.GetName(),
-		"StatefulSet.Namespace", sts.GetNamespace(), "NewReplicas", cluster.Spec.Size)
+		"StatefulSet.Namespace", sts.GetNamespace(), "NewReplicas", *desired.Spec.Replicas,
+		"ConfigHash", desired.Spec.Template.Annotations[configHashAnnotation])
 	return ctx.Client().Update(context.TODO(), sts)
 }
 
-func createStatefulSet(c *v1alpha1.PulsarCluster) *v1.StatefulSet {
+// createStatefulSet creates the broker statefulset. The secretsHash is the hash of the
+// secrets referenced by the brokers; it's part of the config hash so that secret changes roll the brokers
+func createStatefulSet(c *v1alpha1.PulsarCluster, secretsHash string) *v1.StatefulSet {
 	pvcs := createPersistentVolumeClaims(c)
 	brokerSelectorLabels := getBrokerSelectorLabels(c, true)
 	templateSpec := createPodTemplateSpec(c, brokerSelectorLabels)
+	hashed := []interface{}{createConfigmapData(c), templateSpec}
+	if secretsHash != "" {
+		hashed = append(hashed, secretsHash)
+	}
+	templateSpec.Annotations = StampConfigHash(templateSpec.Annotations, ComputeHash(hashed...))
 	spec := statefulset.NewSpec(*c.Spec.Size, c.HeadlessServiceName(), brokerSelectorLabels, pvcs, templateSpec)
 	sts := statefulset.New(c.Namespace, c.StatefulSetName(), c.GenerateLabels(true), spec)
 	sts.Annotations = c.GenerateAnnotations()
 	return sts
 }
 
-func createPodTemplateSpec(c *v1alpha1.PulsarCluster, labels map[string]string) v12.PodTemplateSpec {
+// StampConfigHash returns a copy of the annotations with the config hash added.
+// The copy is needed since the pod annotations map may be shared with the cluster spec
+func StampConfigHash(annotations map[string]string, hash string) map[string]string {
+	stamped := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		stamped[k] = v
+	}
+	stamped[configHashAnnotation] = hash
+	return stamped
+}
+
+func createPodTemplateSpec(c *v1alpha1.PulsarCluster, selectorLabels map[string]string) v12.PodTemplateSpec {
+	labels := map[string]string{
+		v1alpha1.ClusterNameLabel: c.PulsarClusterName(),
+		// the broker in maintenance is relabelled by ReconcileMaintenance
+		v1alpha1.InRotationLabel: "true",
+	}
+	for k, v := range selectorLabels {
+		labels[k] = v
+	}
 	return v12.PodTemplateSpec{
 		ObjectMeta: pod.NewMetadata(c.Spec.PodConfig, "",
 			c.StatefulSetName(), labels,
@@ -122,6 +177,11 @@ func createPodSpec(c *v1alpha1.PulsarCluster) v12.PodSpec {
 	volumeMounts := []v12.VolumeMount{
 		{Name: c.BrokersDataPvcName(), MountPath: dataVolumeMouthPath},
 	}
+	volumes, tlsVolumeMounts := createTLSVolumes(c)
+	authVolumes, authVolumeMounts := createAuthenticationVolumes(c)
+	volumes = append(volumes, authVolumes...)
+	volumeMounts = append(volumeMounts, tlsVolumeMounts...)
+	volumeMounts = append(volumeMounts, authVolumeMounts...)
 	initContainers := []v12.Container{
 		{
 			Name: "broker-setup",
@@ -137,21 +197,50 @@ func createPodSpec(c *v1alpha1.PulsarCluster) v12.PodSpec {
 	envs = append(envs, v12.EnvVar{
 		Name: "PULSAR_DATA_DIRECTORY", Value: dataVolumeMouthPath,
 	})
+	externalVolumes, externalVolumeMounts, externalEnvs := createExternalAccessVolumes(c)
+	volumes = append(volumes, externalVolumes...)
+	envs = append(envs, externalEnvs...)
+	offloadVolumes, offloadVolumeMounts, offloadEnvs := createTieredStorageVolumes(c)
+	volumes = append(volumes, offloadVolumes...)
+	envs = append(envs, offloadEnvs...)
+	metadataStoreVolumes, metadataStoreVolumeMounts, metadataStoreEnvs := createMetadataStoreTLSVolumes(c)
+	volumes = append(volumes, metadataStoreVolumes...)
+	envs = append(envs, metadataStoreEnvs...)
+	envs = append(envs, createProtocolHandlerEnvs(c)...)
+	brokerVolumeMounts := append(append([]v12.VolumeMount{}, volumeMounts...), externalVolumeMounts...)
+	brokerVolumeMounts = append(brokerVolumeMounts, offloadVolumeMounts...)
+	brokerVolumeMounts = append(brokerVolumeMounts, metadataStoreVolumeMounts...)
+	if isLocalMetadataStore(c) {
+		initContainers = append(initContainers, createLocalMetadataInitContainer(c, brokerVolumeMounts))
+	}
+	startup := []string{
+		"echo \"yeah\" > status",
+		"rm -rf /pulsar/connectors",
+		"cp -r \"$PULSAR_DATA_DIRECTORY/connectors\" /pulsar",
+	}
+	startup = append(startup, createListenerScript(c)...)
+	startup = append(startup, createProtocolHandlerScript(c)...)
+	startup = append(startup,
+		"bin/apply-config-from-env.py conf/broker.conf",
+		"bin/pulsar broker",
+	)
 	probePort := c.Spec.Ports.Web
+	probeScheme := v12.URISchemeHTTP
 	if probePort <= 0 {
 		probePort = c.Spec.Ports.WebTLS
+		probeScheme = v12.URISchemeHTTPS
 	}
 	containers := []v12.Container{
 		{
 			Name:            "pulsar-broker",
-			VolumeMounts:    volumeMounts,
+			VolumeMounts:    brokerVolumeMounts,
 			Ports:           createContainerPorts(c),
 			Image:           c.Image().ToString(),
 			ImagePullPolicy: c.Image().PullPolicy,
 			Resources:       c.Spec.PodConfig.Spec.Resources,
-			StartupProbe:    createStartupProbe(c.Spec, probePort),
-			LivenessProbe:   createLivenessProbe(c.Spec, probePort),
-			ReadinessProbe:  createReadinessProbe(c.Spec, probePort),
+			StartupProbe:    createStartupProbe(c.Spec, probePort, probeScheme),
+			LivenessProbe:   createLivenessProbe(c.Spec, probePort, probeScheme),
+			ReadinessProbe:  createReadinessProbe(c.Spec, probePort, probeScheme),
 			Env:             pod.DecorateContainerEnvVars(true, envs...),
 			EnvFrom: []v12.EnvFromSource{
 				{
@@ -162,19 +251,12 @@ func createPodSpec(c *v1alpha1.PulsarCluster) v12.PodSpec {
 					},
 				},
 			},
-			Command: []string{"sh", "-c"},
-			Args: []string{
-				strings.Join([]string{
-					"echo \"yeah\" > status",
-					"rm -rf /pulsar/connectors",
-					"cp -r \"$PULSAR_DATA_DIRECTORY/connectors\" /pulsar",
-					"bin/apply-config-from-env.py conf/broker.conf",
-					"bin/pulsar broker",
-				}, "; "),
-			},
+			Lifecycle: createPreStopHook(c),
+			Command:   []string{"sh", "-c"},
+			Args:      []string{strings.Join(startup, "; ")},
 		},
 	}
-	return pod.NewSpec(c.Spec.PodConfig, nil, initContainers, containers)
+	return pod.NewSpec(c.Spec.PodConfig, volumes, initContainers, containers)
 }
 
 func generateConnectorString(c *v1alpha1.PulsarCluster) string {
@@ -211,36 +293,39 @@ func createContainerPorts(c *v1alpha1.PulsarCluster) []v12.ContainerPort {
 		if kop.PlainTextPort > 0 {
 			containerPorts = append(containerPorts, v12.ContainerPort{Name: v1alpha1.KopPlainTextPortName, ContainerPort: kop.PlainTextPort})
 		}
-		if kop.SecuredPort > 0 {
+		if c.Spec.IsKopSecuredPortEnabled() {
 			containerPorts = append(containerPorts, v12.ContainerPort{Name: v1alpha1.KopSecuredPortName, ContainerPort: kop.SecuredPort})
 		}
 	}
 	return containerPorts
 }
 
-func createStartupProbe(spec v1alpha1.PulsarClusterSpec, port int32) *v12.Probe {
+func createStartupProbe(spec v1alpha1.PulsarClusterSpec, port int32, scheme v12.URIScheme) *v12.Probe {
 	return spec.ProbeConfig.Startup.ToK8sProbe(v12.ProbeHandler{
 		HTTPGet: &v12.HTTPGetAction{
-			Port: intstr.FromInt32(port),
-			Path: "/status.html",
+			Port:   intstr.FromInt32(port),
+			Path:   "/status.html",
+			Scheme: scheme,
 		},
 	})
 }
 
-func createReadinessProbe(spec v1alpha1.PulsarClusterSpec, port int32) *v12.Probe {
+func createReadinessProbe(spec v1alpha1.PulsarClusterSpec, port int32, scheme v12.URIScheme) *v12.Probe {
 	return spec.ProbeConfig.Readiness.ToK8sProbe(v12.ProbeHandler{
 		HTTPGet: &v12.HTTPGetAction{
-			Port: intstr.FromInt32(port),
-			Path: "/status.html",
+			Port:   intstr.FromInt32(port),
+			Path:   "/status.html",
+			Scheme: scheme,
 		},
 	})
 }
 
-func createLivenessProbe(spec v1alpha1.PulsarClusterSpec, port int32) *v12.Probe {
-	return spec.ProbeConfig.Readiness.ToK8sProbe(v12.ProbeHandler{
+func createLivenessProbe(spec v1alpha1.PulsarClusterSpec, port int32, scheme v12.URIScheme) *v12.Probe {
+	return spec.ProbeConfig.Liveness.ToK8sProbe(v12.ProbeHandler{
 		HTTPGet: &v12.HTTPGetAction{
-			Port: intstr.FromInt32(port),
-			Path: "/status.html",
+			Port:   intstr.FromInt32(port),
+			Path:   "/status.html",
+			Scheme: scheme,
 		},
 	})
 }
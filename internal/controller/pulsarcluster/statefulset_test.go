@@ -0,0 +1,60 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"testing"
+)
+
+func TestCreateStatefulSetConfigHash(t *testing.T) {
+	t.Parallel()
+	base := createStatefulSet(newTestCluster(nil), "")
+	hash := base.Spec.Template.Annotations[configHashAnnotation]
+	if hash == "" {
+		t.Fatal("expected the pod template to be stamped with the config hash")
+	}
+	tests := []struct {
+		name        string
+		edit        func(c *v1alpha1.PulsarCluster)
+		secretsHash string
+		wantChange  bool
+	}{
+		{name: "unchanged", edit: func(c *v1alpha1.PulsarCluster) {}},
+		{name: "defaults applied twice", edit: func(c *v1alpha1.PulsarCluster) { c.SetSpecDefaults() }},
+		{name: "broker config", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.BrokerConfig["numIOThreads"] = "8" }, wantChange: true},
+		{name: "jvm memory", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.JVMOptions.Memory = []string{"-Xmx1g"} }, wantChange: true},
+		{name: "jvm extra options", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.JVMOptions.Extra = []string{"-Dfoo=bar"} }, wantChange: true},
+		{name: "startup probe", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.ProbeConfig.Startup.FailureThreshold++ }, wantChange: true},
+		{name: "liveness probe", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.ProbeConfig.Liveness.PeriodSeconds++ }, wantChange: true},
+		{name: "readiness probe", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.ProbeConfig.Readiness.PeriodSeconds++ }, wantChange: true},
+		{name: "client port", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.Ports.Client = 6660 }, wantChange: true},
+		{name: "web port", edit: func(c *v1alpha1.PulsarCluster) { c.Spec.Ports.Web = 8090 }, wantChange: true},
+		{name: "referenced secrets", edit: func(c *v1alpha1.PulsarCluster) {}, secretsHash: "abc", wantChange: true},
+	}
+	for _, tt := range tests {
+		c := newTestCluster(nil)
+		tt.edit(c)
+		desired := createStatefulSet(c, tt.secretsHash)
+		got := desired.Spec.Template.Annotations[configHashAnnotation]
+		if changed := got != hash; changed != tt.wantChange {
+			t.Errorf("%s: expected the config hash to change: %v; got: %v", tt.name, tt.wantChange, changed)
+		}
+		if update := shouldUpdateStatefulSet(c.Spec, base, desired); update != tt.wantChange {
+			t.Errorf("%s: expected the statefulset update: %v; got: %v", tt.name, tt.wantChange, update)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReconcileStatus reconcile the status of the specified cluster from the state of its broker statefulset
+func ReconcileStatus(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	if !cluster.Status.IsMetadataInitialized() {
+		cluster.Status.SetCondition(v1alpha1.ConditionMetadataInitialized, metav1.ConditionFalse,
+			"Initializing", "the cluster metadata init job is running", cluster.Generation)
+		cluster.Status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue,
+			"Initializing", "waiting for the cluster metadata to be initialized", cluster.Generation)
+		return updateStatus(ctx, cluster)
+	}
+	sts := &v1.StatefulSet{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      cluster.StatefulSetName(),
+		Namespace: cluster.Namespace,
+	}, sts,
+		// Found
+		func() error {
+			computeStatus(cluster, sts)
+			return updateStatus(ctx, cluster)
+		},
+		// Not Found
+		func() error {
+			cluster.Status.ReadyReplicas = 0
+			cluster.Status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse,
+				"NoBrokers", "the broker statefulset is not created yet", cluster.Generation)
+			cluster.Status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue,
+				"Creating", "the broker statefulset is being created", cluster.Generation)
+			return updateStatus(ctx, cluster)
+		})
+}
+
+// computeStatus drives the cluster stage and conditions from the replica counts of the statefulset
+func computeStatus(c *v1alpha1.PulsarCluster, sts *v1.StatefulSet) {
+	status := &c.Status
+	desired := *sts.Spec.Replicas
+	ready := sts.Status.ReadyReplicas
+	updated := sts.Status.UpdatedReplicas
+	rolledOut := sts.Status.ObservedGeneration == sts.Generation && updated == desired
+	target := *c.Spec.Size
+	if c.Spec.Autoscaling.IsEnabled() {
+		// the autoscaler sets the replicas of the statefulset
+		target = desired
+	}
+	status.ReadyReplicas = ready
+	if rolledOut && ready == desired && desired == target {
+		status.Metadata.Stage = v1alpha1.ClusterStageRunning
+	} else {
+		status.Metadata.Stage = v1alpha1.ClusterStageLaunched
+	}
+	if status.Upgrade != nil {
+		status.CurrentVersion = status.Upgrade.FromVersion
+	} else if rolledOut {
+		status.CurrentVersion = sts.Labels[k8s.LabelAppVersion]
+	}
+	if ready > 0 {
+		status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionTrue, "BrokersReady",
+			fmt.Sprintf("%d/%d brokers are ready", ready, desired), c.Generation)
+	} else {
+		status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse, "NoBrokerReady",
+			"none of the brokers is ready", c.Generation)
+	}
+	switch {
+	case status.Upgrade != nil:
+		status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue, "Upgrading",
+			fmt.Sprintf("upgrading the broker %s from %s to %s", c.BrokerPodName(status.Upgrade.CurrentOrdinal),
+				status.Upgrade.FromVersion, status.Upgrade.ToVersion), c.Generation)
+	case desired != target || sts.Status.Replicas != desired:
+		status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue, "Scaling",
+			fmt.Sprintf("scaling the brokers from %d to %d", sts.Status.Replicas, target), c.Generation)
+	case !rolledOut:
+		status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue, "RollingUpdate",
+			fmt.Sprintf("%d/%d brokers are updated", updated, desired), c.Generation)
+	default:
+		status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionFalse, "RolledOut",
+			"all the brokers are updated", c.Generation)
+	}
+	if rolledOut && status.Upgrade == nil && ready < desired {
+		status.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionTrue, "BrokersNotReady",
+			fmt.Sprintf("%d/%d brokers are not ready", desired-ready, desired), c.Generation)
+	} else {
+		status.SetCondition(v1alpha1.ConditionDegraded, metav1.ConditionFalse, "AsExpected",
+			"no broker is unexpectedly unready", c.Generation)
+	}
+}
+
+// updateStatus updates the cluster status if it changed since it was last read
+func updateStatus(ctx reconciler.Context, c *v1alpha1.PulsarCluster) error {
+	current := &v1alpha1.PulsarCluster{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      c.Name,
+		Namespace: c.Namespace,
+	}, current); err != nil {
+		return err
+	}
+	c.Status.ObservedGeneration = c.Generation
+	if equality.Semantic.DeepEqual(current.Status, c.Status) {
+		return nil
+	}
+	ctx.Logger().Info("Updating the pulsar cluster status",
+		"cluster", c.GetName(),
+		"Stage", c.Status.Metadata.Stage,
+		"ReadyReplicas", c.Status.ReadyReplicas)
+	return ctx.Client().Status().Update(context.TODO(), c)
+}
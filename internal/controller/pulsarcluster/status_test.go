@@ -0,0 +1,148 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestComputeStatus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		size            int32
+		replicas        int32
+		current         int32
+		ready           int32
+		updated         int32
+		observed        int64
+		upgrade         *v1alpha1.UpgradeStatus
+		wantStage       v1alpha1.ClusterStage
+		wantAvailable   metav1.ConditionStatus
+		wantProgressing string
+		wantDegraded    metav1.ConditionStatus
+		wantVersion     string
+	}{
+		{
+			name: "all ready and updated", size: 3, replicas: 3, current: 3, ready: 3, updated: 3, observed: 2,
+			wantStage: v1alpha1.ClusterStageRunning, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "RolledOut", wantDegraded: metav1.ConditionFalse, wantVersion: "2.10.1",
+		},
+		{
+			name: "none ready", size: 3, replicas: 3, current: 3, ready: 0, updated: 3, observed: 2,
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionFalse,
+			wantProgressing: "RolledOut", wantDegraded: metav1.ConditionTrue, wantVersion: "2.10.1",
+		},
+		{
+			name: "one not ready after the rollout", size: 3, replicas: 3, current: 3, ready: 2, updated: 3, observed: 2,
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "RolledOut", wantDegraded: metav1.ConditionTrue, wantVersion: "2.10.1",
+		},
+		{
+			name: "rolling update", size: 3, replicas: 3, current: 3, ready: 2, updated: 1, observed: 2,
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "RollingUpdate", wantDegraded: metav1.ConditionFalse,
+		},
+		{
+			name: "update not observed yet", size: 3, replicas: 3, current: 3, ready: 3, updated: 3, observed: 1,
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "RollingUpdate", wantDegraded: metav1.ConditionFalse,
+		},
+		{
+			name: "scaling up", size: 5, replicas: 5, current: 3, ready: 3, updated: 5, observed: 2,
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "Scaling", wantDegraded: metav1.ConditionTrue, wantVersion: "2.10.1",
+		},
+		{
+			name: "scale down not applied yet", size: 2, replicas: 3, current: 3, ready: 3, updated: 3, observed: 2,
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "Scaling", wantDegraded: metav1.ConditionFalse, wantVersion: "2.10.1",
+		},
+		{
+			name: "upgrading", size: 3, replicas: 3, current: 3, ready: 2, updated: 1, observed: 2,
+			upgrade:   &v1alpha1.UpgradeStatus{FromVersion: "2.9.3", ToVersion: "2.10.1", CurrentOrdinal: 1},
+			wantStage: v1alpha1.ClusterStageLaunched, wantAvailable: metav1.ConditionTrue,
+			wantProgressing: "Upgrading", wantDegraded: metav1.ConditionFalse, wantVersion: "2.9.3",
+		},
+	}
+	for _, tt := range tests {
+		c := &v1alpha1.PulsarCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Generation: 1},
+			Spec:       v1alpha1.PulsarClusterSpec{Size: &tt.size},
+		}
+		c.Status.Upgrade = tt.upgrade
+		sts := &v1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2, Labels: map[string]string{k8s.LabelAppVersion: "2.10.1"}},
+			Spec:       v1.StatefulSetSpec{Replicas: &tt.replicas},
+			Status: v1.StatefulSetStatus{
+				ObservedGeneration: tt.observed,
+				Replicas:           tt.current,
+				ReadyReplicas:      tt.ready,
+				UpdatedReplicas:    tt.updated,
+			},
+		}
+		computeStatus(c, sts)
+		if c.Status.Metadata.Stage != tt.wantStage {
+			t.Errorf("%s: expected the stage: %v; got: %v", tt.name, tt.wantStage, c.Status.Metadata.Stage)
+		}
+		if c.Status.ReadyReplicas != tt.ready {
+			t.Errorf("%s: expected %d ready replicas; got: %d", tt.name, tt.ready, c.Status.ReadyReplicas)
+		}
+		if got := c.Status.GetCondition(v1alpha1.ConditionAvailable); got == nil || got.Status != tt.wantAvailable {
+			t.Errorf("%s: expected the Available condition: %v; got: %v", tt.name, tt.wantAvailable, got)
+		}
+		if got := c.Status.GetCondition(v1alpha1.ConditionProgressing); got == nil || got.Reason != tt.wantProgressing {
+			t.Errorf("%s: expected the Progressing reason: %v; got: %v", tt.name, tt.wantProgressing, got)
+		}
+		if got := c.Status.GetCondition(v1alpha1.ConditionDegraded); got == nil || got.Status != tt.wantDegraded {
+			t.Errorf("%s: expected the Degraded condition: %v; got: %v", tt.name, tt.wantDegraded, got)
+		}
+		if c.Status.CurrentVersion != tt.wantVersion {
+			t.Errorf("%s: expected the current version: %q; got: %q", tt.name, tt.wantVersion, c.Status.CurrentVersion)
+		}
+	}
+}
+
+func TestComputeStatusWithAutoscaling(t *testing.T) {
+	t.Parallel()
+	size, replicas := int32(2), int32(4)
+	c := &v1alpha1.PulsarCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1alpha1.PulsarClusterSpec{
+			Size:        &size,
+			Autoscaling: &v1alpha1.AutoscalingConfig{Enabled: true, MaxReplicas: 5},
+		},
+	}
+	sts := &v1.StatefulSet{
+		Spec: v1.StatefulSetSpec{Replicas: &replicas},
+		Status: v1.StatefulSetStatus{
+			Replicas:        replicas,
+			ReadyReplicas:   replicas,
+			UpdatedReplicas: replicas,
+		},
+	}
+	computeStatus(c, sts)
+	if c.Status.Metadata.Stage != v1alpha1.ClusterStageRunning {
+		t.Errorf("expected the replicas set by the autoscaler to be the target; got the stage: %v", c.Status.Metadata.Stage)
+	}
+	if got := c.Status.GetCondition(v1alpha1.ConditionProgressing); got == nil || got.Reason != "RolledOut" {
+		t.Errorf("expected no scaling to be reported; got: %v", got)
+	}
+}
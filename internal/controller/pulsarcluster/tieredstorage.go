@@ -0,0 +1,214 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/configmap"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	offloadVolumeName        = "offload"
+	offloadMountPath         = "/pulsar/offload"
+	offloadProfileVolumeName = "offload-profile"
+	offloadProfileMountPath  = "/pulsar/conf/offload"
+	offloadProfileKey        = "filesystem_offload_core_site.xml"
+	gcsKeyVolumeName         = "gcs-key"
+	gcsKeyMountPath          = "/pulsar/keys/gcs"
+)
+
+// offloadProfile is the hadoop profile of the filesystem offloader storing the ledgers in the offload volume
+var offloadProfile = fmt.Sprintf(`<?xml version="1.0"?>
+<configuration>
+  <property><name>fs.defaultFS</name><value>file:///</value></property>
+  <property><name>hadoop.tmp.dir</name><value>%s</value></property>
+  <property><name>io.file.buffer.size</name><value>4096</value></property>
+  <property><name>io.seqfile.compress.blocksize</name><value>1000000</value></property>
+  <property><name>io.seqfile.compression.type</name><value>BLOCK</value></property>
+  <property><name>io.map.index.interval</name><value>128</value></property>
+</configuration>
+`, offloadMountPath)
+
+// ReconcileTieredStorage reconcile the profile of the filesystem offloader; the other drivers are configured
+// through the broker configmap and the credentials secret
+func ReconcileTieredStorage(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	cm := &v1.ConfigMap{}
+	storage := cluster.Spec.TieredStorage
+	enabled := storage.IsEnabled() && storage.Driver == v1alpha1.OffloadDriverFilesystem
+	return ctx.GetResource(types.NamespacedName{
+		Name:      cluster.OffloadProfileConfigMapName(),
+		Namespace: cluster.Namespace,
+	}, cm,
+		// Found
+		func() error {
+			if !enabled {
+				ctx.Logger().Info("Deleting the filesystem offloader profile.",
+					"ConfigMap.Name", cm.GetName(),
+					"ConfigMap.Namespace", cm.GetNamespace())
+				return client.IgnoreNotFound(ctx.Client().Delete(context.TODO(), cm))
+			}
+			if cm.Data[offloadProfileKey] == offloadProfile {
+				return nil
+			}
+			cm.Data = map[string]string{offloadProfileKey: offloadProfile}
+			ctx.Logger().Info("Updating the filesystem offloader profile.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return ctx.Client().Update(context.TODO(), cm)
+		},
+		// Not Found
+		func() error {
+			if !enabled {
+				return nil
+			}
+			cm = configmap.New(cluster.Namespace, cluster.OffloadProfileConfigMapName(),
+				map[string]string{offloadProfileKey: offloadProfile})
+			cm.Labels = cluster.GenerateLabels(false)
+			if err := ctx.SetOwnershipReference(cluster, cm); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the filesystem offloader profile.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return ctx.Client().Create(context.TODO(), cm)
+		})
+}
+
+// createTieredStorageConfigs creates the broker offload configs. The credentials are
+// never part of them; they're injected from the credentials secret
+func createTieredStorageConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	storage := c.Spec.TieredStorage
+	if !storage.IsEnabled() {
+		return nil
+	}
+	configs := map[string]string{
+		"managedLedgerOffloadDriver": string(storage.Driver),
+	}
+	setIfNotEmpty := func(key, value string) {
+		if value != "" {
+			configs[key] = value
+		}
+	}
+	switch storage.Driver {
+	case v1alpha1.OffloadDriverS3:
+		setIfNotEmpty("s3ManagedLedgerOffloadBucket", storage.Bucket)
+		setIfNotEmpty("s3ManagedLedgerOffloadRegion", storage.Region)
+		setIfNotEmpty("s3ManagedLedgerOffloadServiceEndpoint", storage.Endpoint)
+	case v1alpha1.OffloadDriverGCS:
+		setIfNotEmpty("gcsManagedLedgerOffloadBucket", storage.Bucket)
+		setIfNotEmpty("gcsManagedLedgerOffloadRegion", storage.Region)
+		configs["gcsManagedLedgerOffloadServiceAccountKeyFile"] =
+			fmt.Sprintf("%s/%s", gcsKeyMountPath, v1alpha1.OffloadGCSServiceAccountKey)
+	case v1alpha1.OffloadDriverAzureBlob:
+		setIfNotEmpty("managedLedgerOffloadBucket", storage.Bucket)
+		setIfNotEmpty("managedLedgerOffloadRegion", storage.Region)
+		setIfNotEmpty("managedLedgerOffloadServiceEndpoint", storage.Endpoint)
+	case v1alpha1.OffloadDriverFilesystem:
+		configs["fileSystemProfilePath"] = fmt.Sprintf("%s/%s", offloadProfileMountPath, offloadProfileKey)
+	}
+	if storage.SizeThreshold != nil {
+		configs["managedLedgerOffloadAutoTriggerSizeThresholdBytes"] = fmt.Sprint(storage.SizeThreshold.Value())
+	}
+	if storage.TimeThreshold != nil {
+		configs["managedLedgerOffloadThresholdInSeconds"] = fmt.Sprint(int64(storage.TimeThreshold.Seconds()))
+	}
+	if storage.DeletionLag != nil {
+		configs["managedLedgerOffloadDeletionLagMs"] = fmt.Sprint(storage.DeletionLag.Milliseconds())
+	}
+	return configs
+}
+
+// createTieredStorageVolumes creates the volumes and the credential envs of the offload driver
+func createTieredStorageVolumes(c *v1alpha1.PulsarCluster) ([]v1.Volume, []v1.VolumeMount, []v1.EnvVar) {
+	storage := c.Spec.TieredStorage
+	if !storage.IsEnabled() {
+		return nil, nil, nil
+	}
+	secretEnv := func(key string) v1.EnvVar {
+		return v1.EnvVar{
+			Name: key,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: storage.CredentialsSecretName},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	switch storage.Driver {
+	case v1alpha1.OffloadDriverS3:
+		if storage.CredentialsSecretName == "" {
+			return nil, nil, nil
+		}
+		return nil, nil, []v1.EnvVar{
+			secretEnv(v1alpha1.OffloadS3AccessKeyIDKey),
+			secretEnv(v1alpha1.OffloadS3SecretAccessKeyKey),
+		}
+	case v1alpha1.OffloadDriverAzureBlob:
+		return nil, nil, []v1.EnvVar{
+			secretEnv(v1alpha1.OffloadAzureStorageAccountKey),
+			secretEnv(v1alpha1.OffloadAzureStorageAccessKeyKey),
+		}
+	case v1alpha1.OffloadDriverGCS:
+		volumes := []v1.Volume{
+			{
+				Name: gcsKeyVolumeName,
+				VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{
+						SecretName: storage.CredentialsSecretName,
+						Items: []v1.KeyToPath{{
+							Key:  v1alpha1.OffloadGCSServiceAccountKey,
+							Path: v1alpha1.OffloadGCSServiceAccountKey,
+						}},
+					},
+				},
+			},
+		}
+		mounts := []v1.VolumeMount{{Name: gcsKeyVolumeName, MountPath: gcsKeyMountPath, ReadOnly: true}}
+		return volumes, mounts, nil
+	case v1alpha1.OffloadDriverFilesystem:
+		volumes := []v1.Volume{
+			{
+				Name: offloadVolumeName,
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+						ClaimName: storage.Filesystem.ClaimName,
+					},
+				},
+			},
+			{
+				Name: offloadProfileVolumeName,
+				VolumeSource: v1.VolumeSource{
+					ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: c.OffloadProfileConfigMapName()},
+					},
+				},
+			},
+		}
+		mounts := []v1.VolumeMount{
+			{Name: offloadVolumeName, MountPath: offloadMountPath},
+			{Name: offloadProfileVolumeName, MountPath: offloadProfileMountPath, ReadOnly: true},
+		}
+		return volumes, mounts, nil
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTieredStorageCluster(storage *v1alpha1.TieredStorageConfig) *v1alpha1.PulsarCluster {
+	return newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.PulsarVersion = "2.11.0"
+		c.Spec.TieredStorage = storage
+	})
+}
+
+func TestCreateTieredStorageConfigs(t *testing.T) {
+	t.Parallel()
+	threshold := resource.MustParse("1Gi")
+	c := newTieredStorageCluster(&v1alpha1.TieredStorageConfig{
+		Enabled:               true,
+		Driver:                v1alpha1.OffloadDriverS3,
+		Bucket:                "offload",
+		Endpoint:              "http://minio:9000",
+		CredentialsSecretName: "minio-credentials",
+		SizeThreshold:         &threshold,
+		TimeThreshold:         &metav1.Duration{Duration: time.Hour},
+		DeletionLag:           &metav1.Duration{Duration: time.Minute},
+	})
+	data := createConfigmapData(c)
+	want := map[string]string{
+		"managedLedgerOffloadDriver":                        "aws-s3",
+		"s3ManagedLedgerOffloadBucket":                      "offload",
+		"s3ManagedLedgerOffloadServiceEndpoint":             "http://minio:9000",
+		"managedLedgerOffloadAutoTriggerSizeThresholdBytes": "1073741824",
+		"managedLedgerOffloadThresholdInSeconds":            "3600",
+		"managedLedgerOffloadDeletionLagMs":                 "60000",
+	}
+	for k, v := range want {
+		if data[pulsarConfigEnvPrefix+k] != v {
+			t.Errorf("expected the config %q to be %q; got: %q", k, v, data[pulsarConfigEnvPrefix+k])
+		}
+	}
+	if _, ok := data[pulsarConfigEnvPrefix+"s3ManagedLedgerOffloadRegion"]; ok {
+		t.Errorf("expected no region config when the region is unset")
+	}
+	for k := range data {
+		if strings.Contains(k, "AWS_") {
+			t.Errorf("expected no credentials in the configmap; got: %s", k)
+		}
+	}
+	_, _, envs := createTieredStorageVolumes(c)
+	if len(envs) != 2 {
+		t.Fatalf("expected the S3 credential envs; got: %v", envs)
+	}
+	for _, env := range envs {
+		ref := env.ValueFrom.SecretKeyRef
+		if ref == nil || ref.Name != "minio-credentials" || ref.Key != env.Name {
+			t.Errorf("expected the env %s from the credentials secret; got: %v", env.Name, env.ValueFrom)
+		}
+	}
+	if names := ReferencedSecretNames(c); len(names) != 1 || names[0] != "minio-credentials" {
+		t.Errorf("expected the credentials secret to be referenced; got: %v", names)
+	}
+	if image := c.Image().Repository; image != "apachepulsar/pulsar-all" {
+		t.Errorf("expected the image bundling the offloaders; got: %s", image)
+	}
+}
+
+func TestCreateFilesystemOffloadVolumes(t *testing.T) {
+	t.Parallel()
+	c := newTieredStorageCluster(&v1alpha1.TieredStorageConfig{
+		Enabled:    true,
+		Driver:     v1alpha1.OffloadDriverFilesystem,
+		Filesystem: &v1alpha1.FilesystemOffloadConfig{ClaimName: "offload"},
+	})
+	configs := createTieredStorageConfigs(c)
+	if want := offloadProfileMountPath + "/" + offloadProfileKey; configs["fileSystemProfilePath"] != want {
+		t.Errorf("expected the profile path %q; got: %q", want, configs["fileSystemProfilePath"])
+	}
+	volumes, mounts, envs := createTieredStorageVolumes(c)
+	if len(volumes) != 2 || len(mounts) != 2 || len(envs) != 0 {
+		t.Fatalf("expected the offload and profile volumes; got: %v", volumes)
+	}
+	if claim := volumes[0].PersistentVolumeClaim; claim == nil || claim.ClaimName != "offload" {
+		t.Errorf("expected the offload volume of the claim; got: %v", volumes[0])
+	}
+	if cm := volumes[1].ConfigMap; cm == nil || cm.Name != c.OffloadProfileConfigMapName() {
+		t.Errorf("expected the profile volume of the configmap; got: %v", volumes[1])
+	}
+	if !strings.Contains(offloadProfile, "<value>"+offloadMountPath+"</value>") {
+		t.Errorf("expected the profile to store the ledgers in the offload volume")
+	}
+	c.Spec.TieredStorage.Enabled = false
+	if configs := createTieredStorageConfigs(c); len(configs) != 0 {
+		t.Errorf("expected no offload configs when the tiered storage is disabled; got: %v", configs)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v12 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+)
+
+const (
+	tlsVolumeName     = "broker-tls"
+	tlsMountPath      = "/pulsar/certs"
+	tlsCertFilePath   = tlsMountPath + "/tls.crt"
+	tlsKeyFilePath    = tlsMountPath + "/tls.key"
+	tlsCaCertFilePath = tlsMountPath + "/ca.crt"
+)
+
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// ReconcileCertificate reconcile the cert-manager certificate of the specified cluster
+func ReconcileCertificate(ctx reconciler.Context, cluster *v1alpha1.PulsarCluster) error {
+	if !cluster.Spec.TLS.IsCertManaged() {
+		return nil
+	}
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(certificateGVK)
+	err := ctx.GetResource(types.NamespacedName{
+		Name:      cluster.CertificateName(),
+		Namespace: cluster.Namespace,
+	}, cert,
+		// Found
+		func() error {
+			desired := createCertificate(cluster)
+			if cert.GetAnnotations()[configHashAnnotation] == desired.GetAnnotations()[configHashAnnotation] {
+				return nil
+			}
+			cert.Object["spec"] = desired.Object["spec"]
+			cert.SetLabels(desired.GetLabels())
+			cert.SetAnnotations(desired.GetAnnotations())
+			ctx.Logger().Info("Updating the broker certificate.",
+				"Certificate.Name", cert.GetName(),
+				"Certificate.Namespace", cert.GetNamespace())
+			return ctx.Client().Update(context.TODO(), cert)
+		},
+		// Not Found
+		func() (err error) {
+			cert = createCertificate(cluster)
+			if err = ctx.SetOwnershipReference(cluster, cert); err == nil {
+				ctx.Logger().Info("Creating the broker certificate.",
+					"Certificate.Name", cert.GetName(),
+					"Certificate.Namespace", cert.GetNamespace())
+				if err = ctx.Client().Create(context.TODO(), cert); err == nil {
+					ctx.Logger().Info("Certificate creation success.",
+						"Certificate.Name", cert.GetName(),
+						"Certificate.Namespace", cert.GetNamespace())
+				}
+			}
+			return
+		})
+	if meta.IsNoMatchError(err) {
+		return fmt.Errorf("cert-manager must be installed to create the broker certificate: %w", err)
+	}
+	return err
+}
+
+func createCertificate(c *v1alpha1.PulsarCluster) *unstructured.Unstructured {
+	certManager := c.Spec.TLS.CertManager
+	issuerRef := map[string]interface{}{
+		"name":  certManager.IssuerRef.Name,
+		"kind":  "Issuer",
+		"group": certificateGVK.Group,
+	}
+	if certManager.IssuerRef.Kind != "" {
+		issuerRef["kind"] = certManager.IssuerRef.Kind
+	}
+	if certManager.IssuerRef.Group != "" {
+		issuerRef["group"] = certManager.IssuerRef.Group
+	}
+	spec := map[string]interface{}{
+		"secretName": c.TLSSecretName(),
+		"commonName": c.ClientServiceFQDN(),
+		"dnsNames":   toInterfaceSlice(certificateDNSNames(c)),
+		"issuerRef":  issuerRef,
+		"usages":     []interface{}{"server auth", "client auth"},
+		// pulsar only supports PKCS8 private keys
+		"privateKey": map[string]interface{}{
+			"algorithm":      "RSA",
+			"encoding":       "PKCS8",
+			"size":           int64(2048),
+			"rotationPolicy": "Always",
+		},
+	}
+	if certManager.Duration != nil {
+		spec["duration"] = certManager.Duration.Duration.String()
+	}
+	if certManager.RenewBefore != nil {
+		spec["renewBefore"] = certManager.RenewBefore.Duration.String()
+	}
+	cert := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	cert.SetGroupVersionKind(certificateGVK)
+	cert.SetName(c.CertificateName())
+	cert.SetNamespace(c.Namespace)
+	cert.SetLabels(c.GenerateLabels(true))
+	cert.SetAnnotations(map[string]string{configHashAnnotation: ComputeHash(spec)})
+	return cert
+}
+
+// certificateDNSNames returns the DNS names of the client and headless services and of each broker pod
+func certificateDNSNames(c *v1alpha1.PulsarCluster) []string {
+	names := []string{
+		c.ClientServiceName(),
+		fmt.Sprintf("%s.%s", c.ClientServiceName(), c.Namespace),
+		fmt.Sprintf("%s.%s.svc", c.ClientServiceName(), c.Namespace),
+		c.ClientServiceFQDN(),
+		c.ClientHeadlessServiceFQDN(),
+	}
+	for i := int32(0); i < c.Spec.MaxBrokers(); i++ {
+		names = append(names, c.BrokerPodFQDN(i))
+	}
+	return names
+}
+
+// createTLSConfigs creates the broker TLS configs
+func createTLSConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	if !c.Spec.TLS.IsEnabled() {
+		return nil
+	}
+	configs := map[string]string{
+		"tlsEnabled":             "true",
+		"brokerServicePortTls":   strconv.Itoa(int(c.Spec.Ports.ClientTLS)),
+		"webServicePortTls":      strconv.Itoa(int(c.Spec.Ports.WebTLS)),
+		"tlsCertificateFilePath": tlsCertFilePath,
+		"tlsKeyFilePath":         tlsKeyFilePath,
+		"tlsTrustCertsFilePath":  tlsCaCertFilePath,
+	}
+	if c.Spec.TLS.Internal {
+		configs["brokerClientTlsEnabled"] = "true"
+		configs["brokerClientTrustCertsFilePath"] = tlsCaCertFilePath
+	}
+	return configs
+}
+
+func createTLSVolumes(c *v1alpha1.PulsarCluster) ([]v12.Volume, []v12.VolumeMount) {
+	if !c.Spec.TLS.IsEnabled() {
+		return nil, nil
+	}
+	volumes := []v12.Volume{
+		{
+			Name: tlsVolumeName,
+			VolumeSource: v12.VolumeSource{
+				Secret: &v12.SecretVolumeSource{SecretName: c.TLSSecretName()},
+			},
+		},
+	}
+	mounts := []v12.VolumeMount{
+		{Name: tlsVolumeName, MountPath: tlsMountPath, ReadOnly: true},
+	}
+	return volumes, mounts
+}
+
+// ProxyTLSConfigs creates the configs the proxies of the cluster connect to the brokers over TLS with
+func ProxyTLSConfigs(c *v1alpha1.PulsarCluster) map[string]string {
+	if !c.Spec.TLS.IsEnabled() {
+		return nil
+	}
+	return map[string]string{
+		"tlsEnabledWithBroker":           "true",
+		"brokerClientTrustCertsFilePath": tlsCaCertFilePath,
+	}
+}
+
+// ProxyTLSVolumes returns the volume of the CA certificate the proxies verify the brokers with
+func ProxyTLSVolumes(c *v1alpha1.PulsarCluster) ([]v12.Volume, []v12.VolumeMount) {
+	if !c.Spec.TLS.IsEnabled() {
+		return nil, nil
+	}
+	volumes := []v12.Volume{
+		{
+			Name: tlsVolumeName,
+			VolumeSource: v12.VolumeSource{
+				Secret: &v12.SecretVolumeSource{
+					SecretName: c.TLSSecretName(),
+					Items:      []v12.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+				},
+			},
+		},
+	}
+	mounts := []v12.VolumeMount{
+		{Name: tlsVolumeName, MountPath: tlsMountPath, ReadOnly: true},
+	}
+	return volumes, mounts
+}
+
+func toInterfaceSlice(items []string) []interface{} {
+	out := make([]interface{}, len(items))
+	for i := range items {
+		out[i] = items[i]
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestCreateTLSConfigs(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(nil)
+	if configs := createTLSConfigs(c); len(configs) != 0 {
+		t.Errorf("expected no TLS configs when TLS is disabled; got: %v", configs)
+	}
+	if volumes, mounts := createTLSVolumes(c); len(volumes) != 0 || len(mounts) != 0 {
+		t.Errorf("expected no TLS volumes when TLS is disabled")
+	}
+	c.Spec.TLS = &v1alpha1.TLSConfig{Enabled: true, Internal: true}
+	configs := createTLSConfigs(c)
+	want := map[string]string{
+		"tlsEnabled":                     "true",
+		"brokerServicePortTls":           "6651",
+		"webServicePortTls":              "8443",
+		"tlsCertificateFilePath":         tlsCertFilePath,
+		"tlsKeyFilePath":                 tlsKeyFilePath,
+		"brokerClientTlsEnabled":         "true",
+		"brokerClientTrustCertsFilePath": tlsCaCertFilePath,
+	}
+	for k, v := range want {
+		if configs[k] != v {
+			t.Errorf("expected the config %q to be %q; got: %q", k, v, configs[k])
+		}
+	}
+	volumes, _ := createTLSVolumes(c)
+	if len(volumes) != 1 || volumes[0].Secret.SecretName != c.TLSSecretName() {
+		t.Errorf("expected the TLS volume of the secret %q; got: %v", c.TLSSecretName(), volumes)
+	}
+}
+
+func TestCertificateDNSNames(t *testing.T) {
+	t.Parallel()
+	size := int32(2)
+	c := &v1alpha1.PulsarCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       v1alpha1.PulsarClusterSpec{Size: &size},
+	}
+	names := certificateDNSNames(c)
+	for _, want := range []string{c.ClientServiceFQDN(), c.BrokerPodFQDN(0), c.BrokerPodFQDN(1)} {
+		found := false
+		for _, name := range names {
+			found = found || name == want
+		}
+		if !found {
+			t.Errorf("expected the DNS names to contain %q; got: %v", want, names)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+)
+
+// isUpgrading checks whether the brokers need to be, or are being, upgraded to the spec version
+func isUpgrading(c *v1alpha1.PulsarCluster, sts *v1.StatefulSet) bool {
+	return c.Status.Upgrade != nil || c.Spec.VersionLabel() != sts.Labels[k8s.LabelAppVersion]
+}
+
+// upgradeStep is the next step of the upgrade of the current broker
+type upgradeStep int
+
+const (
+	// upgradeStepApplyTemplate applies the new pod template while holding back the brokers not upgraded yet
+	upgradeStepApplyTemplate upgradeStep = iota
+	// upgradeStepRollBroker drains the current broker and moves the partition down to its ordinal
+	upgradeStepRollBroker
+	// upgradeStepCheckBroker waits for the current broker to be updated and healthy
+	upgradeStepCheckBroker
+)
+
+// reconcileUpgrade upgrades the brokers one at a time, from the highest ordinal to the lowest,
+// using the statefulset rolling update partition. Before a broker is restarted its bundles are
+// unloaded to the other brokers, and the next broker is not touched until the upgraded one is
+// ready and passes the admin health check.
+func reconcileUpgrade(ctx reconciler.Context, sts, desired *v1.StatefulSet, c *v1alpha1.PulsarCluster) error {
+	upgrade := c.Status.Upgrade
+	if upgrade == nil || upgrade.ToVersion != c.Spec.VersionLabel() {
+		upgrade = newUpgradeStatus(c, sts)
+		ctx.Logger().Info("Starting the pulsar brokers upgrade",
+			"cluster", c.GetName(),
+			"FromVersion", upgrade.FromVersion,
+			"ToVersion", upgrade.ToVersion)
+		if err := updateUpgradeStatus(ctx, c, upgrade); err != nil {
+			return err
+		}
+	}
+	ordinal := upgrade.CurrentOrdinal
+	switch nextUpgradeStep(sts, desired, ordinal) {
+	case upgradeStepApplyTemplate:
+		// Scaling is also held back till the upgrade completes
+		desired.Spec.Replicas = sts.Spec.Replicas
+		if restartUpgrade(upgrade, sts) {
+			ctx.Logger().Info("The broker pod template changed during the upgrade; restarting it",
+				"cluster", c.GetName(),
+				"CurrentOrdinal", upgrade.CurrentOrdinal)
+			if err := updateUpgradeStatus(ctx, c, upgrade); err != nil {
+				return err
+			}
+		}
+		if err := updateStatefulset(ctx, sts, desired, c); err != nil {
+			return err
+		}
+		return Requeue(defaultRequeueDelay, "the broker pod template is updated")
+	case upgradeStepRollBroker:
+		return rollBroker(ctx, sts, c, ordinal)
+	}
+	p, err := getBrokerPod(ctx, c, ordinal)
+	if err != nil {
+		return err
+	}
+	if !isBrokerPodUpdated(sts, p) {
+		return Requeue(defaultRequeueDelay, "waiting for the upgraded broker to be ready")
+	}
+	client, err := brokerAdminClient(ctx, c, ordinal)
+	if err != nil {
+		return err
+	}
+	if err = client.HealthCheck(); err != nil {
+		ctx.Logger().Info("The upgraded broker is not healthy yet",
+			"cluster", c.GetName(),
+			"Pod", c.BrokerPodName(ordinal),
+			"reason", err.Error())
+		return Requeue(defaultRequeueDelay, "waiting for the upgraded broker to be healthy")
+	}
+	ctx.Logger().Info("The pulsar broker is upgraded successfully",
+		"cluster", c.GetName(),
+		"Pod", c.BrokerPodName(ordinal),
+		"ToVersion", upgrade.ToVersion)
+	if !advanceUpgrade(upgrade, sts) {
+		if err = updateUpgradeStatus(ctx, c, upgrade); err != nil {
+			return err
+		}
+		return Requeue(shortRequeueDelay, "upgrading the next broker")
+	}
+	if err = ctx.Client().Update(context.TODO(), sts); err != nil {
+		return err
+	}
+	ctx.Logger().Info("The pulsar brokers upgrade is complete",
+		"cluster", c.GetName(),
+		"FromVersion", upgrade.FromVersion,
+		"ToVersion", upgrade.ToVersion)
+	return updateUpgradeStatus(ctx, c, nil)
+}
+
+// newUpgradeStatus starts the upgrade from the broker with the highest ordinal
+func newUpgradeStatus(c *v1alpha1.PulsarCluster, sts *v1.StatefulSet) *v1alpha1.UpgradeStatus {
+	return &v1alpha1.UpgradeStatus{
+		FromVersion:    sts.Labels[k8s.LabelAppVersion],
+		ToVersion:      c.Spec.VersionLabel(),
+		CurrentOrdinal: *sts.Spec.Replicas - 1,
+	}
+}
+
+// nextUpgradeStep returns the step the upgrade of the broker with the specified ordinal is at
+func nextUpgradeStep(sts, desired *v1.StatefulSet, ordinal int32) upgradeStep {
+	if sts.Spec.Template.Annotations[configHashAnnotation] != desired.Spec.Template.Annotations[configHashAnnotation] {
+		return upgradeStepApplyTemplate
+	}
+	if statefulSetPartition(sts) > ordinal {
+		return upgradeStepRollBroker
+	}
+	return upgradeStepCheckBroker
+}
+
+// restartUpgrade holds every broker back on its current revision and restarts the upgrade
+// from the highest ordinal. It's done whenever a new pod template is applied; the brokers
+// already upgraded run an outdated template and must be rolled again one at a time.
+// It returns true if the upgrade had already moved past the highest ordinal
+func restartUpgrade(upgrade *v1alpha1.UpgradeStatus, sts *v1.StatefulSet) (restarted bool) {
+	replicas := *sts.Spec.Replicas
+	setStatefulSetPartition(sts, replicas)
+	restarted = upgrade.CurrentOrdinal != replicas-1
+	upgrade.CurrentOrdinal = replicas - 1
+	return
+}
+
+// advanceUpgrade moves the upgrade to the next broker. Once the last one is
+// upgraded the partition is removed and true is returned
+func advanceUpgrade(upgrade *v1alpha1.UpgradeStatus, sts *v1.StatefulSet) (completed bool) {
+	if upgrade.CurrentOrdinal > 0 {
+		upgrade.CurrentOrdinal--
+		return false
+	}
+	sts.Spec.UpdateStrategy.RollingUpdate = nil
+	return true
+}
+
+// rollBroker drains the broker and moves the partition down to its ordinal so the statefulset controller restarts it
+func rollBroker(ctx reconciler.Context, sts *v1.StatefulSet, c *v1alpha1.PulsarCluster, ordinal int32) error {
+	ready, err := isBrokerPodReady(ctx, c, ordinal)
+	if err != nil {
+		return err
+	}
+	if ready {
+		// A broker which is not ready owns no bundles; they're reassigned by the load manager
+		owned, err := drainBroker(ctx, c, ordinal)
+		if err != nil {
+			return err
+		}
+		if owned > 0 {
+			return Requeue(defaultRequeueDelay, "waiting for the broker bundles to be reassigned")
+		}
+	}
+	setStatefulSetPartition(sts, ordinal)
+	ctx.Logger().Info("Upgrading the pulsar broker",
+		"cluster", c.GetName(),
+		"Pod", c.BrokerPodName(ordinal),
+		"ToVersion", c.Spec.PulsarVersion)
+	if err = ctx.Client().Update(context.TODO(), sts); err != nil {
+		return err
+	}
+	return Requeue(defaultRequeueDelay, "waiting for the broker to restart")
+}
+
+// isBrokerPodUpdated checks whether the broker pod runs the latest statefulset revision and is ready
+func isBrokerPodUpdated(sts *v1.StatefulSet, p *v12.Pod) bool {
+	if p == nil || sts.Status.ObservedGeneration != sts.Generation {
+		// the update revision is not computed yet
+		return false
+	}
+	return p.Labels[v1.ControllerRevisionHashLabelKey] == sts.Status.UpdateRevision && pod.IsReady(p)
+}
+
+func updateUpgradeStatus(ctx reconciler.Context, c *v1alpha1.PulsarCluster, upgrade *v1alpha1.UpgradeStatus) error {
+	c.Status.Upgrade = upgrade
+	return ctx.Client().Status().Update(context.TODO(), c)
+}
+
+func statefulSetPartition(sts *v1.StatefulSet) int32 {
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+		return *ru.Partition
+	}
+	return 0
+}
+
+func setStatefulSetPartition(sts *v1.StatefulSet, partition int32) {
+	sts.Spec.UpdateStrategy.Type = v1.RollingUpdateStatefulSetStrategyType
+	sts.Spec.UpdateStrategy.RollingUpdate = &v1.RollingUpdateStatefulSetStrategy{
+		Partition: &partition,
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarcluster
+
+import (
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func newUpgradingStatefulSet(replicas int32, hash string) *v1.StatefulSet {
+	sts := &v1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Generation: 2,
+			Labels:     map[string]string{k8s.LabelAppVersion: "2.10.1"},
+		},
+		Spec: v1.StatefulSetSpec{Replicas: &replicas},
+		Status: v1.StatefulSetStatus{
+			ObservedGeneration: 2,
+			UpdateRevision:     "pulsar-new",
+		},
+	}
+	sts.Spec.Template.Annotations = map[string]string{configHashAnnotation: hash}
+	return sts
+}
+
+func newBrokerPod(revision string, ready bool) *v12.Pod {
+	status := v12.ConditionFalse
+	if ready {
+		status = v12.ConditionTrue
+	}
+	return &v12.Pod{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.ControllerRevisionHashLabelKey: revision}},
+		Status:     v12.PodStatus{Conditions: []v12.PodCondition{{Type: v12.PodReady, Status: status}}},
+	}
+}
+
+func TestUpgradeStepsFromTheTopOrdinalDown(t *testing.T) {
+	t.Parallel()
+	c := &v1alpha1.PulsarCluster{Spec: v1alpha1.PulsarClusterSpec{PulsarVersion: "3.0.1"}}
+	sts := newUpgradingStatefulSet(3, "old")
+	desired := newUpgradingStatefulSet(3, "new")
+	upgrade := newUpgradeStatus(c, sts)
+	if upgrade.CurrentOrdinal != 2 || upgrade.FromVersion != "2.10.1" || upgrade.ToVersion != "3.0.1" {
+		t.Fatalf("expected the upgrade from 2.10.1 to 3.0.1 to start at the ordinal 2; got: %+v", upgrade)
+	}
+	if step := nextUpgradeStep(sts, desired, upgrade.CurrentOrdinal); step != upgradeStepApplyTemplate {
+		t.Fatalf("expected the new template to be applied first; got the step: %d", step)
+	}
+	// the template is applied while every broker is held back
+	if restartUpgrade(upgrade, sts) {
+		t.Fatalf("expected the new upgrade not to be restarted; got the ordinal: %d", upgrade.CurrentOrdinal)
+	}
+	if got := statefulSetPartition(sts); got != 3 {
+		t.Fatalf("expected every broker to be held back by the partition 3; got: %d", got)
+	}
+	sts.Spec.Template = desired.Spec.Template
+	for want := int32(2); ; want-- {
+		ordinal := upgrade.CurrentOrdinal
+		if ordinal != want {
+			t.Fatalf("expected the broker %d to be upgraded; got: %d", want, ordinal)
+		}
+		if step := nextUpgradeStep(sts, desired, ordinal); step != upgradeStepRollBroker {
+			t.Fatalf("broker %d: expected the broker to be rolled; got the step: %d", ordinal, step)
+		}
+		setStatefulSetPartition(sts, ordinal)
+		if got := statefulSetPartition(sts); got != ordinal {
+			t.Fatalf("broker %d: expected the partition %d; got: %d", ordinal, ordinal, got)
+		}
+		if step := nextUpgradeStep(sts, desired, ordinal); step != upgradeStepCheckBroker {
+			t.Fatalf("broker %d: expected the broker to be checked; got the step: %d", ordinal, step)
+		}
+		if advanceUpgrade(upgrade, sts) {
+			break
+		}
+	}
+	if upgrade.CurrentOrdinal != 0 {
+		t.Errorf("expected the upgrade to complete at the ordinal 0; got: %d", upgrade.CurrentOrdinal)
+	}
+}
+
+func TestUpgradeRestartsOnTemplateChange(t *testing.T) {
+	t.Parallel()
+	sts := newUpgradingStatefulSet(4, "new")
+	upgrade := &v1alpha1.UpgradeStatus{CurrentOrdinal: 1}
+	setStatefulSetPartition(sts, 1)
+	desired := newUpgradingStatefulSet(4, "newer")
+	if step := nextUpgradeStep(sts, desired, upgrade.CurrentOrdinal); step != upgradeStepApplyTemplate {
+		t.Fatalf("expected the changed template to be applied; got the step: %d", step)
+	}
+	if !restartUpgrade(upgrade, sts) {
+		t.Fatal("expected the upgrade to be restarted")
+	}
+	if upgrade.CurrentOrdinal != 3 {
+		t.Errorf("expected the upgrade to restart at the ordinal 3; got: %d", upgrade.CurrentOrdinal)
+	}
+	if got := statefulSetPartition(sts); got != 4 {
+		t.Errorf("expected the upgraded brokers to be held back by the partition 4; got: %d", got)
+	}
+	sts.Spec.Template = desired.Spec.Template
+	if step := nextUpgradeStep(sts, desired, upgrade.CurrentOrdinal); step != upgradeStepRollBroker {
+		t.Errorf("expected the broker 3 to be drained and rolled again; got the step: %d", step)
+	}
+}
+
+func TestUpgradeCompletesAtPartitionZero(t *testing.T) {
+	t.Parallel()
+	sts := newUpgradingStatefulSet(3, "new")
+	upgrade := &v1alpha1.UpgradeStatus{CurrentOrdinal: 1}
+	setStatefulSetPartition(sts, 1)
+	if advanceUpgrade(upgrade, sts) {
+		t.Fatal("expected the upgrade to continue with the broker 0")
+	}
+	if sts.Spec.UpdateStrategy.RollingUpdate == nil {
+		t.Fatal("expected the partition to be kept till the last broker is upgraded")
+	}
+	setStatefulSetPartition(sts, 0)
+	if !advanceUpgrade(upgrade, sts) {
+		t.Fatal("expected the upgrade to complete once the partition reaches 0")
+	}
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil || statefulSetPartition(sts) != 0 {
+		t.Errorf("expected the partition to be removed; got: %v", sts.Spec.UpdateStrategy.RollingUpdate)
+	}
+}
+
+func TestIsBrokerPodUpdated(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		pod                *v12.Pod
+		observedGeneration int64
+		want               bool
+	}{
+		{name: "updated and ready", pod: newBrokerPod("pulsar-new", true), observedGeneration: 2, want: true},
+		{name: "not at the update revision", pod: newBrokerPod("pulsar-old", true), observedGeneration: 2},
+		{name: "not ready", pod: newBrokerPod("pulsar-new", false), observedGeneration: 2},
+		{name: "update revision not computed", pod: newBrokerPod("pulsar-new", true), observedGeneration: 1},
+		{name: "pod not recreated yet", observedGeneration: 2},
+	}
+	for _, tt := range tests {
+		sts := newUpgradingStatefulSet(3, "new")
+		sts.Status.ObservedGeneration = tt.observedGeneration
+		if got := isBrokerPodUpdated(sts, tt.pod); got != tt.want {
+			t.Errorf("%s: expected: %v; got: %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -17,6 +17,9 @@
 package pulsarcluster
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/monimesl/operator-helper/k8s"
 	"github.com/monimesl/operator-helper/oputil"
@@ -33,6 +36,7 @@ const (
 var notAllowedVariables = addPulsarEnvPrefix([]string{
 	"statusFilePath", "clusterName", "zookeeperServers",
 	"configurationStoreServers", "bookkeeperMetadataServiceUri",
+	"metadataStoreUrl", "configurationMetadataStoreUrl",
 	"PULSAR_GC", "PULSAR_MEM", "PULSAR_EXTRA_OPTS", "PULSAR_GC_LOG",
 })
 
@@ -81,7 +85,7 @@ func addPulsarEnvPrefix(envs []string) []string {
 		}
 		newEnvs[i] = env
 	}
-	return envs
+	return newEnvs
 }
 
 func getBrokerSelectorLabels(c *v1alpha1.PulsarCluster, broker bool) map[string]string {
@@ -99,8 +103,47 @@ func getBrokerSelectorLabels(c *v1alpha1.PulsarCluster, broker bool) map[string]
 			continue
 		case k8s.LabelAppVersion:
 			continue
+		case v1alpha1.ClusterNameLabel:
+			// not selected on so that the existing pods keep matching until they're rolled
+			continue
+		case v1alpha1.InRotationLabel:
+			// only the client service selects on it; see getClientSelectorLabels
+			continue
 		}
 		out[k] = v
 	}
 	return out
 }
+
+// getClientSelectorLabels returns the selector of the client service. It's the broker selector
+// narrowed down to the brokers in rotation so that the broker in maintenance gets no client traffic
+func getClientSelectorLabels(c *v1alpha1.PulsarCluster) map[string]string {
+	labels := getBrokerSelectorLabels(c, true)
+	labels[v1alpha1.InRotationLabel] = "true"
+	return labels
+}
+
+// ComputeHash computes the sha256 hash of the JSON representation of the objects.
+// encoding/json sorts map keys so the hash is stable across reconciliations
+func ComputeHash(objects ...interface{}) string {
+	hash := sha256.New()
+	encoder := json.NewEncoder(hash)
+	for _, obj := range objects {
+		if err := encoder.Encode(obj); err != nil {
+			log.Printf("error on hashing the object: %T, reason: %s", obj, err)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// mergeMaps returns a new map with the entries of the maps; the later maps take precedence.
+// The cluster labels and annotations may be the maps of the cluster spec so they're not mutated
+func mergeMaps(maps ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
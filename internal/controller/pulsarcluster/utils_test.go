@@ -0,0 +1,70 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarcluster
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+// newTestCluster returns a defaulted cluster backed by static zookeeper and
+// bookkeeper addresses; the edit, when set, customizes it before defaulting.
+func newTestCluster(edit func(c *v1alpha1.PulsarCluster)) *v1alpha1.PulsarCluster {
+	c := &v1alpha1.PulsarCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1alpha1.PulsarClusterSpec{
+			ZookeeperServers:     "zk:2181",
+			BookkeeperClusterUri: "zk+null://zk:2181/ledgers",
+			PulsarVersion:        "2.10.1",
+		},
+	}
+	if edit != nil {
+		edit(c)
+	}
+	c.SetSpecDefaults()
+	return c
+}
+
+func TestBrokerConfigNotAllowedVariables(t *testing.T) {
+	t.Parallel()
+	c := newTestCluster(func(c *v1alpha1.PulsarCluster) {
+		c.Spec.BrokerConfig = map[string]string{
+			"clusterName":                    "other",
+			"PULSAR_PREFIX_zookeeperServers": "other:2181",
+			"metadataStoreUrl":               "zk:other:2181",
+			"PULSAR_MEM":                     "-Xmx8g",
+			"numIOThreads":                   "8",
+		}
+	})
+	data := createConfigmapData(c)
+	want := map[string]string{
+		"clusterName":      c.PulsarClusterName(),
+		"metadataStoreUrl": "zk:zk:2181",
+		"numIOThreads":     "8",
+	}
+	for k, v := range want {
+		if got := data[pulsarConfigEnvPrefix+k]; got != v {
+			t.Errorf("expected %s=%q; got: %q", k, v, got)
+		}
+	}
+	for _, k := range []string{"PULSAR_PREFIX_zookeeperServers", "PULSAR_MEM"} {
+		if got := data[addPulsarEnvPrefix([]string{k})[0]]; got == c.Spec.BrokerConfig[k] {
+			t.Errorf("expected the broker config %s to be ignored; got: %q", k, got)
+		}
+	}
+}
@@ -18,13 +18,21 @@ package controller
 
 import (
 	"context"
+	"github.com/monimesl/operator-helper/oputil"
 	"github.com/monimesl/operator-helper/reconciler"
 	pulsarcluster2 "github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
 	v12 "k8s.io/api/apps/v1"
+	v15 "k8s.io/api/autoscaling/v2"
 	v13 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	v14 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
 
 	pulsarv1alpha1 "github.com/monimesl/pulsar-operator/api/v1alpha1"
 )
@@ -33,11 +41,22 @@ var (
 	_                     reconciler.Context    = &PulsarClusterReconciler{}
 	_                     reconciler.Reconciler = &PulsarClusterReconciler{}
 	clusterReconcileFuncs                       = []func(ctx reconciler.Context, cluster *pulsarv1alpha1.PulsarCluster) error{
+		pulsarcluster2.ReconcileDependencies,
 		pulsarcluster2.ReconcilePodDisruptionBudget,
+		pulsarcluster2.ReconcileMaintenance,
 		pulsarcluster2.ReconcileServices,
+		pulsarcluster2.ReconcileExternalAccess,
+		pulsarcluster2.ReconcileCertificate,
+		pulsarcluster2.ReconcileAuthentication,
+		pulsarcluster2.ReconcileTieredStorage,
 		pulsarcluster2.ReconcileConfigMap,
 		pulsarcluster2.ReconcileJob,
 		pulsarcluster2.ReconcileStatefulSet,
+		pulsarcluster2.ReconcileHorizontalPodAutoscaler,
+		pulsarcluster2.ReconcileMonitoring,
+		pulsarcluster2.ReconcileDashboards,
+		pulsarcluster2.ReconcileReplication,
+		pulsarcluster2.ReconcileStatus,
 	}
 )
 
@@ -49,25 +68,111 @@ type PulsarClusterReconciler struct {
 // Configure configures the above PulsarClusterReconciler
 func (r *PulsarClusterReconciler) Configure(ctx reconciler.Context) error {
 	r.Context = ctx
-	return ctx.NewControllerBuilder().
+	builder := ctx.NewControllerBuilder().
 		For(&pulsarv1alpha1.PulsarCluster{}).
 		Owns(&v14.PodDisruptionBudget{}).
 		Owns(&v12.StatefulSet{}).
 		Owns(&v1.ConfigMap{}).
 		Owns(&v1.Service{}).
 		Owns(&v13.Job{}).
-		Complete(r)
+		Owns(&v15.HorizontalPodAutoscaler{}).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.clustersReferencingSecret))
+	for _, gvk := range []schema.GroupVersionKind{pulsarcluster2.ZookeeperClusterGVK, pulsarcluster2.BookkeeperClusterGVK} {
+		// the zookeeper and bookkeeper operators are optional; without them the referenced
+		// clusters are never found and the clusters referencing them keep being requeued
+		if _, err := ctx.Client().RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			ctx.Logger().Info("The kind is not installed; the clusters referencing it are not watched.",
+				"Kind", gvk.Kind, "Group", gvk.Group)
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		builder = builder.Watches(obj, handler.EnqueueRequestsFromMapFunc(r.clustersReferencingDependency(gvk)))
+	}
+	for _, gvk := range []schema.GroupVersionKind{pulsarcluster2.PodMonitorGVK, pulsarcluster2.PrometheusRuleGVK} {
+		// the prometheus operator is optional; without it the brokers are not monitored
+		if _, err := ctx.Client().RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			ctx.Logger().Info("The kind is not installed; the brokers are not monitored.",
+				"Kind", gvk.Kind, "Group", gvk.Group)
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		builder = builder.Owns(obj)
+	}
+	return builder.Complete(r)
+}
+
+// clustersReferencingDependency maps the zookeeper or bookkeeper cluster to the clusters referencing it
+func (r *PulsarClusterReconciler) clustersReferencingDependency(gvk schema.GroupVersionKind) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		// the references may cross namespaces
+		clusters := &pulsarv1alpha1.PulsarClusterList{}
+		if err := r.Client().List(ctx, clusters); err != nil {
+			r.Logger().Error(err, "error on listing the clusters referencing the dependency",
+				"Kind", gvk.Kind,
+				"Name", obj.GetName(),
+				"Namespace", obj.GetNamespace())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0)
+		for i := range clusters.Items {
+			cluster := &clusters.Items[i]
+			if pulsarcluster2.ReferencesDependency(cluster, gvk, obj.GetNamespace(), obj.GetName()) {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Name:      cluster.Name,
+					Namespace: cluster.Namespace,
+				}})
+			}
+		}
+		return requests
+	}
+}
+
+// clustersReferencingSecret maps the secret to the clusters whose brokers reference it
+func (r *PulsarClusterReconciler) clustersReferencingSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	clusters := &pulsarv1alpha1.PulsarClusterList{}
+	if err := r.Client().List(ctx, clusters, client.InNamespace(secret.GetNamespace())); err != nil {
+		r.Logger().Error(err, "error on listing the clusters referencing the secret",
+			"Secret.Name", secret.GetName(),
+			"Secret.Namespace", secret.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for i := range clusters.Items {
+		cluster := &clusters.Items[i]
+		if oputil.Contains(pulsarcluster2.ReferencedSecretNames(cluster), secret.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      cluster.Name,
+				Namespace: cluster.Namespace,
+			}})
+		}
+	}
+	return requests
 }
 
 // Reconcile handles reconciliation request for PulsarCluster instances
 func (r *PulsarClusterReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
 	cluster := &pulsarv1alpha1.PulsarCluster{}
-	return r.Run(request, cluster, func(_ bool) (err error) {
+	requeueAfter := time.Duration(0)
+	result, err := r.Run(request, cluster, func(_ bool) (err error) {
 		for _, fun := range clusterReconcileFuncs {
 			if err = fun(r, cluster); err != nil {
-				break
+				after, ok := pulsarcluster2.RequeueAfter(err)
+				if !ok {
+					break
+				}
+				// the cluster is waiting on something; continue with the rest and retry later
+				if requeueAfter == 0 || after < requeueAfter {
+					requeueAfter = after
+				}
+				err = nil
 			}
 		}
 		return
 	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
 }
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/deployment"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// configHashAnnotation holds the hash of the pod template. Any change
+// to it causes the deployment controller to roll the manager pod
+const configHashAnnotation = internal.Domain + "/config-hash"
+
+// ReconcileDeployment reconcile the deployment of the specified manager
+func ReconcileDeployment(ctx reconciler.Context, manager *v1alpha1.PulsarManager) error {
+	dep := &v1.Deployment{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      manager.DeploymentName(),
+		Namespace: manager.Namespace,
+	}, dep,
+		// Found
+		func() error {
+			desired := createDeployment(manager)
+			if dep.Spec.Template.Annotations[configHashAnnotation] ==
+				desired.Spec.Template.Annotations[configHashAnnotation] {
+				return nil
+			}
+			dep.Labels = desired.Labels
+			dep.Annotations = desired.Annotations
+			dep.Spec.Template = desired.Spec.Template
+			ctx.Logger().Info("Updating the pulsar manager deployment.",
+				"Deployment.Name", dep.GetName(),
+				"Deployment.Namespace", dep.GetNamespace(),
+				"ConfigHash", desired.Spec.Template.Annotations[configHashAnnotation])
+			return ctx.Client().Update(context.TODO(), dep)
+		},
+		// Not Found
+		func() error {
+			dep = createDeployment(manager)
+			if err := ctx.SetOwnershipReference(manager, dep); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar manager deployment.",
+				"Deployment.Name", dep.GetName(),
+				"Deployment.Namespace", dep.GetNamespace())
+			return ctx.Client().Create(context.TODO(), dep)
+		})
+}
+
+func createDeployment(m *v1alpha1.PulsarManager) *v1.Deployment {
+	templateSpec := createPodTemplateSpec(m)
+	templateSpec.Annotations = pulsarcluster.StampConfigHash(templateSpec.Annotations,
+		pulsarcluster.ComputeHash(templateSpec))
+	replicas := int32(1)
+	dep := deployment.New(m.Namespace, m.DeploymentName(), m.GenerateLabels(), v1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &metav1.LabelSelector{MatchLabels: m.SelectorLabels()},
+		// the store is not shared between the pods
+		Strategy: v1.DeploymentStrategy{Type: v1.RecreateDeploymentStrategyType},
+		Template: templateSpec,
+	})
+	dep.Annotations = m.GenerateAnnotations()
+	return dep
+}
+
+func createPodTemplateSpec(m *v1alpha1.PulsarManager) v12.PodTemplateSpec {
+	return v12.PodTemplateSpec{
+		ObjectMeta: pod.NewMetadata(m.Spec.PodConfig, "", m.DeploymentName(),
+			m.GenerateLabels(), m.GenerateAnnotations()),
+		Spec: createPodSpec(m),
+	}
+}
+
+func createPodSpec(m *v1alpha1.PulsarManager) v12.PodSpec {
+	image := m.Image()
+	volumes, mounts := createStoreVolumes(m)
+	container := v12.Container{
+		Name:            "pulsar-manager",
+		Image:           image.ToString(),
+		ImagePullPolicy: image.PullPolicy,
+		Ports: []v12.ContainerPort{
+			{Name: v1alpha1.ManagerUIPortName, ContainerPort: uiContainerPort},
+			{Name: v1alpha1.ManagerBackendPortName, ContainerPort: backendContainerPort},
+		},
+		Resources: m.Spec.PodConfig.Spec.Resources,
+		ReadinessProbe: &v12.Probe{
+			ProbeHandler: v12.ProbeHandler{
+				HTTPGet: &v12.HTTPGetAction{
+					Port: intstr.FromInt32(backendContainerPort),
+					Path: "/pulsar-manager/csrf-token",
+				},
+			},
+			PeriodSeconds: 10,
+		},
+		LivenessProbe: &v12.Probe{
+			ProbeHandler: v12.ProbeHandler{
+				TCPSocket: &v12.TCPSocketAction{Port: intstr.FromInt32(uiContainerPort)},
+			},
+			InitialDelaySeconds: 60,
+			PeriodSeconds:       30,
+		},
+		Env:          pod.DecorateContainerEnvVars(true, append(createEnvVars(m), m.Spec.PodConfig.Spec.Env...)...),
+		VolumeMounts: mounts,
+	}
+	return pod.NewSpec(m.Spec.PodConfig, volumes, nil, []v12.Container{container})
+}
+
+// createEnvVars creates the env variables the image entrypoint configures the backend with.
+// The embedded postgres server is used unless the datasource is overridden
+func createEnvVars(m *v1alpha1.PulsarManager) []v12.EnvVar {
+	envs := []v12.EnvVar{{Name: "SPRING_CONFIGURATION_FILE", Value: configurationFile}}
+	if m.Spec.Store.Type != v1alpha1.ManagerStorePostgres {
+		return envs
+	}
+	secretName := m.Spec.Store.Postgres.SecretName
+	for _, env := range [][2]string{
+		{"URL", v1alpha1.ManagerPostgresURLKey},
+		{"USERNAME", v1alpha1.ManagerUsernameKey},
+		{"PASSWORD", v1alpha1.ManagerPasswordKey},
+	} {
+		envs = append(envs, v12.EnvVar{
+			Name: env[0],
+			ValueFrom: &v12.EnvVarSource{
+				SecretKeyRef: &v12.SecretKeySelector{
+					LocalObjectReference: v12.LocalObjectReference{Name: secretName},
+					Key:                  env[1],
+				},
+			},
+		})
+	}
+	return envs
+}
+
+func createStoreVolumes(m *v1alpha1.PulsarManager) ([]v12.Volume, []v12.VolumeMount) {
+	if m.Spec.Store.Type != v1alpha1.ManagerStoreEmbedded {
+		return nil, nil
+	}
+	source := v12.VolumeSource{EmptyDir: &v12.EmptyDirVolumeSource{}}
+	if m.IsStorePersisted() {
+		source = v12.VolumeSource{
+			PersistentVolumeClaim: &v12.PersistentVolumeClaimVolumeSource{ClaimName: m.StoreClaimName()},
+		}
+	}
+	volumes := []v12.Volume{{Name: embeddedStoreVolumeName, VolumeSource: source}}
+	mounts := []v12.VolumeMount{{Name: embeddedStoreVolumeName, MountPath: embeddedStoreMountPath}}
+	return volumes, mounts
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"github.com/monimesl/pulsar-operator/internal/manager"
+	v1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strings"
+)
+
+// ReconcileEnvironments registers the referenced clusters as environments through the pulsar
+// manager API once it's ready. The environments the operator registered before and are
+// no longer in the spec are deleted; the ones added through the UI are left alone
+func ReconcileEnvironments(ctx reconciler.Context, m *v1alpha1.PulsarManager) error {
+	if len(m.Spec.Environments) == 0 && len(m.Status.Environments) == 0 {
+		m.Status.SetCondition(v1alpha1.ConditionEnvironmentsRegistered, metav1.ConditionTrue,
+			"NoEnvironments", "no environment to register", m.Generation)
+		return nil
+	}
+	if ready, err := isDeploymentReady(ctx, m); err != nil {
+		return err
+	} else if !ready {
+		return environmentsPending(m, "ManagerNotReady", "waiting for the pulsar manager to be ready")
+	}
+	desired, err := desiredEnvironments(ctx, m)
+	if err != nil {
+		return err
+	}
+	setAccessibleCondition(m, desired.authenticated)
+	client, err := login(ctx, m)
+	if err != nil {
+		if _, ok := pulsarcluster.RequeueAfter(err); ok {
+			return err
+		}
+		return environmentsPending(m, "LoginFailed", err.Error())
+	}
+	registered, err := syncEnvironments(client, desired.environments, m.Status.Environments)
+	m.Status.Environments = registered
+	if err != nil {
+		return environmentsPending(m, "ManagerAPIError", err.Error())
+	}
+	if len(desired.missing) > 0 {
+		return environmentsPending(m, "ClusterNotFound",
+			fmt.Sprintf("the PulsarClusters do not exist: %s", strings.Join(desired.missing, ", ")))
+	}
+	m.Status.SetCondition(v1alpha1.ConditionEnvironmentsRegistered, metav1.ConditionTrue, "Registered",
+		fmt.Sprintf("%d environments are registered", len(registered)), m.Generation)
+	return nil
+}
+
+func environmentsPending(m *v1alpha1.PulsarManager, reason, message string) error {
+	m.Status.SetCondition(v1alpha1.ConditionEnvironmentsRegistered, metav1.ConditionFalse,
+		reason, message, m.Generation)
+	return pulsarcluster.Requeue(requeueDelay, message)
+}
+
+// resolvedEnvironments are the environments of the existing clusters, the names of the
+// missing clusters and the names of the clusters requiring the token authentication
+type resolvedEnvironments struct {
+	environments  []manager.Environment
+	missing       []string
+	authenticated []string
+}
+
+// desiredEnvironments resolves the environments of the referenced clusters
+func desiredEnvironments(ctx reconciler.Context, m *v1alpha1.PulsarManager) (*resolvedEnvironments, error) {
+	resolved := &resolvedEnvironments{}
+	for i := range m.Spec.Environments {
+		env := &m.Spec.Environments[i]
+		cluster := &v1alpha1.PulsarCluster{}
+		err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+			Name:      env.ClusterRef.Name,
+			Namespace: m.Namespace,
+		}, cluster)
+		if apierrors.IsNotFound(err) || (err == nil && cluster.Spec.Ports == nil) {
+			resolved.missing = append(resolved.missing, env.ClusterRef.Name)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		resolved.environments = append(resolved.environments, clusterEnvironment(env, cluster))
+		if cluster.Spec.Authentication.IsEnabled() {
+			resolved.authenticated = append(resolved.authenticated, cluster.Name)
+		}
+	}
+	return resolved, nil
+}
+
+// clusterEnvironment returns the environment of the cluster. The HTTPS port is used when the cluster has TLS enabled
+func clusterEnvironment(env *v1alpha1.ManagerEnvironment, c *v1alpha1.PulsarCluster) manager.Environment {
+	broker := fmt.Sprintf("http://%s:%d", c.ClientServiceFQDN(), c.Spec.Ports.Web)
+	if c.Spec.TLS.IsEnabled() {
+		broker = fmt.Sprintf("https://%s:%d", c.ClientServiceFQDN(), c.Spec.Ports.WebTLS)
+	}
+	return manager.Environment{
+		Name:   env.EnvironmentName(),
+		Broker: broker,
+		Bookie: env.BookieURL,
+	}
+}
+
+// setAccessibleCondition reports the clusters the manager cannot call since it's not issued their tokens.
+// The manager authenticates to every environment with the single token of its backend.jwt.token config
+func setAccessibleCondition(m *v1alpha1.PulsarManager, authenticated []string) {
+	if len(authenticated) == 0 {
+		m.Status.SetCondition(v1alpha1.ConditionEnvironmentsAccessible, metav1.ConditionTrue,
+			"NoAuthentication", "no environment requires authentication", m.Generation)
+		return
+	}
+	m.Status.SetCondition(v1alpha1.ConditionEnvironmentsAccessible, metav1.ConditionFalse, "AuthenticationRequired",
+		fmt.Sprintf("the PulsarClusters require token authentication: %s; the pulsar manager must be "+
+			"configured with a superuser token through its backend.jwt.token config", strings.Join(authenticated, ", ")),
+		m.Generation)
+}
+
+// login logs the superuser in, bootstrapping it if the manager has no user yet
+func login(ctx reconciler.Context, m *v1alpha1.PulsarManager) (*manager.Client, error) {
+	username, password, err := superuserCredentials(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	client := manager.NewClient(m.BackendURL())
+	if err = client.Login(username, password); !errors.Is(err, manager.ErrLoginFailed) {
+		return client, err
+	}
+	ctx.Logger().Info("Bootstrapping the pulsar manager superuser",
+		"PulsarManager.Name", m.Name,
+		"PulsarManager.Namespace", m.Namespace,
+		"Username", username)
+	if err = client.CreateSuperuser(username, password); err != nil {
+		return nil, fmt.Errorf("cannot bootstrap the superuser %s: %w", username, err)
+	}
+	return client, client.Login(username, password)
+}
+
+// syncEnvironments adds or updates the desired environments and deletes the previously
+// registered ones no longer desired. It returns the names of the registered environments
+func syncEnvironments(client *manager.Client, desired []manager.Environment, previous []string) ([]string, error) {
+	current, err := client.ListEnvironments()
+	if err != nil {
+		return previous, err
+	}
+	existing := map[string]manager.Environment{}
+	for _, env := range current {
+		existing[env.Name] = env
+	}
+	registered := make([]string, 0, len(desired))
+	wanted := map[string]bool{}
+	for _, env := range desired {
+		wanted[env.Name] = true
+		if cur, ok := existing[env.Name]; !ok {
+			err = client.AddEnvironment(env)
+		} else if cur != env {
+			err = client.UpdateEnvironment(env)
+		}
+		if err != nil {
+			return previous, fmt.Errorf("cannot register the environment %s: %w", env.Name, err)
+		}
+		registered = append(registered, env.Name)
+	}
+	for _, name := range previous {
+		if _, ok := existing[name]; !ok || wanted[name] {
+			continue
+		}
+		if err = client.DeleteEnvironment(name); err != nil {
+			return previous, fmt.Errorf("cannot delete the environment %s: %w", name, err)
+		}
+	}
+	sort.Strings(registered)
+	return registered, nil
+}
+
+func isDeploymentReady(ctx reconciler.Context, m *v1alpha1.PulsarManager) (bool, error) {
+	dep := &v1.Deployment{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      m.DeploymentName(),
+		Namespace: m.Namespace,
+	}, dep)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return dep.Status.ReadyReplicas > 0, nil
+}
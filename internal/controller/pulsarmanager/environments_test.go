@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"encoding/json"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/manager"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeManager is an in-memory pulsar manager API checking the CSRF and login tokens
+type fakeManager struct {
+	sync.Mutex
+	environments map[string]manager.Environment
+	calls        []string
+}
+
+func newFakeManager(t *testing.T, environments ...manager.Environment) (*fakeManager, *manager.Client) {
+	t.Helper()
+	fake := &fakeManager{environments: map[string]manager.Environment{}}
+	for _, env := range environments {
+		fake.environments[env.Name] = env
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := manager.NewClient(server.URL)
+	if err := client.Login("admin", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	return fake, client
+}
+
+func (f *fakeManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	switch {
+	case r.URL.Path == "/pulsar-manager/csrf-token":
+		http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "csrf"})
+		_, _ = w.Write([]byte("csrf"))
+		return
+	case r.Header.Get("X-XSRF-TOKEN") != "csrf":
+		w.WriteHeader(http.StatusForbidden)
+		return
+	case r.URL.Path == "/pulsar-manager/login":
+		w.Header().Set("token", "login-token")
+		w.Header().Set("username", "admin")
+		_, _ = w.Write([]byte(`{"login":"success"}`))
+		return
+	case r.Header.Get("token") != "login-token":
+		_, _ = w.Write([]byte(`{"error":"Please login"}`))
+		return
+	}
+	env := manager.Environment{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&env)
+	}
+	if r.Method == http.MethodGet {
+		data := make([]manager.Environment, 0, len(f.environments))
+		for _, e := range f.environments {
+			data = append(data, e)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"total": len(data), "data": data})
+		return
+	}
+	f.calls = append(f.calls, r.Method+" "+env.Name)
+	switch r.Method {
+	case http.MethodPut:
+		if _, ok := f.environments[env.Name]; ok {
+			_, _ = w.Write([]byte(`{"error":"Environment already exists"}`))
+			return
+		}
+		f.environments[env.Name] = env
+	case http.MethodPost:
+		f.environments[env.Name] = env
+	case http.MethodDelete:
+		delete(f.environments, env.Name)
+	}
+}
+
+func TestSyncEnvironments(t *testing.T) {
+	t.Parallel()
+	fake, client := newFakeManager(t,
+		manager.Environment{Name: "prod", Broker: "http://old:8080"},
+		manager.Environment{Name: "staging", Broker: "http://staging:8080"},
+		manager.Environment{Name: "added-in-ui", Broker: "http://ui:8080"},
+		manager.Environment{Name: "unchanged", Broker: "http://unchanged:8080"},
+	)
+	desired := []manager.Environment{
+		{Name: "prod", Broker: "http://prod:8080"},
+		{Name: "unchanged", Broker: "http://unchanged:8080"},
+		{Name: "dev", Broker: "http://dev:8080"},
+	}
+	registered, err := syncEnvironments(client, desired, []string{"prod", "staging", "unchanged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dev", "prod", "unchanged"}; !reflect.DeepEqual(registered, want) {
+		t.Errorf("expected the registered environments: %v; got: %v", want, registered)
+	}
+	sort.Strings(fake.calls)
+	if want := []string{"DELETE staging", "POST prod", "PUT dev"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("expected the calls: %v; got: %v", want, fake.calls)
+	}
+	if _, ok := fake.environments["added-in-ui"]; !ok {
+		t.Error("expected the environment added through the UI to be left alone")
+	}
+	if broker := fake.environments["prod"].Broker; broker != "http://prod:8080" {
+		t.Errorf("expected the prod broker to be updated; got: %s", broker)
+	}
+}
+
+func TestSyncEnvironmentsError(t *testing.T) {
+	t.Parallel()
+	client := manager.NewClient("http://127.0.0.1:0")
+	previous := []string{"prod"}
+	registered, err := syncEnvironments(client, []manager.Environment{{Name: "dev"}}, previous)
+	if err == nil {
+		t.Fatal("expected an error from the unreachable manager")
+	}
+	if !reflect.DeepEqual(registered, previous) {
+		t.Errorf("expected the previously registered environments to be kept; got: %v", registered)
+	}
+}
+
+func TestClusterEnvironment(t *testing.T) {
+	t.Parallel()
+	env := &v1alpha1.ManagerEnvironment{ClusterRef: v1alpha1.ClusterReference{Name: "pulsar"}, BookieURL: "http://bk:8000"}
+	cluster := &v1alpha1.PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "pulsar", Namespace: "streaming"}}
+	cluster.SetSpecDefaults()
+	want := manager.Environment{Name: "pulsar", Broker: "http://" + cluster.ClientServiceFQDN() + ":8080", Bookie: "http://bk:8000"}
+	if got := clusterEnvironment(env, cluster); got != want {
+		t.Errorf("expected: %+v; got: %+v", want, got)
+	}
+	cluster.Spec.TLS = &v1alpha1.TLSConfig{Enabled: true}
+	want.Broker = "https://" + cluster.ClientServiceFQDN() + ":8443"
+	if got := clusterEnvironment(env, cluster); got != want {
+		t.Errorf("expected the TLS web service URL: %+v; got: %+v", want, got)
+	}
+}
+
+func TestSetAccessibleCondition(t *testing.T) {
+	t.Parallel()
+	m := &v1alpha1.PulsarManager{}
+	setAccessibleCondition(m, nil)
+	if c := m.Status.GetCondition(v1alpha1.ConditionEnvironmentsAccessible); c == nil || c.Status != metav1.ConditionTrue {
+		t.Errorf("expected the environments to be accessible; got: %v", c)
+	}
+	setAccessibleCondition(m, []string{"pulsar", "pulsar-eu"})
+	c := m.Status.GetCondition(v1alpha1.ConditionEnvironmentsAccessible)
+	if c == nil || c.Status != metav1.ConditionFalse || c.Reason != "AuthenticationRequired" {
+		t.Fatalf("expected the authentication to be reported; got: %v", c)
+	}
+	if !strings.Contains(c.Message, "pulsar, pulsar-eu") {
+		t.Errorf("expected the clusters to be listed; got: %q", c.Message)
+	}
+}
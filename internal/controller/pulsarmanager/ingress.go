@@ -0,0 +1,120 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReconcileIngress reconcile the ingress of the specified manager. The ingress is deleted once it's unset
+func ReconcileIngress(ctx reconciler.Context, manager *v1alpha1.PulsarManager) error {
+	ing := &v1.Ingress{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      manager.IngressName(),
+		Namespace: manager.Namespace,
+	}, ing,
+		// Found
+		func() error {
+			if manager.Spec.Ingress == nil {
+				ctx.Logger().Info("Deleting the pulsar manager ingress.",
+					"Ingress.Name", ing.GetName(),
+					"Ingress.Namespace", ing.GetNamespace())
+				return ctx.Client().Delete(context.TODO(), ing)
+			}
+			desired := createIngress(manager)
+			if equality.Semantic.DeepEqual(ing.Spec, desired.Spec) &&
+				equality.Semantic.DeepEqual(ing.Annotations, desired.Annotations) {
+				return nil
+			}
+			ing.Labels = desired.Labels
+			ing.Annotations = desired.Annotations
+			ing.Spec = desired.Spec
+			ctx.Logger().Info("Updating the pulsar manager ingress.",
+				"Ingress.Name", ing.GetName(),
+				"Ingress.Namespace", ing.GetNamespace())
+			return ctx.Client().Update(context.TODO(), ing)
+		},
+		// Not Found
+		func() error {
+			if manager.Spec.Ingress == nil {
+				return nil
+			}
+			ing = createIngress(manager)
+			if err := ctx.SetOwnershipReference(manager, ing); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar manager ingress.",
+				"Ingress.Name", ing.GetName(),
+				"Ingress.Namespace", ing.GetNamespace(),
+				"Host", manager.Spec.Ingress.Host)
+			return ctx.Client().Create(context.TODO(), ing)
+		})
+}
+
+func createIngress(m *v1alpha1.PulsarManager) *v1.Ingress {
+	spec := m.Spec.Ingress
+	pathType := v1.PathTypePrefix
+	ing := &v1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Ingress",
+			APIVersion: "networking.k8s.io/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        m.IngressName(),
+			Namespace:   m.Namespace,
+			Labels:      m.GenerateLabels(),
+			Annotations: spec.Annotations,
+		},
+		Spec: v1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules: []v1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: v1.IngressRuleValue{
+						HTTP: &v1.HTTPIngressRuleValue{
+							Paths: []v1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: v1.IngressBackend{
+										Service: &v1.IngressServiceBackend{
+											Name: m.ServiceName(),
+											Port: v1.ServiceBackendPort{Name: v1alpha1.ManagerUIPortName},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		tls := v1.IngressTLS{SecretName: spec.TLSSecretName}
+		if spec.Host != "" {
+			tls.Hosts = []string{spec.Host}
+		}
+		ing.Spec.TLS = []v1.IngressTLS{tls}
+	}
+	return ing
+}
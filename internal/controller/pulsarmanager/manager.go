@@ -0,0 +1,33 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package pulsarmanager reconciles the pulsar manager UI and registers the clusters as its environments
+package pulsarmanager
+
+import (
+	"time"
+)
+
+const (
+	// the ports the pulsar manager image serves the UI and the API on
+	uiContainerPort      = 9527
+	backendContainerPort = 7750
+
+	embeddedStoreVolumeName = "data"
+	embeddedStoreMountPath  = "/data"
+	configurationFile       = "/pulsar-manager/pulsar-manager/application.properties"
+
+	requeueDelay = 15 * time.Second
+)
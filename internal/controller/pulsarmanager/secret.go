@@ -0,0 +1,98 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s/secret"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	defaultSuperuser       = "admin"
+	generatedPasswordBytes = 24
+)
+
+// ReconcileSuperuserSecret generates the superuser secret unless the manager references its own
+func ReconcileSuperuserSecret(ctx reconciler.Context, manager *v1alpha1.PulsarManager) error {
+	if manager.Spec.SuperuserSecretName != "" {
+		return nil
+	}
+	sec := &v1.Secret{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      manager.SuperuserSecretName(),
+		Namespace: manager.Namespace,
+	}, sec,
+		// Found
+		func() error { return nil },
+		// Not Found
+		func() error {
+			password, err := generatePassword()
+			if err != nil {
+				return err
+			}
+			sec = secret.New(manager.Namespace, manager.SuperuserSecretName(), map[string][]byte{
+				v1alpha1.ManagerUsernameKey: []byte(defaultSuperuser),
+				v1alpha1.ManagerPasswordKey: []byte(password),
+			})
+			sec.Labels = manager.GenerateLabels()
+			if err = ctx.SetOwnershipReference(manager, sec); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar manager superuser secret.",
+				"Secret.Name", sec.GetName(),
+				"Secret.Namespace", sec.GetNamespace())
+			return ctx.Client().Create(context.TODO(), sec)
+		})
+}
+
+// superuserCredentials returns the username and the password of the superuser
+func superuserCredentials(ctx reconciler.Context, m *v1alpha1.PulsarManager) (string, string, error) {
+	sec := &v1.Secret{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      m.SuperuserSecretName(),
+		Namespace: m.Namespace,
+	}, sec)
+	if errors.IsNotFound(err) {
+		return "", "", pulsarcluster.Requeue(requeueDelay,
+			fmt.Sprintf("waiting for the superuser secret: %s", m.SuperuserSecretName()))
+	} else if err != nil {
+		return "", "", err
+	}
+	username := string(sec.Data[v1alpha1.ManagerUsernameKey])
+	password := string(sec.Data[v1alpha1.ManagerPasswordKey])
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("the superuser secret %s requires both the %s and %s keys",
+			sec.Name, v1alpha1.ManagerUsernameKey, v1alpha1.ManagerPasswordKey)
+	}
+	return username, password, nil
+}
+
+func generatePassword() (string, error) {
+	data := make([]byte, generatedPasswordBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/service"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ReconcileService reconcile the service of the specified manager
+func ReconcileService(ctx reconciler.Context, manager *v1alpha1.PulsarManager) error {
+	svc := &v1.Service{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      manager.ServiceName(),
+		Namespace: manager.Namespace,
+	}, svc,
+		// Found
+		func() error {
+			desired := createService(manager)
+			if !shouldUpdateService(svc, desired) {
+				return nil
+			}
+			svc.Labels = desired.Labels
+			svc.Annotations = desired.Annotations
+			svc.Spec.Type = desired.Spec.Type
+			for i := range svc.Spec.Ports {
+				for _, port := range desired.Spec.Ports {
+					if svc.Spec.Ports[i].Name == port.Name {
+						svc.Spec.Ports[i].Port = port.Port
+					}
+				}
+			}
+			ctx.Logger().Info("Updating the pulsar manager service.",
+				"Service.Name", svc.GetName(),
+				"Service.Namespace", svc.GetNamespace(),
+				"Type", svc.Spec.Type)
+			return ctx.Client().Update(context.TODO(), svc)
+		},
+		// Not Found
+		func() error {
+			svc = createService(manager)
+			if err := ctx.SetOwnershipReference(manager, svc); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar manager service.",
+				"Service.Name", svc.GetName(),
+				"Service.Namespace", svc.GetNamespace())
+			return ctx.Client().Create(context.TODO(), svc)
+		})
+}
+
+// shouldUpdateService compares the type and the port numbers only so that the
+// defaulted fields and the allocated node ports don't cause endless updates
+func shouldUpdateService(svc, desired *v1.Service) bool {
+	if svc.Spec.Type != desired.Spec.Type || len(svc.Spec.Ports) != len(desired.Spec.Ports) {
+		return true
+	}
+	for _, port := range desired.Spec.Ports {
+		found := false
+		for _, current := range svc.Spec.Ports {
+			if current.Name == port.Name && current.Port == port.Port {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
+
+func createService(m *v1alpha1.PulsarManager) *v1.Service {
+	svc := service.New(m.Namespace, m.ServiceName(), m.GenerateLabels(), v1.ServiceSpec{
+		Type:     m.Spec.ServiceType,
+		Selector: m.SelectorLabels(),
+		Ports: []v1.ServicePort{
+			{
+				Name:       v1alpha1.ManagerUIPortName,
+				Port:       m.Spec.Ports.UI,
+				TargetPort: intstr.FromInt32(uiContainerPort),
+			},
+			{
+				Name:       v1alpha1.ManagerBackendPortName,
+				Port:       m.Spec.Ports.Backend,
+				TargetPort: intstr.FromInt32(backendContainerPort),
+			},
+		},
+	})
+	svc.Annotations = m.GenerateAnnotations()
+	return svc
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReconcileStatus reconcile the status of the specified manager from the state of its deployment
+func ReconcileStatus(ctx reconciler.Context, m *v1alpha1.PulsarManager) error {
+	dep := &v1.Deployment{}
+	err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      m.DeploymentName(),
+		Namespace: m.Namespace,
+	}, dep)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	m.Status.URL = m.UIURL()
+	m.Status.ReadyReplicas = dep.Status.ReadyReplicas
+	if m.Status.ReadyReplicas > 0 {
+		m.Status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionTrue, "ManagerReady",
+			"the pulsar manager is ready", m.Generation)
+	} else {
+		m.Status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse, "ManagerNotReady",
+			"the pulsar manager is not ready", m.Generation)
+	}
+	return updateStatus(ctx, m)
+}
+
+// updateStatus updates the manager status if it changed since it was last read
+func updateStatus(ctx reconciler.Context, m *v1alpha1.PulsarManager) error {
+	current := &v1alpha1.PulsarManager{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      m.Name,
+		Namespace: m.Namespace,
+	}, current); err != nil {
+		return err
+	}
+	m.Status.ObservedGeneration = m.Generation
+	if equality.Semantic.DeepEqual(current.Status, m.Status) {
+		return nil
+	}
+	ctx.Logger().Info("Updating the pulsar manager status",
+		"manager", m.GetName(),
+		"ReadyReplicas", m.Status.ReadyReplicas,
+		"Environments", m.Status.Environments)
+	return ctx.Client().Status().Update(context.TODO(), m)
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarmanager
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReconcileStoreClaim creates the persistent volume claim of the embedded store if it's persisted. The
+// claim is never updated or deleted by the operator so that the manager data outlives the spec changes
+func ReconcileStoreClaim(ctx reconciler.Context, manager *v1alpha1.PulsarManager) error {
+	if !manager.IsStorePersisted() {
+		return nil
+	}
+	pvc := &v1.PersistentVolumeClaim{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      manager.StoreClaimName(),
+		Namespace: manager.Namespace,
+	}, pvc,
+		// Found
+		func() error { return nil },
+		// Not Found
+		func() error {
+			pvc = createStoreClaim(manager)
+			if err := ctx.SetOwnershipReference(manager, pvc); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar manager store claim.",
+				"PersistentVolumeClaim.Name", pvc.GetName(),
+				"PersistentVolumeClaim.Namespace", pvc.GetNamespace(),
+				"Size", manager.Spec.Store.Embedded.StorageSize.String())
+			return ctx.Client().Create(context.TODO(), pvc)
+		})
+}
+
+func createStoreClaim(m *v1alpha1.PulsarManager) *v1.PersistentVolumeClaim {
+	embedded := m.Spec.Store.Embedded
+	return &v1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.StoreClaimName(),
+			Namespace: m.Namespace,
+			Labels:    m.GenerateLabels(),
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			StorageClassName: embedded.StorageClassName,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: *embedded.StorageSize},
+			},
+		},
+	}
+}
@@ -20,10 +20,16 @@ package controller
 import (
 	"context"
 	"github.com/monimesl/operator-helper/reconciler"
+	pulsarcluster2 "github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarmanager"
 	v12 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
-	v13 "k8s.io/api/policy/v1"
+	v13 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
 
 	pulsarv1alpha1 "github.com/monimesl/pulsar-operator/api/v1alpha1"
 )
@@ -31,7 +37,15 @@ import (
 var (
 	_                     reconciler.Context    = &PulsarManagerReconciler{}
 	_                     reconciler.Reconciler = &PulsarManagerReconciler{}
-	managerReconcileFuncs                       = []func(ctx reconciler.Context, cluster *pulsarv1alpha1.PulsarManager) error{}
+	managerReconcileFuncs                       = []func(ctx reconciler.Context, manager *pulsarv1alpha1.PulsarManager) error{
+		pulsarmanager.ReconcileSuperuserSecret,
+		pulsarmanager.ReconcileStoreClaim,
+		pulsarmanager.ReconcileDeployment,
+		pulsarmanager.ReconcileService,
+		pulsarmanager.ReconcileIngress,
+		pulsarmanager.ReconcileEnvironments,
+		pulsarmanager.ReconcileStatus,
+	}
 )
 
 // PulsarManagerReconciler reconciles a PulsarManager object
@@ -44,22 +58,61 @@ func (r *PulsarManagerReconciler) Configure(ctx reconciler.Context) error {
 	r.Context = ctx
 	return ctx.NewControllerBuilder().
 		For(&pulsarv1alpha1.PulsarManager{}).
-		Owns(&v13.PodDisruptionBudget{}).
-		Owns(&v12.StatefulSet{}).
-		Owns(&v1.ConfigMap{}).
+		Owns(&v12.Deployment{}).
 		Owns(&v1.Service{}).
+		Owns(&v1.Secret{}).
+		Owns(&v13.Ingress{}).
+		Watches(&pulsarv1alpha1.PulsarCluster{}, handler.EnqueueRequestsFromMapFunc(r.managersOfCluster)).
 		Complete(r)
 }
 
+// managersOfCluster maps the cluster to the managers registering it so that its environment follows its changes
+func (r *PulsarManagerReconciler) managersOfCluster(ctx context.Context, cluster client.Object) []reconcile.Request {
+	managers := &pulsarv1alpha1.PulsarManagerList{}
+	if err := r.Client().List(ctx, managers, client.InNamespace(cluster.GetNamespace())); err != nil {
+		r.Logger().Error(err, "error on listing the managers of the cluster",
+			"PulsarCluster.Name", cluster.GetName(),
+			"PulsarCluster.Namespace", cluster.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for i := range managers.Items {
+		manager := &managers.Items[i]
+		for _, env := range manager.Spec.Environments {
+			if env.ClusterRef.Name == cluster.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Name:      manager.Name,
+					Namespace: manager.Namespace,
+				}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 // Reconcile handles reconciliation request for PulsarManager instances
 func (r *PulsarManagerReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
-	cluster := &pulsarv1alpha1.PulsarManager{}
-	return r.Run(request, cluster, func(_ bool) (err error) {
+	manager := &pulsarv1alpha1.PulsarManager{}
+	requeueAfter := time.Duration(0)
+	result, err := r.Run(request, manager, func(_ bool) (err error) {
 		for _, fun := range managerReconcileFuncs {
-			if err = fun(r, cluster); err != nil {
-				break
+			if err = fun(r, manager); err != nil {
+				after, ok := pulsarcluster2.RequeueAfter(err)
+				if !ok {
+					break
+				}
+				// the manager is waiting on something; continue with the rest and retry later
+				if requeueAfter == 0 || after < requeueAfter {
+					requeueAfter = after
+				}
+				err = nil
 			}
 		}
 		return
 	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
 }
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarnamespace
+
+import (
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarresource"
+)
+
+// finalizer deletes the namespace from pulsar before the object is deleted
+const finalizer = internal.Domain + "/namespace"
+
+// ReconcileNamespace syncs the namespace to the referenced pulsar cluster, or deletes it from the cluster if deleted is true
+func ReconcileNamespace(ctx reconciler.Context, namespace *v1alpha1.PulsarNamespace, deleted bool) error {
+	if deleted {
+		return pulsarresource.Finalize(ctx, namespace, finalizer, func(client *admin.Client) error {
+			ctx.Logger().Info("Deleting the pulsar namespace",
+				"PulsarNamespace.Name", namespace.Name,
+				"PulsarNamespace.Namespace", namespace.Namespace,
+				"Namespace", namespace.NamespaceName())
+			return client.DeleteNamespace(namespace.NamespaceName())
+		})
+	}
+	if err := pulsarresource.EnsureFinalizer(ctx, namespace, finalizer); err != nil {
+		return err
+	}
+	cluster, client, err := pulsarresource.AdminClient(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if err = syncNamespace(ctx, namespace, cluster, client); err != nil {
+		return pulsarresource.SyncFailed(ctx, namespace, "AdminAPIError", err.Error())
+	}
+	return pulsarresource.Synced(ctx, namespace)
+}
+
+func syncNamespace(ctx reconciler.Context, namespace *v1alpha1.PulsarNamespace, cluster *v1alpha1.PulsarCluster, client *admin.Client) error {
+	name := namespace.NamespaceName()
+	policies := managedPolicies(&namespace.Spec.Policies, cluster.PulsarClusterName())
+	current, err := client.GetNamespacePolicies(name)
+	if admin.IsNotFound(err) {
+		if !pulsarresource.IsSpecChanged(namespace) {
+			pulsarresource.Drifted(namespace, namespace.Spec.DriftPolicy, []string{"deleted"})
+			if namespace.Spec.DriftPolicy == v1alpha1.DriftPolicyReport {
+				return nil
+			}
+		}
+		ctx.Logger().Info("Creating the pulsar namespace",
+			"PulsarNamespace.Name", namespace.Name,
+			"PulsarNamespace.Namespace", namespace.Namespace,
+			"Namespace", name)
+		bundles := int32(0)
+		if namespace.Spec.Bundles != nil {
+			bundles = *namespace.Spec.Bundles
+		}
+		if err = client.CreateNamespace(name, bundles); err != nil {
+			return err
+		}
+		return applyPolicies(client, name, policies, &admin.NamespacePolicies{})
+	} else if err != nil {
+		return err
+	}
+	outdated := outdatedPolicies(policies, current)
+	if len(outdated) == 0 {
+		pulsarresource.Drifted(namespace, namespace.Spec.DriftPolicy, nil)
+		return nil
+	}
+	names := policyNames(outdated)
+	if !pulsarresource.IsSpecChanged(namespace) {
+		pulsarresource.Drifted(namespace, namespace.Spec.DriftPolicy, names)
+		if namespace.Spec.DriftPolicy == v1alpha1.DriftPolicyReport {
+			return nil
+		}
+	}
+	ctx.Logger().Info("Updating the pulsar namespace policies",
+		"PulsarNamespace.Name", namespace.Name,
+		"PulsarNamespace.Namespace", namespace.Namespace,
+		"Namespace", name,
+		"Policies", names)
+	return applyPolicies(client, name, outdated, current)
+}
+
+func applyPolicies(client *admin.Client, namespace string, policies []policy, current *admin.NamespacePolicies) error {
+	for _, p := range policies {
+		if err := p.apply(client, namespace, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func outdatedPolicies(policies []policy, current *admin.NamespacePolicies) []policy {
+	var outdated []policy
+	for _, p := range policies {
+		if !p.inSync(current) {
+			outdated = append(outdated, p)
+		}
+	}
+	return outdated
+}
+
+func policyNames(policies []policy) []string {
+	names := make([]string, 0, len(policies))
+	for _, p := range policies {
+		names = append(names, p.name)
+	}
+	return names
+}
@@ -0,0 +1,203 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarnamespace
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarresource"
+)
+
+// policy is a namespace policy managed by the operator
+type policy struct {
+	// name is the name of the policy in the spec
+	name string
+	// inSync checks whether the policy in pulsar matches the spec
+	inSync func(current *admin.NamespacePolicies) bool
+	// apply sets the policy of the namespace in pulsar
+	apply func(client *admin.Client, namespace string, current *admin.NamespacePolicies) error
+}
+
+// managedPolicies returns the policies set in the spec. The rates are
+// per cluster in pulsar so they're keyed by the name of the pulsar cluster
+func managedPolicies(spec *v1alpha1.NamespacePolicies, clusterName string) []policy {
+	var policies []policy
+	if retention := spec.Retention; retention != nil {
+		desired := admin.RetentionPolicies{
+			RetentionTimeInMinutes: retention.TimeInMinutes,
+			RetentionSizeInMB:      retention.SizeInMB,
+		}
+		policies = append(policies, policy{
+			name: "retention",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				return current.RetentionPolicies != nil && *current.RetentionPolicies == desired
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetRetention(namespace, desired)
+			},
+		})
+	}
+	if ttl := spec.MessageTTLSeconds; ttl != nil {
+		desired := *ttl
+		policies = append(policies, policy{
+			name: "messageTTLSeconds",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				if current.MessageTTLInSeconds == nil {
+					return desired == 0
+				}
+				return *current.MessageTTLInSeconds == desired
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetMessageTTL(namespace, desired)
+			},
+		})
+	}
+	if quota := spec.BacklogQuota; quota != nil {
+		limit := quota.Limit.Value()
+		desired := admin.BacklogQuota{Limit: limit, LimitSize: limit, Policy: quota.Policy}
+		policies = append(policies, policy{
+			name: "backlogQuota",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				q, ok := current.BacklogQuotaMap[admin.BacklogQuotaDestinationStorage]
+				return ok && q.SizeLimit() == limit && q.Policy == desired.Policy
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetBacklogQuota(namespace, desired)
+			},
+		})
+	}
+	if rate := spec.PublishRate; rate != nil {
+		desired := admin.PublishRate{
+			PublishThrottlingRateInMsg:  rate.MessagesPerSecond,
+			PublishThrottlingRateInByte: rate.BytesPerSecond,
+		}
+		policies = append(policies, policy{
+			name: "publishRate",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				r, ok := current.PublishMaxMessageRate[clusterName]
+				return ok && r == desired
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetPublishRate(namespace, desired)
+			},
+		})
+	}
+	if rate := spec.DispatchRate; rate != nil {
+		desired := admin.DispatchRate{
+			DispatchThrottlingRateInMsg:  rate.MessagesPerPeriod,
+			DispatchThrottlingRateInByte: rate.BytesPerPeriod,
+			RatePeriodInSecond:           rate.PeriodSeconds,
+		}
+		policies = append(policies, policy{
+			name: "dispatchRate",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				r, ok := current.ClusterDispatchRate[clusterName]
+				return ok && r == desired
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetDispatchRate(namespace, desired)
+			},
+		})
+	}
+	if deduplication := spec.Deduplication; deduplication != nil {
+		desired := *deduplication
+		policies = append(policies, policy{
+			name: "deduplication",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				return current.DeduplicationEnabled != nil && *current.DeduplicationEnabled == desired
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetDeduplication(namespace, desired)
+			},
+		})
+	}
+	if desired := spec.SchemaCompatibilityStrategy; desired != "" {
+		policies = append(policies, policy{
+			name: "schemaCompatibilityStrategy",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				return current.SchemaCompatibilityStrategy == desired
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetSchemaCompatibilityStrategy(namespace, desired)
+			},
+		})
+	}
+	if desired := spec.ReplicationClusters; len(desired) > 0 {
+		policies = append(policies, policy{
+			name: "replicationClusters",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				return pulsarresource.StringSetEqual(current.ReplicationClusters, desired)
+			},
+			apply: func(client *admin.Client, namespace string, _ *admin.NamespacePolicies) error {
+				return client.SetReplicationClusters(namespace, desired)
+			},
+		})
+	}
+	if permissions := spec.Permissions; len(permissions) > 0 {
+		policies = append(policies, policy{
+			name: "permissions",
+			inSync: func(current *admin.NamespacePolicies) bool {
+				return len(permissionChanges(permissions, current)) == 0
+			},
+			apply: applyPermissions(permissions),
+		})
+	}
+	return policies
+}
+
+// permissionChanges returns the roles whose granted actions differ from the spec, including the roles to revoke
+func permissionChanges(permissions []v1alpha1.NamespacePermission, current *admin.NamespacePolicies) []string {
+	granted := current.AuthPolicies.NamespaceAuth
+	var roles []string
+	for _, permission := range permissions {
+		actions, ok := granted[permission.Role]
+		if !ok || !pulsarresource.StringSetEqual(actions, permission.Actions) {
+			roles = append(roles, permission.Role)
+		}
+	}
+	for role := range granted {
+		if findPermission(permissions, role) == nil {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func applyPermissions(permissions []v1alpha1.NamespacePermission) func(*admin.Client, string, *admin.NamespacePolicies) error {
+	return func(client *admin.Client, namespace string, current *admin.NamespacePolicies) error {
+		for _, role := range permissionChanges(permissions, current) {
+			var err error
+			if permission := findPermission(permissions, role); permission != nil {
+				err = client.GrantPermission(namespace, role, permission.Actions)
+			} else {
+				err = client.RevokePermission(namespace, role)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func findPermission(permissions []v1alpha1.NamespacePermission, role string) *v1alpha1.NamespacePermission {
+	for i := range permissions {
+		if permissions[i].Role == role {
+			return &permissions[i]
+		}
+	}
+	return nil
+}
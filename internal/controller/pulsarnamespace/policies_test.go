@@ -0,0 +1,67 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarnamespace
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"reflect"
+	"testing"
+)
+
+func TestManagedPolicies(t *testing.T) {
+	t.Parallel()
+	ttl := int32(0)
+	spec := &v1alpha1.NamespacePolicies{
+		Retention:         &v1alpha1.RetentionPolicy{TimeInMinutes: 60, SizeInMB: 1024},
+		MessageTTLSeconds: &ttl,
+		BacklogQuota:      &v1alpha1.BacklogQuota{Limit: resource.MustParse("1Mi"), Policy: "producer_exception"},
+		PublishRate:       &v1alpha1.PublishRate{MessagesPerSecond: 100, BytesPerSecond: -1},
+	}
+	current := &admin.NamespacePolicies{
+		RetentionPolicies:     &admin.RetentionPolicies{RetentionTimeInMinutes: 60, RetentionSizeInMB: 1024},
+		BacklogQuotaMap:       map[string]admin.BacklogQuota{"destination_storage": {Limit: 1 << 20, Policy: "producer_exception"}},
+		PublishMaxMessageRate: map[string]admin.PublishRate{"east": {PublishThrottlingRateInMsg: 100, PublishThrottlingRateInByte: -1}},
+	}
+	if names := policyNames(outdatedPolicies(managedPolicies(spec, "east"), current)); len(names) != 0 {
+		t.Errorf("expected the policies to be in sync; got the outdated: %v", names)
+	}
+	names := policyNames(outdatedPolicies(managedPolicies(spec, "west"), current))
+	if !reflect.DeepEqual(names, []string{"publishRate"}) {
+		t.Errorf("expected the publish rate of the other cluster to be outdated; got: %v", names)
+	}
+	if policies := managedPolicies(&v1alpha1.NamespacePolicies{}, "east"); len(policies) != 0 {
+		t.Errorf("expected the unset policies not to be managed; got: %v", policyNames(policies))
+	}
+}
+
+func TestPermissionChanges(t *testing.T) {
+	t.Parallel()
+	permissions := []v1alpha1.NamespacePermission{
+		{Role: "app", Actions: []string{"produce", "consume"}},
+		{Role: "reader", Actions: []string{"consume"}},
+	}
+	current := &admin.NamespacePolicies{AuthPolicies: admin.AuthPolicies{NamespaceAuth: map[string][]string{
+		"app":    {"consume", "produce"},
+		"reader": {"produce"},
+		"legacy": {"consume"},
+	}}}
+	roles := permissionChanges(permissions, current)
+	if !reflect.DeepEqual(roles, []string{"reader", "legacy"}) {
+		t.Errorf("expected the changed and the unlisted roles; got: %v", roles)
+	}
+}
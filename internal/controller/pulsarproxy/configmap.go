@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarproxy
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/configmap"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	pulsarConfigEnvPrefix = "PULSAR_PREFIX_"
+	statusFilePath        = "/pulsar/status"
+)
+
+// ReconcileConfigMap reconcile the configmap of the specified proxy
+func ReconcileConfigMap(ctx reconciler.Context, proxy *v1alpha1.PulsarProxy) error {
+	cluster, err := referencedCluster(ctx, proxy)
+	if err != nil {
+		return err
+	}
+	cm := &v1.ConfigMap{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      proxy.ConfigMapName(),
+		Namespace: proxy.Namespace,
+	}, cm,
+		// Found
+		func() error {
+			data := createConfigMapData(proxy, cluster)
+			if equality.Semantic.DeepEqual(cm.Data, data) {
+				return nil
+			}
+			cm.Labels = proxy.GenerateLabels()
+			cm.Data = data
+			ctx.Logger().Info("Updating the pulsar proxy configmap.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return ctx.Client().Update(context.TODO(), cm)
+		},
+		// Not Found
+		func() error {
+			cm = configmap.New(proxy.Namespace, proxy.ConfigMapName(), createConfigMapData(proxy, cluster))
+			cm.Labels = proxy.GenerateLabels()
+			if err := ctx.SetOwnershipReference(proxy, cm); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar proxy configmap.",
+				"ConfigMap.Name", cm.GetName(),
+				"ConfigMap.Namespace", cm.GetNamespace())
+			return ctx.Client().Create(context.TODO(), cm)
+		})
+}
+
+// createConfigMapData renders the proxy.conf overrides as the environment variables
+// applied by the image's apply-config-from-env.py. The configs derived from the
+// referenced cluster override the user's ones
+func createConfigMapData(p *v1alpha1.PulsarProxy, c *v1alpha1.PulsarCluster) map[string]string {
+	data := map[string]string{}
+	for key, value := range p.Spec.ProxyConfig {
+		data[pulsarConfigEnvPrefix+strings.TrimPrefix(key, pulsarConfigEnvPrefix)] = value
+	}
+	derived := map[string]string{
+		"clusterName":    c.PulsarClusterName(),
+		"servicePort":    strconv.Itoa(int(p.Spec.Ports.Client)),
+		"webServicePort": strconv.Itoa(int(p.Spec.Ports.Web)),
+		"statusFilePath": statusFilePath,
+	}
+	if c.Spec.TLS.IsEnabled() {
+		derived["brokerServiceURLTLS"] = brokerServiceURL(c)
+		derived["brokerWebServiceURLTLS"] = brokerWebServiceURL(c)
+	} else {
+		derived["brokerServiceURL"] = brokerServiceURL(c)
+		derived["brokerWebServiceURL"] = brokerWebServiceURL(c)
+	}
+	for _, configs := range []map[string]string{
+		derived,
+		pulsarcluster.ProxyTLSConfigs(c),
+		pulsarcluster.ProxyAuthenticationConfigs(c),
+	} {
+		for key, value := range configs {
+			data[pulsarConfigEnvPrefix+key] = value
+		}
+	}
+	jvmOptions := p.Spec.JVMOptions
+	data["PULSAR_MEM"] = strings.Join(jvmOptions.Memory, " ")
+	data["PULSAR_GC"] = strings.Join(jvmOptions.Gc, " ")
+	data["PULSAR_GC_LOG"] = strings.Join(jvmOptions.GcLogging, " ")
+	data["PULSAR_EXTRA_OPTS"] = strings.Join(jvmOptions.Extra, " ")
+	return data
+}
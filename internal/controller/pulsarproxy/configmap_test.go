@@ -0,0 +1,164 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarproxy
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestCreateConfigMapData(t *testing.T) {
+	t.Parallel()
+	cluster := &v1alpha1.PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "pulsar", Namespace: "streaming"}}
+	cluster.SetSpecDefaults()
+	proxy := &v1alpha1.PulsarProxy{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "streaming"},
+		Spec: v1alpha1.PulsarProxySpec{
+			ClusterRef: v1alpha1.ClusterReference{Name: "pulsar"},
+			ProxyConfig: map[string]string{
+				"maxConcurrentLookupRequests":    "100",
+				"PULSAR_PREFIX_numIOThreads":     "4",
+				"brokerServiceURL":               "pulsar://elsewhere:6650",
+				"PULSAR_PREFIX_brokerServiceURL": "pulsar://elsewhere:6650",
+			},
+		},
+	}
+	proxy.SetSpecDefaults()
+	data := createConfigMapData(proxy, cluster)
+	want := map[string]string{
+		"PULSAR_PREFIX_maxConcurrentLookupRequests": "100",
+		"PULSAR_PREFIX_numIOThreads":                "4",
+		"PULSAR_PREFIX_clusterName":                 "pulsar",
+		"PULSAR_PREFIX_brokerServiceURL":            "pulsar://" + cluster.ClientServiceFQDN() + ":6650",
+		"PULSAR_PREFIX_brokerWebServiceURL":         "http://" + cluster.ClientServiceFQDN() + ":8080",
+		"PULSAR_PREFIX_servicePort":                 "6650",
+		"PULSAR_PREFIX_webServicePort":              "8080",
+		"PULSAR_PREFIX_statusFilePath":              statusFilePath,
+	}
+	for key, value := range want {
+		if data[key] != value {
+			t.Errorf("%s: expected: %q; got: %q", key, value, data[key])
+		}
+	}
+	if _, ok := data["PULSAR_PREFIX_PULSAR_PREFIX_numIOThreads"]; ok {
+		t.Error("the already prefixed config is prefixed twice")
+	}
+}
+
+func TestCreateConfigMapDataWithAuthentication(t *testing.T) {
+	t.Parallel()
+	cluster := &v1alpha1.PulsarCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "pulsar", Namespace: "streaming"},
+		Spec: v1alpha1.PulsarClusterSpec{
+			Authentication: &v1alpha1.AuthenticationConfig{Enabled: true},
+			Authorization:  &v1alpha1.AuthorizationConfig{Enabled: true},
+		},
+	}
+	cluster.SetSpecDefaults()
+	proxy := &v1alpha1.PulsarProxy{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "streaming"},
+		Spec:       v1alpha1.PulsarProxySpec{ClusterRef: v1alpha1.ClusterReference{Name: "pulsar"}},
+	}
+	proxy.SetSpecDefaults()
+	data := createConfigMapData(proxy, cluster)
+	want := map[string]string{
+		"PULSAR_PREFIX_authenticationEnabled":                "true",
+		"PULSAR_PREFIX_authenticationProviders":              "org.apache.pulsar.broker.authentication.AuthenticationProviderToken",
+		"PULSAR_PREFIX_brokerClientAuthenticationPlugin":     "org.apache.pulsar.client.impl.auth.AuthenticationToken",
+		"PULSAR_PREFIX_brokerClientAuthenticationParameters": "file:///pulsar/tokens/proxy/proxy",
+		"PULSAR_PREFIX_tokenPublicKey":                       "file:///pulsar/keys/token/public.key",
+		"PULSAR_PREFIX_tokenPublicAlg":                       "RS256",
+		"PULSAR_PREFIX_forwardAuthorizationCredentials":      "true",
+		"PULSAR_PREFIX_brokerServiceURL":                     "pulsar://" + cluster.ClientServiceFQDN() + ":6650",
+	}
+	for key, value := range want {
+		if data[key] != value {
+			t.Errorf("%s: expected: %q; got: %q", key, value, data[key])
+		}
+	}
+	podSpec := createPodSpec(proxy, cluster)
+	mounts := map[string]string{}
+	for _, mount := range podSpec.Containers[0].VolumeMounts {
+		mounts[mount.Name] = mount.MountPath
+	}
+	if mounts["proxy-token"] != "/pulsar/tokens/proxy" || mounts["token-keys"] != "/pulsar/keys/token" {
+		t.Errorf("expected the proxy token and the token keys to be mounted; got: %v", mounts)
+	}
+	if len(podSpec.Volumes) != 2 {
+		t.Errorf("expected 2 volumes; got: %d", len(podSpec.Volumes))
+	}
+}
+
+func TestCreateConfigMapDataWithTLS(t *testing.T) {
+	t.Parallel()
+	cluster := &v1alpha1.PulsarCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "pulsar", Namespace: "streaming"},
+		Spec: v1alpha1.PulsarClusterSpec{
+			TLS: &v1alpha1.TLSConfig{Enabled: true, SecretName: "pulsar-tls"},
+		},
+	}
+	cluster.SetSpecDefaults()
+	proxy := &v1alpha1.PulsarProxy{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "streaming"},
+		Spec:       v1alpha1.PulsarProxySpec{ClusterRef: v1alpha1.ClusterReference{Name: "pulsar"}},
+	}
+	proxy.SetSpecDefaults()
+	data := createConfigMapData(proxy, cluster)
+	want := map[string]string{
+		"PULSAR_PREFIX_brokerServiceURLTLS":            "pulsar+ssl://" + cluster.ClientServiceFQDN() + ":6651",
+		"PULSAR_PREFIX_brokerWebServiceURLTLS":         "https://" + cluster.ClientServiceFQDN() + ":8443",
+		"PULSAR_PREFIX_tlsEnabledWithBroker":           "true",
+		"PULSAR_PREFIX_brokerClientTrustCertsFilePath": "/pulsar/certs/ca.crt",
+	}
+	for key, value := range want {
+		if data[key] != value {
+			t.Errorf("%s: expected: %q; got: %q", key, value, data[key])
+		}
+	}
+	for _, key := range []string{"PULSAR_PREFIX_brokerServiceURL", "PULSAR_PREFIX_authenticationEnabled"} {
+		if _, ok := data[key]; ok {
+			t.Errorf("expected no %s config", key)
+		}
+	}
+	podSpec := createPodSpec(proxy, cluster)
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].Secret.SecretName != "pulsar-tls" {
+		t.Errorf("expected the CA certificate volume of the TLS secret; got: %v", podSpec.Volumes)
+	}
+}
+
+func TestCreateDeploymentConfigHash(t *testing.T) {
+	t.Parallel()
+	cluster := &v1alpha1.PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "pulsar", Namespace: "streaming"}}
+	cluster.SetSpecDefaults()
+	proxy := &v1alpha1.PulsarProxy{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "streaming"},
+		Spec:       v1alpha1.PulsarProxySpec{ClusterRef: v1alpha1.ClusterReference{Name: "pulsar"}},
+	}
+	proxy.SetSpecDefaults()
+	dep := createDeployment(proxy, cluster)
+	hash := dep.Spec.Template.Annotations[configHashAnnotation]
+	if hash == "" {
+		t.Fatal("expected the pod template to be stamped with the config hash")
+	}
+	if *dep.Spec.Replicas != *proxy.Spec.Replicas {
+		t.Errorf("expected %d replicas; got: %d", *proxy.Spec.Replicas, *dep.Spec.Replicas)
+	}
+	proxy.Spec.ProxyConfig = map[string]string{"numIOThreads": "8"}
+	if changed := createDeployment(proxy, cluster); changed.Spec.Template.Annotations[configHashAnnotation] == hash {
+		t.Error("expected the config hash to change with the proxy configs")
+	}
+}
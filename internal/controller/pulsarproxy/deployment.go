@@ -0,0 +1,167 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarproxy
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/operator-helper/k8s/deployment"
+	"github.com/monimesl/operator-helper/k8s/pod"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	v1 "k8s.io/api/apps/v1"
+	v12 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strings"
+)
+
+// configHashAnnotation holds the hash of the rendered proxy configs and pod template.
+// Any change to it causes the deployment controller to roll the proxy pods
+const configHashAnnotation = internal.Domain + "/config-hash"
+
+// ReconcileDeployment reconcile the deployment of the specified proxy
+func ReconcileDeployment(ctx reconciler.Context, proxy *v1alpha1.PulsarProxy) error {
+	cluster, err := referencedCluster(ctx, proxy)
+	if err != nil {
+		return err
+	}
+	dep := &v1.Deployment{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      proxy.DeploymentName(),
+		Namespace: proxy.Namespace,
+	}, dep,
+		// Found
+		func() error {
+			desired := createDeployment(proxy, cluster)
+			if !shouldUpdateDeployment(dep, desired) {
+				return nil
+			}
+			dep.Spec.Replicas = desired.Spec.Replicas
+			dep.Spec.Template = desired.Spec.Template
+			dep.Labels = desired.Labels
+			dep.Annotations = desired.Annotations
+			ctx.Logger().Info("Updating the pulsar proxy deployment.",
+				"Deployment.Name", dep.GetName(),
+				"Deployment.Namespace", dep.GetNamespace(),
+				"Replicas", *desired.Spec.Replicas,
+				"ConfigHash", desired.Spec.Template.Annotations[configHashAnnotation])
+			return ctx.Client().Update(context.TODO(), dep)
+		},
+		// Not Found
+		func() error {
+			dep = createDeployment(proxy, cluster)
+			if err := ctx.SetOwnershipReference(proxy, dep); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar proxy deployment.",
+				"Deployment.Name", dep.GetName(),
+				"Deployment.Namespace", dep.GetNamespace())
+			return ctx.Client().Create(context.TODO(), dep)
+		})
+}
+
+func shouldUpdateDeployment(dep, desired *v1.Deployment) bool {
+	if *dep.Spec.Replicas != *desired.Spec.Replicas {
+		return true
+	}
+	if dep.Labels[k8s.LabelAppVersion] != desired.Labels[k8s.LabelAppVersion] {
+		return true
+	}
+	return dep.Spec.Template.Annotations[configHashAnnotation] !=
+		desired.Spec.Template.Annotations[configHashAnnotation]
+}
+
+func createDeployment(p *v1alpha1.PulsarProxy, c *v1alpha1.PulsarCluster) *v1.Deployment {
+	templateSpec := createPodTemplateSpec(p, c)
+	hash := pulsarcluster.ComputeHash(createConfigMapData(p, c), templateSpec)
+	templateSpec.Annotations = pulsarcluster.StampConfigHash(templateSpec.Annotations, hash)
+	labels := p.GenerateLabels()
+	labels[k8s.LabelAppVersion] = p.VersionLabel(c)
+	dep := deployment.New(p.Namespace, p.DeploymentName(), labels, v1.DeploymentSpec{
+		Replicas: p.Spec.Replicas,
+		Selector: &metav1.LabelSelector{MatchLabels: p.SelectorLabels()},
+		Template: templateSpec,
+	})
+	dep.Annotations = p.GenerateAnnotations()
+	return dep
+}
+
+func createPodTemplateSpec(p *v1alpha1.PulsarProxy, c *v1alpha1.PulsarCluster) v12.PodTemplateSpec {
+	return v12.PodTemplateSpec{
+		ObjectMeta: pod.NewMetadata(p.Spec.PodConfig, "", p.DeploymentName(),
+			p.GenerateLabels(), p.GenerateAnnotations()),
+		Spec: createPodSpec(p, c),
+	}
+}
+
+func createPodSpec(p *v1alpha1.PulsarProxy, c *v1alpha1.PulsarCluster) v12.PodSpec {
+	image := p.Image(c)
+	ports := p.Spec.Ports
+	probeHandler := v12.ProbeHandler{
+		HTTPGet: &v12.HTTPGetAction{
+			Port:   intstr.FromInt32(ports.Web),
+			Path:   "/status.html",
+			Scheme: v12.URISchemeHTTP,
+		},
+	}
+	containers := []v12.Container{
+		{
+			Name:            "pulsar-proxy",
+			Image:           image.ToString(),
+			ImagePullPolicy: image.PullPolicy,
+			Ports: []v12.ContainerPort{
+				{Name: v1alpha1.ClientPortName, ContainerPort: ports.Client},
+				{Name: v1alpha1.WebPortName, ContainerPort: ports.Web},
+			},
+			Resources:      p.Spec.PodConfig.Spec.Resources,
+			StartupProbe:   p.Spec.ProbeConfig.Startup.ToK8sProbe(probeHandler),
+			LivenessProbe:  p.Spec.ProbeConfig.Liveness.ToK8sProbe(probeHandler),
+			ReadinessProbe: p.Spec.ProbeConfig.Readiness.ToK8sProbe(probeHandler),
+			Env:            pod.DecorateContainerEnvVars(true, p.Spec.PodConfig.Spec.Env...),
+			EnvFrom: []v12.EnvFromSource{
+				{
+					ConfigMapRef: &v12.ConfigMapEnvSource{
+						LocalObjectReference: v12.LocalObjectReference{
+							Name: p.ConfigMapName(),
+						},
+					},
+				},
+			},
+			Command: []string{"sh", "-c"},
+			Args: []string{
+				strings.Join([]string{
+					"echo \"OK\" > " + statusFilePath,
+					"bin/apply-config-from-env.py conf/proxy.conf",
+					"exec bin/pulsar proxy",
+				}, "; "),
+			},
+		},
+	}
+	var volumes []v12.Volume
+	for _, fn := range []func(*v1alpha1.PulsarCluster) ([]v12.Volume, []v12.VolumeMount){
+		pulsarcluster.ProxyTLSVolumes,
+		pulsarcluster.ProxyAuthenticationVolumes,
+	} {
+		vols, mounts := fn(c)
+		volumes = append(volumes, vols...)
+		containers[0].VolumeMounts = append(containers[0].VolumeMounts, mounts...)
+	}
+	return pod.NewSpec(p.Spec.PodConfig, volumes, nil, containers)
+}
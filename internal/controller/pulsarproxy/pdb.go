@@ -0,0 +1,83 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarproxy
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ReconcilePodDisruptionBudget reconcile the poddisruptionbudget of the specified proxy
+func ReconcilePodDisruptionBudget(ctx reconciler.Context, proxy *v1alpha1.PulsarProxy) error {
+	pdb := &v1.PodDisruptionBudget{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      proxy.PodDisruptionBudgetName(),
+		Namespace: proxy.Namespace,
+	}, pdb,
+		// Found
+		func() error {
+			maxUnavailable := *proxy.Spec.MaxUnavailableReplicas
+			if pdb.Spec.MaxUnavailable != nil && pdb.Spec.MaxUnavailable.IntValue() == int(maxUnavailable) {
+				return nil
+			}
+			value := intstr.FromInt32(maxUnavailable)
+			pdb.Spec.MaxUnavailable = &value
+			pdb.Labels = proxy.GenerateLabels()
+			ctx.Logger().Info("Updating the pulsar proxy poddisruptionbudget.",
+				"PodDisruptionBudget.Name", pdb.GetName(),
+				"PodDisruptionBudget.Namespace", pdb.GetNamespace(),
+				"MaxUnavailable", maxUnavailable)
+			return ctx.Client().Update(context.TODO(), pdb)
+		},
+		// Not Found
+		func() error {
+			pdb = createPodDisruptionBudget(proxy)
+			if err := ctx.SetOwnershipReference(proxy, pdb); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar proxy poddisruptionbudget.",
+				"PodDisruptionBudget.Name", pdb.GetName(),
+				"PodDisruptionBudget.Namespace", pdb.GetNamespace(),
+				"MaxUnavailable", *proxy.Spec.MaxUnavailableReplicas)
+			return ctx.Client().Create(context.TODO(), pdb)
+		})
+}
+
+func createPodDisruptionBudget(p *v1alpha1.PulsarProxy) *v1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt32(*p.Spec.MaxUnavailableReplicas)
+	return &v1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: "policy/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.PodDisruptionBudgetName(),
+			Namespace: p.Namespace,
+			Labels:    p.GenerateLabels(),
+		},
+		Spec: v1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: p.SelectorLabels(),
+			},
+		},
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package pulsarproxy reconciles the proxies fronting the brokers of a PulsarCluster
+package pulsarproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"time"
+)
+
+// clusterRequeueDelay is the delay used while waiting on the referenced cluster
+const clusterRequeueDelay = 15 * time.Second
+
+// referencedCluster returns the referenced cluster or a requeue error if it does not exist yet.
+// The cluster is returned along with the requeue error while its defaults are not set yet
+func referencedCluster(ctx reconciler.Context, proxy *v1alpha1.PulsarProxy) (*v1alpha1.PulsarCluster, error) {
+	cluster := &v1alpha1.PulsarCluster{}
+	if err := ctx.Client().Get(context.TODO(), proxy.ClusterKey(), cluster); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, pulsarcluster.Requeue(clusterRequeueDelay,
+				fmt.Sprintf("waiting for the PulsarCluster: %s", proxy.Spec.ClusterRef.Name))
+		}
+		return nil, err
+	}
+	if cluster.Spec.Ports == nil {
+		return cluster, pulsarcluster.Requeue(clusterRequeueDelay,
+			fmt.Sprintf("waiting for the PulsarCluster defaults: %s", cluster.Name))
+	}
+	return cluster, nil
+}
+
+// brokerServiceURL returns the binary protocol URL of the cluster's client service.
+// The TLS port is used when the cluster has TLS enabled
+func brokerServiceURL(c *v1alpha1.PulsarCluster) string {
+	if c.Spec.TLS.IsEnabled() {
+		return fmt.Sprintf("pulsar+ssl://%s:%d", c.ClientServiceFQDN(), c.Spec.Ports.ClientTLS)
+	}
+	return fmt.Sprintf("pulsar://%s:%d", c.ClientServiceFQDN(), c.Spec.Ports.Client)
+}
+
+// brokerWebServiceURL returns the HTTP URL of the cluster's client service.
+// The HTTPS port is used when the cluster has TLS enabled
+func brokerWebServiceURL(c *v1alpha1.PulsarCluster) string {
+	if c.Spec.TLS.IsEnabled() {
+		return fmt.Sprintf("https://%s:%d", c.ClientServiceFQDN(), c.Spec.Ports.WebTLS)
+	}
+	return fmt.Sprintf("http://%s:%d", c.ClientServiceFQDN(), c.Spec.Ports.Web)
+}
+
+// proxyServiceURL returns the binary protocol URL the clients connect to the proxy with
+func proxyServiceURL(p *v1alpha1.PulsarProxy, c *v1alpha1.PulsarCluster) string {
+	return fmt.Sprintf("pulsar://%s.%s.svc.%s:%d", p.ServiceName(), p.Namespace, c.Spec.ClusterDomain, p.Spec.Ports.Client)
+}
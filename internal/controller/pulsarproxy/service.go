@@ -0,0 +1,96 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarproxy
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/k8s/service"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReconcileService reconcile the client service of the specified proxy
+func ReconcileService(ctx reconciler.Context, proxy *v1alpha1.PulsarProxy) error {
+	svc := &v1.Service{}
+	return ctx.GetResource(types.NamespacedName{
+		Name:      proxy.ServiceName(),
+		Namespace: proxy.Namespace,
+	}, svc,
+		// Found
+		func() error {
+			desired := createService(proxy)
+			ports := keepNodePorts(desired.Spec.Ports, svc.Spec.Ports)
+			if svc.Spec.Type == desired.Spec.Type &&
+				equality.Semantic.DeepEqual(svc.Spec.Ports, ports) &&
+				equality.Semantic.DeepEqual(svc.Labels, desired.Labels) {
+				return nil
+			}
+			svc.Labels = desired.Labels
+			svc.Annotations = desired.Annotations
+			svc.Spec.Type = desired.Spec.Type
+			svc.Spec.Ports = ports
+			ctx.Logger().Info("Updating the pulsar proxy service.",
+				"Service.Name", svc.GetName(),
+				"Service.Namespace", svc.GetNamespace(),
+				"Type", svc.Spec.Type)
+			return ctx.Client().Update(context.TODO(), svc)
+		},
+		// Not Found
+		func() error {
+			svc = createService(proxy)
+			if err := ctx.SetOwnershipReference(proxy, svc); err != nil {
+				return err
+			}
+			ctx.Logger().Info("Creating the pulsar proxy service.",
+				"Service.Name", svc.GetName(),
+				"Service.Namespace", svc.GetNamespace())
+			return ctx.Client().Create(context.TODO(), svc)
+		})
+}
+
+func createService(p *v1alpha1.PulsarProxy) *v1.Service {
+	svc := service.New(p.Namespace, p.ServiceName(), p.GenerateLabels(), v1.ServiceSpec{
+		Type:     p.Spec.ServiceType,
+		Selector: p.SelectorLabels(),
+		Ports: []v1.ServicePort{
+			{Name: v1alpha1.ClientPortName, Port: p.Spec.Ports.Client},
+			{Name: v1alpha1.WebPortName, Port: p.Spec.Ports.Web},
+		},
+	})
+	svc.Annotations = p.GenerateAnnotations()
+	return svc
+}
+
+// keepNodePorts copies the node ports allocated to the current ports into the desired
+// ones so that the updates don't reallocate them. The defaulted fields are copied too
+func keepNodePorts(desired, current []v1.ServicePort) []v1.ServicePort {
+	ports := make([]v1.ServicePort, len(desired))
+	for i, port := range desired {
+		for _, cur := range current {
+			if cur.Name != port.Name || cur.Port != port.Port {
+				continue
+			}
+			port.Protocol = cur.Protocol
+			port.TargetPort = cur.TargetPort
+			port.NodePort = cur.NodePort
+		}
+		ports[i] = port
+	}
+	return ports
+}
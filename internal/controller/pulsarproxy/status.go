@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsarproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/k8s"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ReconcileStatus reconcile the status of the specified proxy from the state of its deployment
+func ReconcileStatus(ctx reconciler.Context, proxy *v1alpha1.PulsarProxy) error {
+	cluster, err := referencedCluster(ctx, proxy)
+	if _, waiting := pulsarcluster.RequeueAfter(err); waiting {
+		if cluster != nil {
+			proxy.Status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse, "ClusterNotReady",
+				fmt.Sprintf("the PulsarCluster %s is not defaulted yet", cluster.Name), proxy.Generation)
+		} else {
+			proxy.Status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse, "ClusterNotFound",
+				fmt.Sprintf("the PulsarCluster %s does not exist", proxy.Spec.ClusterRef.Name), proxy.Generation)
+		}
+		if updateErr := updateStatus(ctx, proxy); updateErr != nil {
+			return updateErr
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+	proxy.Status.ServiceURL = proxyServiceURL(proxy, cluster)
+	proxy.Status.BrokerServiceURL = brokerServiceURL(cluster)
+	proxy.Status.BrokerWebServiceURL = brokerWebServiceURL(cluster)
+	dep := &v1.Deployment{}
+	err = ctx.GetResource(types.NamespacedName{
+		Name:      proxy.DeploymentName(),
+		Namespace: proxy.Namespace,
+	}, dep,
+		// Found
+		func() error {
+			computeStatus(proxy, dep)
+			return nil
+		},
+		// Not Found
+		func() error {
+			proxy.Status.Replicas = 0
+			proxy.Status.ReadyReplicas = 0
+			proxy.Status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse,
+				"NoProxies", "the proxy deployment is not created yet", proxy.Generation)
+			proxy.Status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue,
+				"Creating", "the proxy deployment is being created", proxy.Generation)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	return updateStatus(ctx, proxy)
+}
+
+// computeStatus drives the proxy conditions from the replica counts of the deployment
+func computeStatus(p *v1alpha1.PulsarProxy, dep *v1.Deployment) {
+	status := &p.Status
+	desired := *dep.Spec.Replicas
+	ready := dep.Status.ReadyReplicas
+	updated := dep.Status.UpdatedReplicas
+	rolledOut := dep.Status.ObservedGeneration == dep.Generation && updated == desired && dep.Status.Replicas == desired
+	status.Replicas = dep.Status.Replicas
+	status.ReadyReplicas = ready
+	if rolledOut {
+		status.CurrentVersion = dep.Labels[k8s.LabelAppVersion]
+	}
+	if ready > 0 {
+		status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionTrue, "ProxiesReady",
+			fmt.Sprintf("%d/%d proxies are ready", ready, desired), p.Generation)
+	} else {
+		status.SetCondition(v1alpha1.ConditionAvailable, metav1.ConditionFalse, "NoProxyReady",
+			"none of the proxies is ready", p.Generation)
+	}
+	if rolledOut {
+		status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionFalse, "RolledOut",
+			"all the proxies are updated", p.Generation)
+	} else {
+		status.SetCondition(v1alpha1.ConditionProgressing, metav1.ConditionTrue, "RollingUpdate",
+			fmt.Sprintf("%d/%d proxies are updated", updated, desired), p.Generation)
+	}
+}
+
+// updateStatus updates the proxy status if it changed since it was last read
+func updateStatus(ctx reconciler.Context, p *v1alpha1.PulsarProxy) error {
+	current := &v1alpha1.PulsarProxy{}
+	if err := ctx.Client().Get(context.TODO(), types.NamespacedName{
+		Name:      p.Name,
+		Namespace: p.Namespace,
+	}, current); err != nil {
+		return err
+	}
+	p.Status.ObservedGeneration = p.Generation
+	if equality.Semantic.DeepEqual(current.Status, p.Status) {
+		return nil
+	}
+	ctx.Logger().Info("Updating the pulsar proxy status",
+		"proxy", p.GetName(),
+		"ReadyReplicas", p.Status.ReadyReplicas)
+	return ctx.Client().Status().Update(context.TODO(), p)
+}
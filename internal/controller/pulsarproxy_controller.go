@@ -20,10 +20,16 @@ package controller
 import (
 	"context"
 	"github.com/monimesl/operator-helper/reconciler"
+	pulsarcluster2 "github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarproxy"
 	v12 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	v13 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
 
 	pulsarv1alpha1 "github.com/monimesl/pulsar-operator/api/v1alpha1"
 )
@@ -31,7 +37,13 @@ import (
 var (
 	_                   reconciler.Context    = &PulsarProxyReconciler{}
 	_                   reconciler.Reconciler = &PulsarProxyReconciler{}
-	proxyReconcileFuncs                       = []func(ctx reconciler.Context, cluster *pulsarv1alpha1.PulsarProxy) error{}
+	proxyReconcileFuncs                       = []func(ctx reconciler.Context, proxy *pulsarv1alpha1.PulsarProxy) error{
+		pulsarproxy.ReconcilePodDisruptionBudget,
+		pulsarproxy.ReconcileService,
+		pulsarproxy.ReconcileConfigMap,
+		pulsarproxy.ReconcileDeployment,
+		pulsarproxy.ReconcileStatus,
+	}
 )
 
 // PulsarProxyReconciler reconciles a PulsarProxy object
@@ -45,21 +57,57 @@ func (r *PulsarProxyReconciler) Configure(ctx reconciler.Context) error {
 	return ctx.NewControllerBuilder().
 		For(&pulsarv1alpha1.PulsarProxy{}).
 		Owns(&v13.PodDisruptionBudget{}).
-		Owns(&v12.StatefulSet{}).
+		Owns(&v12.Deployment{}).
 		Owns(&v1.ConfigMap{}).
 		Owns(&v1.Service{}).
+		Watches(&pulsarv1alpha1.PulsarCluster{}, handler.EnqueueRequestsFromMapFunc(r.proxiesOfCluster)).
 		Complete(r)
 }
 
+// proxiesOfCluster maps the cluster to the proxies referencing it so that they follow its changes
+func (r *PulsarProxyReconciler) proxiesOfCluster(ctx context.Context, cluster client.Object) []reconcile.Request {
+	proxies := &pulsarv1alpha1.PulsarProxyList{}
+	if err := r.Client().List(ctx, proxies, client.InNamespace(cluster.GetNamespace())); err != nil {
+		r.Logger().Error(err, "error on listing the proxies of the cluster",
+			"PulsarCluster.Name", cluster.GetName(),
+			"PulsarCluster.Namespace", cluster.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for i := range proxies.Items {
+		proxy := &proxies.Items[i]
+		if proxy.Spec.ClusterRef.Name == cluster.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      proxy.Name,
+				Namespace: proxy.Namespace,
+			}})
+		}
+	}
+	return requests
+}
+
 // Reconcile handles reconciliation request for PulsarProxy instances
 func (r *PulsarProxyReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
-	cluster := &pulsarv1alpha1.PulsarProxy{}
-	return r.Run(request, cluster, func(_ bool) (err error) {
+	proxy := &pulsarv1alpha1.PulsarProxy{}
+	requeueAfter := time.Duration(0)
+	result, err := r.Run(request, proxy, func(_ bool) (err error) {
 		for _, fun := range proxyReconcileFuncs {
-			if err = fun(r, cluster); err != nil {
-				break
+			if err = fun(r, proxy); err != nil {
+				after, ok := pulsarcluster2.RequeueAfter(err)
+				if !ok {
+					break
+				}
+				// the proxy is waiting on its cluster; continue with the rest and retry later
+				if requeueAfter == 0 || after < requeueAfter {
+					requeueAfter = after
+				}
+				err = nil
 			}
 		}
 		return
 	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
 }
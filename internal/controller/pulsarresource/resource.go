@@ -0,0 +1,252 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package pulsarresource holds the logic shared by the controllers of the
+// pulsar resources (tenants, namespaces, topics) synced through the admin API
+package pulsarresource
+
+import (
+	"context"
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SyncInterval is the interval the resources are re-synced at to detect drift
+	SyncInterval = 5 * time.Minute
+	// retryDelay is the delay used while waiting on the cluster or on a failed sync
+	retryDelay = 15 * time.Second
+	// finalizeTimeout is how long the deletion of a resource waits on an unreachable cluster
+	// before the finalizer is removed without deleting the resource from pulsar
+	finalizeTimeout = 30 * time.Minute
+)
+
+// Resource is a pulsar resource synced to a PulsarCluster through the admin API
+type Resource interface {
+	client.Object
+	// ClusterKey returns the namespaced name of the referenced PulsarCluster
+	ClusterKey() types.NamespacedName
+	// GetSyncStatus returns the sync status of the resource
+	GetSyncStatus() *v1alpha1.SyncStatus
+}
+
+// EnsureFinalizer adds the finalizer to the resource if it's missing
+func EnsureFinalizer(ctx reconciler.Context, res Resource, finalizer string) error {
+	if controllerutil.ContainsFinalizer(res, finalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(res, finalizer)
+	return ctx.Client().Update(context.TODO(), res)
+}
+
+// RemoveFinalizer removes the finalizer from the resource if it's present
+func RemoveFinalizer(ctx reconciler.Context, res Resource, finalizer string) error {
+	if !controllerutil.ContainsFinalizer(res, finalizer) {
+		return nil
+	}
+	controllerutil.RemoveFinalizer(res, finalizer)
+	return ctx.Client().Update(context.TODO(), res)
+}
+
+// AdminClient returns the admin client of the referenced cluster. If the cluster
+// does not exist or is unavailable, the failed sync is recorded and a requeue error returned
+func AdminClient(ctx reconciler.Context, res Resource) (*v1alpha1.PulsarCluster, *admin.Client, error) {
+	cluster := &v1alpha1.PulsarCluster{}
+	if err := ctx.Client().Get(context.TODO(), res.ClusterKey(), cluster); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		return nil, nil, SyncFailed(ctx, res, "ClusterNotFound",
+			fmt.Sprintf("the PulsarCluster %s does not exist", res.ClusterKey().Name))
+	}
+	if !cluster.Status.IsAvailable() {
+		return nil, nil, SyncFailed(ctx, res, "ClusterUnavailable",
+			fmt.Sprintf("the PulsarCluster %s is not available", cluster.Name))
+	}
+	adminClient, err := pulsarcluster.AdminClient(ctx, cluster)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cluster, adminClient, nil
+}
+
+// Finalize runs the delete func against the cluster and removes the finalizer. The delete
+// func is skipped if the cluster is gone or being deleted since the resource goes away with it.
+// A failed deletion is recorded and retried; if pulsar is unreachable past the finalizeTimeout
+// the finalizer is removed anyway, as it is when the ForceDeleteAnnotation is set
+func Finalize(ctx reconciler.Context, res Resource, finalizer string, deleteFn func(client *admin.Client) error) error {
+	if !controllerutil.ContainsFinalizer(res, finalizer) {
+		return nil
+	}
+	cluster := &v1alpha1.PulsarCluster{}
+	if err := ctx.Client().Get(context.TODO(), res.ClusterKey(), cluster); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		cluster = nil
+	}
+	if reason := skipDeleteReason(res, cluster); reason != "" {
+		return abandon(ctx, res, finalizer, reason)
+	}
+	if !cluster.Status.IsAvailable() {
+		return finalizeFailed(ctx, res, finalizer, "ClusterUnavailable",
+			fmt.Sprintf("the PulsarCluster %s is not available", cluster.Name), true)
+	}
+	adminClient, err := pulsarcluster.AdminClient(ctx, cluster)
+	if err == nil {
+		err = deleteFn(adminClient)
+	}
+	if err == nil || admin.IsNotFound(err) {
+		return RemoveFinalizer(ctx, res, finalizer)
+	}
+	reason, unreachable := "AdminAPIError", admin.IsUnreachable(err)
+	if admin.IsConflict(err) {
+		reason = "NotEmpty"
+	} else if unreachable {
+		reason = "ClusterUnreachable"
+	}
+	return finalizeFailed(ctx, res, finalizer, reason, fmt.Sprintf("cannot delete from pulsar: %s", err), unreachable)
+}
+
+// skipDeleteReason returns why the resource is not deleted from pulsar before its finalizer is
+// removed, or an empty string if it must be. The cluster is nil if it does not exist
+func skipDeleteReason(res Resource, cluster *v1alpha1.PulsarCluster) string {
+	switch {
+	case res.GetAnnotations()[v1alpha1.ForceDeleteAnnotation] == "true":
+		return "the " + v1alpha1.ForceDeleteAnnotation + " annotation is set"
+	case cluster == nil:
+		return "the PulsarCluster does not exist"
+	case cluster.DeletionTimestamp != nil:
+		return "the PulsarCluster is being deleted"
+	}
+	return ""
+}
+
+// isFinalizeTimedOut checks whether the deletion of the resource waited on an unreachable
+// pulsar for longer than the finalizeTimeout; the finalizer is then removed without deleting it
+func isFinalizeTimedOut(res Resource, unreachable bool, now time.Time) bool {
+	deleted := res.GetDeletionTimestamp()
+	return unreachable && deleted != nil && now.Sub(deleted.Time) > finalizeTimeout
+}
+
+// finalizeFailed records the failed deletion and requests a retry, unless pulsar is
+// unreachable past the finalizeTimeout in which case the finalizer is removed
+func finalizeFailed(ctx reconciler.Context, res Resource, finalizer, reason, message string, unreachable bool) error {
+	if isFinalizeTimedOut(res, unreachable, time.Now()) {
+		return abandon(ctx, res, finalizer, fmt.Sprintf("%s for more than %s", message, finalizeTimeout))
+	}
+	if unreachable {
+		message = fmt.Sprintf("%s; the finalizer is removed without deleting from pulsar after %s or when the %s annotation is set to \"true\"",
+			message, finalizeTimeout, v1alpha1.ForceDeleteAnnotation)
+	}
+	return SyncFailed(ctx, res, reason, message)
+}
+
+// abandon removes the finalizer without deleting the resource from pulsar
+func abandon(ctx reconciler.Context, res Resource, finalizer, reason string) error {
+	ctx.Logger().Info("Removing the finalizer without deleting the resource from pulsar",
+		"Resource.Name", res.GetName(),
+		"Resource.Namespace", res.GetNamespace(),
+		"reason", reason)
+	return RemoveFinalizer(ctx, res, finalizer)
+}
+
+// IsSpecChanged checks whether the spec changed since the last successful sync. A difference
+// between pulsar and the spec is a drift only if the spec did not change since then
+func IsSpecChanged(res Resource) bool {
+	status := res.GetSyncStatus()
+	return status.LastSyncTime == nil || status.ObservedGeneration != res.GetGeneration()
+}
+
+// Drifted records the drifted fields in the Drifted condition. It's cleared if there's no drift
+func Drifted(res Resource, policy v1alpha1.DriftPolicy, drifted []string) {
+	status := res.GetSyncStatus()
+	if len(drifted) == 0 {
+		status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionFalse, "InSync",
+			"pulsar matches the spec", res.GetGeneration())
+		return
+	}
+	sort.Strings(drifted)
+	reason, action := "Detected", "kept"
+	if policy != v1alpha1.DriftPolicyReport {
+		reason, action = "Reverted", "reverted"
+	}
+	status.SetCondition(v1alpha1.ConditionDrifted, metav1.ConditionTrue, reason,
+		fmt.Sprintf("modified outside the operator and %s: %s", action, strings.Join(drifted, ", ")),
+		res.GetGeneration())
+}
+
+// Synced records the successful sync and requests a re-sync to detect drift
+func Synced(ctx reconciler.Context, res Resource) error {
+	status := res.GetSyncStatus()
+	now := metav1.Now()
+	status.LastSyncTime = &now
+	status.ObservedGeneration = res.GetGeneration()
+	status.SetCondition(v1alpha1.ConditionSynced, metav1.ConditionTrue, "Synced",
+		"synced to pulsar", res.GetGeneration())
+	if err := ctx.Client().Status().Update(context.TODO(), res); err != nil {
+		return err
+	}
+	return pulsarcluster.Requeue(SyncInterval, "re-sync to detect drift")
+}
+
+// SyncFailed records the failed sync and requests a retry
+func SyncFailed(ctx reconciler.Context, res Resource, reason, message string) error {
+	ctx.Logger().Info("The pulsar resource sync failed",
+		"Resource.Name", res.GetName(),
+		"Resource.Namespace", res.GetNamespace(),
+		"reason", reason,
+		"message", message)
+	status := res.GetSyncStatus()
+	now := metav1.Now()
+	status.LastSyncTime = &now
+	status.SetCondition(v1alpha1.ConditionSynced, metav1.ConditionFalse, reason, message, res.GetGeneration())
+	if err := ctx.Client().Status().Update(context.TODO(), res); err != nil {
+		return err
+	}
+	return pulsarcluster.Requeue(retryDelay, message)
+}
+
+// StringSetEqual compares the string slices ignoring the order of the items
+func StringSetEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = sortedCopy(a), sortedCopy(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedCopy(items []string) []string {
+	out := append([]string{}, items...)
+	sort.Strings(out)
+	return out
+}
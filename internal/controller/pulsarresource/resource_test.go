@@ -0,0 +1,69 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsarresource
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestSkipDeleteReason(t *testing.T) {
+	t.Parallel()
+	deleted := metav1.Now()
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		cluster     *v1alpha1.PulsarCluster
+		wantSkip    bool
+	}{
+		{name: "available cluster", cluster: &v1alpha1.PulsarCluster{}},
+		{name: "cluster not found", wantSkip: true},
+		{name: "cluster being deleted", cluster: &v1alpha1.PulsarCluster{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted}}, wantSkip: true},
+		{name: "force delete", annotations: map[string]string{v1alpha1.ForceDeleteAnnotation: "true"}, cluster: &v1alpha1.PulsarCluster{}, wantSkip: true},
+		{name: "force delete disabled", annotations: map[string]string{v1alpha1.ForceDeleteAnnotation: "false"}, cluster: &v1alpha1.PulsarCluster{}},
+	}
+	for _, tt := range tests {
+		tenant := &v1alpha1.PulsarTenant{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+		if reason := skipDeleteReason(tenant, tt.cluster); (reason != "") != tt.wantSkip {
+			t.Errorf("%s: expected the deletion from pulsar to be skipped: %v; got the reason: %q", tt.name, tt.wantSkip, reason)
+		}
+	}
+}
+
+func TestIsFinalizeTimedOut(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	tests := []struct {
+		name        string
+		deletedAgo  time.Duration
+		unreachable bool
+		want        bool
+	}{
+		{name: "unavailable cluster within the timeout", deletedAgo: time.Minute, unreachable: true},
+		{name: "unavailable cluster past the timeout", deletedAgo: finalizeTimeout + time.Minute, unreachable: true, want: true},
+		{name: "non-empty resource past the timeout", deletedAgo: finalizeTimeout + time.Minute},
+	}
+	for _, tt := range tests {
+		deleted := metav1.NewTime(now.Add(-tt.deletedAgo))
+		tenant := &v1alpha1.PulsarTenant{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted}}
+		if got := isFinalizeTimedOut(tenant, tt.unreachable, now); got != tt.want {
+			t.Errorf("%s: expected the finalizer to be removed: %v; got: %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pulsartenant
+
+import (
+	"fmt"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarresource"
+)
+
+// finalizer deletes the tenant from pulsar before the object is deleted
+const finalizer = internal.Domain + "/tenant"
+
+// ReconcileTenant syncs the tenant to the referenced pulsar cluster, or deletes it from the cluster if deleted is true
+func ReconcileTenant(ctx reconciler.Context, tenant *v1alpha1.PulsarTenant, deleted bool) error {
+	if deleted {
+		return pulsarresource.Finalize(ctx, tenant, finalizer, func(client *admin.Client) error {
+			ctx.Logger().Info("Deleting the pulsar tenant",
+				"PulsarTenant.Name", tenant.Name,
+				"PulsarTenant.Namespace", tenant.Namespace,
+				"Tenant", tenant.TenantName())
+			return client.DeleteTenant(tenant.TenantName())
+		})
+	}
+	if err := pulsarresource.EnsureFinalizer(ctx, tenant, finalizer); err != nil {
+		return err
+	}
+	cluster, client, err := pulsarresource.AdminClient(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	if err = syncTenant(ctx, tenant, cluster, client); err != nil {
+		return pulsarresource.SyncFailed(ctx, tenant, "AdminAPIError", err.Error())
+	}
+	return pulsarresource.Synced(ctx, tenant)
+}
+
+func syncTenant(ctx reconciler.Context, tenant *v1alpha1.PulsarTenant, cluster *v1alpha1.PulsarCluster, client *admin.Client) error {
+	name := tenant.TenantName()
+	desired := desiredTenantInfo(tenant, cluster)
+	current, err := client.GetTenant(name)
+	if admin.IsNotFound(err) {
+		if !pulsarresource.IsSpecChanged(tenant) {
+			pulsarresource.Drifted(tenant, tenant.Spec.DriftPolicy, []string{"deleted"})
+			if tenant.Spec.DriftPolicy == v1alpha1.DriftPolicyReport {
+				return nil
+			}
+		}
+		ctx.Logger().Info("Creating the pulsar tenant",
+			"PulsarTenant.Name", tenant.Name,
+			"PulsarTenant.Namespace", tenant.Namespace,
+			"Tenant", name)
+		return client.CreateTenant(name, desired)
+	} else if err != nil {
+		return err
+	}
+	drifted := tenantInfoDiff(current, desired)
+	if len(drifted) == 0 {
+		pulsarresource.Drifted(tenant, tenant.Spec.DriftPolicy, nil)
+		return nil
+	}
+	if !pulsarresource.IsSpecChanged(tenant) {
+		pulsarresource.Drifted(tenant, tenant.Spec.DriftPolicy, drifted)
+		if tenant.Spec.DriftPolicy == v1alpha1.DriftPolicyReport {
+			return nil
+		}
+	}
+	ctx.Logger().Info("Updating the pulsar tenant",
+		"PulsarTenant.Name", tenant.Name,
+		"PulsarTenant.Namespace", tenant.Namespace,
+		"Tenant", name)
+	return client.UpdateTenant(name, desired)
+}
+
+func desiredTenantInfo(tenant *v1alpha1.PulsarTenant, cluster *v1alpha1.PulsarCluster) *admin.TenantInfo {
+	allowedClusters := tenant.Spec.AllowedClusters
+	if len(allowedClusters) == 0 {
+		allowedClusters = []string{cluster.PulsarClusterName()}
+	}
+	adminRoles := tenant.Spec.AdminRoles
+	if adminRoles == nil {
+		adminRoles = []string{}
+	}
+	return &admin.TenantInfo{AdminRoles: adminRoles, AllowedClusters: allowedClusters}
+}
+
+// tenantInfoDiff returns the fields of the current tenant info that differ from the desired one
+func tenantInfoDiff(current, desired *admin.TenantInfo) []string {
+	var diff []string
+	if !pulsarresource.StringSetEqual(current.AdminRoles, desired.AdminRoles) {
+		diff = append(diff, fmt.Sprintf("adminRoles=%v", current.AdminRoles))
+	}
+	if !pulsarresource.StringSetEqual(current.AllowedClusters, desired.AllowedClusters) {
+		diff = append(diff, fmt.Sprintf("allowedClusters=%v", current.AllowedClusters))
+	}
+	return diff
+}
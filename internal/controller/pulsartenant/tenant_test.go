@@ -0,0 +1,59 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsartenant
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestDesiredTenantInfo(t *testing.T) {
+	t.Parallel()
+	cluster := &v1alpha1.PulsarCluster{ObjectMeta: metav1.ObjectMeta{Name: "pulsar", Namespace: "default"}}
+	tenant := &v1alpha1.PulsarTenant{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "default"},
+		Spec:       v1alpha1.PulsarTenantSpec{ClusterRef: v1alpha1.ClusterReference{Name: "pulsar"}},
+	}
+	info := desiredTenantInfo(tenant, cluster)
+	if len(info.AllowedClusters) != 1 || info.AllowedClusters[0] != "pulsar" {
+		t.Errorf("expected the referenced cluster to be the allowed cluster; got: %v", info.AllowedClusters)
+	}
+	if info.AdminRoles == nil {
+		t.Error("expected the admin roles to be an empty list rather than null")
+	}
+	tenant.Spec.AllowedClusters = []string{"east", "west"}
+	if info = desiredTenantInfo(tenant, cluster); len(info.AllowedClusters) != 2 {
+		t.Errorf("expected the specified allowed clusters; got: %v", info.AllowedClusters)
+	}
+}
+
+func TestTenantInfoDiff(t *testing.T) {
+	t.Parallel()
+	a := &admin.TenantInfo{AdminRoles: []string{"a", "b"}, AllowedClusters: []string{"east", "west"}}
+	b := &admin.TenantInfo{AdminRoles: []string{"b", "a"}, AllowedClusters: []string{"west", "east"}}
+	if diff := tenantInfoDiff(a, b); len(diff) != 0 {
+		t.Errorf("expected the tenant infos to be equal regardless of the order; got: %v", diff)
+	}
+	b.AdminRoles = []string{"a"}
+	if diff := tenantInfoDiff(b, a); len(diff) != 1 || diff[0] != "adminRoles=[a]" {
+		t.Errorf("expected the admin roles to differ; got: %v", diff)
+	}
+	if a.AdminRoles[0] != "a" || a.AllowedClusters[0] != "east" {
+		t.Error("expected the comparison not to reorder the roles")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	pulsarcluster2 "github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsartenant"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+
+	pulsarv1alpha1 "github.com/monimesl/pulsar-operator/api/v1alpha1"
+)
+
+var (
+	_ reconciler.Context    = &PulsarTenantReconciler{}
+	_ reconciler.Reconciler = &PulsarTenantReconciler{}
+)
+
+// PulsarTenantReconciler reconciles a PulsarTenant object
+type PulsarTenantReconciler struct {
+	reconciler.Context
+}
+
+// Configure configures the above PulsarTenantReconciler
+func (r *PulsarTenantReconciler) Configure(ctx reconciler.Context) error {
+	r.Context = ctx
+	return ctx.NewControllerBuilder().
+		For(&pulsarv1alpha1.PulsarTenant{}).
+		Watches(&pulsarv1alpha1.PulsarCluster{}, handler.EnqueueRequestsFromMapFunc(r.tenantsOfCluster)).
+		Complete(r)
+}
+
+// tenantsOfCluster maps the cluster to the tenants referencing it so that they're synced once it's available
+func (r *PulsarTenantReconciler) tenantsOfCluster(ctx context.Context, cluster client.Object) []reconcile.Request {
+	tenants := &pulsarv1alpha1.PulsarTenantList{}
+	if err := r.Client().List(ctx, tenants, client.InNamespace(cluster.GetNamespace())); err != nil {
+		r.Logger().Error(err, "error on listing the tenants of the cluster",
+			"PulsarCluster.Name", cluster.GetName(),
+			"PulsarCluster.Namespace", cluster.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for i := range tenants.Items {
+		tenant := &tenants.Items[i]
+		if tenant.Spec.ClusterRef.Name == cluster.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      tenant.Name,
+				Namespace: tenant.Namespace,
+			}})
+		}
+	}
+	return requests
+}
+
+// Reconcile handles reconciliation request for PulsarTenant instances
+func (r *PulsarTenantReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
+	tenant := &pulsarv1alpha1.PulsarTenant{}
+	requeueAfter := time.Duration(0)
+	result, err := r.Run(request, tenant, func(deleted bool) error {
+		err := pulsartenant.ReconcileTenant(r, tenant, deleted)
+		if after, ok := pulsarcluster2.RequeueAfter(err); ok {
+			// not a failure; the tenant is re-synced later
+			requeueAfter = after
+			return nil
+		}
+		return err
+	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
+}
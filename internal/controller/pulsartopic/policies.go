@@ -0,0 +1,171 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsartopic
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+)
+
+// policy is a topic level policy managed by the operator
+type policy struct {
+	// name is the name of the policy in the spec
+	name string
+	// inSync fetches the policy of the topic and checks whether it matches the spec
+	inSync func(client *admin.Client, topic string) (bool, error)
+	// apply sets the policy of the topic in pulsar
+	apply func(client *admin.Client, topic string) error
+}
+
+// managedPolicies returns the topic level policies set in the spec
+func managedPolicies(spec *v1alpha1.TopicPolicies) []policy {
+	var policies []policy
+	if retention := spec.Retention; retention != nil {
+		desired := admin.RetentionPolicies{
+			RetentionTimeInMinutes: retention.TimeInMinutes,
+			RetentionSizeInMB:      retention.SizeInMB,
+		}
+		policies = append(policies, policy{
+			name: "retention",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				current, err := client.GetTopicRetention(topic)
+				return current != nil && *current == desired, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetTopicRetention(topic, desired)
+			},
+		})
+	}
+	if ttl := spec.MessageTTLSeconds; ttl != nil {
+		desired := *ttl
+		policies = append(policies, policy{
+			name: "messageTTLSeconds",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				current, err := client.GetTopicMessageTTL(topic)
+				return current != nil && *current == desired, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetTopicMessageTTL(topic, desired)
+			},
+		})
+	}
+	if quota := spec.BacklogQuota; quota != nil {
+		limit := quota.Limit.Value()
+		desired := admin.BacklogQuota{Limit: limit, LimitSize: limit, Policy: quota.Policy}
+		policies = append(policies, policy{
+			name: "backlogQuota",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				quotas, err := client.GetTopicBacklogQuotas(topic)
+				current, ok := quotas[admin.BacklogQuotaDestinationStorage]
+				return ok && current.SizeLimit() == limit && current.Policy == desired.Policy, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetTopicBacklogQuota(topic, desired)
+			},
+		})
+	}
+	if rate := spec.PublishRate; rate != nil {
+		desired := admin.PublishRate{
+			PublishThrottlingRateInMsg:  rate.MessagesPerSecond,
+			PublishThrottlingRateInByte: rate.BytesPerSecond,
+		}
+		policies = append(policies, policy{
+			name: "publishRate",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				current, err := client.GetTopicPublishRate(topic)
+				return current != nil && *current == desired, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetTopicPublishRate(topic, desired)
+			},
+		})
+	}
+	if rate := spec.DispatchRate; rate != nil {
+		desired := admin.DispatchRate{
+			DispatchThrottlingRateInMsg:  rate.MessagesPerPeriod,
+			DispatchThrottlingRateInByte: rate.BytesPerPeriod,
+			RatePeriodInSecond:           rate.PeriodSeconds,
+		}
+		policies = append(policies, policy{
+			name: "dispatchRate",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				current, err := client.GetTopicDispatchRate(topic)
+				return current != nil && *current == desired, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetTopicDispatchRate(topic, desired)
+			},
+		})
+	}
+	if deduplication := spec.Deduplication; deduplication != nil {
+		desired := *deduplication
+		policies = append(policies, policy{
+			name: "deduplication",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				current, err := client.GetTopicDeduplication(topic)
+				return current != nil && *current == desired, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetTopicDeduplication(topic, desired)
+			},
+		})
+	}
+	if threshold := spec.CompactionThreshold; threshold != nil {
+		desired := threshold.Value()
+		policies = append(policies, policy{
+			name: "compactionThreshold",
+			inSync: func(client *admin.Client, topic string) (bool, error) {
+				current, err := client.GetCompactionThreshold(topic)
+				return current != nil && *current == desired, err
+			},
+			apply: func(client *admin.Client, topic string) error {
+				return client.SetCompactionThreshold(topic, desired)
+			},
+		})
+	}
+	return policies
+}
+
+func outdatedPolicies(client *admin.Client, topic string, policies []policy) ([]policy, error) {
+	var outdated []policy
+	for _, p := range policies {
+		inSync, err := p.inSync(client, topic)
+		if err != nil {
+			return nil, err
+		}
+		if !inSync {
+			outdated = append(outdated, p)
+		}
+	}
+	return outdated, nil
+}
+
+func applyPolicies(client *admin.Client, topic string, policies []policy) error {
+	for _, p := range policies {
+		if err := p.apply(client, topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func policyNames(policies []policy) []string {
+	names := make([]string, 0, len(policies))
+	for _, p := range policies {
+		names = append(names, p.name)
+	}
+	return names
+}
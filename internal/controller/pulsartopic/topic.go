@@ -0,0 +1,174 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsartopic
+
+import (
+	"fmt"
+	"github.com/monimesl/operator-helper/oputil"
+	"github.com/monimesl/operator-helper/reconciler"
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsarresource"
+)
+
+// finalizer deletes the topic from pulsar before the object is deleted. It's
+// only set if the deletion policy is Delete so that retained topics are never touched
+const finalizer = internal.Domain + "/topic"
+
+// ReconcileTopic syncs the topic to the referenced pulsar cluster, or deletes it from the cluster if deleted is true
+func ReconcileTopic(ctx reconciler.Context, topic *v1alpha1.PulsarTopic, deleted bool) error {
+	if deleted {
+		return pulsarresource.Finalize(ctx, topic, finalizer, func(client *admin.Client) error {
+			ctx.Logger().Info("Deleting the pulsar topic",
+				"PulsarTopic.Name", topic.Name,
+				"PulsarTopic.Namespace", topic.Namespace,
+				"Topic", topic.TopicName())
+			return client.DeleteTopic(topic.TopicName(), topic.Spec.Partitions > 0)
+		})
+	}
+	var err error
+	if topic.Spec.DeletionPolicy == v1alpha1.DeletionPolicyDelete {
+		err = pulsarresource.EnsureFinalizer(ctx, topic, finalizer)
+	} else {
+		err = pulsarresource.RemoveFinalizer(ctx, topic, finalizer)
+	}
+	if err != nil {
+		return err
+	}
+	_, client, err := pulsarresource.AdminClient(ctx, topic)
+	if err != nil {
+		return err
+	}
+	if err = syncTopic(ctx, topic, client); err != nil {
+		return pulsarresource.SyncFailed(ctx, topic, "AdminAPIError", err.Error())
+	}
+	return pulsarresource.Synced(ctx, topic)
+}
+
+func syncTopic(ctx reconciler.Context, topic *v1alpha1.PulsarTopic, client *admin.Client) error {
+	name := topic.TopicName()
+	policies := managedPolicies(&topic.Spec.Policies)
+	current, exists, err := topicPartitions(client, topic)
+	if err != nil {
+		return err
+	}
+	desired := topic.Spec.Partitions
+	if !exists && isLoadedOnDemand(topic) {
+		// the idle topic is unloaded and recreated by the next client; it's not a drift
+		pulsarresource.Drifted(topic, topic.Spec.DriftPolicy, nil)
+		return nil
+	}
+	if !exists {
+		if !pulsarresource.IsSpecChanged(topic) {
+			pulsarresource.Drifted(topic, topic.Spec.DriftPolicy, []string{"deleted"})
+			if topic.Spec.DriftPolicy == v1alpha1.DriftPolicyReport {
+				return nil
+			}
+		}
+		ctx.Logger().Info("Creating the pulsar topic",
+			"PulsarTopic.Name", topic.Name,
+			"PulsarTopic.Namespace", topic.Namespace,
+			"Topic", name,
+			"Partitions", desired)
+		if desired > 0 {
+			err = client.CreatePartitionedTopic(name, desired)
+		} else {
+			err = client.CreateTopic(name)
+		}
+		if err != nil {
+			return err
+		}
+		topic.Status.Partitions = desired
+		return applyPolicies(client, name, policies)
+	}
+	topic.Status.Partitions = current
+	outdated, err := outdatedPolicies(client, name, policies)
+	if err != nil {
+		return err
+	}
+	drifted := policyNames(outdated)
+	if current != desired {
+		drifted = append(drifted, fmt.Sprintf("partitions=%d", current))
+	}
+	if len(drifted) == 0 {
+		pulsarresource.Drifted(topic, topic.Spec.DriftPolicy, nil)
+		return nil
+	}
+	if !pulsarresource.IsSpecChanged(topic) {
+		pulsarresource.Drifted(topic, topic.Spec.DriftPolicy, drifted)
+		if topic.Spec.DriftPolicy == v1alpha1.DriftPolicyReport {
+			return nil
+		}
+	}
+	if current < desired {
+		ctx.Logger().Info("Increasing the pulsar topic partitions",
+			"PulsarTopic.Name", topic.Name,
+			"PulsarTopic.Namespace", topic.Namespace,
+			"Topic", name,
+			"Partitions", desired)
+		if err = client.UpdatePartitions(name, desired); err != nil {
+			return err
+		}
+		topic.Status.Partitions = desired
+	} else if current > desired {
+		// pulsar cannot decrease the partitions; the drift is only reported
+		ctx.Logger().Info("The pulsar topic has more partitions than the spec and they cannot be decreased",
+			"PulsarTopic.Name", topic.Name,
+			"PulsarTopic.Namespace", topic.Namespace,
+			"Topic", name,
+			"Partitions", current)
+	}
+	if len(outdated) == 0 {
+		return nil
+	}
+	ctx.Logger().Info("Updating the pulsar topic policies",
+		"PulsarTopic.Name", topic.Name,
+		"PulsarTopic.Namespace", topic.Namespace,
+		"Topic", name,
+		"Policies", policyNames(outdated))
+	return applyPolicies(client, name, outdated)
+}
+
+// isLoadedOnDemand checks whether the topic is a non-partitioned non-persistent one. Such a topic
+// is only listed while it's loaded on a broker and is created on demand by the clients using it
+func isLoadedOnDemand(topic *v1alpha1.PulsarTopic) bool {
+	return topic.Spec.Persistence == v1alpha1.TopicNonPersistent && topic.Spec.Partitions == 0
+}
+
+// topicPartitions returns the partitions of the topic in pulsar and whether the topic exists.
+// The topics are looked up in the namespace listings since the partitioned topic metadata
+// of a non-existent topic is indistinguishable from the one of a non-partitioned topic
+func topicPartitions(client *admin.Client, topic *v1alpha1.PulsarTopic) (int32, bool, error) {
+	name := topic.TopicName()
+	domain := string(topic.Spec.Persistence)
+	partitioned, err := client.ListPartitionedTopics(domain, topic.Spec.Namespace)
+	if err != nil {
+		return 0, false, err
+	}
+	if oputil.Contains(partitioned, name) {
+		metadata, err := client.GetPartitionedTopicMetadata(name)
+		if err != nil {
+			return 0, false, err
+		}
+		return metadata.Partitions, true, nil
+	}
+	topics, err := client.ListTopics(domain, topic.Spec.Namespace)
+	if err != nil {
+		return 0, false, err
+	}
+	return 0, oputil.Contains(topics, name), nil
+}
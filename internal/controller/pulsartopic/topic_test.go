@@ -0,0 +1,121 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pulsartopic
+
+import (
+	"github.com/monimesl/pulsar-operator/api/v1alpha1"
+	"github.com/monimesl/pulsar-operator/internal/admin"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// fakeAdmin serves the canned responses of the admin API paths; the unknown paths respond with an empty body
+func fakeAdmin(t *testing.T, responses map[string]string) *admin.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(responses[r.URL.Path]))
+	}))
+	t.Cleanup(server.Close)
+	return admin.NewClient(server.URL)
+}
+
+func TestOutdatedPolicies(t *testing.T) {
+	t.Parallel()
+	ttl, deduplication := int32(60), true
+	threshold := resource.MustParse("1Mi")
+	client := fakeAdmin(t, map[string]string{
+		"/admin/v2/persistent/team-a/orders/payments/retention":           `{"retentionTimeInMinutes":10,"retentionSizeInMB":100}`,
+		"/admin/v2/persistent/team-a/orders/payments/messageTTL":          `60`,
+		"/admin/v2/persistent/team-a/orders/payments/compactionThreshold": `1048576`,
+	})
+	policies := managedPolicies(&v1alpha1.TopicPolicies{
+		Retention:           &v1alpha1.RetentionPolicy{TimeInMinutes: 10, SizeInMB: 200},
+		MessageTTLSeconds:   &ttl,
+		Deduplication:       &deduplication,
+		CompactionThreshold: &threshold,
+	})
+	outdated, err := outdatedPolicies(client, "persistent://team-a/orders/payments", policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := policyNames(outdated); !reflect.DeepEqual(names, []string{"retention", "deduplication"}) {
+		t.Errorf("expected the changed and the unset policies to be outdated; got: %v", names)
+	}
+}
+
+func TestTopicPartitions(t *testing.T) {
+	t.Parallel()
+	client := fakeAdmin(t, map[string]string{
+		"/admin/v2/persistent/team-a/orders/partitioned":         `["persistent://team-a/orders/payments"]`,
+		"/admin/v2/persistent/team-a/orders/payments/partitions": `{"partitions":4}`,
+		"/admin/v2/persistent/team-a/orders":                     `["persistent://team-a/orders/audit"]`,
+	})
+	tests := []struct {
+		name       string
+		partitions int32
+		exists     bool
+	}{
+		{name: "payments", partitions: 4, exists: true},
+		{name: "audit", exists: true},
+		{name: "refunds"},
+	}
+	for _, tt := range tests {
+		topic := &v1alpha1.PulsarTopic{ObjectMeta: metav1.ObjectMeta{Name: tt.name}, Spec: v1alpha1.PulsarTopicSpec{
+			Namespace: "team-a/orders", Persistence: v1alpha1.TopicPersistent}}
+		partitions, exists, err := topicPartitions(client, topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partitions != tt.partitions || exists != tt.exists {
+			t.Errorf("%s: expected partitions: %d, exists: %v; got: %d, %v", tt.name, tt.partitions, tt.exists, partitions, exists)
+		}
+	}
+}
+
+func TestSyncIdleNonPersistentTopic(t *testing.T) {
+	t.Parallel()
+	client := fakeAdmin(t, map[string]string{
+		"/admin/v2/non-persistent/team-a/orders/partitioned": `["non-persistent://team-a/orders/prices"]`,
+		"/admin/v2/non-persistent/team-a/orders":             `[]`,
+	})
+	synced := metav1.Now()
+	tests := []struct {
+		name        string
+		partitions  int32
+		wantDrifted metav1.ConditionStatus
+	}{
+		{name: "quotes", wantDrifted: metav1.ConditionFalse},
+		{name: "ticks", partitions: 2, wantDrifted: metav1.ConditionTrue},
+	}
+	for _, tt := range tests {
+		topic := &v1alpha1.PulsarTopic{ObjectMeta: metav1.ObjectMeta{Name: tt.name, Generation: 1}, Spec: v1alpha1.PulsarTopicSpec{
+			Namespace: "team-a/orders", Persistence: v1alpha1.TopicNonPersistent, Partitions: tt.partitions,
+			DriftPolicy: v1alpha1.DriftPolicyReport}}
+		topic.Status.LastSyncTime = &synced
+		topic.Status.ObservedGeneration = 1
+		// the context is only used to log the changes made to pulsar
+		if err := syncTopic(nil, topic, client); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got := topic.Status.GetCondition(v1alpha1.ConditionDrifted); got == nil || got.Status != tt.wantDrifted {
+			t.Errorf("%s: expected the Drifted condition: %s; got: %+v", tt.name, tt.wantDrifted, got)
+		}
+	}
+}
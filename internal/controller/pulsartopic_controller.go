@@ -0,0 +1,90 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"context"
+	"github.com/monimesl/operator-helper/reconciler"
+	pulsarcluster2 "github.com/monimesl/pulsar-operator/internal/controller/pulsarcluster"
+	"github.com/monimesl/pulsar-operator/internal/controller/pulsartopic"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+
+	pulsarv1alpha1 "github.com/monimesl/pulsar-operator/api/v1alpha1"
+)
+
+var (
+	_ reconciler.Context    = &PulsarTopicReconciler{}
+	_ reconciler.Reconciler = &PulsarTopicReconciler{}
+)
+
+// PulsarTopicReconciler reconciles a PulsarTopic object
+type PulsarTopicReconciler struct {
+	reconciler.Context
+}
+
+// Configure configures the above PulsarTopicReconciler
+func (r *PulsarTopicReconciler) Configure(ctx reconciler.Context) error {
+	r.Context = ctx
+	return ctx.NewControllerBuilder().
+		For(&pulsarv1alpha1.PulsarTopic{}).
+		Watches(&pulsarv1alpha1.PulsarCluster{}, handler.EnqueueRequestsFromMapFunc(r.topicsOfCluster)).
+		Complete(r)
+}
+
+// topicsOfCluster maps the cluster to the topics referencing it so that they're synced once it's available
+func (r *PulsarTopicReconciler) topicsOfCluster(ctx context.Context, cluster client.Object) []reconcile.Request {
+	topics := &pulsarv1alpha1.PulsarTopicList{}
+	if err := r.Client().List(ctx, topics, client.InNamespace(cluster.GetNamespace())); err != nil {
+		r.Logger().Error(err, "error on listing the topics of the cluster",
+			"PulsarCluster.Name", cluster.GetName(),
+			"PulsarCluster.Namespace", cluster.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for i := range topics.Items {
+		topic := &topics.Items[i]
+		if topic.Spec.ClusterRef.Name == cluster.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      topic.Name,
+				Namespace: topic.Namespace,
+			}})
+		}
+	}
+	return requests
+}
+
+// Reconcile handles reconciliation request for PulsarTopic instances
+func (r *PulsarTopicReconciler) Reconcile(_ context.Context, request reconcile.Request) (reconcile.Result, error) {
+	topic := &pulsarv1alpha1.PulsarTopic{}
+	requeueAfter := time.Duration(0)
+	result, err := r.Run(request, topic, func(deleted bool) error {
+		err := pulsartopic.ReconcileTopic(r, topic, deleted)
+		if after, ok := pulsarcluster2.RequeueAfter(err); ok {
+			// not a failure; the topic is re-synced later
+			requeueAfter = after
+			return nil
+		}
+		return err
+	})
+	if err == nil && requeueAfter > 0 {
+		result.RequeueAfter = requeueAfter
+	}
+	return result, err
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package manager provides a minimal client of the pulsar manager REST API
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	maxErrorBody   = 1024
+	apiPrefix      = "/pulsar-manager"
+	csrfHeader     = "X-XSRF-TOKEN"
+	listPageSize   = 1000
+)
+
+// ErrLoginFailed is returned when the pulsar manager rejects the credentials
+var ErrLoginFailed = errors.New("the pulsar manager login failed")
+
+// Error is returned when the pulsar manager responds with a non 2xx status code or an error message
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pulsar manager api error; status: %d, message: %s", e.StatusCode, e.Message)
+}
+
+// Environment is a pulsar cluster registered in the pulsar manager
+type Environment struct {
+	Name   string `json:"name"`
+	Broker string `json:"broker"`
+	Bookie string `json:"bookie"`
+}
+
+// Client is a client of the pulsar manager backend API. The API requires a CSRF
+// token on the mutating requests and a login token on the environment requests
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	csrfToken  string
+	username   string
+	token      string
+}
+
+// NewClient creates a new pulsar manager client for the backend URL e.g http://pulsar-manager:7750
+func NewClient(backendURL string) *Client {
+	// the jar keeps the CSRF cookie the token is checked against
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		baseURL:    strings.TrimSuffix(backendURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout, Jar: jar},
+	}
+}
+
+// CreateSuperuser creates the superuser. It's only allowed while the manager has no user
+func (c *Client) CreateSuperuser(username, password string) error {
+	if err := c.ensureCSRFToken(); err != nil {
+		return err
+	}
+	user := map[string]string{
+		"name":        username,
+		"password":    password,
+		"description": "The superuser bootstrapped by the pulsar operator",
+		"email":       username + "@pulsar.local",
+	}
+	_, err := c.do(http.MethodPut, "/users/superuser", user, nil)
+	return err
+}
+
+// Login logs the user in. It returns ErrLoginFailed if the credentials are rejected
+func (c *Client) Login(username, password string) error {
+	if err := c.ensureCSRFToken(); err != nil {
+		return err
+	}
+	credentials := map[string]string{"username": username, "password": password}
+	res, err := c.do(http.MethodPost, "/login", credentials, nil)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError {
+			return ErrLoginFailed
+		}
+		return err
+	}
+	c.token = res.Get("token")
+	c.username = res.Get("username")
+	if c.token == "" {
+		return ErrLoginFailed
+	}
+	return nil
+}
+
+// ListEnvironments returns the environments registered in the pulsar manager
+func (c *Client) ListEnvironments() ([]Environment, error) {
+	out := &struct {
+		Data []Environment `json:"data"`
+	}{}
+	path := fmt.Sprintf("/environments?page_num=1&page_size=%d", listPageSize)
+	if _, err := c.do(http.MethodGet, path, nil, out); err != nil {
+		return nil, err
+	}
+	return out.Data, nil
+}
+
+// AddEnvironment registers the environment
+func (c *Client) AddEnvironment(env Environment) error {
+	_, err := c.do(http.MethodPut, "/environments/environment", env, nil)
+	return err
+}
+
+// UpdateEnvironment updates the broker and bookie URLs of the environment
+func (c *Client) UpdateEnvironment(env Environment) error {
+	_, err := c.do(http.MethodPost, "/environments/environment", env, nil)
+	return err
+}
+
+// DeleteEnvironment deletes the environment
+func (c *Client) DeleteEnvironment(name string) error {
+	_, err := c.do(http.MethodDelete, "/environments/environment", Environment{Name: name}, nil)
+	return err
+}
+
+func (c *Client) ensureCSRFToken() error {
+	if c.csrfToken != "" {
+		return nil
+	}
+	res, err := c.httpClient.Get(c.baseURL + apiPrefix + "/csrf-token")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	c.csrfToken = strings.TrimSpace(string(body))
+	return nil
+}
+
+// do sends the request and decodes the response. The manager reports most
+// failures with a 200 status and an `error` field so both are checked
+func (c *Client) do(method, path string, body, out interface{}) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(context.TODO(), method, c.baseURL+apiPrefix+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.csrfToken != "" {
+		req.Header.Set(csrfHeader, c.csrfToken)
+	}
+	if c.token != "" {
+		req.Header.Set("token", c.token)
+		req.Header.Set("username", c.username)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if len(data) > maxErrorBody {
+			data = data[:maxErrorBody]
+		}
+		return nil, &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if len(data) == 0 {
+		return res.Header, nil
+	}
+	result := &struct {
+		Error string `json:"error"`
+	}{}
+	if json.Unmarshal(data, result) == nil && result.Error != "" {
+		return nil, &Error{StatusCode: http.StatusBadRequest, Message: result.Error}
+	}
+	if out != nil {
+		if err = json.Unmarshal(data, out); err != nil {
+			return nil, err
+		}
+	}
+	return res.Header, nil
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package token generates the signing keys and issues the JWT tokens
+// understood by the pulsar token authentication provider
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Algorithm is a JWT signing algorithm supported by pulsar
+type Algorithm string
+
+const (
+	// HS256 signs the tokens with a symmetric secret key
+	HS256 Algorithm = "HS256"
+	// RS256 signs the tokens with an RSA private key
+	RS256 Algorithm = "RS256"
+	// ES256 signs the tokens with an ECDSA P-256 private key
+	ES256 Algorithm = "ES256"
+)
+
+const (
+	secretKeySize = 32
+	rsaKeySize    = 2048
+	es256KeySize  = 32
+)
+
+// Keys holds the signing keys in the DER format pulsar reads them in.
+// Symmetric algorithms only set the Secret key
+type Keys struct {
+	Secret  []byte
+	Private []byte
+	Public  []byte
+}
+
+// IsSymmetric checks whether the algorithm signs with a symmetric secret key
+func (a Algorithm) IsSymmetric() bool {
+	return a == HS256
+}
+
+// IsValid checks whether the algorithm is supported
+func (a Algorithm) IsValid() bool {
+	switch a {
+	case HS256, RS256, ES256:
+		return true
+	}
+	return false
+}
+
+// GenerateKeys generates new signing keys for the algorithm
+func GenerateKeys(alg Algorithm) (*Keys, error) {
+	switch alg {
+	case HS256:
+		secret := make([]byte, secretKeySize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return &Keys{Secret: secret}, nil
+	case RS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return nil, err
+		}
+		return marshalKeyPair(key, &key.PublicKey)
+	case ES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return marshalKeyPair(key, &key.PublicKey)
+	}
+	return nil, fmt.Errorf("unsupported token signing algorithm: %s", alg)
+}
+
+func marshalKeyPair(private, public interface{}) (*Keys, error) {
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{Private: privateDer, Public: publicDer}, nil
+}
+
+// Issue issues a token for the subject (the pulsar role) signed with the keys.
+// The token never expires, just like the ones created by `pulsar tokens create`
+func Issue(alg Algorithm, keys *Keys, subject string) (string, error) {
+	header, err := encodeSegment(map[string]string{"alg": string(alg)})
+	if err != nil {
+		return "", err
+	}
+	claims, err := encodeSegment(map[string]string{"sub": subject})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + claims
+	signature, err := sign(alg, keys, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func sign(alg Algorithm, keys *Keys, input []byte) ([]byte, error) {
+	if alg.IsSymmetric() {
+		if len(keys.Secret) == 0 {
+			return nil, fmt.Errorf("the secret key is required to sign %s tokens", alg)
+		}
+		mac := hmac.New(sha256.New, keys.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	}
+	if len(keys.Private) == 0 {
+		return nil, fmt.Errorf("the private key is required to sign %s tokens", alg)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(keys.Private)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	digest := sha256.Sum256(input)
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		if alg == RS256 {
+			return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		}
+	case *ecdsa.PrivateKey:
+		if alg == ES256 {
+			r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			// JWS uses the fixed size R || S encoding rather than ASN.1
+			signature := make([]byte, 2*es256KeySize)
+			r.FillBytes(signature[:es256KeySize])
+			s.FillBytes(signature[es256KeySize:])
+			return signature, nil
+		}
+	}
+	return nil, fmt.Errorf("the private key does not match the %s algorithm", alg)
+}
+
+func encodeSegment(segment interface{}) (string, error) {
+	data, err := json.Marshal(segment)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestIssue(t *testing.T) {
+	t.Parallel()
+	for _, alg := range []Algorithm{HS256, RS256, ES256} {
+		keys, err := GenerateKeys(alg)
+		if err != nil {
+			t.Fatalf("%s: unexpected error generating the keys: %v", alg, err)
+		}
+		tok, err := Issue(alg, keys, "admin")
+		if err != nil {
+			t.Fatalf("%s: unexpected error issuing the token: %v", alg, err)
+		}
+		segments := strings.Split(tok, ".")
+		if len(segments) != 3 {
+			t.Fatalf("%s: expected 3 token segments; got: %s", alg, tok)
+		}
+		claims, _ := base64.RawURLEncoding.DecodeString(segments[1])
+		if string(claims) != `{"sub":"admin"}` {
+			t.Errorf("%s: unexpected claims: %s", alg, claims)
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(segments[2])
+		if !verify(t, alg, keys, []byte(segments[0]+"."+segments[1]), signature) {
+			t.Errorf("%s: the token signature is invalid", alg)
+		}
+	}
+}
+
+func TestIssueWithMismatchedKeys(t *testing.T) {
+	t.Parallel()
+	keys, err := GenerateKeys(ES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Issue(RS256, keys, "admin"); err == nil {
+		t.Error("expected an error signing RS256 tokens with an EC key")
+	}
+	if _, err = Issue(HS256, keys, "admin"); err == nil {
+		t.Error("expected an error signing HS256 tokens without a secret key")
+	}
+}
+
+func verify(t *testing.T, alg Algorithm, keys *Keys, input, signature []byte) bool {
+	t.Helper()
+	if alg.IsSymmetric() {
+		mac := hmac.New(sha256.New, keys.Secret)
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+	public, err := x509.ParsePKIXPublicKey(keys.Public)
+	if err != nil {
+		t.Fatalf("%s: invalid public key: %v", alg, err)
+	}
+	digest := sha256.Sum256(input)
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		r := new(big.Int).SetBytes(signature[:es256KeySize])
+		s := new(big.Int).SetBytes(signature[es256KeySize:])
+		return ecdsa.Verify(key, digest[:], r, s)
+	}
+	return false
+}
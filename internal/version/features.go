@@ -0,0 +1,76 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package version
+
+// Feature is a pulsar feature whose availability depends on the pulsar version
+type Feature string
+
+const (
+	// BookkeeperMetadataServiceURIFlag is the `--bookkeeper-metadata-service-uri` flag of `initialize-cluster-metadata`
+	BookkeeperMetadataServiceURIFlag Feature = "BookkeeperMetadataServiceURIFlag"
+	// ExistingBookkeeperMetadataServiceURIFlag is the `--existing-bk-metadata-service-uri` flag
+	// of `initialize-cluster-metadata` which replaced BookkeeperMetadataServiceURIFlag
+	ExistingBookkeeperMetadataServiceURIFlag Feature = "ExistingBookkeeperMetadataServiceURIFlag"
+	// MetadataStoreURL is the pluggable metadata store; the `metadataStoreUrl` and `configurationMetadataStoreUrl`
+	// broker configs and the `--metadata-store` and `--configuration-metadata-store` flags of `initialize-cluster-metadata`
+	MetadataStoreURL Feature = "MetadataStoreURL"
+	// ProtocolHandlers is the broker support of pluggable protocol handlers
+	ProtocolHandlers Feature = "ProtocolHandlers"
+	// KafkaProtocolHandler is the Kafka-on-Pulsar (KoP) protocol handler
+	KafkaProtocolHandler Feature = "KafkaProtocolHandler"
+	// BrokerEntryMetadata is the broker entry metadata interceptors required by KoP
+	BrokerEntryMetadata Feature = "BrokerEntryMetadata"
+	// AdvertisedListeners is the `advertisedListeners` and `internalListenerName` broker configs
+	AdvertisedListeners Feature = "AdvertisedListeners"
+	// OxiaMetadataStore is the oxia metadata store backend
+	OxiaMetadataStore Feature = "OxiaMetadataStore"
+	// OffloadTimeThreshold is the `managedLedgerOffloadThresholdInSeconds` broker config
+	OffloadTimeThreshold Feature = "OffloadTimeThreshold"
+)
+
+// gate defines the version range [since, until) of a feature; an empty until means it's not removed
+type gate struct {
+	since string
+	until string
+}
+
+// features is the central table of the version gated features. Every renderer
+// of the broker configs, job arguments and protocol handlers must consult it.
+var features = map[Feature]gate{
+	BookkeeperMetadataServiceURIFlag:         {since: "2.6.2", until: "2.7.0"},
+	ExistingBookkeeperMetadataServiceURIFlag: {since: "2.7.0"},
+	MetadataStoreURL:                         {since: "2.10.0"},
+	ProtocolHandlers:                         {since: "2.6.0"},
+	KafkaProtocolHandler:                     {since: "2.8.0"},
+	BrokerEntryMetadata:                      {since: "2.8.0"},
+	AdvertisedListeners:                      {since: "2.6.0"},
+	OxiaMetadataStore:                        {since: "3.1.0"},
+	OffloadTimeThreshold:                     {since: "2.11.0"},
+}
+
+// Supports checks whether the version supports the feature. Floating versions
+// support every feature which has not been removed.
+func (v Version) Supports(feature Feature) bool {
+	g, ok := features[feature]
+	if !ok {
+		return false
+	}
+	if g.until != "" && v.AtLeast(MustParse(g.until)) {
+		return false
+	}
+	return v.AtLeast(MustParse(g.since))
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package version models the pulsar versions and the features they support
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const latestTag = "latest"
+
+var (
+	// the version is also the image tag, so neither the `v` prefix nor the build metadata are accepted
+	versionRegex = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?$`)
+	digestRegex  = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Version is a semantic pulsar version. The `latest` tag and image digests are
+// floating versions whose actual version can't be known; they're assumed to be
+// newer than any other version.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+	// Digest is the image digest of a floating version pinned by digest
+	Digest string
+	// Floating is true for the `latest` tag and image digests
+	Floating bool
+}
+
+// Parse parses the pulsar version, the `latest` tag or an image digest (sha256:<hex>)
+func Parse(s string) (Version, error) {
+	if s == latestTag {
+		return Version{Floating: true}, nil
+	}
+	if digestRegex.MatchString(s) {
+		return Version{Floating: true, Digest: s}, nil
+	}
+	m := versionRegex.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid pulsar version: %q; expected a semantic version "+
+			"without the `v` prefix, `latest` or an image digest", s)
+	}
+	v := Version{PreRelease: m[4]}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+// MustParse is like Parse but panics on invalid versions
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the canonical representation of the version
+func (v Version) String() string {
+	if v.Floating {
+		if v.Digest != "" {
+			return v.Digest
+		}
+		return latestTag
+	}
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.PreRelease != "" {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if the version is lower than, equal to or greater than the other.
+// Pre-releases are lower than their release, as per https://semver.org/#spec-item-11
+func (v Version) Compare(o Version) int {
+	if v.Floating || o.Floating {
+		return compareBool(v.Floating, o.Floating)
+	}
+	for _, d := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if c := compareInt(d[0], d[1]); c != 0 {
+			return c
+		}
+	}
+	return comparePreRelease(v.PreRelease, o.PreRelease)
+}
+
+// AtLeast checks whether the version is greater than or equal to the other
+func (v Version) AtLeast(o Version) bool {
+	return v.Compare(o) >= 0
+}
+
+// LessThan checks whether the version is lower than the other
+func (v Version) LessThan(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+func comparePreRelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	// a release has a higher precedence than its pre-releases
+	if a == "" || b == "" {
+		return compareBool(a == "", b == "")
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareInt(an, bn)
+		case aErr == nil:
+			// numeric identifiers have a lower precedence than alphanumeric ones
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(as), len(bs))
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2021 - now, the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *       https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package version
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	digest := "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "2.7.0", want: Version{Major: 2, Minor: 7}},
+		{input: "2.9.0", want: Version{Major: 2, Minor: 9}},
+		{input: "2.10.1", want: Version{Major: 2, Minor: 10, Patch: 1}},
+		{input: "3.0.0", want: Version{Major: 3}},
+		{input: "3.3", want: Version{Major: 3, Minor: 3}},
+		{input: "4.0.0-rc.1", want: Version{Major: 4, PreRelease: "rc.1"}},
+		{input: "latest", want: Version{Floating: true}},
+		{input: digest, want: Version{Floating: true, Digest: digest}},
+		{input: "", wantErr: true},
+		{input: "2", wantErr: true},
+		{input: "2.x.0", wantErr: true},
+		{input: "sha256:abc", wantErr: true},
+		{input: "v3.1.2", wantErr: true},
+		{input: "4.0.1+build.5", wantErr: true},
+		{input: " 2.10.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.9.0", "2.10.1", -1},
+		{"2.10.1", "2.9.0", 1},
+		{"2.10.1", "3.0.0", -1},
+		{"3.0.0", "2.11.4", 1},
+		{"3.0.0", "3.0.0", 0},
+		{"3.3", "3.3.0", 0},
+		{"3.0.0-rc.1", "3.0.0", -1},
+		{"3.0.0-rc.2", "3.0.0-rc.10", -1},
+		{"3.0.0-alpha", "3.0.0-alpha.1", -1},
+		{"3.0.0-1", "3.0.0-alpha", -1},
+		{"4.0.0", "3.3.2", 1},
+		{"4.0.0-rc.1", "3.3.2", 1},
+		{"latest", "4.0.0", 1},
+		{"4.0.0", "latest", -1},
+		{"latest", "latest", 0},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.a).Compare(MustParse(tt.b)); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		version string
+		feature Feature
+		want    bool
+	}{
+		{"2.6.1", BookkeeperMetadataServiceURIFlag, false},
+		{"2.6.2", BookkeeperMetadataServiceURIFlag, true},
+		{"2.7.0", BookkeeperMetadataServiceURIFlag, false},
+		{"2.6.4", ExistingBookkeeperMetadataServiceURIFlag, false},
+		{"2.7.0", ExistingBookkeeperMetadataServiceURIFlag, true},
+		{"2.10.1", ExistingBookkeeperMetadataServiceURIFlag, true},
+		{"2.9.5", MetadataStoreURL, false},
+		{"2.10.0", MetadataStoreURL, true},
+		{"2.10.0-rc.1", MetadataStoreURL, false},
+		{"3.0.0", MetadataStoreURL, true},
+		{"4.0.2", MetadataStoreURL, true},
+		{"2.7.4", KafkaProtocolHandler, false},
+		{"2.8.0", KafkaProtocolHandler, true},
+		{"3.3.1", KafkaProtocolHandler, true},
+		{"4.0.0", BrokerEntryMetadata, true},
+		{"latest", MetadataStoreURL, true},
+		{"latest", BookkeeperMetadataServiceURIFlag, false},
+		{"4.0.0", Feature("Unknown"), false},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.version).Supports(tt.feature); got != tt.want {
+			t.Errorf("%s.Supports(%s) = %v, want %v", tt.version, tt.feature, got, tt.want)
+		}
+	}
+}
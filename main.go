@@ -56,13 +56,19 @@ func main() {
 	if err = webhook.Configure(mgr,
 		&pulsarv1alpha1.PulsarProxy{},
 		&pulsarv1alpha1.PulsarCluster{},
-		&pulsarv1alpha1.PulsarManager{}); err != nil {
+		&pulsarv1alpha1.PulsarManager{},
+		&pulsarv1alpha1.PulsarTenant{},
+		&pulsarv1alpha1.PulsarNamespace{},
+		&pulsarv1alpha1.PulsarTopic{}); err != nil {
 		log.Fatalf("webhook config error: %s", err)
 	}
 	if err = reconciler.Configure(mgr,
 		&controller.PulsarClusterReconciler{},
 		&controller.PulsarManagerReconciler{},
-		&controller.PulsarProxyReconciler{}); err != nil {
+		&controller.PulsarProxyReconciler{},
+		&controller.PulsarTenantReconciler{},
+		&controller.PulsarNamespaceReconciler{},
+		&controller.PulsarTopicReconciler{}); err != nil {
 		log.Fatalf("reconciler config error: %s", err)
 	}
 	if err = mgr.Start(ctrl.SetupSignalHandler()); err != nil {